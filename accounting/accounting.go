@@ -14,6 +14,7 @@
 package accounting
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -97,6 +98,17 @@ type Timer interface {
 	Update(t time.Duration)            // Sample a new value
 }
 
+// BucketHistogram counts values in buckets of fixed upper bounds, so that, unlike
+// Histogram, it can be aggregated across time windows and nodes
+type BucketHistogram interface {
+	Metric
+	Bounds() []float64 // The upper bounds of the buckets, in ascending order
+	Buckets() []int64  // The number of values up to each bound, cumulatively
+	Count() int64      // The number of values in the histogram
+	Sum() float64      // The sum of all values in the histogram
+	Observe(v float64) // Sample a new value
+}
+
 // MetricRegistry is the container for creating and maintaining Metrics
 type MetricRegistry interface {
 	// Register a metric with a name.
@@ -117,12 +129,14 @@ type MetricRegistry interface {
 	Meter(name string) Meter
 	Timer(name string) Timer
 	Histogram(name string) Histogram
-
-	Counters() map[string]Counter     // all registered counters
-	Gauges() map[string]Gauge         // all registered gauges
-	Meters() map[string]Meter         // all registered meters
-	Timers() map[string]Timer         // all registered timers
-	Histograms() map[string]Histogram // all registered histograms
+	BucketHistogram(name string, bounds []float64) BucketHistogram
+
+	Counters() map[string]Counter                 // all registered counters
+	Gauges() map[string]Gauge                     // all registered gauges
+	Meters() map[string]Meter                     // all registered meters
+	Timers() map[string]Timer                     // all registered timers
+	Histograms() map[string]Histogram             // all registered histograms
+	BucketHistograms() map[string]BucketHistogram // all registered bucket histograms
 }
 
 // A check that tests the status of an entity or compares a metric value against a
@@ -223,6 +237,64 @@ const (
 	PREPARED = "prepared"
 )
 
+// Request metrics broken down by statement type, keyspace and phase
+const (
+	STATEMENT_REQUESTS = "statement_requests"
+	KEYSPACE_REQUESTS  = "keyspace_requests"
+	REQUEST_DURATION   = "request_duration_seconds"
+	PHASE_DURATION     = "phase_duration_seconds"
+
+	LABEL_STATEMENT = "statement"
+	LABEL_KEYSPACE  = "keyspace"
+	LABEL_PHASE     = "phase"
+)
+
+// Upper bounds of the duration buckets, in seconds
+var DURATION_BUCKETS = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Labelled metrics carry their labels in their name, as in
+// request_duration_seconds{statement="select"}
+func LabelledMetric(name string, labels ...string) string {
+	if len(labels) < 2 {
+		return name
+	}
+
+	buf := make([]byte, 0, len(name)+32)
+	buf = append(buf, name...)
+	buf = append(buf, '{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, labels[i]...)
+		buf = append(buf, '=')
+		buf = strconv.AppendQuote(buf, labels[i+1])
+	}
+	buf = append(buf, '}')
+	return string(buf)
+}
+
+// Split a metric name into its name proper and its labels, without braces
+func SplitMetric(metric string) (string, string) {
+	i := strings.IndexByte(metric, '{')
+	if i < 0 || metric[len(metric)-1] != '}' {
+		return metric, ""
+	}
+	return metric[:i], metric[i+1 : len(metric)-1]
+}
+
+// Workload class metrics, named after their class
+const (
+	WORKLOAD_QUEUED     = "queued_requests"
+	WORKLOAD_ADMITTED   = "admitted_requests"
+	WORKLOAD_REJECTED   = "rejected_requests"
+	WORKLOAD_QUEUE_TIME = "queue_time"
+)
+
+func WorkloadMetric(class string, name string) string {
+	return "workload_" + class + "_" + name
+}
+
 var metricNames = []string{REQUESTS, CANCELLED, SELECTS, UPDATES, INSERTS, DELETES, ACTIVE_REQUESTS, QUEUED_REQUESTS, INVALID_REQUESTS,
 	UNBOUNDED, AT_PLUS, SCAN_PLUS,
 	REQUEST_TIME, SERVICE_TIME, RESULT_COUNT, RESULT_SIZE, ERRORS, REQUESTS_250MS, REQUESTS_500MS, REQUESTS_1000MS,
@@ -313,6 +385,28 @@ func RecordMetrics(acctstore AccountingStore,
 	}
 }
 
+// Record labelled request metrics, and the time spent in each phase
+func RecordRequestMetrics(acctstore AccountingStore, stmt string, keyspaces []string,
+	request_time time.Duration, phaseTimes map[string]time.Duration) {
+
+	ms := acctstore.MetricRegistry()
+	stmt = strings.ToLower(stmt)
+	if stmt == "" {
+		stmt = UNKNOWN
+	}
+
+	ms.Counter(LabelledMetric(STATEMENT_REQUESTS, LABEL_STATEMENT, stmt)).Inc(1)
+	for _, keyspace := range keyspaces {
+		ms.Counter(LabelledMetric(KEYSPACE_REQUESTS, LABEL_KEYSPACE, keyspace)).Inc(1)
+	}
+	ms.BucketHistogram(LabelledMetric(REQUEST_DURATION, LABEL_STATEMENT, stmt),
+		DURATION_BUCKETS).Observe(request_time.Seconds())
+	for phase, duration := range phaseTimes {
+		ms.BucketHistogram(LabelledMetric(PHASE_DURATION, LABEL_PHASE, phase, LABEL_STATEMENT, stmt),
+			DURATION_BUCKETS).Observe(duration.Seconds())
+	}
+}
+
 func requestType(stmt string, prepared bool) string {
 
 	switch strings.ToLower(stmt) {
@@ -327,3 +421,34 @@ func requestType(stmt string, prepared bool) string {
 	}
 	return UNKNOWN
 }
+
+// n is the change in the number of requests queued
+func RecordWorkloadQueued(acctstore AccountingStore, class string, n int64) {
+	if acctstore == nil {
+		return
+	}
+	ms := acctstore.MetricRegistry()
+	if n >= 0 {
+		ms.Counter(QUEUED_REQUESTS).Inc(n)
+		ms.Counter(WorkloadMetric(class, WORKLOAD_QUEUED)).Inc(n)
+	} else {
+		ms.Counter(QUEUED_REQUESTS).Dec(-n)
+		ms.Counter(WorkloadMetric(class, WORKLOAD_QUEUED)).Dec(-n)
+	}
+}
+
+func RecordWorkloadAdmitted(acctstore AccountingStore, class string, wait time.Duration) {
+	if acctstore == nil {
+		return
+	}
+	ms := acctstore.MetricRegistry()
+	ms.Counter(WorkloadMetric(class, WORKLOAD_ADMITTED)).Inc(1)
+	ms.Counter(WorkloadMetric(class, WORKLOAD_QUEUE_TIME)).Inc(int64(wait))
+}
+
+func RecordWorkloadRejected(acctstore AccountingStore, class string) {
+	if acctstore == nil {
+		return
+	}
+	acctstore.MetricRegistry().Counter(WorkloadMetric(class, WORKLOAD_REJECTED)).Inc(1)
+}
@@ -17,6 +17,7 @@ import (
 	"expvar"
 	"fmt"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -179,11 +180,23 @@ func (g *goMetricRegistry) Register(name string, metric accounting.Metric) error
 }
 
 func (g *goMetricRegistry) Get(name string) accounting.Metric {
-	return metrics.Get(name)
+	metric := metrics.Get(name)
+	if metric == nil {
+		bucketHistograms.RLock()
+		h, ok := bucketHistograms.histograms[name]
+		bucketHistograms.RUnlock()
+		if ok {
+			return h
+		}
+	}
+	return metric
 }
 
 func (g *goMetricRegistry) Unregister(name string) errors.Error {
 	metrics.Unregister(name)
+	bucketHistograms.Lock()
+	delete(bucketHistograms.histograms, name)
+	bucketHistograms.Unlock()
 	return nil
 }
 
@@ -207,6 +220,24 @@ func (g *goMetricRegistry) Histogram(name string) accounting.Histogram {
 	return metrics.GetOrRegisterHistogram(name, metrics.DefaultRegistry, metrics.NewExpDecaySample(1028, 0.015))
 }
 
+func (g *goMetricRegistry) BucketHistogram(name string, bounds []float64) accounting.BucketHistogram {
+	bucketHistograms.RLock()
+	h, ok := bucketHistograms.histograms[name]
+	bucketHistograms.RUnlock()
+	if ok {
+		return h
+	}
+
+	bucketHistograms.Lock()
+	defer bucketHistograms.Unlock()
+	h, ok = bucketHistograms.histograms[name]
+	if !ok {
+		h = newBucketHistogram(bounds)
+		bucketHistograms.histograms[name] = h
+	}
+	return h
+}
+
 func (g *goMetricRegistry) Counters() map[string]accounting.Counter {
 	r := metrics.DefaultRegistry
 	counters := make(map[string]accounting.Counter)
@@ -267,6 +298,80 @@ func (g *goMetricRegistry) Histograms() map[string]accounting.Histogram {
 	return histograms
 }
 
+func (g *goMetricRegistry) BucketHistograms() map[string]accounting.BucketHistogram {
+	bucketHistograms.RLock()
+	defer bucketHistograms.RUnlock()
+
+	histograms := make(map[string]accounting.BucketHistogram, len(bucketHistograms.histograms))
+	for name, h := range bucketHistograms.histograms {
+		histograms[name] = h
+	}
+	return histograms
+}
+
+// go-metrics only has sampling histograms, and will not register
+// any other type, so we roll our own and keep them separately
+var bucketHistograms = struct {
+	sync.RWMutex
+	histograms map[string]*goBucketHistogram
+}{histograms: map[string]*goBucketHistogram{}}
+
+type goBucketHistogram struct {
+	sync.Mutex
+	bounds  []float64
+	buckets []int64 // not cumulative, the last one for values past all bounds
+	sum     float64
+}
+
+func newBucketHistogram(bounds []float64) *goBucketHistogram {
+	return &goBucketHistogram{
+		bounds:  bounds,
+		buckets: make([]int64, len(bounds)+1),
+	}
+}
+
+func (h *goBucketHistogram) Bounds() []float64 {
+	return h.bounds
+}
+
+func (h *goBucketHistogram) Buckets() []int64 {
+	h.Lock()
+	defer h.Unlock()
+
+	rv := make([]int64, len(h.bounds))
+	count := int64(0)
+	for i := range h.bounds {
+		count += h.buckets[i]
+		rv[i] = count
+	}
+	return rv
+}
+
+func (h *goBucketHistogram) Count() int64 {
+	h.Lock()
+	defer h.Unlock()
+
+	count := int64(0)
+	for _, n := range h.buckets {
+		count += n
+	}
+	return count
+}
+
+func (h *goBucketHistogram) Sum() float64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.sum
+}
+
+func (h *goBucketHistogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.Lock()
+	h.buckets[i]++
+	h.sum += v
+	h.Unlock()
+}
+
 type goMetricReporter struct {
 }
 
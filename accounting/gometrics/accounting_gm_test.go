@@ -11,6 +11,8 @@ package accounting_gm
 
 import (
 	"testing"
+
+	"github.com/couchbase/query/accounting"
 )
 
 func TestGoMetrics(t *testing.T) {
@@ -51,3 +53,37 @@ func TestGoMetrics(t *testing.T) {
 	acctstore.MetricRegistry().Histogram("response_count")
 	acctstore.MetricRegistry().Timer("request_time")
 }
+
+func TestBucketHistogram(t *testing.T) {
+	mr := NewAccountingStore().MetricRegistry()
+
+	name := accounting.LabelledMetric("my_durations", "phase", "fetch")
+	h := mr.BucketHistogram(name, []float64{0.1, 1, 10})
+	for _, v := range []float64{0.05, 0.1, 0.5, 2, 20} {
+		h.Observe(v)
+	}
+
+	h2, ok := mr.Get(name).(accounting.BucketHistogram)
+	if !ok || h2 != h {
+		t.Fatalf("Expected to find bucket histogram %v", name)
+	}
+	if mr.BucketHistograms()[name] == nil {
+		t.Fatalf("Expected bucket histogram %v to be listed", name)
+	}
+
+	buckets := h.Buckets()
+	expected := []int64{2, 3, 4}
+	for i := range expected {
+		if buckets[i] != expected[i] {
+			t.Fatalf("Expected buckets %v, received %v", expected, buckets)
+		}
+	}
+	if h.Count() != 5 || h.Sum() != 22.65 {
+		t.Fatalf("Unexpected count %v or sum %v", h.Count(), h.Sum())
+	}
+
+	mr.Unregister(name)
+	if mr.Get(name) != nil {
+		t.Fatalf("Expected bucket histogram %v to be gone", name)
+	}
+}
@@ -147,6 +147,19 @@ func (TimerStub) Rate15() float64 { return 0.0 }
 
 func (TimerStub) RateMean() float64 { return 0.0 }
 
+// BucketHistogramStub is a stub implementation of BucketHistogram
+type BucketHistogramStub struct{}
+
+func (BucketHistogramStub) Bounds() []float64 { return nil }
+
+func (BucketHistogramStub) Buckets() []int64 { return nil }
+
+func (BucketHistogramStub) Count() int64 { return 0 }
+
+func (BucketHistogramStub) Sum() float64 { return 0.0 }
+
+func (BucketHistogramStub) Observe(float64) {} // Nop
+
 // MetricRegistryStub is a stub implementation of MetricRegistry
 type MetricRegistryStub struct{}
 
@@ -182,6 +195,10 @@ func (MetricRegistryStub) Histogram(name string) accounting.Histogram {
 	return HistogramStub{}
 }
 
+func (MetricRegistryStub) BucketHistogram(name string, bounds []float64) accounting.BucketHistogram {
+	return BucketHistogramStub{}
+}
+
 func (MetricRegistryStub) Counters() map[string]accounting.Counter {
 	return nil
 }
@@ -202,6 +219,10 @@ func (MetricRegistryStub) Histograms() map[string]accounting.Histogram {
 	return nil
 }
 
+func (MetricRegistryStub) BucketHistograms() map[string]accounting.BucketHistogram {
+	return nil
+}
+
 // A check that tests the status of an entity or compares a metric value against a
 // configurable threshold.
 type HealthCheckStub struct{}
@@ -16,7 +16,8 @@ import (
 )
 
 type statementBase struct {
-	stmt Statement
+	stmt        Statement
+	rowPolicies *auth.RowPolicies
 }
 
 /*
@@ -48,3 +49,14 @@ func subqueryPrivileges(exprs expression.Expressions) (*auth.Privileges, errors.
 func (this *statementBase) Type() string {
 	return ""
 }
+
+/*
+Returns the row security policies the statement was rewritten with.
+*/
+func (this *statementBase) RowPolicies() *auth.RowPolicies {
+	return this.rowPolicies
+}
+
+func (this *statementBase) SetRowPolicies(rowPolicies *auth.RowPolicies) {
+	this.rowPolicies = rowPolicies
+}
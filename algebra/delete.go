@@ -240,6 +240,13 @@ func (this *Delete) Where() expression.Expression {
 	return this.where
 }
 
+/*
+Replaces the where clause in the delete statement.
+*/
+func (this *Delete) SetWhere(where expression.Expression) {
+	this.where = where
+}
+
 /*
 Returns the expression for the limit clause in the
 delete statement.
@@ -16,6 +16,24 @@ import (
 	"github.com/couchbase/query/value"
 )
 
+/*
+Formats of the explain output: the plan as JSON, as an indented
+operator tree, or as a Graphviz graph.
+*/
+const (
+	EXPLAIN_FORMAT_JSON = "json"
+	EXPLAIN_FORMAT_TEXT = "text"
+	EXPLAIN_FORMAT_DOT  = "dot"
+)
+
+func IsExplainFormat(format string) bool {
+	switch format {
+	case EXPLAIN_FORMAT_JSON, EXPLAIN_FORMAT_TEXT, EXPLAIN_FORMAT_DOT:
+		return true
+	}
+	return false
+}
+
 /*
 Represents the explain text for a query. Type Explain is
 a struct that represents the explain json statement.
@@ -23,18 +41,24 @@ a struct that represents the explain json statement.
 type Explain struct {
 	statementBase
 
-	stmt Statement `json:"stmt"`
-	text string    `json:"text"`
+	stmt    Statement `json:"stmt"`
+	text    string    `json:"text"`
+	analyze bool      `json:"analyze"`
+	format  string    `json:"format"`
 }
 
 /*
 The function NewExplain returns a pointer to the Explain
 struct that has its field stmt set to the input Statement.
+With analyze set, the statement is executed, and the plan
+is annotated with its runtime statistics.
 */
-func NewExplain(stmt Statement, text string) *Explain {
+func NewExplain(stmt Statement, text string, analyze bool, format string) *Explain {
 	rv := &Explain{
-		stmt: stmt,
-		text: text,
+		stmt:    stmt,
+		text:    text,
+		analyze: analyze,
+		format:  format,
 	}
 
 	rv.statementBase.stmt = rv
@@ -99,6 +123,20 @@ func (this *Explain) Text() string {
 	return this.text
 }
 
+/*
+Returns true for EXPLAIN ANALYZE.
+*/
+func (this *Explain) Analyze() bool {
+	return this.analyze
+}
+
+/*
+Returns the format of the explain output.
+*/
+func (this *Explain) Format() string {
+	return this.format
+}
+
 func (this *Explain) Type() string {
 	return "EXPLAIN"
 }
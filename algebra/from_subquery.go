@@ -18,13 +18,14 @@ import (
 type SubqueryTerm struct {
 	subquery *Select
 	as       string
+	lateral  bool
 }
 
 /*
 Constructor.
 */
 func NewSubqueryTerm(subquery *Select, as string) *SubqueryTerm {
-	return &SubqueryTerm{subquery, as}
+	return &SubqueryTerm{subquery, as, false}
 }
 
 /*
@@ -59,15 +60,25 @@ func (this *SubqueryTerm) Privileges() (*auth.Privileges, errors.Error) {
    Representation as a N1QL string.
 */
 func (this *SubqueryTerm) String() string {
-	return "(" + this.subquery.String() + ") as " + this.as
+	s := "(" + this.subquery.String() + ") as " + this.as
+	if this.lateral {
+		s = "lateral " + s
+	}
+	return s
 }
 
 /*
 Qualify all identifiers for the parent expression. Checks for
-duplicate aliases.
+duplicate aliases. A LATERAL subquery is formalized against the
+parent, so that it can reference the preceding FROM terms; any
+other subquery must be self-contained.
 */
 func (this *SubqueryTerm) Formalize(parent *expression.Formalizer) (f *expression.Formalizer, err error) {
-	err = this.subquery.Formalize()
+	if this.lateral {
+		err = this.subquery.FormalizeSubquery(parent)
+	} else {
+		err = this.subquery.Formalize()
+	}
 	if err != nil {
 		return
 	}
@@ -109,3 +120,18 @@ Returns the inner subquery.
 func (this *SubqueryTerm) Subquery() *Select {
 	return this.subquery
 }
+
+/*
+Returns true if this is a LATERAL subquery, i.e. one that is
+re-evaluated for each item produced by the preceding FROM terms.
+*/
+func (this *SubqueryTerm) IsLateral() bool {
+	return this.lateral
+}
+
+/*
+Marks this subquery as LATERAL.
+*/
+func (this *SubqueryTerm) SetLateral() {
+	this.lateral = true
+}
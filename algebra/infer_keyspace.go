@@ -25,6 +25,7 @@ type InferKeyspace struct {
 	keyspace *KeyspaceRef            `json:"keyspace"`
 	using    datastore.InferenceType `json:"using"`
 	with     value.Value             `json:"with"`
+	masks    map[string]string       `json:"masks"`
 }
 
 func NewInferKeyspace(keyspace *KeyspaceRef, using datastore.InferenceType,
@@ -86,6 +87,18 @@ func (this *InferKeyspace) With() value.Value {
 	return this.with
 }
 
+/*
+Returns the masking methods of the masked paths, whose samples
+are masked in the inferred schema.
+*/
+func (this *InferKeyspace) Masks() map[string]string {
+	return this.masks
+}
+
+func (this *InferKeyspace) SetMasks(masks map[string]string) {
+	this.masks = masks
+}
+
 func (this *InferKeyspace) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"type": "InferKeyspace"}
 	r["keyspaceRef"] = this.keyspace
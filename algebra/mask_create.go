@@ -0,0 +1,154 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the CREATE MASK statement, which masks a field of the
+documents of a keyspace, by hashing it, partially masking it or
+nulling it out, for a set of roles and users. An empty list of
+grantees means every user.
+*/
+type CreateMask struct {
+	statementBase
+
+	keyspace *KeyspaceRef          `json:"keyspace"`
+	name     string                `json:"name"`
+	path     expression.Expression `json:"path"`
+	method   string                `json:"method"`
+	to       []string              `json:"to"`
+}
+
+/*
+The function NewCreateMask returns a pointer to the
+CreateMask struct with the input argument values as fields.
+*/
+func NewCreateMask(keyspace *KeyspaceRef, name string, path expression.Expression,
+	method string, to []string) *CreateMask {
+	rv := &CreateMask{
+		keyspace: keyspace,
+		name:     name,
+		path:     path,
+		method:   method,
+		to:       to,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitCreateMask method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *CreateMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateMask(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateMask) Signature() value.Value {
+	return nil
+}
+
+/*
+Fully qualify identifiers in the path, so that the keyspace is
+referred to as SELF, as for index keys.
+*/
+func (this *CreateMask) Formalize() error {
+	f := expression.NewKeyspaceFormalizer(this.keyspace.Keyspace(), nil)
+	return this.MapExpressions(f)
+}
+
+/*
+Maps the path.
+*/
+func (this *CreateMask) MapExpressions(mapper expression.Mapper) (err error) {
+	this.path, err = mapper.Map(this.path)
+	return
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *CreateMask) Expressions() expression.Expressions {
+	return expression.Expressions{this.path}
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *CreateMask) Privileges() (*auth.Privileges, errors.Error) {
+	privs := auth.NewPrivileges()
+	privs.Add("", auth.PRIV_SECURITY_WRITE)
+	return privs, nil
+}
+
+/*
+Returns the keyspace the mask applies to.
+*/
+func (this *CreateMask) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the name of the mask.
+*/
+func (this *CreateMask) Name() string {
+	return this.name
+}
+
+/*
+Returns the path of the masked field.
+*/
+func (this *CreateMask) Path() expression.Expression {
+	return this.path
+}
+
+/*
+Returns the masking method: hash, partial or null.
+*/
+func (this *CreateMask) Method() string {
+	return this.method
+}
+
+/*
+Returns the roles and users the mask applies to.
+*/
+func (this *CreateMask) To() []string {
+	return this.to
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *CreateMask) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createMask"}
+	r["keyspaceRef"] = this.keyspace
+	r["name"] = this.name
+	r["path"] = expression.NewStringer().Visit(this.path)
+	r["method"] = this.method
+	r["to"] = this.to
+	return json.Marshal(r)
+}
+
+func (this *CreateMask) Type() string {
+	return "CREATE_MASK"
+}
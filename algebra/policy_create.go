@@ -0,0 +1,154 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the CREATE POLICY statement, which restricts the rows
+of a keyspace visible to, or modifiable by, a set of roles and
+users. An empty operations list means SELECT, UPDATE and DELETE,
+and an empty list of grantees means every user.
+*/
+type CreatePolicy struct {
+	statementBase
+
+	keyspace   *KeyspaceRef          `json:"keyspace"`
+	name       string                `json:"name"`
+	operations []string              `json:"operations"`
+	using      expression.Expression `json:"using"`
+	to         []string              `json:"to"`
+}
+
+/*
+The function NewCreatePolicy returns a pointer to the
+CreatePolicy struct with the input argument values as fields.
+*/
+func NewCreatePolicy(keyspace *KeyspaceRef, name string, operations []string,
+	using expression.Expression, to []string) *CreatePolicy {
+	rv := &CreatePolicy{
+		keyspace:   keyspace,
+		name:       name,
+		operations: operations,
+		using:      using,
+		to:         to,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitCreatePolicy method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *CreatePolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreatePolicy(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *CreatePolicy) Signature() value.Value {
+	return nil
+}
+
+/*
+Fully qualify identifiers in the using clause, so that the
+keyspace is referred to as SELF, as for index keys.
+*/
+func (this *CreatePolicy) Formalize() error {
+	f := expression.NewKeyspaceFormalizer(this.keyspace.Keyspace(), nil)
+	return this.MapExpressions(f)
+}
+
+/*
+Maps the using clause.
+*/
+func (this *CreatePolicy) MapExpressions(mapper expression.Mapper) (err error) {
+	this.using, err = mapper.Map(this.using)
+	return
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *CreatePolicy) Expressions() expression.Expressions {
+	return expression.Expressions{this.using}
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *CreatePolicy) Privileges() (*auth.Privileges, errors.Error) {
+	privs := auth.NewPrivileges()
+	privs.Add("", auth.PRIV_SECURITY_WRITE)
+	return privs, nil
+}
+
+/*
+Returns the keyspace the policy applies to.
+*/
+func (this *CreatePolicy) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the name of the policy.
+*/
+func (this *CreatePolicy) Name() string {
+	return this.name
+}
+
+/*
+Returns the statement types the policy applies to.
+*/
+func (this *CreatePolicy) Operations() []string {
+	return this.operations
+}
+
+/*
+Returns the predicate rows must satisfy.
+*/
+func (this *CreatePolicy) Using() expression.Expression {
+	return this.using
+}
+
+/*
+Returns the roles and users the policy applies to.
+*/
+func (this *CreatePolicy) To() []string {
+	return this.to
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *CreatePolicy) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createPolicy"}
+	r["keyspaceRef"] = this.keyspace
+	r["name"] = this.name
+	r["operations"] = this.operations
+	r["using"] = expression.NewStringer().Visit(this.using)
+	r["to"] = this.to
+	return json.Marshal(r)
+}
+
+func (this *CreatePolicy) Type() string {
+	return "CREATE_POLICY"
+}
@@ -0,0 +1,118 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the DROP POLICY statement, namely the keyspace
+and the name of the policy.
+*/
+type DropPolicy struct {
+	statementBase
+
+	keyspace *KeyspaceRef `json:"keyspace"`
+	name     string       `json:"name"`
+}
+
+/*
+The function NewDropPolicy returns a pointer to the
+DropPolicy struct with the input argument values as fields.
+*/
+func NewDropPolicy(keyspace *KeyspaceRef, name string) *DropPolicy {
+	rv := &DropPolicy{
+		keyspace: keyspace,
+		name:     name,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitDropPolicy method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *DropPolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropPolicy(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *DropPolicy) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropPolicy) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropPolicy) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *DropPolicy) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *DropPolicy) Privileges() (*auth.Privileges, errors.Error) {
+	privs := auth.NewPrivileges()
+	privs.Add("", auth.PRIV_SECURITY_WRITE)
+	return privs, nil
+}
+
+/*
+Returns the keyspace the policy applies to.
+*/
+func (this *DropPolicy) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the name of the policy to be dropped.
+*/
+func (this *DropPolicy) Name() string {
+	return this.name
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *DropPolicy) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "dropPolicy"}
+	r["keyspaceRef"] = this.keyspace
+	r["name"] = this.name
+	return json.Marshal(r)
+}
+
+func (this *DropPolicy) Type() string {
+	return "DROP_POLICY"
+}
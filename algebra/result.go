@@ -26,9 +26,10 @@ represent if the keywords DISTINCT and RAW are used in the
 query. Terms represent the result expression.
 */
 type Projection struct {
-	distinct bool        `json:"distinct"`
-	raw      bool        `json:"raw"`
-	terms    ResultTerms `json:terms`
+	distinct bool                  `json:"distinct"`
+	raw      bool                  `json:"raw"`
+	terms    ResultTerms           `json:terms`
+	mask     expression.Expression `json:"mask"`
 }
 
 /*
@@ -209,6 +210,21 @@ func (this *Projection) SetRaw(raw bool) {
 	this.raw = raw
 }
 
+/*
+Return the expression projected by unprefixed star terms in
+place of the item, when column masks apply.
+*/
+func (this *Projection) Mask() expression.Expression {
+	return this.mask
+}
+
+/*
+Set the masked expression of unprefixed star terms.
+*/
+func (this *Projection) SetMask(mask expression.Expression) {
+	this.mask = mask
+}
+
 /*
 Return the result expression terms.
 */
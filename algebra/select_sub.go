@@ -279,6 +279,13 @@ func (this *Subselect) Where() expression.Expression {
 	return this.where
 }
 
+/*
+Replaces the where clause in the subselect.
+*/
+func (this *Subselect) SetWhere(where expression.Expression) {
+	this.where = where
+}
+
 /*
 Returns the group field that represents the group by
 clause in the subselect statement.
@@ -279,6 +279,13 @@ func (this *Update) Where() expression.Expression {
 	return this.where
 }
 
+/*
+Replaces the where clause in the UPDATE statement.
+*/
+func (this *Update) SetWhere(where expression.Expression) {
+	this.where = where
+}
+
 /*
 Returns the limit expression for the LIMIT
 clause in an UPDATE statement.
@@ -43,6 +43,18 @@ type Visitor interface {
 	VisitGrantRole(stmt *GrantRole) (interface{}, error)
 	VisitRevokeRole(stmt *RevokeRole) (interface{}, error)
 
+	/*
+	   Visitor for row security POLICY statements.
+	*/
+	VisitCreatePolicy(stmt *CreatePolicy) (interface{}, error)
+	VisitDropPolicy(stmt *DropPolicy) (interface{}, error)
+
+	/*
+	   Visitor for column MASK statements.
+	*/
+	VisitCreateMask(stmt *CreateMask) (interface{}, error)
+	VisitDropMask(stmt *DropMask) (interface{}, error)
+
 	/*
 	   Visitor for EXPLAIN statements.
 	*/
@@ -37,6 +37,9 @@ type Auditable interface {
 	// User ids submitted with request. eg. ["kirk", "spock"]
 	EventUsers() []string
 
+	// Keyspaces accessed by the statement. eg. ["default:orders"]
+	EventKeyspaces() []string
+
 	// The User-Agent string from the request. This is used to identify the type of client
 	// that sent the request (SDK, QWB, CBQ, ...)
 	UserAgent() string
@@ -62,9 +65,9 @@ type Auditable interface {
 }
 
 // An auditor is a component that can accept an audit record for processing.
-// We create a formal interface, so we can have two Auditors: the regular one that
-// talks to the audit daemon, and a mock that just stores audit records for testing.
-// The mock is over in the test file.
+// We create a formal interface, so we can have several Auditors: the regular one that
+// talks to the audit daemon, the one that writes to local files, and a mock that just
+// stores audit records for testing. The mock is over in the test file.
 type Auditor interface {
 	doAudit() bool
 
@@ -120,17 +123,24 @@ var _EVENT_TYPE_MAP = map[string]uint32{
 	"GRANT_ROLE":           28685,
 	"REVOKE_ROLE":          28686,
 	"CREATE_PRIMARY_INDEX": 28688,
+	"CREATE_POLICY":        28689,
+	"DROP_POLICY":          28690,
+	"CREATE_MASK":          28691,
+	"DROP_MASK":            28692,
 }
 
 var doLog bool = false
 
 func Submit(event Auditable) {
-	if _AUDITOR == nil {
-		return // Nothing configured. Nothing to be done.
+	var auditors []Auditor
+	if _AUDITOR != nil && _AUDITOR.doAudit() {
+		auditors = append(auditors, _AUDITOR)
 	}
-
-	if !_AUDITOR.doAudit() {
-		return
+	if _FILE_AUDITOR.doAudit() {
+		auditors = append(auditors, _FILE_AUDITOR)
+	}
+	if len(auditors) == 0 {
+		return // Nothing configured. Nothing to be done.
 	}
 
 	if doLog {
@@ -150,10 +160,12 @@ func Submit(event Auditable) {
 	// and we don't want to cause a race condition.
 	auditRecords := buildAuditRecords(event)
 	for _, record := range auditRecords {
-		if _AUDITOR.submitInline() {
-			submitForAudit(eventTypeId, record)
-		} else {
-			go submitForAudit(eventTypeId, record)
+		for _, auditor := range auditors {
+			if auditor.submitInline() {
+				submitForAudit(auditor, eventTypeId, record)
+			} else {
+				go submitForAudit(auditor, eventTypeId, record)
+			}
 		}
 	}
 }
@@ -172,6 +184,7 @@ func buildAuditRecords(event Auditable) []*n1qlAuditEvent {
 	userAgent := event.UserAgent()
 	node := event.EventNodeName()
 	status := event.EventStatus()
+	keyspaces := event.EventKeyspaces()
 	metrics := &n1qlMetrics{
 		ElapsedTime:   fmt.Sprintf("%v", event.ElapsedTime()),
 		ExecutionTime: fmt.Sprintf("%v", event.ExecutionTime()),
@@ -196,6 +209,7 @@ func buildAuditRecords(event Auditable) []*n1qlAuditEvent {
 			UserAgent:      userAgent,
 			Node:           node,
 			Status:         status,
+			Keyspaces:      keyspaces,
 			Metrics:        metrics,
 		}
 		return []*n1qlAuditEvent{record}
@@ -214,6 +228,7 @@ func buildAuditRecords(event Auditable) []*n1qlAuditEvent {
 			UserAgent:      userAgent,
 			Node:           node,
 			Status:         status,
+			Keyspaces:      keyspaces,
 			Metrics:        metrics,
 		}
 		source := "local"
@@ -232,8 +247,8 @@ func buildAuditRecords(event Auditable) []*n1qlAuditEvent {
 	return records
 }
 
-func submitForAudit(eventId uint32, auditRecord *n1qlAuditEvent) {
-	err := _AUDITOR.submit(eventId, auditRecord)
+func submitForAudit(auditor Auditor, eventId uint32, auditRecord *n1qlAuditEvent) {
+	err := auditor.submit(eventId, auditRecord)
 	if err != nil {
 		logging.Errorf("Unable to submit event %+v for audit: %v", *auditRecord, err)
 	}
@@ -264,7 +279,8 @@ type n1qlAuditEvent struct {
 	UserAgent string `json:"userAgent"`
 	Node      string `json:"node"`
 
-	Status string `json:"status"`
+	Status    string   `json:"status"`
+	Keyspaces []string `json:"keyspaces,omitempty"`
 
 	Metrics *n1qlMetrics `json:"metrics"`
 }
@@ -12,6 +12,6 @@
 package audit
 
 func (sa *standardAuditor) doAudit() bool {
-	// Do not audit in CE version.
+	// There is no audit daemon in CE; records go to the file auditor, if configured.
 	return false
 }
@@ -0,0 +1,453 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+The file auditor writes audit records to a rotating set of local files, as
+JSON lines, for deployments without an audit daemon, such as the community
+edition.
+Records can be restricted to certain event ids, users, statement types and
+keyspaces. Within each kind of filter any entry may match, and a record must
+match every kind of filter that is set.
+When chaining is on, each record carries the SHA-256 hash of the line before
+it, across files, so that removing or altering a record can be detected by
+walking the files in order. Without a chain key, the hashes only catch
+accidental edits, since anyone able to rewrite the files can recompute them;
+with a key kept away from the log, they are HMACs that cannot be forged
+without it.
+*/
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/couchbase/query/errors"
+)
+
+const (
+	FILE_DEFAULT_SIZE  = 64 * 1024 * 1024
+	FILE_DEFAULT_FILES = 10
+
+	_FILE_PREFIX = "audit-"
+	_FILE_SUFFIX = ".jsonl"
+)
+
+// Audit log settings
+const (
+	_FILE_SETTING = "audit-log"
+
+	_FILE_DIR       = "dir"
+	_FILE_SIZE      = "max-size"
+	_FILE_FILES     = "max-files"
+	_FILE_CHAIN     = "chain"
+	_FILE_EVENT_IDS = "event-ids"
+	_FILE_USERS     = "users"
+	_FILE_TYPES     = "statement-types"
+	_FILE_KEYSPACES = "keyspaces"
+)
+
+type FileSettings struct {
+	Dir            string // empty disables the file auditor
+	MaxSize        int64
+	MaxFiles       int
+	Chain          bool
+	EventIds       []uint32
+	Users          []string // source:user, or user for local users
+	StatementTypes []string
+	Keyspaces      []string // namespace:keyspace
+}
+
+// Parse the file auditor settings, an object.
+func NewFileSettings(val interface{}) (*FileSettings, errors.Error) {
+	settings, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.NewAdminSettingTypeError(_FILE_SETTING, val)
+	}
+
+	rv := &FileSettings{MaxSize: FILE_DEFAULT_SIZE, MaxFiles: FILE_DEFAULT_FILES}
+	for setting, val := range settings {
+		switch setting {
+		case _FILE_DIR:
+			rv.Dir, ok = val.(string)
+		case _FILE_SIZE:
+			var n float64
+			n, ok = val.(float64)
+			ok = ok && n > 0
+			rv.MaxSize = int64(n)
+		case _FILE_FILES:
+			var n float64
+			n, ok = val.(float64)
+			ok = ok && n > 0
+			rv.MaxFiles = int(n)
+		case _FILE_CHAIN:
+			rv.Chain, ok = val.(bool)
+		case _FILE_EVENT_IDS:
+			var list []interface{}
+			list, ok = val.([]interface{})
+			for _, v := range list {
+				n, isNumber := v.(float64)
+				if !isNumber || n <= 0 || n != float64(uint32(n)) {
+					ok = false
+					break
+				}
+				rv.EventIds = append(rv.EventIds, uint32(n))
+			}
+		case _FILE_USERS:
+			rv.Users, ok = fileStrings(val, nonEmptyString)
+		case _FILE_TYPES:
+			rv.StatementTypes, ok = fileStrings(val, func(v interface{}) (string, bool) {
+				s, ok := nonEmptyString(v)
+				return strings.ToUpper(s), ok
+			})
+		case _FILE_KEYSPACES:
+			rv.Keyspaces, ok = fileStrings(val, func(v interface{}) (string, bool) {
+				s, ok := nonEmptyString(v)
+				if ok && !strings.Contains(s, ":") {
+					s = "default:" + s
+				}
+				return s, ok
+			})
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, errors.NewAdminSettingTypeError(_FILE_SETTING+"."+setting, val)
+		}
+	}
+	return rv, nil
+}
+
+func nonEmptyString(val interface{}) (string, bool) {
+	s, ok := val.(string)
+	return s, ok && s != ""
+}
+
+func fileStrings(val interface{}, conv func(interface{}) (string, bool)) ([]string, bool) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	rv := make([]string, 0, len(list))
+	for _, v := range list {
+		s, ok := conv(v)
+		if !ok {
+			return nil, false
+		}
+		rv = append(rv, s)
+	}
+	return rv, true
+}
+
+// The settings as accepted by NewFileSettings
+func (this *FileSettings) Settings() map[string]interface{} {
+	rv := map[string]interface{}{
+		_FILE_DIR:   this.Dir,
+		_FILE_SIZE:  this.MaxSize,
+		_FILE_FILES: this.MaxFiles,
+		_FILE_CHAIN: this.Chain,
+	}
+	if len(this.EventIds) > 0 {
+		rv[_FILE_EVENT_IDS] = this.EventIds
+	}
+	if len(this.Users) > 0 {
+		rv[_FILE_USERS] = this.Users
+	}
+	if len(this.StatementTypes) > 0 {
+		rv[_FILE_TYPES] = this.StatementTypes
+	}
+	if len(this.Keyspaces) > 0 {
+		rv[_FILE_KEYSPACES] = this.Keyspaces
+	}
+	return rv
+}
+
+type fileAuditor struct {
+	sync.Mutex
+	settings  FileSettings
+	eventIds  map[uint32]bool
+	users     map[string]bool
+	types     map[string]bool
+	keyspaces map[string]bool
+	seq       int64
+	file      *os.File
+	size      int64
+	prevHash  string
+	chainKey  []byte
+}
+
+var _FILE_AUDITOR = &fileAuditor{settings: FileSettings{MaxSize: FILE_DEFAULT_SIZE, MaxFiles: FILE_DEFAULT_FILES}}
+
+// The event type of each event id
+var _EVENT_ID_MAP = func() map[uint32]string {
+	rv := make(map[uint32]string, len(_EVENT_TYPE_MAP))
+	for eventType, id := range _EVENT_TYPE_MAP {
+		rv[id] = eventType
+	}
+	return rv
+}()
+
+func FileAuditSettings() map[string]interface{} {
+	_FILE_AUDITOR.Lock()
+	defer _FILE_AUDITOR.Unlock()
+	return _FILE_AUDITOR.settings.Settings()
+}
+
+// Configure the file auditor, an empty directory disables it
+func SetFileAuditSettings(settings *FileSettings) errors.Error {
+	_FILE_AUDITOR.Lock()
+	defer _FILE_AUDITOR.Unlock()
+	return _FILE_AUDITOR.configure(settings)
+}
+
+// Set the key the chain hashes are HMACs with; meant to be called at
+// startup, before the file auditor is configured
+func SetFileAuditChainKey(key []byte) {
+	_FILE_AUDITOR.Lock()
+	defer _FILE_AUDITOR.Unlock()
+	_FILE_AUDITOR.chainKey = key
+}
+
+func (this *fileAuditor) configure(settings *FileSettings) errors.Error {
+	this.settings = *settings
+	if this.settings.MaxSize <= 0 {
+		this.settings.MaxSize = FILE_DEFAULT_SIZE
+	}
+	if this.settings.MaxFiles <= 0 {
+		this.settings.MaxFiles = FILE_DEFAULT_FILES
+	}
+
+	this.eventIds = nil
+	if len(settings.EventIds) > 0 {
+		this.eventIds = make(map[uint32]bool, len(settings.EventIds))
+		for _, id := range settings.EventIds {
+			this.eventIds[id] = true
+		}
+	}
+	this.users = stringSet(settings.Users)
+	this.types = stringSet(settings.StatementTypes)
+	this.keyspaces = stringSet(settings.Keyspaces)
+	return this.setDir(settings.Dir)
+}
+
+func stringSet(list []string) map[string]bool {
+	if len(list) == 0 {
+		return nil
+	}
+	rv := make(map[string]bool, len(list))
+	for _, s := range list {
+		rv[s] = true
+	}
+	return rv
+}
+
+func (this *fileAuditor) setDir(dir string) errors.Error {
+	if this.file != nil {
+		this.file.Close()
+		this.file = nil
+	}
+	this.settings.Dir = ""
+	if dir == "" {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.NewAuditFileError(err, "cannot create "+dir)
+	}
+	this.settings.Dir = dir
+
+	// carry on from the latest file, and the chain where it was left
+	seqs := this.files()
+	this.seq = 0
+	if len(seqs) > 0 {
+		this.seq = seqs[len(seqs)-1]
+	}
+	this.prevHash = ""
+	for i := len(seqs) - 1; i >= 0 && this.prevHash == ""; i-- {
+		this.prevHash = lastLineHash(this.fileName(seqs[i]), this.chainKey)
+	}
+	return this.open()
+}
+
+func (this *fileAuditor) fileName(seq int64) string {
+	return filepath.Join(this.settings.Dir, fmt.Sprintf("%s%08d%s", _FILE_PREFIX, seq, _FILE_SUFFIX))
+}
+
+// the sequence numbers of the existing files, oldest first
+func (this *fileAuditor) files() []int64 {
+	names, _ := filepath.Glob(filepath.Join(this.settings.Dir, _FILE_PREFIX+"*"+_FILE_SUFFIX))
+	seqs := make([]int64, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), _FILE_PREFIX), _FILE_SUFFIX)
+		seq, err := strconv.ParseInt(name, 10, 64)
+		if err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+// the hash of the last complete line of a file, if any
+func lastLineHash(name string, key []byte) string {
+	file, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var last []byte
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		last = line[:len(line)-1]
+	}
+	if last == nil {
+		return ""
+	}
+	return lineHash(last, key)
+}
+
+// the SHA-256 hash of a line, or its HMAC-SHA256 if there is a key
+func lineHash(line []byte, key []byte) string {
+	if len(key) == 0 {
+		sum := sha256.Sum256(line)
+		return hex.EncodeToString(sum[:])
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(line)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (this *fileAuditor) open() errors.Error {
+	f, err := os.OpenFile(this.fileName(this.seq), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.NewAuditFileError(err, "cannot open "+this.fileName(this.seq))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.NewAuditFileError(err, "cannot open "+this.fileName(this.seq))
+	}
+	this.file = f
+	this.size = info.Size()
+	return nil
+}
+
+// start a new file and get rid of the oldest
+func (this *fileAuditor) rotate() errors.Error {
+	this.file.Close()
+	this.file = nil
+	this.seq++
+	err := this.open()
+	for _, seq := range this.files() {
+		if seq > this.seq-int64(this.settings.MaxFiles) {
+			break
+		}
+		os.Remove(this.fileName(seq))
+	}
+	return err
+}
+
+func (this *fileAuditor) doAudit() bool {
+	this.Lock()
+	defer this.Unlock()
+	return this.file != nil
+}
+
+// Written in the order requests complete, so that the chain follows the files.
+func (this *fileAuditor) submitInline() bool {
+	return true
+}
+
+type fileAuditRecord struct {
+	Id   uint32 `json:"id"`
+	Name string `json:"name"`
+	*n1qlAuditEvent
+	PrevHash string `json:"prevHash,omitempty"`
+}
+
+func (this *fileAuditor) submit(eventId uint32, event *n1qlAuditEvent) error {
+	eventType, ok := _EVENT_ID_MAP[eventId]
+	if !ok {
+		eventType = "UNRECOGNIZED"
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	if this.file == nil || !this.matches(eventId, eventType, event) {
+		return nil
+	}
+
+	record := &fileAuditRecord{Id: eventId, Name: eventType, n1qlAuditEvent: event}
+	if this.settings.Chain {
+		record.PrevHash = this.prevHash
+	}
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if this.size > 0 && this.size+int64(len(bytes))+1 > this.settings.MaxSize {
+		if err := this.rotate(); err != nil {
+			return err
+		}
+	}
+
+	// a single write, so that readers never see partial records
+	// other than at the end of the file
+	n, err := this.file.Write(append(bytes, '\n'))
+	this.size += int64(n)
+	if err != nil {
+		return errors.NewAuditFileError(err, "cannot write "+this.fileName(this.seq))
+	}
+	this.prevHash = lineHash(bytes, this.chainKey)
+	return nil
+}
+
+func (this *fileAuditor) matches(eventId uint32, eventType string, event *n1qlAuditEvent) bool {
+	if this.eventIds != nil && !this.eventIds[eventId] {
+		return false
+	}
+	if this.types != nil && !this.types[eventType] {
+		return false
+	}
+	if this.users != nil {
+		user := event.RealUserid
+		if !this.users[user.Source+":"+user.Username] &&
+			!(user.Source == "local" && this.users[user.Username]) {
+			return false
+		}
+	}
+	if this.keyspaces != nil {
+		found := false
+		for _, keyspace := range event.Keyspaces {
+			if this.keyspaces[keyspace] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileSettings(t *testing.T) {
+	var val interface{}
+	json.Unmarshal([]byte(`{"dir": "/tmp/audit", "max-files": 3, "chain": true, "event-ids": [28672],
+		"users": ["local:joe"], "statement-types": ["select"], "keyspaces": ["orders"]}`), &val)
+	settings, err := NewFileSettings(val)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if settings.MaxFiles != 3 || settings.MaxSize != FILE_DEFAULT_SIZE || !settings.Chain ||
+		settings.StatementTypes[0] != "SELECT" || settings.Keyspaces[0] != "default:orders" {
+		t.Errorf("Unexpected settings %v", settings.Settings())
+	}
+
+	invalid := []string{
+		`[]`,
+		`{"max-size": 0}`,
+		`{"event-ids": [-1]}`,
+		`{"users": [""]}`,
+		`{"chain": "yes"}`,
+		`{"color": "blue"}`,
+	}
+	for _, s := range invalid {
+		var val interface{}
+		json.Unmarshal([]byte(s), &val)
+		if _, err := NewFileSettings(val); err == nil {
+			t.Errorf("Expected %s to be rejected", s)
+		}
+	}
+}
+
+func TestFileAuditor(t *testing.T) {
+	dir, e := ioutil.TempDir("", "audit")
+	if e != nil {
+		t.Fatalf("Unable to create directory %v", e)
+	}
+	defer os.RemoveAll(dir)
+
+	_AUDITOR = nil
+	defer SetFileAuditSettings(&FileSettings{})
+	err := SetFileAuditSettings(&FileSettings{Dir: dir, MaxSize: 2048, MaxFiles: 2, Chain: true,
+		Users: []string{"joe"}, Keyspaces: []string{"default:orders"}})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	auditable := &simpleAuditable{eventType: "SELECT", eventUsers: []string{"joe", "external:ann"},
+		eventKeyspaces: []string{"default:orders"}, statement: "SELECT * FROM orders"}
+	for i := 0; i < 20; i++ {
+		Submit(auditable)
+	}
+
+	// filtered out
+	auditable.eventKeyspaces = []string{"default:customers"}
+	Submit(auditable)
+	auditable.eventUsers = nil
+	Submit(auditable)
+
+	// old files are rotated away
+	seqs := _FILE_AUDITOR.files()
+	if len(seqs) != 2 || seqs[0] == 0 {
+		t.Fatalf("Expected two files after rotation, found %v", seqs)
+	}
+
+	// the chain holds across files, and carries on after a restart
+	prevHash := ""
+	for i, seq := range seqs {
+		file, e := os.Open(_FILE_AUDITOR.fileName(seq))
+		if e != nil {
+			t.Fatalf("Unable to open %v", e)
+		}
+		reader := bufio.NewReader(file)
+		for n := 0; ; n++ {
+			line, e := reader.ReadBytes('\n')
+			if e != nil {
+				break
+			}
+			line = line[:len(line)-1]
+			var record map[string]interface{}
+			json.Unmarshal(line, &record)
+			if record["name"] != "SELECT" || record["id"] != float64(28672) {
+				t.Errorf("Unexpected record %s", line)
+			}
+			if (i > 0 || n > 0) && record["prevHash"] != prevHash {
+				t.Errorf("Broken chain at %s", line)
+			}
+			prevHash = lineHash(line, nil)
+		}
+		file.Close()
+	}
+
+	SetFileAuditSettings(&FileSettings{Dir: dir, MaxSize: 2048, MaxFiles: 2, Chain: true})
+	if _FILE_AUDITOR.prevHash != prevHash {
+		t.Errorf("Expected chain to carry on from %v, found %v", prevHash, _FILE_AUDITOR.prevHash)
+	}
+}
+
+func TestFileAuditorChainKey(t *testing.T) {
+	dir, e := ioutil.TempDir("", "audit")
+	if e != nil {
+		t.Fatalf("Unable to create directory %v", e)
+	}
+	defer os.RemoveAll(dir)
+
+	key := []byte("chain key")
+	_AUDITOR = nil
+	SetFileAuditChainKey(key)
+	defer SetFileAuditChainKey(nil)
+	defer SetFileAuditSettings(&FileSettings{})
+	err := SetFileAuditSettings(&FileSettings{Dir: dir, Chain: true})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	auditable := &simpleAuditable{eventType: "SELECT", eventUsers: []string{"joe"},
+		statement: "SELECT * FROM orders"}
+	Submit(auditable)
+	Submit(auditable)
+
+	file, e := os.Open(_FILE_AUDITOR.fileName(_FILE_AUDITOR.seq))
+	if e != nil {
+		t.Fatalf("Unable to open %v", e)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	first, _ := reader.ReadBytes('\n')
+	second, _ := reader.ReadBytes('\n')
+	first = first[:len(first)-1]
+
+	// the chain can only be followed with the key
+	var record map[string]interface{}
+	json.Unmarshal(second, &record)
+	if record["prevHash"] != lineHash(first, key) {
+		t.Errorf("Expected the HMAC of %s, found %v", first, record["prevHash"])
+	}
+	if record["prevHash"] == lineHash(first, nil) {
+		t.Errorf("Expected the chain to be keyed, found %v", record["prevHash"])
+	}
+}
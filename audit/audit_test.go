@@ -40,6 +40,7 @@ type simpleAuditable struct {
 	eventId             string
 	eventType           string
 	eventUsers          []string
+	eventKeyspaces      []string
 	userAgent           string
 	eventNodeName       string
 	eventNamedArgs      map[string]string
@@ -79,6 +80,10 @@ func (sa *simpleAuditable) EventUsers() []string {
 	return sa.eventUsers
 }
 
+func (sa *simpleAuditable) EventKeyspaces() []string {
+	return sa.eventKeyspaces
+}
+
 func (sa *simpleAuditable) UserAgent() string {
 	return sa.userAgent
 }
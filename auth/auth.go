@@ -9,6 +9,8 @@
 
 package auth
 
+import "sort"
+
 type Privilege int
 
 const (
@@ -101,3 +103,38 @@ type Credentials map[string]string
 Type AuthenticatedUsers is a list of users whose credentials checked out.
 */
 type AuthenticatedUsers []string
+
+/*
+Type RowPolicies records the row security policies a statement was
+planned with: the keyspaces that were checked, as operation:namespace:keyspace
+scopes, and the revisions of the policies whose predicates were injected
+and of the column masks applied.
+*/
+type RowPolicies struct {
+	Scopes  []string         `json:"scopes"`
+	Applied map[string]int64 `json:"applied,omitempty"`
+	Masks   map[string]int64 `json:"masks,omitempty"`
+}
+
+/*
+Returns the names of the policies applied, in order.
+*/
+func (this *RowPolicies) Names() []string {
+	return sortedNames(this.Applied)
+}
+
+/*
+Returns the names of the column masks applied, in order.
+*/
+func (this *RowPolicies) MaskNames() []string {
+	return sortedNames(this.Masks)
+}
+
+func sortedNames(revisions map[string]int64) []string {
+	names := make([]string, 0, len(revisions))
+	for name, _ := range revisions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,52 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// An Identity is a user authenticated by the query service itself, from a
+// bearer token or a client certificate, rather than by the datastore from a
+// password. It travels with the http request to the datastore's Authorize.
+type Identity struct {
+	User   string   // domain:id
+	Method string   // "jwt" or "cert"
+	Roles  []string // role or role[keyspace]; nil if the token carries none
+}
+
+type identityKey struct{}
+
+// Returns a copy of the request carrying the identity.
+func WithIdentity(req *http.Request, identity *Identity) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), identityKey{}, identity))
+}
+
+// Returns the identity a request carries, if any.
+func RequestIdentity(req *http.Request) *Identity {
+	if req == nil {
+		return nil
+	}
+	identity, _ := req.Context().Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Splits a role of the form role[keyspace] into its name, in its long
+// form, and its keyspace.
+func ParseRole(role string) (string, string) {
+	keyspace := ""
+	if i := strings.IndexByte(role, '['); i > 0 && strings.HasSuffix(role, "]") {
+		keyspace = role[i+1 : len(role)-1]
+		role = role[:i]
+	}
+	return NormalizeRoleNames([]string{role})[0], keyspace
+}
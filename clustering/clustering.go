@@ -65,6 +65,16 @@ type ConfigurationStore interface {
 	WhoAmI() (string, errors.Error)                        // The Id of the local node, if clustered
 	State() (Mode, errors.Error)                           // The clustring state of the local node
 	SetOptions(httpAddr, httpsAddr string) errors.Error    // Set options for the local ConfigurationStore
+	GetMetadata(key string) ([]byte, errors.Error)         // Get a metadata document, nil if not present
+	SetMetadata(key string, value []byte) errors.Error     // Store a metadata document shared by the Query Nodes
+}
+
+// SettingsStore is implemented by ConfigurationStores that keep the query
+// settings themselves, for standalone deployments without metakv.
+type SettingsStore interface {
+	GetSettings() (map[string]interface{}, errors.Error)                      // The stored settings, by /admin/settings name
+	SetSettings(settings map[string]interface{}) errors.Error                 // Merge changed settings into the stored ones
+	WatchSettings(callb func(map[string]interface{}), cancelCh chan struct{}) // Call back with all settings when edited elsewhere
 }
 
 // Cluster is a named collection of Query Nodes. It is basically a single-level namespace for one or more Query Nodes.
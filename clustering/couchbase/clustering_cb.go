@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/couchbase/cbauth"
+	"github.com/couchbase/cbauth/metakv"
 	"github.com/couchbase/go-couchbase"
 	"github.com/couchbase/query/accounting"
 	"github.com/couchbase/query/clustering"
@@ -254,6 +255,31 @@ func (this *cbConfigStore) State() (clustering.Mode, errors.Error) {
 	return state, nil
 }
 
+// metadata documents are shared through metakv
+const _METADATA_PATH = "/query/metadata/"
+
+func (this *cbConfigStore) GetMetadata(key string) ([]byte, errors.Error) {
+	val, _, err := metakv.Get(_METADATA_PATH + key)
+	if err != nil {
+		return nil, errors.NewAdminGetMetadataError(err, key)
+	}
+	return val, nil
+}
+
+func (this *cbConfigStore) SetMetadata(key string, value []byte) errors.Error {
+	var err error
+
+	if value == nil {
+		err = metakv.Delete(_METADATA_PATH+key, nil)
+	} else {
+		err = metakv.Set(_METADATA_PATH+key, value, nil)
+	}
+	if err != nil {
+		return errors.NewAdminSetMetadataError(err, key)
+	}
+	return nil
+}
+
 func (this *cbConfigStore) doNameState() (string, clustering.Mode, errors.Error) {
 
 	// once things get to a certain state, no changes are possible
@@ -0,0 +1,276 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package clustering_file provides a configuration store kept in a local JSON
+file, for standalone deployments without metakv.
+
+The file holds the query settings, by their /admin/settings names, and the
+metadata documents shared through the configuration store:
+
+	{
+	    "settings": {"servicers": 8, "scan-cap": 1024},
+	    "metadata": {"policies": [...]}
+	}
+
+Changes are written to a temporary file that is renamed over the original,
+so that the file is never left half written. The file is polled for edits
+made elsewhere, which are applied as if made through /admin/settings.
+
+Like the stub, the store has one cluster with one Query Node, and does not
+authorize admin requests.
+*/
+package clustering_file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/clustering"
+	"github.com/couchbase/query/clustering/stub"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+)
+
+const _PREFIX = "file:"
+
+// how often the file is checked for edits
+const _WATCH_INTERVAL = time.Second
+
+type fileConfig struct {
+	Settings map[string]interface{}     `json:"settings,omitempty"`
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+}
+
+type settingsWatcher struct {
+	callb    func(map[string]interface{})
+	cancelCh chan struct{}
+}
+
+// fileConfigStore implements clustering.ConfigurationStore and
+// clustering.SettingsStore
+type fileConfigStore struct {
+	clustering_stub.ConfigurationStoreStub
+
+	sync.Mutex
+	path     string
+	content  []byte // as last read or written
+	config   fileConfig
+	watchers []*settingsWatcher
+	watching bool
+}
+
+func NewConfigstore(uri string) (clustering.ConfigurationStore, errors.Error) {
+	path := strings.TrimPrefix(uri, _PREFIX)
+	if path == "" {
+		return nil, errors.NewAdminInvalidURL("ConfigurationStore", uri)
+	}
+
+	rv := &fileConfigStore{path: path}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+
+		// the file is created on the first change
+		return rv, nil
+	} else if err != nil {
+		return nil, errors.NewAdminConfigFileError(err, path)
+	}
+	err = json.Unmarshal(content, &rv.config)
+	if err != nil {
+		return nil, errors.NewAdminConfigFileError(err, path)
+	}
+	rv.content = content
+	return rv, nil
+}
+
+func (this *fileConfigStore) Id() string {
+	return this.URL()
+}
+
+func (this *fileConfigStore) URL() string {
+	return _PREFIX + this.path
+}
+
+func (this *fileConfigStore) GetMetadata(key string) ([]byte, errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	data, ok := this.config.Metadata[key]
+	if !ok {
+		return nil, nil
+	}
+	return []byte(data), nil
+}
+
+// metadata documents are kept as JSON in the file
+func (this *fileConfigStore) SetMetadata(key string, value []byte) errors.Error {
+	if value != nil && !json.Valid(value) {
+		return errors.NewAdminSetMetadataError(nil, key)
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	config := this.config
+	config.Metadata = make(map[string]json.RawMessage, len(this.config.Metadata)+1)
+	for k, v := range this.config.Metadata {
+		config.Metadata[k] = v
+	}
+	if value == nil {
+		delete(config.Metadata, key)
+	} else {
+		config.Metadata[key] = json.RawMessage(value)
+	}
+
+	err := this.write(&config)
+	if err != nil {
+		return errors.NewAdminSetMetadataError(err, key)
+	}
+	return nil
+}
+
+func (this *fileConfigStore) GetSettings() (map[string]interface{}, errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	return copySettings(this.config.Settings), nil
+}
+
+func (this *fileConfigStore) SetSettings(settings map[string]interface{}) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	config := this.config
+	config.Settings = copySettings(this.config.Settings)
+	for k, v := range settings {
+		config.Settings[k] = v
+	}
+
+	err := this.write(&config)
+	if err != nil {
+		return errors.NewAdminConfigFileError(err, this.path)
+	}
+	return nil
+}
+
+func (this *fileConfigStore) WatchSettings(callb func(map[string]interface{}), cancelCh chan struct{}) {
+	this.Lock()
+	defer this.Unlock()
+	this.watchers = append(this.watchers, &settingsWatcher{callb: callb, cancelCh: cancelCh})
+	if !this.watching {
+		this.watching = true
+		go this.watch()
+	}
+}
+
+func (this *fileConfigStore) watch() {
+	ticker := time.NewTicker(_WATCH_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		settings, changed := this.reload()
+
+		this.Lock()
+		watchers := this.watchers[:0]
+		for _, w := range this.watchers {
+			select {
+			case <-w.cancelCh:
+			default:
+				watchers = append(watchers, w)
+			}
+		}
+		this.watchers = watchers
+		if len(watchers) == 0 {
+			this.watching = false
+		}
+		watchers = append([]*settingsWatcher(nil), watchers...)
+		this.Unlock()
+
+		if len(watchers) == 0 {
+			return
+		}
+		if changed {
+			for _, w := range watchers {
+				w.callb(copySettings(settings))
+			}
+		}
+	}
+}
+
+// Reads the file back if it has been edited, and returns the settings
+// and whether they have changed. Edits that do not parse are ignored.
+func (this *fileConfigStore) reload() (map[string]interface{}, bool) {
+	content, err := ioutil.ReadFile(this.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Errorf("Unable to read configuration file %v: %v", this.path, err)
+		}
+		return nil, false
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	if bytes.Equal(content, this.content) {
+		return nil, false
+	}
+	this.content = content
+
+	var config fileConfig
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		logging.Errorf("Ignoring invalid configuration file %v: %v", this.path, err)
+		return nil, false
+	}
+	changed := !reflect.DeepEqual(config.Settings, this.config.Settings)
+	this.config = config
+	logging.Infof("Configuration file %v reloaded", this.path)
+	return config.Settings, changed
+}
+
+// Replaces the file, and what is kept of it, by the new configuration.
+func (this *fileConfigStore) write(config *fileConfig) error {
+	content, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(this.path), filepath.Base(this.path)+".")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), this.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	this.content = content
+	this.config = *config
+	return nil
+}
+
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	rv := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		rv[k] = v
+	}
+	return rv
+}
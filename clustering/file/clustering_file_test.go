@@ -0,0 +1,117 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package clustering_file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/clustering"
+)
+
+func TestFileConfigstore(t *testing.T) {
+	dir, e := ioutil.TempDir("", "configstore")
+	if e != nil {
+		t.Fatalf("Unable to create directory %v", e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	cs, err := NewConfigstore("file:" + path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	store := cs.(clustering.SettingsStore)
+	settings, _ := store.GetSettings()
+	if len(settings) != 0 {
+		t.Errorf("Expected no settings, found %v", settings)
+	}
+
+	err = store.SetSettings(map[string]interface{}{"servicers": float64(8)})
+	if err == nil {
+		err = store.SetSettings(map[string]interface{}{"scan-cap": float64(1024)})
+	}
+	if err == nil {
+		err = cs.SetMetadata("policies", []byte(`[{"name":"p1"}]`))
+	}
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if cs.SetMetadata("bad", []byte("{")) == nil {
+		t.Errorf("Expected invalid JSON metadata to be rejected")
+	}
+
+	// the settings and metadata survive a restart
+	cs, err = NewConfigstore("file:" + path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	store = cs.(clustering.SettingsStore)
+	settings, _ = store.GetSettings()
+	if len(settings) != 2 || settings["servicers"] != float64(8) || settings["scan-cap"] != float64(1024) {
+		t.Errorf("Unexpected settings %v", settings)
+	}
+	data, _ := cs.GetMetadata("policies")
+	var compact bytes.Buffer
+	json.Compact(&compact, data)
+	if compact.String() != `[{"name":"p1"}]` {
+		t.Errorf("Unexpected metadata %s", data)
+	}
+	cs.SetMetadata("policies", nil)
+	if data, _ = cs.GetMetadata("policies"); data != nil {
+		t.Errorf("Expected metadata to be removed, found %s", data)
+	}
+
+	// no temporary files are left behind
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected only the configuration file, found %v", len(files))
+	}
+
+	// edits made elsewhere are picked up
+	changes := make(chan map[string]interface{}, 1)
+	cancelCh := make(chan struct{})
+	defer close(cancelCh)
+	store.WatchSettings(func(settings map[string]interface{}) {
+		changes <- settings
+	}, cancelCh)
+
+	ioutil.WriteFile(path, []byte(`{"settings": {"servicers": 4}}`), 0600)
+	select {
+	case settings = <-changes:
+		if len(settings) != 1 || settings["servicers"] != float64(4) {
+			t.Errorf("Unexpected settings %v", settings)
+		}
+	case <-time.After(5 * _WATCH_INTERVAL):
+		t.Errorf("Expected settings to be reloaded")
+	}
+
+	// but not changes made through the store itself, or invalid edits
+	store.SetSettings(map[string]interface{}{"servicers": float64(2)})
+	if _, changed := cs.(*fileConfigStore).reload(); changed {
+		t.Errorf("Expected own changes not to be reloaded")
+	}
+	ioutil.WriteFile(path, []byte(`{"settings": `), 0600)
+	if _, changed := cs.(*fileConfigStore).reload(); changed {
+		t.Errorf("Expected invalid edits to be ignored")
+	}
+	if settings, _ = store.GetSettings(); settings["servicers"] != float64(2) {
+		t.Errorf("Unexpected settings %v", settings)
+	}
+
+	if _, err = NewConfigstore("file:"); err == nil {
+		t.Errorf("Expected empty path to be rejected")
+	}
+}
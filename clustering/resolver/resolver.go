@@ -15,6 +15,7 @@ import (
 	"github.com/couchbase/query/accounting"
 	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/clustering/couchbase"
+	"github.com/couchbase/query/clustering/file"
 	"github.com/couchbase/query/clustering/stub"
 	"github.com/couchbase/query/clustering/zookeeper"
 	"github.com/couchbase/query/datastore"
@@ -35,6 +36,10 @@ func NewConfigstore(uri string) (clustering.ConfigurationStore, errors.Error) {
 	if strings.HasPrefix(uri, "stub:") {
 		return clustering_stub.NewConfigurationStore()
 	}
+
+	if strings.HasPrefix(uri, "file:") {
+		return clustering_file.NewConfigstore(uri)
+	}
 	return nil, errors.NewAdminInvalidURL("ConfigurationStore", uri)
 }
 
@@ -0,0 +1,429 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package clustering_static provides cluster membership for standalone query
+nodes, from a static list of peers, without Couchbase or ZooKeeper.
+
+The peers file lists the query nodes by the host:port of their HTTP
+service, and optionally the local node and the credentials to use on the
+peers' admin endpoints:
+
+	{
+	    "self": "10.0.0.1:8093",
+	    "nodes": ["10.0.0.1:8093", "10.0.0.2:8093", "10.0.0.3:8093"],
+	    "user": "admin",
+	    "password": "secret"
+	}
+
+Without self, the local node is the one whose host resolves to a local
+address, on the port of the local HTTP service.
+
+System keyspaces are gathered from the peers over plain HTTP, and the peers
+are pinged periodically, so that nodes that are down are skipped with a
+warning rather than holding up every scan.
+*/
+package clustering_static
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+)
+
+const (
+	_HEALTH_INTERVAL = 5 * time.Second
+	_HEALTH_TIMEOUT  = 2 * time.Second
+	_REMOTE_TIMEOUT  = 5 * time.Second
+)
+
+const (
+	_STATUS_UNKNOWN   = "unknown"
+	_STATUS_HEALTHY   = "healthy"
+	_STATUS_UNHEALTHY = "unhealthy"
+)
+
+type peersFile struct {
+	Self     string   `json:"self"`
+	Nodes    []string `json:"nodes"`
+	User     string   `json:"user"`
+	Password string   `json:"password"`
+}
+
+type peer struct {
+	name    string
+	status  string
+	checked time.Time
+	err     error
+}
+
+// static implementation of SystemRemoteAccess and NodeServices
+type systemRemoteStatic struct {
+	sync.RWMutex
+	self     string
+	names    []string
+	peers    map[string]*peer
+	user     string
+	password string
+	client   *http.Client
+}
+
+func NewSystemRemoteAccess(path string, httpAddr string) (distributed.SystemRemoteAccess, errors.Error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewAdminConfigFileError(err, path)
+	}
+	var file peersFile
+	err = json.Unmarshal(data, &file)
+	if err == nil && len(file.Nodes) == 0 {
+		err = fmt.Errorf("no nodes")
+	}
+	if err != nil {
+		return nil, errors.NewAdminConfigFileError(err, path)
+	}
+
+	rv := newSystemRemoteStatic(&file)
+	for _, name := range rv.names {
+		if _, _, err := net.SplitHostPort(name); err != nil {
+			return nil, errors.NewAdminConfigFileError(err, path)
+		}
+	}
+	if rv.self == "" {
+		rv.self = localNode(rv.names, httpAddr)
+	}
+	if rv.self == "" {
+		logging.Errorf("Local node not found in %v, system keyspaces will not be gathered from peers", path)
+	} else if _, ok := rv.peers[rv.self]; !ok {
+		return nil, errors.NewAdminConfigFileError(fmt.Errorf("node %v not listed", rv.self), path)
+	}
+
+	go rv.healthChecks()
+	return rv, nil
+}
+
+func newSystemRemoteStatic(file *peersFile) *systemRemoteStatic {
+	rv := &systemRemoteStatic{
+		self:     file.Self,
+		peers:    make(map[string]*peer, len(file.Nodes)),
+		user:     file.User,
+		password: file.Password,
+		client:   &http.Client{Timeout: _REMOTE_TIMEOUT},
+	}
+	for _, name := range file.Nodes {
+		if _, ok := rv.peers[name]; ok {
+			continue
+		}
+		rv.names = append(rv.names, name)
+		rv.peers[name] = &peer{name: name, status: _STATUS_UNKNOWN}
+	}
+	return rv
+}
+
+// the node listening on the local HTTP port, on a local address
+func localNode(names []string, httpAddr string) string {
+	_, port, err := net.SplitHostPort(httpAddr)
+	if err != nil {
+		return ""
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	local := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			local[ipNet.IP.String()] = true
+		}
+	}
+
+	for _, name := range names {
+		host, p, _ := net.SplitHostPort(name)
+		if p != port {
+			continue
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			if local[ip.String()] {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// construct a key from node name and local key
+func (this *systemRemoteStatic) MakeKey(node string, key string) string {
+	if node == "" {
+		return key
+	}
+	return "[" + node + "]" + key
+}
+
+// split global key into name and local key
+// node names may be IPv6 addresses in brackets themselves
+func (this *systemRemoteStatic) SplitKey(key string) (string, string) {
+	if !strings.HasPrefix(key, "[") {
+		return "", key
+	}
+	brackets := 0
+	for i, c := range key {
+		switch c {
+		case '[':
+			brackets++
+		case ']':
+			brackets--
+			if brackets == 0 {
+
+				// node but no document key?
+				if i == len(key)-1 {
+					return "", key
+				}
+				return key[1:i], key[i+1:]
+			}
+		}
+	}
+	return "", key
+}
+
+// get remote keys from the specified nodes for the specified endpoint
+func (this *systemRemoteStatic) GetRemoteKeys(nodes []string, endpoint string,
+	keyFn func(id string) bool, warnFn func(warn errors.Error)) {
+
+	// not part of a cluster, no keys can be gathered
+	if this.self == "" {
+		return
+	}
+
+	// no nodes means all nodes
+	if len(nodes) == 0 {
+		nodes = this.names
+	}
+
+	for _, node := range nodes {
+
+		// skip ourselves, we will be processed locally
+		if node == this.self {
+			continue
+		}
+
+		var keys []string
+		err := this.available(node)
+		if err == nil {
+			var body []byte
+			body, err = this.doRemoteOp(node, "indexes/"+endpoint, "GET", distributed.NO_CREDS)
+			if err == nil {
+				err = json.Unmarshal(body, &keys)
+			}
+		}
+		if err != nil {
+			if warnFn != nil {
+				warnFn(errors.NewSystemRemoteWarning(err, "scan", endpoint))
+			}
+			continue
+		}
+
+		if keyFn != nil {
+			for _, key := range keys {
+				if !keyFn(this.MakeKey(node, key)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// get a specified remote document from a remote node
+func (this *systemRemoteStatic) GetRemoteDoc(node string, key string, endpoint string, command string,
+	docFn func(map[string]interface{}), warnFn func(warn errors.Error), creds distributed.Creds, authToken string) {
+	var doc map[string]interface{}
+
+	err := this.available(node)
+	if err == nil {
+		var body []byte
+		body, err = this.doRemoteOp(node, endpoint+"/"+url.PathEscape(key), command, creds)
+		if err == nil {
+			err = json.Unmarshal(body, &doc)
+		}
+	}
+	if err != nil {
+		if warnFn != nil {
+			warnFn(errors.NewSystemRemoteWarning(err, "fetch", endpoint))
+		}
+		return
+	}
+
+	if docFn != nil {
+		docFn(doc)
+	}
+}
+
+// returns the local node identity, as known to the cluster
+func (this *systemRemoteStatic) WhoAmI() string {
+	return this.self
+}
+
+// get the node names
+func (this *systemRemoteStatic) GetNodeNames() []string {
+	if this.self == "" {
+		return nil
+	}
+	return this.names
+}
+
+// the state and endpoints of a node
+func (this *systemRemoteStatic) NodeServices(node string) map[string]interface{} {
+	if this.self == "" {
+		return nil
+	}
+	this.RLock()
+	defer this.RUnlock()
+	p, ok := this.peers[node]
+	if !ok {
+		return nil
+	}
+
+	rv := map[string]interface{}{
+		"name":   node,
+		"status": p.status,
+		"services": map[string]interface{}{
+			"n1ql":  "http://" + node + "/query/service",
+			"admin": "http://" + node + "/admin",
+		},
+	}
+	if node == this.self {
+		rv["status"] = _STATUS_HEALTHY
+		rv["thisNode"] = true
+	} else if !p.checked.IsZero() {
+		rv["lastCheck"] = p.checked.Format(time.RFC3339)
+		if p.err != nil {
+			rv["error"] = p.err.Error()
+		}
+	}
+	return rv
+}
+
+// nodes are assumed available until a health check fails
+func (this *systemRemoteStatic) available(node string) error {
+	this.RLock()
+	defer this.RUnlock()
+	p, ok := this.peers[node]
+	if !ok {
+		return fmt.Errorf("node %v not found", node)
+	}
+	if p.status == _STATUS_UNHEALTHY {
+		return fmt.Errorf("node %v is unhealthy: %v", node, p.err)
+	}
+	return nil
+}
+
+func (this *systemRemoteStatic) healthChecks() {
+	ticker := time.NewTicker(_HEALTH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		this.checkHealth()
+		<-ticker.C
+	}
+}
+
+// ping all the other nodes, in parallel
+func (this *systemRemoteStatic) checkHealth() {
+	client := &http.Client{Timeout: _HEALTH_TIMEOUT}
+	var wg sync.WaitGroup
+	for _, name := range this.names {
+		if name == this.self {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			err := ping(client, name)
+
+			this.Lock()
+			defer this.Unlock()
+			p := this.peers[name]
+			status := _STATUS_HEALTHY
+			if err != nil {
+				status = _STATUS_UNHEALTHY
+			}
+			if status != p.status {
+				logging.Infof("Query node %v is %v", name, status)
+			}
+			p.status = status
+			p.checked = time.Now()
+			p.err = err
+		}(name)
+	}
+	wg.Wait()
+}
+
+func ping(client *http.Client, node string) error {
+	resp, err := client.Get("http://" + node + "/admin/ping")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 512))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping returned %v", resp.Status)
+	}
+	return nil
+}
+
+// helper for the REST op
+// a single user's credentials are passed as such, several as a creds
+// parameter, and none as the credentials in the peers file
+func (this *systemRemoteStatic) doRemoteOp(node string, endpoint string, command string,
+	creds distributed.Creds) ([]byte, error) {
+	fullEndpoint := "http://" + node + "/admin/" + endpoint
+	if len(creds) > 1 {
+		list := make([]map[string]string, 0, len(creds))
+		for user, pass := range creds {
+			list = append(list, map[string]string{"user": user, "pass": pass})
+		}
+		data, _ := json.Marshal(list)
+		fullEndpoint += "?creds=" + url.QueryEscape(string(data))
+	}
+	request, err := http.NewRequest(command, fullEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	if len(creds) == 1 {
+		for user, pass := range creds {
+			request.SetBasicAuth(user, pass)
+		}
+	} else if len(creds) == 0 && this.user != "" {
+		request.SetBasicAuth(this.user, this.password)
+	}
+
+	resp, err := this.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%v %v on %v returned %v", command, endpoint, node, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
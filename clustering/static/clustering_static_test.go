@@ -0,0 +1,156 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package clustering_static
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/errors"
+)
+
+func TestSplitKey(t *testing.T) {
+	rv := newSystemRemoteStatic(&peersFile{})
+	keys := []struct {
+		key, node, local string
+	}{
+		{"abc", "", "abc"},
+		{"[10.0.0.1:8093]abc", "10.0.0.1:8093", "abc"},
+		{"[[::1]:8093]abc", "[::1]:8093", "abc"},
+		{"[10.0.0.1:8093]", "", "[10.0.0.1:8093]"},
+		{"[10.0.0.1:8093", "", "[10.0.0.1:8093"},
+	}
+	for _, k := range keys {
+		node, local := rv.SplitKey(k.key)
+		if node != k.node || local != k.local {
+			t.Errorf("Unexpected split of %v: %v %v", k.key, node, local)
+		}
+		if node != "" && rv.MakeKey(node, local) != k.key {
+			t.Errorf("Unexpected key %v", rv.MakeKey(node, local))
+		}
+	}
+}
+
+func TestRemoteAccess(t *testing.T) {
+	var user string
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, _, _ = req.BasicAuth()
+		switch req.URL.Path {
+		case "/admin/ping":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/admin/indexes/active_requests":
+			w.Write([]byte(`["r1","r2"]`))
+		case "/admin/active_requests/r1":
+			w.Write([]byte(`{"requestId":"r1"}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer peer.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	self := "127.0.0.1:1"
+	node := strings.TrimPrefix(peer.URL, "http://")
+	downNode := strings.TrimPrefix(down.URL, "http://")
+	rv := newSystemRemoteStatic(&peersFile{Self: self, Nodes: []string{self, node, downNode},
+		User: "admin", Password: "secret"})
+	var remote distributed.SystemRemoteAccess = rv
+
+	if remote.WhoAmI() != self || len(remote.GetNodeNames()) != 3 {
+		t.Errorf("Unexpected nodes %v %v", remote.WhoAmI(), remote.GetNodeNames())
+	}
+
+	rv.checkHealth()
+	if s := rv.NodeServices(node); s["status"] != _STATUS_HEALTHY {
+		t.Errorf("Unexpected services %v", s)
+	}
+	if s := rv.NodeServices(downNode); s["status"] != _STATUS_UNHEALTHY || s["error"] == nil {
+		t.Errorf("Unexpected services %v", s)
+	}
+	if s := rv.NodeServices(self); s["status"] != _STATUS_HEALTHY || s["thisNode"] != true {
+		t.Errorf("Unexpected services %v", s)
+	}
+	if rv.NodeServices("127.0.0.1:2") != nil {
+		t.Errorf("Expected unknown node to have no services")
+	}
+
+	// keys come from the healthy peer, and the others are warned about
+	var keys []string
+	var warnings []errors.Error
+	remote.GetRemoteKeys(nil, "active_requests", func(id string) bool {
+		keys = append(keys, id)
+		return true
+	}, func(warn errors.Error) {
+		warnings = append(warnings, warn)
+	})
+	if len(keys) != 2 || keys[0] != "["+node+"]r1" {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Unexpected warnings %v", warnings)
+	}
+	if user != "admin" {
+		t.Errorf("Expected the peers file credentials, found %v", user)
+	}
+
+	var doc map[string]interface{}
+	remote.GetRemoteDoc(node, "r1", "active_requests", "GET", func(d map[string]interface{}) {
+		doc = d
+	}, nil, distributed.Creds{"joe": "pass"}, "")
+	if doc["requestId"] != "r1" || user != "joe" {
+		t.Errorf("Unexpected document %v for %v", doc, user)
+	}
+
+	warnings = nil
+	remote.GetRemoteDoc(node, "r3", "active_requests", "GET", nil, func(warn errors.Error) {
+		warnings = append(warnings, warn)
+	}, distributed.NO_CREDS, "")
+	if len(warnings) != 1 {
+		t.Errorf("Expected a warning for a missing document")
+	}
+}
+
+func TestPeersFile(t *testing.T) {
+	dir, e := ioutil.TempDir("", "peers")
+	if e != nil {
+		t.Fatalf("Unable to create directory %v", e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers.json")
+
+	invalid := []string{
+		`{"nodes": []}`,
+		`{"nodes": ["10.0.0.1"]}`,
+		`{"self": "10.0.0.3:8093", "nodes": ["10.0.0.1:8093", "10.0.0.2:8093"]}`,
+	}
+	for _, s := range invalid {
+		ioutil.WriteFile(path, []byte(s), 0600)
+		if _, err := NewSystemRemoteAccess(path, ":8093"); err == nil {
+			t.Errorf("Expected %s to be rejected", s)
+		}
+	}
+
+	// the local node is found by address
+	ioutil.WriteFile(path, []byte(`{"nodes": ["10.255.255.1:8093", "127.0.0.1:8093"]}`), 0600)
+	remote, err := NewSystemRemoteAccess(path, ":8093")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if remote.WhoAmI() != "127.0.0.1:8093" {
+		t.Errorf("Unexpected local node %v", remote.WhoAmI())
+	}
+}
@@ -21,6 +21,8 @@ Query Node Name, Query Endpoint and Cluster Endpoint.
 package clustering_stub
 
 import (
+	"sync"
+
 	"github.com/couchbase/query/accounting"
 	"github.com/couchbase/query/accounting/stub"
 	"github.com/couchbase/query/clustering"
@@ -98,6 +100,29 @@ func (ConfigurationStoreStub) State() (clustering.Mode, errors.Error) {
 	return clustering.STANDALONE, nil
 }
 
+// metadata is kept in memory, and shared by all stub instances
+var metadata = struct {
+	sync.RWMutex
+	docs map[string][]byte
+}{docs: make(map[string][]byte)}
+
+func (ConfigurationStoreStub) GetMetadata(key string) ([]byte, errors.Error) {
+	metadata.RLock()
+	defer metadata.RUnlock()
+	return metadata.docs[key], nil
+}
+
+func (ConfigurationStoreStub) SetMetadata(key string, value []byte) errors.Error {
+	metadata.Lock()
+	defer metadata.Unlock()
+	if value == nil {
+		delete(metadata.docs, key)
+	} else {
+		metadata.docs[key] = value
+	}
+	return nil
+}
+
 // ClusterStub is a stub implementation of clustering.Cluster
 // It has one Query Node, an instance of QueryNodeStub
 type ClusterStub struct{}
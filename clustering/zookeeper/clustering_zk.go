@@ -19,6 +19,7 @@ import (
 
 const _PREFIX = "zookeeper:"
 const _RESERVED_NAME = "zookeeper"
+const _METADATA_NAME = "_metadata"
 
 // zkConfigStore implements clustering.ConfigurationStore
 type zkConfigStore struct {
@@ -67,6 +68,9 @@ func (z *zkConfigStore) ClusterNames() ([]string, errors.Error) {
 		return nil, errors.NewAdminGetClusterError(err, "/")
 	}
 	for _, name := range nodes {
+		if name == _RESERVED_NAME || name == _METADATA_NAME {
+			continue
+		}
 		clusterIds = append(clusterIds, name)
 	}
 	return clusterIds, nil
@@ -130,7 +134,7 @@ func (z *zkConfigStore) GetClusters() ([]clustering.Cluster, errors.Error) {
 		return nil, errors.NewAdminGetClusterError(err, "/")
 	}
 	for _, name := range nodes {
-		if name == _RESERVED_NAME {
+		if name == _RESERVED_NAME || name == _METADATA_NAME {
 			continue
 		}
 		data, _, err := z.conn.Get("/" + name)
@@ -159,6 +163,42 @@ func (z *zkConfigStore) State() (clustering.Mode, errors.Error) {
 	return clustering.STARTING, nil
 }
 
+// metadata documents are stored as children of /_metadata
+func (z *zkConfigStore) GetMetadata(key string) ([]byte, errors.Error) {
+	data, _, err := z.conn.Get("/" + _METADATA_NAME + "/" + key)
+	if err == zk.ErrNoNode {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.NewAdminGetMetadataError(err, key)
+	}
+	return data, nil
+}
+
+func (z *zkConfigStore) SetMetadata(key string, value []byte) errors.Error {
+	path := "/" + _METADATA_NAME + "/" + key
+	if value == nil {
+		err := z.conn.Delete(path, -1)
+		if err != nil && err != zk.ErrNoNode {
+			return errors.NewAdminSetMetadataError(err, key)
+		}
+		return nil
+	}
+
+	acl := zk.WorldACL(zk.PermAll)
+	_, err := z.conn.Create("/"+_METADATA_NAME, []byte{}, 0, acl)
+	if err != nil && err != zk.ErrNodeExists {
+		return errors.NewAdminSetMetadataError(err, key)
+	}
+	_, err = z.conn.Set(path, value, -1)
+	if err == zk.ErrNoNode {
+		_, err = z.conn.Create(path, value, 0, acl)
+	}
+	if err != nil {
+		return errors.NewAdminSetMetadataError(err, key)
+	}
+	return nil
+}
+
 // zkCluster implements clustering.Cluster
 type zkCluster struct {
 	configStore    clustering.ConfigurationStore `json:"-"`
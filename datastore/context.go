@@ -45,6 +45,9 @@ func (ci *contextImpl) Warning(err errors.Error) {
 }
 
 // A subset of execution.Context that is useful at the datastore level.
+// Contexts of traced requests also implement tracing.Carrier, so that
+// the trace can be propagated to the HTTP services called by CURL().
+// The data and index services are not passed the trace.
 type QueryContext interface {
 	GetReqDeadline() time.Time
 	Credentials() auth.Credentials
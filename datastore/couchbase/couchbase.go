@@ -34,6 +34,7 @@ import (
 	gsi "github.com/couchbase/indexing/secondary/queryport/n1ql"
 	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/rbac"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/logging"
@@ -300,6 +301,11 @@ func (s *store) authWebCreds(req *http.Request) (cbauth.Creds, error) {
 }
 
 func (s *store) Authorize(privileges *auth.Privileges, credentials auth.Credentials, req *http.Request) (auth.AuthenticatedUsers, errors.Error) {
+	// users authenticated by the query service are not known to cbauth
+	if identity := auth.RequestIdentity(req); identity != nil {
+		return rbac.AuthorizeIdentity(privileges, identity)
+	}
+
 	if s.CbAuthInit == false {
 		// cbauth is not initialized. Access to SASL protected buckets will be
 		// denied by the couchbase server
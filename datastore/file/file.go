@@ -27,6 +27,7 @@ import (
 
 	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/rbac"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/logging"
@@ -35,13 +36,18 @@ import (
 	"github.com/couchbase/query/value"
 )
 
+// USERS_FILE, at the root of the datastore, holds users and their
+// roles in the format of package rbac.
+const USERS_FILE = "users.json"
+
 // datastore is the root for the file-based Datastore.
 type store struct {
 	path           string
 	namespaces     map[string]*namespace
 	namespaceNames []string
 
-	users map[string]*datastore.User
+	users     map[string]*datastore.User
+	rbacUsers *rbac.Users // from the users file, if there is one
 }
 
 func (s *store) Id() string {
@@ -92,7 +98,14 @@ func (s *store) NamespaceByName(name string) (p datastore.Namespace, e errors.Er
 	return
 }
 
-func (s *store) Authorize(*auth.Privileges, auth.Credentials, *http.Request) (auth.AuthenticatedUsers, errors.Error) {
+func (s *store) Authorize(privileges *auth.Privileges, credentials auth.Credentials, req *http.Request) (auth.AuthenticatedUsers, errors.Error) {
+	identity := auth.RequestIdentity(req)
+	if s.rbacUsers != nil {
+		return s.rbacUsers.Authorize(privileges, credentials, identity)
+	}
+	if identity != nil {
+		return auth.AuthenticatedUsers{identity.User}, nil
+	}
 	return nil, nil
 }
 
@@ -113,6 +126,10 @@ func (s *store) Inferencers() ([]datastore.Inferencer, errors.Error) {
 }
 
 func (s *store) UserInfo() (value.Value, errors.Error) {
+	if s.rbacUsers != nil {
+		return s.rbacUsers.UserInfo()
+	}
+
 	// Return an array of no users.
 	jsonData := make([]interface{}, 0)
 	v := value.NewValue(jsonData)
@@ -120,6 +137,9 @@ func (s *store) UserInfo() (value.Value, errors.Error) {
 }
 
 func (s *store) GetUserInfoAll() ([]datastore.User, errors.Error) {
+	if s.rbacUsers != nil {
+		return s.rbacUsers.GetUserInfoAll()
+	}
 	ret := make([]datastore.User, 0, len(s.users))
 	for _, v := range s.users {
 		ret = append(ret, *v)
@@ -128,16 +148,15 @@ func (s *store) GetUserInfoAll() ([]datastore.User, errors.Error) {
 }
 
 func (s *store) PutUserInfo(u *datastore.User) errors.Error {
+	if s.rbacUsers != nil {
+		return s.rbacUsers.PutUserInfo(u)
+	}
 	s.users[u.Id] = u
 	return nil
 }
 
 func (s *store) GetRolesAll() ([]datastore.Role, errors.Error) {
-	return []datastore.Role{
-		datastore.Role{Name: "cluster_admin"},
-		datastore.Role{Name: "replication_admin"},
-		datastore.Role{Name: "bucket_admin", Bucket: "*"},
-	}, nil
+	return rbac.GetRolesAll(), nil
 }
 
 // NewStore creates a new file-based store for the given filepath.
@@ -154,10 +173,26 @@ func NewDatastore(path string) (s datastore.Datastore, e errors.Error) {
 		return
 	}
 
+	e = fs.loadUsers()
+	if e != nil {
+		return
+	}
+
 	s = fs
 	return
 }
 
+// Without a users file, everyone is authorized.
+func (s *store) loadUsers() (e errors.Error) {
+	usersPath := filepath.Join(s.path, USERS_FILE)
+	_, er := os.Stat(usersPath)
+	if os.IsNotExist(er) {
+		return nil
+	}
+	s.rbacUsers, e = rbac.LoadUsers(usersPath)
+	return
+}
+
 func (s *store) loadNamespaces() (e errors.Error) {
 	dirEntries, er := ioutil.ReadDir(s.path)
 	if er != nil {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/rbac"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/logging"
@@ -45,6 +46,7 @@ type store struct {
 	namespaces     map[string]*namespace
 	namespaceNames []string
 	params         map[string]int
+	rbacUsers      *rbac.Users // from the users param, if given
 }
 
 func (s *store) Id() string {
@@ -80,7 +82,14 @@ func (s *store) NamespaceByName(name string) (p datastore.Namespace, e errors.Er
 	return
 }
 
-func (s *store) Authorize(*auth.Privileges, auth.Credentials, *http.Request) (auth.AuthenticatedUsers, errors.Error) {
+func (s *store) Authorize(privileges *auth.Privileges, credentials auth.Credentials, req *http.Request) (auth.AuthenticatedUsers, errors.Error) {
+	identity := auth.RequestIdentity(req)
+	if s.rbacUsers != nil {
+		return s.rbacUsers.Authorize(privileges, credentials, identity)
+	}
+	if identity != nil {
+		return auth.AuthenticatedUsers{identity.User}, nil
+	}
 	return nil, nil
 }
 
@@ -101,6 +110,10 @@ func (s *store) Inferencers() ([]datastore.Inferencer, errors.Error) {
 }
 
 func (s *store) UserInfo() (value.Value, errors.Error) {
+	if s.rbacUsers != nil {
+		return s.rbacUsers.UserInfo()
+	}
+
 	// Stub implementation with fixed content.
 	content := `[{"name":"Ivan Ivanov","id":"ivanivanov","domain":"local","roles":[{"role":"cluster_admin"},
                         {"role":"bucket_admin","bucket_name":"default"}]},
@@ -115,14 +128,23 @@ func (s *store) UserInfo() (value.Value, errors.Error) {
 }
 
 func (s *store) GetUserInfoAll() ([]datastore.User, errors.Error) {
+	if s.rbacUsers != nil {
+		return s.rbacUsers.GetUserInfoAll()
+	}
 	return nil, errors.NewOtherNotImplementedError(nil, "GetUserInfoAll")
 }
 
 func (s *store) PutUserInfo(u *datastore.User) errors.Error {
+	if s.rbacUsers != nil {
+		return s.rbacUsers.PutUserInfo(u)
+	}
 	return errors.NewOtherNotImplementedError(nil, "PutUserInfo")
 }
 
 func (s *store) GetRolesAll() ([]datastore.Role, errors.Error) {
+	if s.rbacUsers != nil {
+		return rbac.GetRolesAll(), nil
+	}
 	return nil, errors.NewOtherNotImplementedError(nil, "GetRolesAll")
 }
 
@@ -365,7 +387,12 @@ func (mi *mockIndexer) SetLogLevel(level logging.Level) {
 // keyspace with 50000 items.  By default, you get...
 // mock:namespaces=1,keyspaces=1,items=100000 Which is what you'd get
 // by specifying a path of just...  mock:
+// A users=<file> param enforces the users and roles of a users file,
+// in the format of package rbac.
 func NewDatastore(path string) (datastore.Datastore, errors.Error) {
+	var rbacUsers *rbac.Users
+	var err errors.Error
+
 	if strings.HasPrefix(path, "mock:") {
 		path = path[5:]
 	}
@@ -374,7 +401,18 @@ func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 		if kv == "" {
 			continue
 		}
-		pair := strings.Split(kv, "=")
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 {
+			return nil, errors.NewOtherDatastoreError(nil,
+				fmt.Sprintf("could not parse mock param: %s", kv))
+		}
+		if pair[0] == "users" {
+			rbacUsers, err = rbac.LoadUsers(pair[1])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		v, e := strconv.Atoi(pair[1])
 		if e != nil {
 			return nil, errors.NewOtherDatastoreError(e,
@@ -386,7 +424,8 @@ func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 	nnamespaces := paramVal(params, "namespaces", DEFAULT_NUM_NAMESPACES)
 	nkeyspaces := paramVal(params, "keyspaces", DEFAULT_NUM_KEYSPACES)
 	nitems := paramVal(params, "items", DEFAULT_NUM_ITEMS)
-	s := &store{path: path, params: params, namespaces: map[string]*namespace{}, namespaceNames: []string{}}
+	s := &store{path: path, params: params, namespaces: map[string]*namespace{}, namespaceNames: []string{},
+		rbacUsers: rbacUsers}
 	for i := 0; i < nnamespaces; i++ {
 		p := &namespace{store: s, name: "p" + strconv.Itoa(i), keyspaces: map[string]*keyspace{}, keyspaceNames: []string{}}
 		for j := 0; j < nkeyspaces; j++ {
@@ -37,5 +37,8 @@ func CredsString(creds auth.Credentials, req *http.Request) string {
 			credsList = append(credsList, reqName)
 		}
 	}
+	if identity := auth.RequestIdentity(req); identity != nil {
+		credsList = append(credsList, identity.User)
+	}
 	return strings.Join(credsList, ",")
 }
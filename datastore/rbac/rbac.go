@@ -0,0 +1,509 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package rbac provides users and role based access control for the
+datastores that do not have a cluster manager to ask, from a users
+file in this format:
+
+	[{"id":"joe","domain":"local","name":"Joe Bloggs",
+	  "password":"$2a$10$...",
+	  "roles":[{"role":"select","bucket_name":"orders"},
+	           {"role":"query_system_catalog"}]}]
+
+Passwords are bcrypt hashes, or scrypt hashes in the form
+$scrypt$N$r$p$salt$key, with salt and key base64 encoded.
+Role names can be given in their alias forms, and a bucket_name of
+"*" stands for every keyspace.
+Hashes are slow on purpose, so successful password checks are
+remembered until the user changes.
+*/
+package rbac
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/value"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const _DEFAULT_DOMAIN = "local"
+
+// roles that apply to the whole system
+var _SYSTEM_ROLES = map[string][]auth.Privilege{
+	"admin": []auth.Privilege{auth.PRIV_READ, auth.PRIV_WRITE, auth.PRIV_SYSTEM_READ,
+		auth.PRIV_SECURITY_READ, auth.PRIV_SECURITY_WRITE, auth.PRIV_QUERY_SELECT,
+		auth.PRIV_QUERY_UPDATE, auth.PRIV_QUERY_INSERT, auth.PRIV_QUERY_DELETE,
+		auth.PRIV_QUERY_BUILD_INDEX, auth.PRIV_QUERY_CREATE_INDEX, auth.PRIV_QUERY_ALTER_INDEX,
+		auth.PRIV_QUERY_DROP_INDEX, auth.PRIV_QUERY_LIST_INDEX, auth.PRIV_QUERY_EXTERNAL_ACCESS},
+	"cluster_admin": []auth.Privilege{auth.PRIV_READ, auth.PRIV_WRITE, auth.PRIV_SYSTEM_READ,
+		auth.PRIV_SECURITY_READ, auth.PRIV_QUERY_SELECT, auth.PRIV_QUERY_UPDATE,
+		auth.PRIV_QUERY_INSERT, auth.PRIV_QUERY_DELETE, auth.PRIV_QUERY_BUILD_INDEX,
+		auth.PRIV_QUERY_CREATE_INDEX, auth.PRIV_QUERY_ALTER_INDEX, auth.PRIV_QUERY_DROP_INDEX,
+		auth.PRIV_QUERY_LIST_INDEX},
+	"ro_admin":              []auth.Privilege{auth.PRIV_SYSTEM_READ, auth.PRIV_SECURITY_READ},
+	"replication_admin":     []auth.Privilege{},
+	"query_system_catalog":  []auth.Privilege{auth.PRIV_SYSTEM_READ},
+	"query_external_access": []auth.Privilege{auth.PRIV_QUERY_EXTERNAL_ACCESS},
+}
+
+// roles that apply to a keyspace, or all of them
+var _KEYSPACE_ROLES = map[string][]auth.Privilege{
+	"bucket_admin": []auth.Privilege{auth.PRIV_READ, auth.PRIV_WRITE, auth.PRIV_QUERY_SELECT,
+		auth.PRIV_QUERY_UPDATE, auth.PRIV_QUERY_INSERT, auth.PRIV_QUERY_DELETE,
+		auth.PRIV_QUERY_BUILD_INDEX, auth.PRIV_QUERY_CREATE_INDEX, auth.PRIV_QUERY_ALTER_INDEX,
+		auth.PRIV_QUERY_DROP_INDEX, auth.PRIV_QUERY_LIST_INDEX},
+	"bucket_full_access": []auth.Privilege{auth.PRIV_READ, auth.PRIV_WRITE, auth.PRIV_QUERY_SELECT,
+		auth.PRIV_QUERY_UPDATE, auth.PRIV_QUERY_INSERT, auth.PRIV_QUERY_DELETE},
+	"data_reader":        []auth.Privilege{auth.PRIV_READ},
+	"data_reader_writer": []auth.Privilege{auth.PRIV_READ, auth.PRIV_WRITE},
+	"query_select":       []auth.Privilege{auth.PRIV_QUERY_SELECT},
+	"query_update":       []auth.Privilege{auth.PRIV_QUERY_UPDATE},
+	"query_insert":       []auth.Privilege{auth.PRIV_QUERY_INSERT},
+	"query_delete":       []auth.Privilege{auth.PRIV_QUERY_DELETE},
+	"query_manage_index": []auth.Privilege{auth.PRIV_QUERY_BUILD_INDEX, auth.PRIV_QUERY_CREATE_INDEX,
+		auth.PRIV_QUERY_ALTER_INDEX, auth.PRIV_QUERY_DROP_INDEX, auth.PRIV_QUERY_LIST_INDEX},
+}
+
+// GetRolesAll returns the roles understood by Authorize, keyspace
+// roles having "*" as their bucket, sorted by name.
+func GetRolesAll() []datastore.Role {
+	roles := make([]datastore.Role, 0, len(_SYSTEM_ROLES)+len(_KEYSPACE_ROLES))
+	for name := range _SYSTEM_ROLES {
+		roles = append(roles, datastore.Role{Name: name})
+	}
+	for name := range _KEYSPACE_ROLES {
+		roles = append(roles, datastore.Role{Name: name, Bucket: "*"})
+	}
+	sort.Sort(rolesByName(roles))
+	return roles
+}
+
+type rolesByName []datastore.Role
+
+func (this rolesByName) Len() int           { return len(this) }
+func (this rolesByName) Less(i, j int) bool { return this[i].Name < this[j].Name }
+func (this rolesByName) Swap(i, j int)      { this[i], this[j] = this[j], this[i] }
+
+type fileRole struct {
+	Role       string `json:"role"`
+	BucketName string `json:"bucket_name,omitempty"`
+}
+
+type fileUser struct {
+	Id       string     `json:"id"`
+	Domain   string     `json:"domain"`
+	Name     string     `json:"name,omitempty"`
+	Password string     `json:"password,omitempty"`
+	Roles    []fileRole `json:"roles"`
+}
+
+func (this *fileUser) key() string {
+	return this.Domain + ":" + this.Id
+}
+
+// Users holds the contents of a users file. Changes to the roles
+// of users are written back to the file.
+type Users struct {
+	sync.RWMutex
+	path  string
+	users map[string]*fileUser // by domain:id
+	order []string             // as in the file
+
+	// the passwords checked, as keyed digests of hash and password, by domain:id
+	verifiedLock sync.Mutex
+	verified     map[string]string
+	secret       []byte
+}
+
+func LoadUsers(path string) (*Users, errors.Error) {
+	data, er := ioutil.ReadFile(path)
+	if er != nil {
+		return nil, errors.NewOtherDatastoreError(er, "reading users file "+path)
+	}
+
+	var list []*fileUser
+	er = json.Unmarshal(data, &list)
+	if er != nil {
+		return nil, errors.NewOtherDatastoreError(er, "parsing users file "+path)
+	}
+
+	rv := &Users{path: path, users: make(map[string]*fileUser, len(list)), order: make([]string, 0, len(list)),
+		verified: make(map[string]string, len(list)), secret: make([]byte, 32)}
+	_, er = rand.Read(rv.secret)
+	if er != nil {
+		return nil, errors.NewOtherDatastoreError(er, "generating users secret")
+	}
+	for _, u := range list {
+		if u.Id == "" {
+			return nil, errors.NewOtherDatastoreError(nil, "users file "+path+": user with no id")
+		}
+		if u.Domain == "" {
+			u.Domain = _DEFAULT_DOMAIN
+		}
+		for i, r := range u.Roles {
+			u.Roles[i].Role = auth.NormalizeRoleNames([]string{r.Role})[0]
+			if _SYSTEM_ROLES[u.Roles[i].Role] == nil && _KEYSPACE_ROLES[u.Roles[i].Role] == nil {
+				logging.Warnp("Users file: unknown role", logging.Pair{"user", u.Id}, logging.Pair{"role", r.Role})
+			}
+		}
+		key := u.key()
+		if _, ok := rv.users[key]; ok {
+			return nil, errors.NewOtherDatastoreError(nil, "users file "+path+": duplicate user "+key)
+		}
+		rv.users[key] = u
+		rv.order = append(rv.order, key)
+	}
+	return rv, nil
+}
+
+// Authorize checks the credentials against the users file, and the
+// privileges against the roles of the users authenticated. A user
+// authenticated by the query service, from a bearer token or a client
+// certificate, has the roles the token carries, or else those it has
+// in the file.
+// Users are looked up under the lock, and their passwords checked
+// outside of it. Users are replaced rather than modified, so those
+// found stay valid.
+func (this *Users) Authorize(privileges *auth.Privileges, credentials auth.Credentials,
+	identity *auth.Identity) (auth.AuthenticatedUsers, errors.Error) {
+	keys := make([]string, 0, len(credentials))
+	found := make([]*fileUser, 0, len(credentials))
+	passwords := make([]string, 0, len(credentials))
+	var identified *fileUser
+
+	this.RLock()
+	for username, password := range credentials {
+		key := username
+		if !strings.Contains(key, ":") {
+			key = _DEFAULT_DOMAIN + ":" + key
+		}
+		u, ok := this.users[key]
+		if !ok {
+			logging.Debugf("Unable to authenticate %s.", username)
+			continue
+		}
+		keys = append(keys, key)
+		found = append(found, u)
+		passwords = append(passwords, password)
+	}
+	if identity != nil {
+		identified = identityUser(identity)
+		if identified == nil {
+			identified = this.users[identity.User]
+		}
+	}
+	this.RUnlock()
+
+	authenticated := make(auth.AuthenticatedUsers, 0, len(credentials)+1)
+	users := make([]*fileUser, 0, len(credentials)+1)
+	for i, u := range found {
+		if !this.checkPassword(keys[i], u, passwords[i]) {
+			logging.Debugf("Unable to authenticate %s.", keys[i])
+			continue
+		}
+		authenticated = append(authenticated, keys[i])
+		users = append(users, u)
+	}
+
+	if identity != nil {
+		if identified != nil {
+			authenticated = append(authenticated, identity.User)
+			users = append(users, identified)
+		} else {
+			logging.Debugf("Unable to find roles for %s.", identity.User)
+		}
+	}
+
+	return authorize(privileges, authenticated, users)
+}
+
+// checks the password of a user, hashing it only if it was not
+// successfully checked before
+func (this *Users) checkPassword(key string, u *fileUser, password string) bool {
+	mac := hmac.New(sha256.New, this.secret)
+	mac.Write([]byte(u.Password))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	digest := string(mac.Sum(nil))
+
+	this.verifiedLock.Lock()
+	verified, ok := this.verified[key]
+	this.verifiedLock.Unlock()
+	if ok && hmac.Equal([]byte(verified), []byte(digest)) {
+		return true
+	}
+
+	if !checkPassword(u.Password, password) {
+		return false
+	}
+	this.verifiedLock.Lock()
+	this.verified[key] = digest
+	this.verifiedLock.Unlock()
+	return true
+}
+
+// AuthorizeIdentity checks the privileges against the roles carried by the
+// token of a user authenticated by the query service, for datastores that
+// do not know of the user.
+func AuthorizeIdentity(privileges *auth.Privileges, identity *auth.Identity) (auth.AuthenticatedUsers, errors.Error) {
+	u := identityUser(identity)
+	if u == nil {
+		u = &fileUser{}
+	}
+	return authorize(privileges, auth.AuthenticatedUsers{identity.User}, []*fileUser{u})
+}
+
+// nil if the identity carries no roles
+func identityUser(identity *auth.Identity) *fileUser {
+	if identity.Roles == nil {
+		return nil
+	}
+	rv := &fileUser{Roles: make([]fileRole, len(identity.Roles))}
+	for i, r := range identity.Roles {
+		rv.Roles[i].Role, rv.Roles[i].BucketName = auth.ParseRole(r)
+	}
+	return rv
+}
+
+func authorize(privileges *auth.Privileges, authenticated auth.AuthenticatedUsers,
+	users []*fileUser) (auth.AuthenticatedUsers, errors.Error) {
+	if privileges == nil {
+		return authenticated, nil
+	}
+	for _, pair := range privileges.List {
+		granted := false
+		for _, u := range users {
+			if u.grants(pair) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return nil, errors.NewDatastoreInsufficientCredentials(messageForDeniedPrivilege(pair))
+		}
+	}
+	return authenticated, nil
+}
+
+func (this *fileUser) grants(pair auth.PrivilegePair) bool {
+	keyspace := pair.Target
+	if i := strings.LastIndex(keyspace, ":"); i >= 0 {
+		if keyspace[:i] == "#system" {
+			keyspace = ""
+		} else {
+			keyspace = keyspace[i+1:]
+		}
+	}
+
+	for _, r := range this.Roles {
+		privs, ok := _SYSTEM_ROLES[r.Role]
+		if !ok {
+			if keyspace == "" || (r.BucketName != "*" && r.BucketName != keyspace) {
+				continue
+			}
+			privs = _KEYSPACE_ROLES[r.Role]
+		}
+		for _, p := range privs {
+			if p == pair.Priv {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var _PRIVILEGE_NAMES = map[auth.Privilege]string{
+	auth.PRIV_READ:                  "data read queries",
+	auth.PRIV_WRITE:                 "data write queries",
+	auth.PRIV_SYSTEM_READ:           "queries accessing the system tables",
+	auth.PRIV_SECURITY_READ:         "queries accessing user information",
+	auth.PRIV_SECURITY_WRITE:        "queries updating user information",
+	auth.PRIV_QUERY_SELECT:          "SELECT queries",
+	auth.PRIV_QUERY_UPDATE:          "UPDATE queries",
+	auth.PRIV_QUERY_INSERT:          "INSERT queries",
+	auth.PRIV_QUERY_DELETE:          "DELETE queries",
+	auth.PRIV_QUERY_BUILD_INDEX:     "index operations",
+	auth.PRIV_QUERY_CREATE_INDEX:    "index operations",
+	auth.PRIV_QUERY_ALTER_INDEX:     "index operations",
+	auth.PRIV_QUERY_DROP_INDEX:      "index operations",
+	auth.PRIV_QUERY_LIST_INDEX:      "index operations",
+	auth.PRIV_QUERY_EXTERNAL_ACCESS: "queries using the CURL() function",
+}
+
+func messageForDeniedPrivilege(pair auth.PrivilegePair) string {
+	privilege, ok := _PRIVILEGE_NAMES[pair.Priv]
+	if !ok {
+		privilege = "this type of query"
+	}
+	if pair.Target == "" {
+		return fmt.Sprintf("User does not have credentials to run %s.", privilege)
+	}
+	return fmt.Sprintf("User does not have credentials to run %s on %s.", privilege, pair.Target)
+}
+
+func checkPassword(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$scrypt$"):
+		fields := strings.Split(hash, "$")
+		if len(fields) != 7 {
+			return false
+		}
+		n, err1 := strconv.Atoi(fields[2])
+		r, err2 := strconv.Atoi(fields[3])
+		p, err3 := strconv.Atoi(fields[4])
+		salt, err4 := base64.StdEncoding.DecodeString(fields[5])
+		key, err5 := base64.StdEncoding.DecodeString(fields[6])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+			return false
+		}
+		derived, err := scrypt.Key([]byte(password), salt, n, r, p, len(key))
+		return err == nil && subtle.ConstantTimeCompare(derived, key) == 1
+	}
+
+	// no clear text passwords
+	return false
+}
+
+// UserInfo returns the users in the format of system:user_info
+func (this *Users) UserInfo() (value.Value, errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+
+	data := make([]interface{}, 0, len(this.order))
+	for _, key := range this.order {
+		u := this.users[key]
+		roles := make([]interface{}, len(u.Roles))
+		for i, r := range u.Roles {
+			role := map[string]interface{}{"role": r.Role}
+			if r.BucketName != "" {
+				role["bucket_name"] = r.BucketName
+			}
+			roles[i] = role
+		}
+		data = append(data, map[string]interface{}{
+			"id":     u.Id,
+			"domain": u.Domain,
+			"name":   u.Name,
+			"roles":  roles,
+		})
+	}
+	return value.NewValue(data), nil
+}
+
+func (this *Users) GetUserInfoAll() ([]datastore.User, errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+
+	rv := make([]datastore.User, 0, len(this.order))
+	for _, key := range this.order {
+		u := this.users[key]
+		roles := make([]datastore.Role, len(u.Roles))
+		for i, r := range u.Roles {
+			roles[i] = datastore.Role{Name: r.Role, Bucket: r.BucketName}
+		}
+		rv = append(rv, datastore.User{Name: u.Name, Id: u.Id, Domain: u.Domain, Roles: roles})
+	}
+	return rv, nil
+}
+
+// PutUserInfo replaces the roles of a user and saves the users file.
+// Users not in the file are added with no password, and so cannot
+// authenticate until one is set in the file.
+func (this *Users) PutUserInfo(u *datastore.User) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+
+	domain := u.Domain
+	if domain == "" {
+		domain = _DEFAULT_DOMAIN
+	}
+	key := domain + ":" + u.Id
+	old, ok := this.users[key]
+	user := &fileUser{Id: u.Id, Domain: domain, Name: u.Name, Roles: make([]fileRole, len(u.Roles))}
+	if ok {
+		user.Password = old.Password
+	}
+	for i, r := range u.Roles {
+		user.Roles[i] = fileRole{Role: r.Name, BucketName: r.Bucket}
+	}
+
+	this.users[key] = user
+	if !ok {
+		this.order = append(this.order, key)
+	}
+	this.verifiedLock.Lock()
+	delete(this.verified, key)
+	this.verifiedLock.Unlock()
+	err := this.save()
+	if err != nil {
+		if ok {
+			this.users[key] = old
+		} else {
+			delete(this.users, key)
+			this.order = this.order[:len(this.order)-1]
+		}
+		return errors.NewSystemUnableToUpdateError(err)
+	}
+	return nil
+}
+
+// the file is replaced, so that a failure leaves it as it was
+func (this *Users) save() error {
+	list := make([]*fileUser, 0, len(this.order))
+	for _, key := range this.order {
+		list = append(list, this.users[key])
+	}
+	data, err := json.MarshalIndent(list, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(this.path), ".users")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), this.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
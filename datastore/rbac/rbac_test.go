@@ -0,0 +1,234 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package rbac
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+func writeUsers(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "rbac")
+	if err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+
+	bhash, _ := bcrypt.GenerateFromPassword([]byte("joepwd"), bcrypt.MinCost)
+	salt := []byte("saltsalt")
+	key, _ := scrypt.Key([]byte("annpwd"), salt, 1024, 8, 1, 32)
+	shash := fmt.Sprintf("$scrypt$1024$8$1$%s$%s", base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(key))
+
+	content := fmt.Sprintf(`[
+	{"id":"joe","name":"Joe","password":%q,
+	 "roles":[{"role":"select","bucket_name":"orders"},{"role":"query_system_catalog"}]},
+	{"id":"ann","domain":"local","password":%q,
+	 "roles":[{"role":"bucket_full_access","bucket_name":"*"}]},
+	{"id":"bob","password":"bobpwd","roles":[{"role":"admin"}]}
+]`, bhash, shash)
+
+	path := filepath.Join(dir, "users.json")
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("unable to write users: %v", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestAuthorize(t *testing.T) {
+	path, cleanup := writeUsers(t)
+	defer cleanup()
+
+	users, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("unable to load users: %v", err)
+	}
+
+	cases := []struct {
+		creds   auth.Credentials
+		target  string
+		priv    auth.Privilege
+		granted bool
+	}{
+		{auth.Credentials{"joe": "joepwd"}, "default:orders", auth.PRIV_QUERY_SELECT, true},
+		{auth.Credentials{"local:joe": "joepwd"}, "orders", auth.PRIV_QUERY_SELECT, true},
+		{auth.Credentials{"joe": "joepwd"}, "default:orders", auth.PRIV_QUERY_INSERT, false},
+		{auth.Credentials{"joe": "joepwd"}, "default:customers", auth.PRIV_QUERY_SELECT, false},
+		{auth.Credentials{"joe": "joepwd"}, "#system:active_requests", auth.PRIV_SYSTEM_READ, true},
+		{auth.Credentials{"joe": "joepwd"}, "", auth.PRIV_SECURITY_WRITE, false},
+		{auth.Credentials{"joe": "wrong"}, "default:orders", auth.PRIV_QUERY_SELECT, false},
+		{auth.Credentials{"ann": "annpwd"}, "default:customers", auth.PRIV_QUERY_DELETE, true},
+		{auth.Credentials{"ann": "annpwd"}, "#system:active_requests", auth.PRIV_SYSTEM_READ, false},
+		{auth.Credentials{"ann": "annpwd", "joe": "joepwd"}, "#system:active_requests", auth.PRIV_SYSTEM_READ, true},
+		{auth.Credentials{"bob": "bobpwd"}, "", auth.PRIV_SECURITY_WRITE, false}, // clear text passwords
+		{nil, "default:orders", auth.PRIV_QUERY_SELECT, false},
+	}
+
+	for i, c := range cases {
+		privs := auth.NewPrivileges()
+		privs.Add(c.target, c.priv)
+		_, err := users.Authorize(privs, c.creds, nil)
+		if (err == nil) != c.granted {
+			t.Errorf("case %d: expected granted %v, found error %v", i, c.granted, err)
+		}
+	}
+
+	authUsers, err := users.Authorize(nil, auth.Credentials{"joe": "joepwd", "ann": "wrong"}, nil)
+	if err != nil || len(authUsers) != 1 || authUsers[0] != "local:joe" {
+		t.Errorf("expected local:joe to be authenticated, found %v, error %v", authUsers, err)
+	}
+}
+
+func TestAuthorizeIdentity(t *testing.T) {
+	path, cleanup := writeUsers(t)
+	defer cleanup()
+
+	users, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("unable to load users: %v", err)
+	}
+
+	cases := []struct {
+		identity *auth.Identity
+		target   string
+		priv     auth.Privilege
+		granted  bool
+	}{
+		// roles from the token
+		{&auth.Identity{User: "external:eve", Roles: []string{"select[orders]"}}, "default:orders", auth.PRIV_QUERY_SELECT, true},
+		{&auth.Identity{User: "external:eve", Roles: []string{"select[orders]"}}, "default:customers", auth.PRIV_QUERY_SELECT, false},
+		{&auth.Identity{User: "local:joe", Roles: []string{}}, "default:orders", auth.PRIV_QUERY_SELECT, false},
+
+		// or from the file
+		{&auth.Identity{User: "local:joe"}, "default:orders", auth.PRIV_QUERY_SELECT, true},
+		{&auth.Identity{User: "external:eve"}, "default:orders", auth.PRIV_QUERY_SELECT, false},
+	}
+
+	for i, c := range cases {
+		privs := auth.NewPrivileges()
+		privs.Add(c.target, c.priv)
+		authUsers, err := users.Authorize(privs, nil, c.identity)
+		if (err == nil) != c.granted {
+			t.Errorf("case %d: expected granted %v, found error %v", i, c.granted, err)
+		} else if c.granted && (len(authUsers) != 1 || authUsers[0] != c.identity.User) {
+			t.Errorf("case %d: expected %v to be authenticated, found %v", i, c.identity.User, authUsers)
+		}
+	}
+
+	privs := auth.NewPrivileges()
+	privs.Add("default:customers", auth.PRIV_QUERY_DELETE)
+	_, err = AuthorizeIdentity(privs, &auth.Identity{User: "external:eve", Roles: []string{"bucket_full_access[*]"}})
+	if err != nil {
+		t.Errorf("expected delete to be granted, found %v", err)
+	}
+	_, err = AuthorizeIdentity(privs, &auth.Identity{User: "external:eve"})
+	if err == nil {
+		t.Errorf("expected delete to be denied without roles")
+	}
+}
+
+func TestPutUserInfo(t *testing.T) {
+	path, cleanup := writeUsers(t)
+	defer cleanup()
+
+	users, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("unable to load users: %v", err)
+	}
+
+	// role aliases are stored in their long forms
+	all, _ := users.GetUserInfoAll()
+	if all[0].Id != "joe" || all[0].Roles[0].Name != "query_select" {
+		t.Fatalf("unexpected users %v", all)
+	}
+
+	joe := all[0]
+	joe.Roles = append(joe.Roles, datastore.Role{Name: "query_insert", Bucket: "orders"})
+	err = users.PutUserInfo(&joe)
+	if err != nil {
+		t.Fatalf("unable to update user: %v", err)
+	}
+
+	// changes survive a reload, and passwords are kept
+	users, err = LoadUsers(path)
+	if err != nil {
+		t.Fatalf("unable to reload users: %v", err)
+	}
+	privs := auth.NewPrivileges()
+	privs.Add("default:orders", auth.PRIV_QUERY_INSERT)
+	_, err = users.Authorize(privs, auth.Credentials{"joe": "joepwd"}, nil)
+	if err != nil {
+		t.Errorf("expected insert to be granted after update, found %v", err)
+	}
+
+	info, _ := users.UserInfo()
+	if l := len(info.Actual().([]interface{})); l != 3 {
+		t.Errorf("expected 3 users, found %v", l)
+	}
+}
+
+func TestAuthorizeVerified(t *testing.T) {
+	path, cleanup := writeUsers(t)
+	defer cleanup()
+
+	users, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("unable to load users: %v", err)
+	}
+
+	privs := auth.NewPrivileges()
+	privs.Add("default:orders", auth.PRIV_QUERY_SELECT)
+	for i := 0; i < 2; i++ {
+		_, err = users.Authorize(privs, auth.Credentials{"joe": "joepwd"}, nil)
+		if err != nil {
+			t.Fatalf("expected select to be granted, found %v", err)
+		}
+	}
+	if _, ok := users.verified["local:joe"]; !ok {
+		t.Fatalf("expected the password check to be remembered")
+	}
+
+	// a remembered check does not let other passwords in
+	_, err = users.Authorize(privs, auth.Credentials{"joe": "wrong"}, nil)
+	if err == nil {
+		t.Errorf("expected a wrong password to be denied")
+	}
+
+	// and is forgotten when the user changes
+	all, _ := users.GetUserInfoAll()
+	err = users.PutUserInfo(&all[0])
+	if err != nil {
+		t.Fatalf("unable to update user: %v", err)
+	}
+	if _, ok := users.verified["local:joe"]; ok {
+		t.Errorf("expected the password check to be forgotten")
+	}
+}
+
+func TestGetRolesAll(t *testing.T) {
+	roles := GetRolesAll()
+	if len(roles) != len(_SYSTEM_ROLES)+len(_KEYSPACE_ROLES) {
+		t.Fatalf("expected %v roles, found %v", len(_SYSTEM_ROLES)+len(_KEYSPACE_ROLES), len(roles))
+	}
+	for i := 1; i < len(roles); i++ {
+		if roles[i-1].Name >= roles[i].Name {
+			t.Errorf("roles not sorted: %v before %v", roles[i-1].Name, roles[i].Name)
+		}
+	}
+}
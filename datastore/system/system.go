@@ -30,11 +30,13 @@ const KEYSPACE_NAME_INDEXES = "indexes"
 const KEYSPACE_NAME_DUAL = "dual"
 const KEYSPACE_NAME_PREPAREDS = "prepareds"
 const KEYSPACE_NAME_REQUESTS = "completed_requests"
+const KEYSPACE_NAME_REQUESTS_HISTORY = "completed_requests_history"
 const KEYSPACE_NAME_ACTIVE = "active_requests"
 const KEYSPACE_NAME_USER_INFO = "user_info"
 const KEYSPACE_NAME_MY_USER_INFO = "my_user_info"
 const KEYSPACE_NAME_NODES = "nodes"
 const KEYSPACE_NAME_APPLICABLE_ROLES = "applicable_roles"
+const KEYSPACE_NAME_RATE_LIMITS = "rate_limits"
 
 // TODO, sync with fetch timeout
 const scanTimeout = 30 * time.Second
@@ -125,6 +125,11 @@ func (b *activeRequestsKeyspace) Fetch(keys []string, context datastore.QueryCon
 				if cId != "" {
 					item.SetField("clientContextID", cId)
 				}
+				class := request.WorkloadClass()
+				if class != "" {
+					item.SetField("workloadClass", class)
+					item.SetField("queueTime", request.QueueTime().String())
+				}
 				if request.Statement() != "" {
 					item.SetField("statement", request.Statement())
 				}
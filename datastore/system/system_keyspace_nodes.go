@@ -11,6 +11,7 @@ package system
 
 import (
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/distributed"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/timestamp"
@@ -38,10 +39,37 @@ func (b *nodeKeyspace) Name() string {
 	return b.name
 }
 
+// standalone datastores know no topology, but the query nodes may
+func (b *nodeKeyspace) peers() distributed.NodeServices {
+	info := b.namespace.store.actualStore.Info()
+	if info != nil {
+		topology, errs := info.Topology()
+		if len(topology) > 0 || len(errs) > 0 {
+			return nil
+		}
+	}
+	peers, _ := distributed.RemoteAccess().(distributed.NodeServices)
+	return peers
+}
+
+func (b *nodeKeyspace) topology() ([]string, []errors.Error) {
+	if b.peers() != nil {
+		return distributed.RemoteAccess().GetNodeNames(), nil
+	}
+	return b.namespace.store.actualStore.Info().Topology()
+}
+
+func (b *nodeKeyspace) services(node string) (map[string]interface{}, []errors.Error) {
+	if peers := b.peers(); peers != nil {
+		return peers.NodeServices(node), nil
+	}
+	return b.namespace.store.actualStore.Info().Services(node)
+}
+
 func (b *nodeKeyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
 	var err errors.Error
 
-	topology, errs := b.namespace.store.actualStore.Info().Topology()
+	topology, errs := b.topology()
 	if errs != nil {
 		err = errs[0]
 	}
@@ -59,11 +87,10 @@ func (b *nodeKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
 func (b *nodeKeyspace) Fetch(keys []string, context datastore.QueryContext) ([]value.AnnotatedPair, []errors.Error) {
 	var errs []errors.Error
 	rv := make([]value.AnnotatedPair, 0, len(keys))
-	info := b.namespace.store.actualStore.Info()
 
 	for _, k := range keys {
 
-		nodeServices, errList := info.Services(k)
+		nodeServices, errList := b.services(k)
 
 		if nodeServices != nil {
 			item := value.NewAnnotatedValue(nodeServices)
@@ -190,8 +217,7 @@ func (pi *nodeIndex) Scan(requestId string, span *datastore.Span, distinct bool,
 			conn.Error(err)
 			return
 		}
-		info := pi.keyspace.namespace.store.actualStore.Info()
-		topology, errs := info.Topology()
+		topology, errs := pi.keyspace.topology()
 		for _, key := range topology {
 			if spanEvaluator.evaluate(key) {
 				entry := datastore.IndexEntry{PrimaryKey: key}
@@ -215,8 +241,7 @@ func (pi *nodeIndex) ScanEntries(requestId string, limit int64, cons datastore.S
 	var numProduced int64 = 0
 
 	defer close(conn.EntryChannel())
-	info := pi.keyspace.namespace.store.actualStore.Info()
-	topology, errs := info.Topology()
+	topology, errs := pi.keyspace.topology()
 	for _, key := range topology {
 		entry := datastore.IndexEntry{PrimaryKey: key}
 		if !sendSystemKey(conn, &entry) {
@@ -111,6 +111,12 @@ func (b *preparedsKeyspace) Fetch(keys []string, context datastore.QueryContext)
 				if node != "" {
 					itemMap["node"] = node
 				}
+				if entry.State != "" {
+					itemMap["state"] = entry.State
+					if entry.ReloadError != nil {
+						itemMap["error"] = entry.ReloadError.Error()
+					}
+				}
 				if entry.Uses > 0 {
 					itemMap["lastUse"] = entry.LastUse.String()
 					itemMap["avgElapsedTime"] = (time.Duration(entry.RequestTime) /
@@ -0,0 +1,200 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type rateLimitsKeyspace struct {
+	namespace *namespace
+	name      string
+	si        datastore.Indexer
+}
+
+func (b *rateLimitsKeyspace) Release() {
+}
+
+func (b *rateLimitsKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *rateLimitsKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *rateLimitsKeyspace) Name() string {
+	return b.name
+}
+
+func (b *rateLimitsKeyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
+	return int64(server.RateLimitsCount()), nil
+}
+
+func (b *rateLimitsKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.si, nil
+}
+
+func (b *rateLimitsKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.si}, nil
+}
+
+func (b *rateLimitsKeyspace) Fetch(keys []string, context datastore.QueryContext) ([]value.AnnotatedPair, []errors.Error) {
+	var errs []errors.Error
+	rv := make([]value.AnnotatedPair, 0, len(keys))
+
+	for _, k := range keys {
+		state := server.RateLimitsGet(k)
+		if state == nil {
+			continue
+		}
+
+		item := value.NewAnnotatedValue(state)
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+		rv = append(rv, value.AnnotatedPair{
+			Name:  k,
+			Value: item,
+		})
+	}
+
+	return rv, errs
+}
+
+func (b *rateLimitsKeyspace) Insert(inserts []value.Pair) ([]value.Pair, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func (b *rateLimitsKeyspace) Update(updates []value.Pair) ([]value.Pair, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func (b *rateLimitsKeyspace) Upsert(upserts []value.Pair) ([]value.Pair, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func (b *rateLimitsKeyspace) Delete(deletes []string, context datastore.QueryContext) ([]string, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func newRateLimitsKeyspace(p *namespace) (*rateLimitsKeyspace, errors.Error) {
+	b := new(rateLimitsKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_RATE_LIMITS
+
+	primary := &rateLimitsIndex{name: "#primary", keyspace: b}
+	b.si = newSystemIndexer(b, primary)
+
+	return b, nil
+}
+
+type rateLimitsIndex struct {
+	name     string
+	keyspace *rateLimitsKeyspace
+}
+
+func (pi *rateLimitsIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *rateLimitsIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *rateLimitsIndex) Name() string {
+	return pi.name
+}
+
+func (pi *rateLimitsIndex) Type() datastore.IndexType {
+	return datastore.SYSTEM
+}
+
+func (pi *rateLimitsIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *rateLimitsIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *rateLimitsIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *rateLimitsIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *rateLimitsIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *rateLimitsIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *rateLimitsIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, pi.Name())
+}
+
+func (pi *rateLimitsIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	if span == nil {
+		pi.ScanEntries(requestId, limit, cons, vector, conn)
+	} else {
+		var numProduced int64 = 0
+
+		defer close(conn.EntryChannel())
+		spanEvaluator, err := compileSpan(span)
+		if err != nil {
+			conn.Error(err)
+			return
+		}
+		server.RateLimitsForEach(func(key string) bool {
+			if spanEvaluator.evaluate(key) {
+				entry := datastore.IndexEntry{PrimaryKey: key}
+				if !sendSystemKey(conn, &entry) {
+					return false
+				}
+				numProduced++
+				if limit > 0 && numProduced >= limit {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (pi *rateLimitsIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	var numProduced int64 = 0
+
+	defer close(conn.EntryChannel())
+	server.RateLimitsForEach(func(key string) bool {
+		entry := datastore.IndexEntry{PrimaryKey: key}
+		if !sendSystemKey(conn, &entry) {
+			return false
+		}
+		numProduced++
+		return limit <= 0 || numProduced < limit
+	})
+}
@@ -0,0 +1,232 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type requestHistoryKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *requestHistoryKeyspace) Release() {
+}
+
+func (b *requestHistoryKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *requestHistoryKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *requestHistoryKeyspace) Name() string {
+	return b.name
+}
+
+func (b *requestHistoryKeyspace) Count(context datastore.QueryContext) (int64, errors.Error) {
+	var count int
+
+	count = 0
+	distributed.RemoteAccess().GetRemoteKeys([]string{}, KEYSPACE_NAME_REQUESTS_HISTORY, func(id string) bool {
+		count++
+		return true
+	}, func(warn errors.Error) {
+		context.Warning(warn)
+	})
+	return int64(server.RequestsHistoryCount() + count), nil
+}
+
+func (b *requestHistoryKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *requestHistoryKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *requestHistoryKeyspace) Fetch(keys []string, context datastore.QueryContext) ([]value.AnnotatedPair, []errors.Error) {
+	var errs []errors.Error
+	rv := make([]value.AnnotatedPair, 0, len(keys))
+
+	creds, authToken := credsFromContext(context)
+
+	// now that the node name can change in flight, use a consistent one across fetches
+	whoAmI := distributed.RemoteAccess().WhoAmI()
+	for _, key := range keys {
+		node, localKey := distributed.RemoteAccess().SplitKey(key)
+
+		// remote entry
+		if len(node) != 0 && node != whoAmI {
+			distributed.RemoteAccess().GetRemoteDoc(node, localKey,
+				KEYSPACE_NAME_REQUESTS_HISTORY, "GET",
+				func(doc map[string]interface{}) {
+					remoteValue := value.NewAnnotatedValue(doc)
+					remoteValue.SetField("node", node)
+					remoteValue.SetAttachment("meta", map[string]interface{}{
+						"id": key,
+					})
+					rv = append(rv, value.AnnotatedPair{
+						Name:  key,
+						Value: remoteValue,
+					})
+				},
+				func(warn errors.Error) {
+					context.Warning(warn)
+				},
+				creds, authToken)
+		} else {
+
+			// local entry, which may have been rotated away
+			doc, err := server.RequestsHistoryGet(localKey)
+			if err != nil {
+				continue
+			}
+			item := value.NewAnnotatedValue(doc)
+			if node != "" {
+				item.SetField("node", node)
+			}
+			item.SetAttachment("meta", map[string]interface{}{
+				"id": key,
+			})
+			rv = append(rv, value.AnnotatedPair{
+				Name:  key,
+				Value: item,
+			})
+		}
+	}
+	return rv, errs
+}
+
+func (b *requestHistoryKeyspace) Insert(inserts []value.Pair) ([]value.Pair, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func (b *requestHistoryKeyspace) Update(updates []value.Pair) ([]value.Pair, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func (b *requestHistoryKeyspace) Upsert(upserts []value.Pair) ([]value.Pair, errors.Error) {
+	// FIXME
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func (b *requestHistoryKeyspace) Delete(deletes []string, context datastore.QueryContext) ([]string, errors.Error) {
+	// the history only goes away through rotation
+	return nil, errors.NewSystemNotImplementedError(nil, "")
+}
+
+func newRequestsHistoryKeyspace(p *namespace) (*requestHistoryKeyspace, errors.Error) {
+	b := new(requestHistoryKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_REQUESTS_HISTORY
+
+	primary := &requestHistoryIndex{name: "#primary", keyspace: b}
+	b.indexer = newSystemIndexer(b, primary)
+
+	return b, nil
+}
+
+type requestHistoryIndex struct {
+	name     string
+	keyspace *requestHistoryKeyspace
+}
+
+func (pi *requestHistoryIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *requestHistoryIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *requestHistoryIndex) Name() string {
+	return pi.name
+}
+
+func (pi *requestHistoryIndex) Type() datastore.IndexType {
+	return datastore.SYSTEM
+}
+
+func (pi *requestHistoryIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *requestHistoryIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *requestHistoryIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *requestHistoryIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *requestHistoryIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *requestHistoryIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *requestHistoryIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "")
+}
+
+func (pi *requestHistoryIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	pi.ScanEntries(requestId, limit, cons, vector, conn)
+}
+
+func (pi *requestHistoryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	var numProduced int64 = 0
+
+	defer close(conn.EntryChannel())
+
+	// now that the node name can change in flight, use a consistent one across the scan
+	whoAmI := distributed.RemoteAccess().WhoAmI()
+	server.RequestsHistoryForeach(func(key string) bool {
+		entry := datastore.IndexEntry{PrimaryKey: distributed.RemoteAccess().MakeKey(whoAmI, key)}
+		if !sendSystemKey(conn, &entry) {
+			return false
+		}
+		numProduced++
+		return limit <= 0 || numProduced < limit
+	})
+	if limit > 0 && numProduced >= limit {
+		return
+	}
+	distributed.RemoteAccess().GetRemoteKeys([]string{}, KEYSPACE_NAME_REQUESTS_HISTORY, func(id string) bool {
+		indexEntry := datastore.IndexEntry{PrimaryKey: id}
+		if !sendSystemKey(conn, &indexEntry) {
+			return false
+		}
+		numProduced++
+		return limit <= 0 || numProduced < limit
+	}, func(warn errors.Error) {
+		conn.Warning(warn)
+	})
+}
@@ -120,6 +120,12 @@ func (p *namespace) loadKeyspaces() (e errors.Error) {
 	}
 	p.keyspaces[reqs.Name()] = reqs
 
+	history, e := newRequestsHistoryKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[history.Name()] = history
+
 	actives, e := newActiveRequestsKeyspace(p)
 	if e != nil {
 		return e
@@ -150,5 +156,11 @@ func (p *namespace) loadKeyspaces() (e errors.Error) {
 	}
 	p.keyspaces[applicableRoles.Name()] = applicableRoles
 
+	rateLimits, e := newRateLimitsKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[rateLimits.Name()] = rateLimits
+
 	return nil
 }
@@ -42,6 +42,14 @@ type SystemRemoteAccess interface {
 	GetNodeNames() []string
 }
 
+// Implemented by remote access that keeps track of the query nodes itself,
+// for datastores that know nothing of the cluster topology
+type NodeServices interface {
+
+	// the state and endpoints of a node, nil if not known
+	NodeServices(node string) map[string]interface{}
+}
+
 // It would be convenient to use datastore/Credentials here, but that causes an import circularity,
 // so we define an equivalent here.
 type Creds map[string]string
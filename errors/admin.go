@@ -158,3 +158,28 @@ func NewAdminBodyError(e error) Error {
 	return &err{level: EXCEPTION, ICode: 2220, IKey: "admin.accounting.bad_body", ICause: e,
 		InternalMsg: "Error getting request body", InternalCaller: CallerN(1)}
 }
+
+func NewCompletedHistoryError(e error, what string) Error {
+	return &err{level: EXCEPTION, ICode: 2230, IKey: "admin.accounting.completed_history", ICause: e,
+		InternalMsg: "Completed requests history error: " + what, InternalCaller: CallerN(1)}
+}
+
+func NewAdminGetMetadataError(e error, key string) Error {
+	return &err{level: EXCEPTION, ICode: 2240, IKey: "admin.clustering.get_metadata_error", ICause: e,
+		InternalMsg: "Error retrieving metadata " + key, InternalCaller: CallerN(1)}
+}
+
+func NewAdminSetMetadataError(e error, key string) Error {
+	return &err{level: EXCEPTION, ICode: 2250, IKey: "admin.clustering.set_metadata_error", ICause: e,
+		InternalMsg: "Error storing metadata " + key, InternalCaller: CallerN(1)}
+}
+
+func NewAuditFileError(e error, what string) Error {
+	return &err{level: EXCEPTION, ICode: 2260, IKey: "admin.audit.file", ICause: e,
+		InternalMsg: "Audit log error: " + what, InternalCaller: CallerN(1)}
+}
+
+func NewAdminConfigFileError(e error, what string) Error {
+	return &err{level: EXCEPTION, ICode: 2270, IKey: "admin.clustering.config_file", ICause: e,
+		InternalMsg: "Configuration file error: " + what, InternalCaller: CallerN(1)}
+}
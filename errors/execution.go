@@ -186,3 +186,61 @@ func NewUserWithNoRoles(user string) Error {
 		InternalMsg:    fmt.Sprintf("User %s has no roles. Connecting with this user may not be possible", user),
 		InternalCaller: CallerN(1)}
 }
+
+func NewPolicyExistsError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 5290, IKey: "execution.policy_exists",
+		InternalMsg: fmt.Sprintf("Policy %s already exists.", name), InternalCaller: CallerN(1)}
+}
+
+func NewPolicyNotFoundError(name string, keyspace string) Error {
+	return &err{level: EXCEPTION, ICode: 5300, IKey: "execution.policy_not_found",
+		InternalMsg: fmt.Sprintf("Policy %s not found on %s.", name, keyspace), InternalCaller: CallerN(1)}
+}
+
+func NewInvalidPolicyError(name string, reason string) Error {
+	return &err{level: EXCEPTION, ICode: 5310, IKey: "execution.invalid_policy",
+		InternalMsg: fmt.Sprintf("Invalid policy %s: %s", name, reason), InternalCaller: CallerN(1)}
+}
+
+func NewPolicyStoreError(e error, op string) Error {
+	return &err{level: EXCEPTION, ICode: 5320, IKey: "execution.policy_store_error", ICause: e,
+		InternalMsg: "Error " + op + " row security policies", InternalCaller: CallerN(1)}
+}
+
+func NewPolicyUnsupportedError(keyspace string, what string) Error {
+	return &err{level: EXCEPTION, ICode: 5330, IKey: "execution.policy_unsupported",
+		InternalMsg:    fmt.Sprintf("Row security policies on %s cannot be applied to %s.", keyspace, what),
+		InternalCaller: CallerN(1)}
+}
+
+func NewPolicyMismatchError() Error {
+	return &err{level: EXCEPTION, ICode: 5340, IKey: "execution.policy_mismatch",
+		InternalMsg:    "The row security policies or column masks applicable to this request have changed since it was planned - prepare it again.",
+		InternalCaller: CallerN(1)}
+}
+
+func NewMaskExistsError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 5350, IKey: "execution.mask_exists",
+		InternalMsg: fmt.Sprintf("Mask %s already exists.", name), InternalCaller: CallerN(1)}
+}
+
+func NewMaskNotFoundError(name string, keyspace string) Error {
+	return &err{level: EXCEPTION, ICode: 5360, IKey: "execution.mask_not_found",
+		InternalMsg: fmt.Sprintf("Mask %s not found on %s.", name, keyspace), InternalCaller: CallerN(1)}
+}
+
+func NewInvalidMaskError(name string, reason string) Error {
+	return &err{level: EXCEPTION, ICode: 5370, IKey: "execution.invalid_mask",
+		InternalMsg: fmt.Sprintf("Invalid mask %s: %s", name, reason), InternalCaller: CallerN(1)}
+}
+
+func NewMaskStoreError(e error, op string) Error {
+	return &err{level: EXCEPTION, ICode: 5380, IKey: "execution.mask_store_error", ICause: e,
+		InternalMsg: "Error " + op + " column masks", InternalCaller: CallerN(1)}
+}
+
+func NewMaskUnsupportedError(keyspace string, what string) Error {
+	return &err{level: EXCEPTION, ICode: 5390, IKey: "execution.mask_unsupported",
+		InternalMsg:    fmt.Sprintf("Column masks on %s cannot be applied to %s.", keyspace, what),
+		InternalCaller: CallerN(1)}
+}
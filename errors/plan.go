@@ -98,6 +98,21 @@ func NewUseKeysError(termType string, iKey string) Error {
 		InternalMsg: fmt.Sprintf("%s term should not have USE KEYS", termType), InternalCaller: CallerN(1)}
 }
 
+const PREPARED_PERSIST = 4120
+
+func NewPreparedPersistError(e error, name string) Error {
+	return &err{level: EXCEPTION, ICode: PREPARED_PERSIST, IKey: "plan.build_prepared.persist",
+		ICause: e, InternalMsg: fmt.Sprintf("Unable to persist prepared statement %s", name), InternalCaller: CallerN(1)}
+}
+
+const PREPARED_RELOAD = 4130
+
+func NewPreparedReloadError(name string, e error) Error {
+	return &err{level: EXCEPTION, ICode: PREPARED_RELOAD, IKey: "plan.build_prepared.reload",
+		ICause: e, InternalMsg: fmt.Sprintf("Prepared statement %s could not be prepared again after restart", name),
+		InternalCaller: CallerN(1)}
+}
+
 const NOT_GROUP_KEY_OR_AGG = 4210
 
 func NewNotGroupKeyOrAggError(expr string) Error {
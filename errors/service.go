@@ -108,3 +108,80 @@ func NewServiceErrorHttpMethod(method string) Error {
 	return &err{level: EXCEPTION, ICode: 1170, IKey: "service.io.request.method",
 		InternalMsg: fmt.Sprintf("Unsupported method %s", method), InternalCaller: CallerN(1)}
 }
+
+func NewServiceErrorAsyncLimit(limit int) Error {
+	return &err{level: EXCEPTION, ICode: 1180, IKey: "service.io.async.limit",
+		InternalMsg: fmt.Sprintf("Too many outstanding async requests (limit %d)", limit), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorAsyncSpool(e error) Error {
+	return &err{level: EXCEPTION, ICode: 1181, IKey: "service.io.async.spool", ICause: e,
+		InternalMsg: "Unable to spool async request results", InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorAsyncHandle(handle string) Error {
+	return &err{level: EXCEPTION, ICode: 1182, IKey: "service.io.async.handle",
+		InternalMsg: fmt.Sprintf("No async request with handle %s", handle), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorCursor(id string) Error {
+	return &err{level: EXCEPTION, ICode: 1183, IKey: "service.io.cursor",
+		InternalMsg: fmt.Sprintf("No open cursor %s", id), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorWorkloadClass(class string) Error {
+	return &err{level: EXCEPTION, ICode: 1184, IKey: "service.workload.class",
+		InternalMsg: fmt.Sprintf("Unknown workload class %s", class), InternalCaller: CallerN(1)}
+}
+
+// RetryAfter is the cause of errors for requests that may be resubmitted,
+// and tells how long clients should wait before doing so
+type RetryAfter time.Duration
+
+func (this RetryAfter) Error() string {
+	return fmt.Sprintf("retry after %v", time.Duration(this))
+}
+
+func NewServiceErrorRateLimit(limit, name, reason string, retry time.Duration) Error {
+	var cause error
+	if retry > 0 {
+		cause = RetryAfter(retry)
+	}
+	return &err{level: EXCEPTION, ICode: 1185, IKey: "service.ratelimit", ICause: cause,
+		InternalMsg: fmt.Sprintf("Request exceeds %s of %s %s", reason, limit, name), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorPgProtocol(msg string) Error {
+	return &err{level: EXCEPTION, ICode: 1186, IKey: "service.pgwire.protocol",
+		InternalMsg: fmt.Sprintf("PostgreSQL protocol error: %s", msg), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorPgAuthentication(user string) Error {
+	return &err{level: EXCEPTION, ICode: 1187, IKey: "service.pgwire.authentication",
+		InternalMsg: fmt.Sprintf("Password authentication failed for user %s", user), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorUnavailable() Error {
+	return &err{level: EXCEPTION, ICode: 1188, IKey: "service.unavailable",
+		InternalMsg: "Request queue full, retry later", InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorStopped() Error {
+	return &err{level: EXCEPTION, ICode: 1189, IKey: "service.request.stopped",
+		InternalMsg: "Request stopped before completion", InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorAuthentication(method, reason string) Error {
+	return &err{level: EXCEPTION, ICode: 1190, IKey: "service.authentication",
+		InternalMsg: fmt.Sprintf("Unable to authenticate with %s: %s", method, reason), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorOrigin(origin string) Error {
+	return &err{level: EXCEPTION, ICode: 1191, IKey: "service.websocket.origin",
+		InternalMsg: fmt.Sprintf("Origin %s is not allowed to open a websocket", origin), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorCursorLimit(limit int) Error {
+	return &err{level: EXCEPTION, ICode: 1192, IKey: "service.cursor.limit",
+		InternalMsg: fmt.Sprintf("Too many open cursors (limit %d)", limit), InternalCaller: CallerN(1)}
+}
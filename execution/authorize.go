@@ -14,6 +14,7 @@ import (
 
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
 	"github.com/couchbase/query/value"
 )
 
@@ -70,6 +71,22 @@ func (this *Authorize) RunOnce(context *Context, parent value.Value) {
 				return
 			}
 			context.authenticatedUsers = authenticatedUsers
+
+			err = policy.Verify(this.plan.RowPolicies(), this.plan.Privileges(), authenticatedUsers)
+			if err != nil {
+				context.Fatal(err)
+				this.close(context)
+				return
+			}
+		}
+
+		if context.admission != nil {
+			err := context.admission(context.authenticatedUsers, this.plan.Privileges())
+			if err != nil {
+				context.Fatal(err)
+				this.close(context)
+				return
+			}
 		}
 
 		this.switchPhase(_EXECTIME)
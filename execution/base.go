@@ -500,7 +500,7 @@ func (this *base) runConsumer(cons consumer, context *Context, parent value.Valu
 		defer func() { this.switchPhase(_NOTIME) }() // accrue current phase's time
 		if this.serialized == true {
 			ok := true
-			if !active || (context.Readonly() && !cons.readonly()) {
+			if !active || (context.Readonly() && !context.DryRun() && !cons.readonly()) {
 				ok = false
 			} else {
 				ok = cons.beforeItems(context, parent)
@@ -520,7 +520,7 @@ func (this *base) runConsumer(cons consumer, context *Context, parent value.Valu
 		defer this.notify() // Notify that I have stopped
 		defer func() { this.batch = nil }()
 
-		if !active || (context.Readonly() && !cons.readonly()) {
+		if !active || (context.Readonly() && !context.DryRun() && !cons.readonly()) {
 			return
 		}
 
@@ -943,7 +943,11 @@ func (this *base) marshalTimes(r map[string]interface{}) {
 		var versions []interface{}
 
 		versions = append(versions, util.VERSION)
-		versions = append(versions, datastore.GetDatastore().Info().Version())
+
+		// not all stores, such as mock:, report a version
+		if info := datastore.GetDatastore().Info(); info != nil {
+			versions = append(versions, info.Version())
+		}
 		r["~versions"] = versions
 	}
 }
@@ -520,6 +520,26 @@ func (this *builder) VisitRevokeRole(plan *plan.RevokeRole) (interface{}, error)
 	return NewRevokeRole(plan, this.context), nil
 }
 
+// CreatePolicy
+func (this *builder) VisitCreatePolicy(plan *plan.CreatePolicy) (interface{}, error) {
+	return NewCreatePolicy(plan, this.context), nil
+}
+
+// DropPolicy
+func (this *builder) VisitDropPolicy(plan *plan.DropPolicy) (interface{}, error) {
+	return NewDropPolicy(plan, this.context), nil
+}
+
+// CreateMask
+func (this *builder) VisitCreateMask(plan *plan.CreateMask) (interface{}, error) {
+	return NewCreateMask(plan, this.context), nil
+}
+
+// DropMask
+func (this *builder) VisitDropMask(plan *plan.DropMask) (interface{}, error) {
+	return NewDropMask(plan, this.context), nil
+}
+
 // CreateIndex
 func (this *builder) VisitCreateIndex(plan *plan.CreateIndex) (interface{}, error) {
 	return NewCreateIndex(plan, this.context), nil
@@ -547,7 +567,15 @@ func (this *builder) VisitPrepare(plan *plan.Prepare) (interface{}, error) {
 
 // Explain
 func (this *builder) VisitExplain(plan *plan.Explain) (interface{}, error) {
-	return NewExplain(plan, this.context), nil
+	if !plan.Analyze() {
+		return NewExplain(plan, this.context, nil), nil
+	}
+
+	operator, err := plan.Operator().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+	return NewExplain(plan, this.context, operator.(Operator)), nil
 }
 
 // Infer
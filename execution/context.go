@@ -25,6 +25,7 @@ import (
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/planner"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/value"
 )
 
@@ -136,9 +137,17 @@ type Context struct {
 	subresults         *subqueryMap
 	httpRequest        *http.Request
 	authenticatedUsers auth.AuthenticatedUsers
+	admission          Admission
+	traceSpan          *tracing.Span
+	dryRun             bool
 	mutex              sync.RWMutex
 }
 
+// Admission vets a request once its users have been authenticated,
+// and before anything is executed, returning an error if the request
+// cannot go ahead.
+type Admission func(users auth.AuthenticatedUsers, privileges *auth.Privileges) errors.Error
+
 func NewContext(requestId string, datastore, systemstore datastore.Datastore,
 	namespace string, readonly bool, maxParallelism int, scanCap, pipelineCap int64,
 	pipelineBatch int, namedArgs map[string]value.Value, positionalArgs value.Values,
@@ -272,6 +281,35 @@ func (this *Context) SetReqDeadline(reqDeadline time.Time) {
 	this.reqDeadline = reqDeadline
 }
 
+func (this *Context) SetAdmission(admission Admission) {
+	this.admission = admission
+}
+
+// the span under which the request executes, if traced
+func (this *Context) TraceSpan() *tracing.Span {
+	return this.traceSpan
+}
+
+func (this *Context) SetTraceSpan(span *tracing.Span) {
+	this.traceSpan = span
+}
+
+// For tracing.Carrier: datastores and curl() propagate this to the
+// services they call
+func (this *Context) TraceParent() string {
+	return this.traceSpan.TraceParent()
+}
+
+// In a dry run, as for EXPLAIN ANALYZE, statements go through all the
+// motions, but mutations are not applied
+func (this *Context) DryRun() bool {
+	return this.dryRun
+}
+
+func (this *Context) SetDryRun(dryRun bool) {
+	this.dryRun = dryRun
+}
+
 func (this *Context) GetPipelineCap() int64 {
 	if this.pipelineCap > 0 {
 		return this.pipelineCap
@@ -305,6 +343,9 @@ func (this *Context) SetPipelineBatch(pipelineBatch int) {
 }
 
 func (this *Context) AddMutationCount(i uint64) {
+	if this.dryRun {
+		return
+	}
 	this.output.AddMutationCount(i)
 }
 
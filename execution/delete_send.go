@@ -119,7 +119,11 @@ func (this *SendDelete) flushBatch(context *Context) bool {
 
 	this.switchPhase(_SERVTIME)
 
-	deleted_keys, e := this.plan.Keyspace().Delete(keys, context)
+	deleted_keys := keys
+	var e errors.Error
+	if !context.DryRun() {
+		deleted_keys, e = this.plan.Keyspace().Delete(keys, context)
+	}
 
 	this.switchPhase(_EXECTIME)
 
@@ -25,6 +25,10 @@ const _LARGE_CHILD_POOL = 64
 // In order to avoid deadlocks, any actor will just signal waiters as required, but manipulate
 // nothing bar changing whatever state is required.
 // It is the responsibility of the newly woken go routine to manipulate the queue as required.
+// Queues are bounded: a writer finding the queue full waits on the write waiters until a
+// reader makes room. This is what provides backpressure: a consumer that stops reading (say,
+// a request holding a cursor in between fetches) suspends every operator upstream, each
+// holding at most a queue's worth of values, until it resumes reading or a stop comes in.
 
 type opQueue struct {
 	next *operatorState
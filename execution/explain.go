@@ -19,25 +19,38 @@ import (
 
 type Explain struct {
 	base
-	plan plan.Operator
+	plan     *plan.Explain
+	analysis *Sequence
 }
 
-func NewExplain(plan plan.Operator, context *Context) *Explain {
+// for EXPLAIN ANALYZE, the operator is the execution tree of the statement,
+// which gets run, and its results discarded, before the plan is returned
+func NewExplain(plan *plan.Explain, context *Context, operator Operator) *Explain {
 	rv := &Explain{
 		plan: plan,
 	}
 
+	if operator != nil {
+		rv.analysis = newAnalysis(operator, context)
+	}
 	newRedirectBase(&rv.base)
 	rv.output = rv
 	return rv
 }
 
+func newAnalysis(operator Operator, context *Context) *Sequence {
+	return NewSequence(plan.NewSequence(), context, operator, NewDiscard(plan.NewDiscard(), context))
+}
+
 func (this *Explain) Accept(visitor Visitor) (interface{}, error) {
 	return visitor.VisitExplain(this)
 }
 
 func (this *Explain) Copy() Operator {
 	rv := &Explain{plan: this.plan}
+	if this.analysis != nil {
+		rv.analysis = this.analysis.Copy().(*Sequence)
+	}
 	this.base.copy(&rv.base)
 	return rv
 }
@@ -54,7 +67,13 @@ func (this *Explain) RunOnce(context *Context, parent value.Value) {
 			return
 		}
 
-		bytes, err := this.plan.MarshalJSON()
+		var bytes []byte
+		var err error
+		if this.analysis != nil {
+			bytes, err = this.analyze(context, parent)
+		} else {
+			bytes, err = this.plan.MarshalFormat()
+		}
 		if err != nil {
 			context.Fatal(errors.NewExplainError(err, "EXPLAIN: Error marshaling JSON."))
 			return
@@ -66,15 +85,46 @@ func (this *Explain) RunOnce(context *Context, parent value.Value) {
 	})
 }
 
+// Run the statement as a dry run, and return the plan as executed,
+// with each operator's items in and out, and execution, kernel and
+// service times
+func (this *Explain) analyze(context *Context, parent value.Value) ([]byte, error) {
+	context.SetDryRun(true)
+	this.switchPhase(_CHANTIME)
+	this.analysis.RunOnce(context, parent)
+	this.analysis.children[1].getBase().waitComplete()
+	this.switchPhase(_EXECTIME)
+
+	r := this.plan.MarshalBase(func(r map[string]interface{}) {
+		r["plan"] = this.analysis.children[0]
+	})
+	return json.Marshal(r)
+}
+
 func (this *Explain) MarshalJSON() ([]byte, error) {
 	r := this.plan.MarshalBase(func(r map[string]interface{}) {
 		this.marshalTimes(r)
-		r["plan"] = this.plan
+		if this.analysis != nil {
+			r["plan"] = this.analysis.children[0]
+		} else {
+			r["plan"] = this.plan
+		}
 	})
 	return json.Marshal(r)
 }
 
+func (this *Explain) SendStop() {
+	this.baseSendStop()
+	if this.analysis != nil {
+		this.analysis.SendStop()
+	}
+}
+
 func (this *Explain) Done() {
 	this.baseDone()
+	if this.analysis != nil {
+		this.analysis.Done()
+		this.analysis = nil
+	}
 	this.plan = nil
 }
@@ -11,9 +11,11 @@ package execution
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
 )
@@ -66,12 +68,16 @@ func (this *InferKeyspace) RunOnce(context *Context, parent value.Value) {
 		go infer.InferKeyspace(this.plan.Keyspace(), this.plan.Node().With(), conn)
 
 		var val value.Value
+		masks := this.plan.Node().Masks()
 
 		ok := true
 		for ok {
 			item, cont := this.getItemValue(conn.ValueChannel())
 			if item != nil && cont {
 				val = item.(value.Value)
+				if len(masks) > 0 {
+					val = maskSchema(val, masks)
+				}
 
 				// current policy is to only count 'in' documents
 				// from operators, not kv
@@ -95,3 +101,66 @@ func (this *InferKeyspace) MarshalJSON() ([]byte, error) {
 func (this *InferKeyspace) SendStop() {
 	this.chanSendStop()
 }
+
+/*
+Mask the samples of the masked fields in an inferred schema, and
+in the samples of the objects and arrays containing them. masks maps
+dotted paths, relative to the schema, to masking methods.
+*/
+func maskSchema(schema value.Value, masks map[string]string) value.Value {
+	switch schema.Type() {
+	case value.ARRAY:
+		elems := schema.Actual().([]interface{})
+		rv := make([]interface{}, len(elems))
+		for i, elem := range elems {
+			rv[i] = maskSchema(value.NewValue(elem), masks)
+		}
+		return value.NewValue(rv)
+	case value.OBJECT:
+	default:
+		return schema
+	}
+
+	rv := schema.Copy()
+	if samples, ok := schema.Field("samples"); ok && samples.Type() == value.ARRAY {
+		elems := samples.Actual().([]interface{})
+		masked := make([]interface{}, len(elems))
+		for i, elem := range elems {
+			sample := value.NewValue(elem)
+			for path, method := range masks {
+				var names []string
+				if path != "" {
+					names = strings.Split(path, ".")
+				}
+				sample = expression.RedactValue(sample, names, method)
+			}
+			masked[i] = sample
+		}
+		rv.SetField("samples", masked)
+	}
+
+	// array elements have the same paths as the array
+	if items, ok := schema.Field("items"); ok {
+		rv.SetField("items", maskSchema(items, masks))
+	}
+
+	if properties, ok := schema.Field("properties"); ok && properties.Type() == value.OBJECT {
+		masked := properties.Copy()
+		for name, property := range properties.Fields() {
+			inner := make(map[string]string, len(masks))
+			for path, method := range masks {
+				if path == name {
+					inner[""] = method
+				} else if strings.HasPrefix(path, name+".") {
+					inner[path[len(name)+1:]] = method
+				}
+			}
+			if len(inner) > 0 {
+				masked.SetField(name, maskSchema(value.NewValue(property), inner))
+			}
+		}
+		rv.SetField("properties", masked)
+	}
+
+	return rv
+}
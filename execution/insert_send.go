@@ -160,9 +160,11 @@ func (this *SendInsert) flushBatch(context *Context) bool {
 
 	this.switchPhase(_SERVTIME)
 
-	// Perform the actual INSERT
+	// Perform the actual INSERT, unless this is a dry run
 	var er errors.Error
-	dpairs, er = this.plan.Keyspace().Insert(dpairs)
+	if !context.DryRun() {
+		dpairs, er = this.plan.Keyspace().Insert(dpairs)
+	}
 
 	this.switchPhase(_EXECTIME)
 
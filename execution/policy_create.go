@@ -0,0 +1,71 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/value"
+)
+
+type CreatePolicy struct {
+	base
+	plan *plan.CreatePolicy
+}
+
+func NewCreatePolicy(plan *plan.CreatePolicy, context *Context) *CreatePolicy {
+	rv := &CreatePolicy{
+		plan: plan,
+	}
+
+	newRedirectBase(&rv.base)
+	rv.output = rv
+	return rv
+}
+
+func (this *CreatePolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreatePolicy(this)
+}
+
+func (this *CreatePolicy) Copy() Operator {
+	rv := &CreatePolicy{plan: this.plan}
+	this.base.copy(&rv.base)
+	return rv
+}
+
+func (this *CreatePolicy) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover() // Recover from any panic
+		this.active()
+		defer this.close(context)
+		this.switchPhase(_EXECTIME)
+		defer this.switchPhase(_NOTIME)
+		defer this.notify() // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		this.switchPhase(_SERVTIME)
+		err := policy.Create(policy.NewPolicy(this.plan.Node(), context.Namespace()))
+		if err != nil {
+			context.Error(err)
+		}
+	})
+}
+
+func (this *CreatePolicy) MarshalJSON() ([]byte, error) {
+	r := this.plan.MarshalBase(func(r map[string]interface{}) {
+		this.marshalTimes(r)
+	})
+	return json.Marshal(r)
+}
@@ -0,0 +1,76 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/value"
+)
+
+type DropPolicy struct {
+	base
+	plan *plan.DropPolicy
+}
+
+func NewDropPolicy(plan *plan.DropPolicy, context *Context) *DropPolicy {
+	rv := &DropPolicy{
+		plan: plan,
+	}
+
+	newRedirectBase(&rv.base)
+	rv.output = rv
+	return rv
+}
+
+func (this *DropPolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropPolicy(this)
+}
+
+func (this *DropPolicy) Copy() Operator {
+	rv := &DropPolicy{plan: this.plan}
+	this.base.copy(&rv.base)
+	return rv
+}
+
+func (this *DropPolicy) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover() // Recover from any panic
+		this.active()
+		defer this.close(context)
+		this.switchPhase(_EXECTIME)
+		defer this.switchPhase(_NOTIME)
+		defer this.notify() // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		this.switchPhase(_SERVTIME)
+		node := this.plan.Node()
+		namespace := node.Keyspace().Namespace()
+		if namespace == "" {
+			namespace = context.Namespace()
+		}
+		err := policy.Drop(namespace, node.Keyspace().Keyspace(), node.Name())
+		if err != nil {
+			context.Error(err)
+		}
+	})
+}
+
+func (this *DropPolicy) MarshalJSON() ([]byte, error) {
+	r := this.plan.MarshalBase(func(r map[string]interface{}) {
+		this.marshalTimes(r)
+	})
+	return json.Marshal(r)
+}
@@ -61,6 +61,8 @@ func (this *Prepare) RunOnce(context *Context, parent value.Value) {
 			return
 		}
 
+		plan.PersistPrepared(this.plan.Plan(), context.Namespace(), context.AuthenticatedUsers())
+
 		// We are going to amend the prepared name, so make a copy not
 		// to affect the cache
 		val := value.NewValue(this.prepared).Copy()
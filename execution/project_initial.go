@@ -66,7 +66,7 @@ func (this *InitialProject) processItem(item value.AnnotatedValue, context *Cont
 	result := terms[0].Result()
 	expr := result.Expression()
 
-	if result.Star() && (expr == expression.SELF || expr == nil) {
+	if result.Star() && (expr == expression.SELF || expr == nil) && this.plan.Projection().Mask() == nil {
 		// Unprefixed star
 		if item.Type() == value.OBJECT {
 			return this.sendItem(item)
@@ -128,6 +128,14 @@ func (this *InitialProject) processTerms(item value.AnnotatedValue, context *Con
 					context.Error(errors.NewEvaluationError(err, "projection"))
 					return false
 				}
+			} else if mask := this.plan.Projection().Mask(); mask != nil {
+				// Masked fields of unprefixed stars
+				var err error
+				starval, err = mask.Evaluate(item, context)
+				if err != nil {
+					context.Error(errors.NewEvaluationError(err, "projection"))
+					return false
+				}
 			}
 
 			// Latest star overwrites previous star
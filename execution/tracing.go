@@ -0,0 +1,112 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/couchbase/query/tracing"
+)
+
+// the plan properties that identify what an operator works on
+var _TRACE_PROPERTIES = []string{"namespace", "keyspace", "index", "as", "using"}
+
+// Emit a span per operator of a completed execution tree, under the
+// execution span.
+// Operators in a pipeline run concurrently, so each span starts with
+// the execution, and lasts for as long as the operator was active.
+func TraceOperators(op Operator, span *tracing.Span) {
+	if op == nil || span == nil {
+		return
+	}
+	bytes, err := json.Marshal(op)
+	if err != nil {
+		return
+	}
+	var tree map[string]interface{}
+	if json.Unmarshal(bytes, &tree) != nil {
+		return
+	}
+	traceOperator(tree, span, time.Now())
+}
+
+// operators last at least as long as their children
+func traceOperator(op map[string]interface{}, parent *tracing.Span, limit time.Time) time.Time {
+	name, _ := op["#operator"].(string)
+	start := parent.Start()
+	span := parent.StartChild(name, start)
+
+	var active time.Duration
+	if stats, ok := op["#stats"].(map[string]interface{}); ok {
+		for _, t := range []string{"execTime", "kernTime", "servTime"} {
+			s, ok := stats[t].(string)
+			if !ok {
+				continue
+			}
+			d, err := time.ParseDuration(s)
+			if err == nil {
+				active += d
+				span.SetAttribute(t, s)
+			}
+		}
+		for _, c := range []string{"#itemsIn", "#itemsOut", "#phaseSwitches"} {
+			if n, ok := stats[c]; ok {
+				span.SetAttribute(c, n)
+			}
+		}
+	}
+	for _, p := range _TRACE_PROPERTIES {
+		if s, ok := op[p].(string); ok {
+			span.SetAttribute(p, s)
+		}
+	}
+
+	end := start.Add(active)
+
+	// children are any operators found among the properties
+	keys := make([]string, 0, len(op))
+	for k := range op {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch v := op[k].(type) {
+		case map[string]interface{}:
+			if _, ok := v["#operator"]; ok {
+				end = laterOf(end, traceOperator(v, span, limit))
+			}
+		case []interface{}:
+			for _, c := range v {
+				child, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if _, ok := child["#operator"]; ok {
+					end = laterOf(end, traceOperator(child, span, limit))
+				}
+			}
+		}
+	}
+
+	if end.After(limit) {
+		end = limit
+	}
+	span.FinishAt(end)
+	return end
+}
+
+func laterOf(t1, t2 time.Time) time.Time {
+	if t2.After(t1) {
+		return t2
+	}
+	return t1
+}
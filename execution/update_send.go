@@ -151,7 +151,10 @@ func (this *SendUpdate) flushBatch(context *Context) bool {
 
 	this.switchPhase(_SERVTIME)
 
-	pairs, e := this.plan.Keyspace().Update(pairs)
+	var e errors.Error
+	if !context.DryRun() {
+		pairs, e = this.plan.Keyspace().Update(pairs)
+	}
 
 	this.switchPhase(_EXECTIME)
 
@@ -130,9 +130,11 @@ func (this *SendUpsert) flushBatch(context *Context) bool {
 
 	this.switchPhase(_SERVTIME)
 
-	// Perform the actual UPSERT
+	// Perform the actual UPSERT, unless this is a dry run
 	var er errors.Error
-	dpairs, er = this.plan.Keyspace().Upsert(dpairs)
+	if !context.DryRun() {
+		dpairs, er = this.plan.Keyspace().Upsert(dpairs)
+	}
 
 	this.switchPhase(_EXECTIME)
 
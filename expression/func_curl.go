@@ -24,6 +24,7 @@ import (
 	curl "github.com/andelf/go-curl"
 	"github.com/couchbase/cbauth"
 	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 
@@ -191,7 +192,7 @@ func (this *Curl) Apply(context Context, args ...value.Value) (value.Value, erro
 	}
 
 	// Now you have the URL and the options with which to call curl.
-	result, err := this.handleCurl(curl_url, options)
+	result, err := this.handleCurl(curl_url, options, tracing.TraceParent(context))
 
 	if err != nil {
 		return value.NULL_VALUE, err
@@ -230,7 +231,7 @@ func (this *Curl) Constructor() FunctionConstructor {
 	return NewCurl
 }
 
-func (this *Curl) handleCurl(url string, options map[string]interface{}) (interface{}, error) {
+func (this *Curl) handleCurl(url string, options map[string]interface{}, traceParent string) (interface{}, error) {
 	// Handle different cases
 
 	// initial check for curl_whitelist.json has been completed. The file exists.
@@ -708,6 +709,11 @@ func (this *Curl) handleCurl(url string, options map[string]interface{}) (interf
 		of the callback function to be func(buf []byte, userdata interface{}) bool {}
 	*/
 
+	// Propagate the trace of the calling request, if any
+	if traceParent != "" {
+		header = append(header, tracing.TRACEPARENT+": "+traceParent)
+	}
+
 	// Set the header, so that the entire []string are passed in.
 	this.curlHeader(header)
 	this.curlCiphers()
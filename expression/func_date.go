@@ -12,6 +12,8 @@ package expression
 import (
 	"fmt"
 	"math"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -681,6 +683,104 @@ func (this *DateFormatStr) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// DateFormat
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the Date function DATE_FORMAT(expr, fmt, [ tz ]).
+It returns the input date, either UNIX milliseconds or a string in
+a supported format, formatted using the strftime-style format fmt.
+The date is converted to the optional time zone tz first.
+*/
+type DateFormat struct {
+	FunctionBase
+}
+
+func NewDateFormat(operands ...Expression) Function {
+	rv := &DateFormat{
+		*NewFunctionBase("date_format", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *DateFormat) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *DateFormat) Type() value.Type { return value.STRING }
+
+func (this *DateFormat) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *DateFormat) Apply(context Context, args ...value.Value) (value.Value, error) {
+	ev := args[0]
+	fv := args[1]
+	zv := value.NULL_VALUE
+
+	if len(args) > 2 {
+		zv = args[2]
+	}
+
+	if ev.Type() == value.MISSING || fv.Type() == value.MISSING || zv.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if (ev.Type() != value.STRING && ev.Type() != value.NUMBER) || fv.Type() != value.STRING ||
+		(len(args) > 2 && zv.Type() != value.STRING) {
+		return value.NULL_VALUE, nil
+	}
+
+	var t time.Time
+	if ev.Type() == value.NUMBER {
+		t = millisToTime(ev.Actual().(float64))
+	} else {
+		var err error
+		t, err = strToTime(ev.Actual().(string))
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	if len(args) > 2 {
+		loc, err := time.LoadLocation(zv.Actual().(string))
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+		t = t.In(loc)
+	}
+
+	str, err := strftime(t, fv.Actual().(string))
+	if err != nil {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(str), nil
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *DateFormat) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *DateFormat) MaxArgs() int { return 3 }
+
+/*
+Factory method pattern.
+*/
+func (this *DateFormat) Constructor() FunctionConstructor {
+	return NewDateFormat
+}
+
 ///////////////////////////////////////////////////
 //
 // DatePartMillis
@@ -1173,6 +1273,166 @@ func (this *DateRangeMillis) Constructor() FunctionConstructor {
 	return NewDateRangeMillis
 }
 
+///////////////////////////////////////////////////
+//
+// DateRangeTZ
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the Date function DATE_RANGE_TZ(start,end,tz,part,[n],[fmt]).
+It returns a range of date strings in the time zone tz, from start
+to end. start and end are either both UNIX milliseconds or both date
+strings; date strings without a time zone are interpreted in tz.
+n and part are used to define an interval and duration, and the
+arithmetic is performed in tz, so that day based steps follow its
+daylight saving transitions.
+*/
+type DateRangeTZ struct {
+	FunctionBase
+}
+
+func NewDateRangeTZ(operands ...Expression) Function {
+	rv := &DateRangeTZ{
+		*NewFunctionBase("date_range_tz", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *DateRangeTZ) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *DateRangeTZ) Type() value.Type { return value.ARRAY }
+
+func (this *DateRangeTZ) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *DateRangeTZ) Apply(context Context, args ...value.Value) (value.Value, error) {
+	startDate := args[0]
+	endDate := args[1]
+	zone := args[2]
+	part := args[3]
+
+	// Default value for the increment is 1.
+	n := value.ONE_VALUE
+	if len(args) > 4 {
+		n = args[4]
+	}
+
+	// The default format is that of the start date, or the default
+	// format for UNIX milliseconds.
+	fv := value.NULL_VALUE
+	if len(args) > 5 {
+		fv = args[5]
+	}
+
+	for _, arg := range args {
+		if arg.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		}
+	}
+
+	if startDate.Type() != endDate.Type() ||
+		(startDate.Type() != value.STRING && startDate.Type() != value.NUMBER) ||
+		zone.Type() != value.STRING || part.Type() != value.STRING || n.Type() != value.NUMBER ||
+		(len(args) > 5 && fv.Type() != value.STRING) {
+		return value.NULL_VALUE, nil
+	}
+
+	loc, err := time.LoadLocation(zone.Actual().(string))
+	if err != nil {
+		return value.NULL_VALUE, nil
+	}
+
+	var t1, t2 time.Time
+	format := _DEFAULT_FORMAT
+	if startDate.Type() == value.NUMBER {
+		t1 = millisToTime(startDate.Actual().(float64))
+		t2 = millisToTime(endDate.Actual().(float64))
+	} else {
+		t1, format, err = strToTimeFormatIn(startDate.Actual().(string), loc)
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+
+		t2, _, err = strToTimeFormatIn(endDate.Actual().(string), loc)
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	if len(args) > 5 {
+		format = fv.Actual().(string)
+	}
+
+	t1 = t1.In(loc)
+	t2 = t2.In(loc)
+
+	// Return null value for decimal increments.
+	step := n.Actual().(float64)
+	if step != math.Trunc(step) {
+		return value.NULL_VALUE, nil
+	}
+
+	// Return an empty array if the increment does not move
+	// the start date towards the end date.
+	if t1.Equal(t2) || (t1.After(t2) && step >= 0.0) || (t1.Before(t2) && step <= 0.0) {
+		return value.EMPTY_ARRAY_VALUE, nil
+	}
+
+	partStr := part.Actual().(string)
+	capacity, err := dateDiff(t1, t2, partStr)
+	if err != nil {
+		return value.NULL_VALUE, err
+	}
+	if capacity < 0 {
+		capacity = -capacity
+	}
+	if capacity > RANGE_LIMIT {
+		return nil, errors.NewRangeError("DATE_RANGE_TZ()")
+	}
+
+	rv := make([]interface{}, 0, capacity)
+	start := t1
+	end := timeToMillis(t2)
+	for (step > 0.0 && timeToMillis(start) < end) ||
+		(step < 0.0 && timeToMillis(start) > end) {
+		rv = append(rv, timeToStr(start, format))
+		t, err := dateAdd(start, int(step), partStr)
+		if err != nil {
+			return value.NULL_VALUE, err
+		}
+
+		start = t
+	}
+
+	return value.NewValue(rv), nil
+}
+
+/*
+Minimum input arguments required is 4.
+*/
+func (this *DateRangeTZ) MinArgs() int { return 4 }
+
+/*
+Maximum input arguments allowed is 6.
+*/
+func (this *DateRangeTZ) MaxArgs() int { return 6 }
+
+/*
+Factory method pattern.
+*/
+func (this *DateRangeTZ) Constructor() FunctionConstructor {
+	return NewDateRangeTZ
+}
+
 ///////////////////////////////////////////////////
 //
 // DateTruncMillis
@@ -1180,17 +1440,18 @@ func (this *DateRangeMillis) Constructor() FunctionConstructor {
 ///////////////////////////////////////////////////
 
 /*
-This represents the Date function DATE_TRUNC_MILLIS(expr, part).
+This represents the Date function DATE_TRUNC_MILLIS(expr, part, [ start ]).
 It truncates UNIX timestamp so that the given date part string
-is the least significant.
+is the least significant. The optional start is the first day of
+the week used when truncating to "week".
 */
 type DateTruncMillis struct {
-	BinaryFunctionBase
+	FunctionBase
 }
 
-func NewDateTruncMillis(first, second Expression) Function {
+func NewDateTruncMillis(operands ...Expression) Function {
 	rv := &DateTruncMillis{
-		*NewBinaryFunctionBase("date_trunc_millis", first, second),
+		*NewFunctionBase("date_trunc_millis", operands...),
 	}
 
 	rv.expr = rv
@@ -1207,22 +1468,35 @@ func (this *DateTruncMillis) Accept(visitor Visitor) (interface{}, error) {
 func (this *DateTruncMillis) Type() value.Type { return value.NUMBER }
 
 func (this *DateTruncMillis) Evaluate(item value.Value, context Context) (value.Value, error) {
-	return this.BinaryEval(this, item, context)
+	return this.Eval(this, item, context)
 }
 
-func (this *DateTruncMillis) Apply(context Context, first, second value.Value) (value.Value, error) {
-	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+func (this *DateTruncMillis) Apply(context Context, args ...value.Value) (value.Value, error) {
+	first := args[0]
+	second := args[1]
+	start := _DEFAULT_WEEK_START_VALUE
+
+	if len(args) > 2 {
+		start = args[2]
+	}
+
+	if first.Type() == value.MISSING || second.Type() == value.MISSING || start.Type() == value.MISSING {
 		return value.MISSING_VALUE, nil
 	} else if first.Type() != value.NUMBER || second.Type() != value.STRING {
 		return value.NULL_VALUE, nil
 	}
 
+	ws, ok := weekStart(start)
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
 	millis := first.Actual().(float64)
 	part := second.Actual().(string)
 	t := millisToTime(millis)
 
 	var err error
-	t, err = dateTrunc(t, part)
+	t, err = dateTrunc(t, part, ws)
 	if err != nil {
 		return value.NULL_VALUE, err
 	}
@@ -1230,13 +1504,21 @@ func (this *DateTruncMillis) Apply(context Context, first, second value.Value) (
 	return value.NewValue(timeToMillis(t)), nil
 }
 
+/*
+Minimum input arguments required is 2.
+*/
+func (this *DateTruncMillis) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *DateTruncMillis) MaxArgs() int { return 3 }
+
 /*
 Factory method pattern.
 */
 func (this *DateTruncMillis) Constructor() FunctionConstructor {
-	return func(operands ...Expression) Function {
-		return NewDateTruncMillis(operands[0], operands[1])
-	}
+	return NewDateTruncMillis
 }
 
 ///////////////////////////////////////////////////
@@ -1246,17 +1528,18 @@ func (this *DateTruncMillis) Constructor() FunctionConstructor {
 ///////////////////////////////////////////////////
 
 /*
-This represents the Date function DATE_TRUNC_STR(expr, part).
+This represents the Date function DATE_TRUNC_STR(expr, part, [ start ]).
 It truncates ISO 8601 timestamp so that the given date part
-string is the least significant.
+string is the least significant. The optional start is the first
+day of the week used when truncating to "week".
 */
 type DateTruncStr struct {
-	BinaryFunctionBase
+	FunctionBase
 }
 
-func NewDateTruncStr(first, second Expression) Function {
+func NewDateTruncStr(operands ...Expression) Function {
 	rv := &DateTruncStr{
-		*NewBinaryFunctionBase("date_trunc_str", first, second),
+		*NewFunctionBase("date_trunc_str", operands...),
 	}
 
 	rv.expr = rv
@@ -1273,16 +1556,29 @@ func (this *DateTruncStr) Accept(visitor Visitor) (interface{}, error) {
 func (this *DateTruncStr) Type() value.Type { return value.STRING }
 
 func (this *DateTruncStr) Evaluate(item value.Value, context Context) (value.Value, error) {
-	return this.BinaryEval(this, item, context)
+	return this.Eval(this, item, context)
 }
 
-func (this *DateTruncStr) Apply(context Context, first, second value.Value) (value.Value, error) {
-	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+func (this *DateTruncStr) Apply(context Context, args ...value.Value) (value.Value, error) {
+	first := args[0]
+	second := args[1]
+	start := _DEFAULT_WEEK_START_VALUE
+
+	if len(args) > 2 {
+		start = args[2]
+	}
+
+	if first.Type() == value.MISSING || second.Type() == value.MISSING || start.Type() == value.MISSING {
 		return value.MISSING_VALUE, nil
 	} else if first.Type() != value.STRING || second.Type() != value.STRING {
 		return value.NULL_VALUE, nil
 	}
 
+	ws, ok := weekStart(start)
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
 	str := first.Actual().(string)
 	part := second.Actual().(string)
 	t, err := strToTime(str)
@@ -1290,7 +1586,7 @@ func (this *DateTruncStr) Apply(context Context, first, second value.Value) (val
 		return value.NULL_VALUE, nil
 	}
 
-	t, err = dateTrunc(t, part)
+	t, err = dateTrunc(t, part, ws)
 	if err != nil {
 		return value.NULL_VALUE, err
 	}
@@ -1298,13 +1594,21 @@ func (this *DateTruncStr) Apply(context Context, first, second value.Value) (val
 	return value.NewValue(timeToStr(t, str)), nil
 }
 
+/*
+Minimum input arguments required is 2.
+*/
+func (this *DateTruncStr) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *DateTruncStr) MaxArgs() int { return 3 }
+
 /*
 Factory method pattern.
 */
 func (this *DateTruncStr) Constructor() FunctionConstructor {
-	return func(operands ...Expression) Function {
-		return NewDateTruncStr(operands[0], operands[1])
-	}
+	return NewDateTruncStr
 }
 
 ///////////////////////////////////////////////////
@@ -2103,6 +2407,156 @@ func (this *DurationToStr) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// DurationToISO
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the Date function DURATION_TO_ISO(duration)
+It converts a duration in nanoseconds to an ISO 8601 duration
+string such as "P1DT2H".
+*/
+type DurationToISO struct {
+	UnaryFunctionBase
+}
+
+func NewDurationToISO(first Expression) Function {
+	rv := &DurationToISO{
+		*NewUnaryFunctionBase("duration_to_iso", first),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *DurationToISO) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *DurationToISO) Type() value.Type { return value.STRING }
+
+func (this *DurationToISO) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+/*
+This method takes a duration and converts it to an ISO 8601
+representation. If the argument is missing, it returns missing.
+If it's not a number, it returns null.
+*/
+func (this *DurationToISO) Apply(context Context, first value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.NUMBER {
+		return value.NULL_VALUE, nil
+	}
+
+	d := first.Actual().(float64)
+	return value.NewValue(formatISODuration(int64(d))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *DurationToISO) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewDurationToISO(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// StrToDate
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the Date function STR_TO_DATE(expr, fmt, [ tz ]).
+It parses the input string using the strftime-style format fmt,
+and returns the date as an ISO 8601 timestamp. Strings without a
+time zone are interpreted in the optional time zone tz.
+*/
+type StrToDate struct {
+	FunctionBase
+}
+
+func NewStrToDate(operands ...Expression) Function {
+	rv := &StrToDate{
+		*NewFunctionBase("str_to_date", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *StrToDate) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *StrToDate) Type() value.Type { return value.STRING }
+
+func (this *StrToDate) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *StrToDate) Apply(context Context, args ...value.Value) (value.Value, error) {
+	ev := args[0]
+	fv := args[1]
+	zv := value.NULL_VALUE
+
+	if len(args) > 2 {
+		zv = args[2]
+	}
+
+	if ev.Type() == value.MISSING || fv.Type() == value.MISSING || zv.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if ev.Type() != value.STRING || fv.Type() != value.STRING ||
+		(len(args) > 2 && zv.Type() != value.STRING) {
+		return value.NULL_VALUE, nil
+	}
+
+	loc := time.Local
+	if len(args) > 2 {
+		var err error
+		loc, err = time.LoadLocation(zv.Actual().(string))
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	t, err := strptime(ev.Actual().(string), fv.Actual().(string), loc)
+	if err != nil {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(t.Format(_DEFAULT_FORMAT)), nil
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *StrToDate) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *StrToDate) MaxArgs() int { return 3 }
+
+/*
+Factory method pattern.
+*/
+func (this *StrToDate) Constructor() FunctionConstructor {
+	return NewStrToDate
+}
+
 ///////////////////////////////////////////////////
 //
 // StrToDuration
@@ -2111,7 +2565,9 @@ func (this *DurationToStr) Constructor() FunctionConstructor {
 
 /*
 This represents the Date function STR_TO_DURATION(string)
-It converts a string to a duration in nanoseconds.
+It converts a string to a duration in nanoseconds. The string
+is either a Go-style duration such as "1h30m" or an ISO 8601
+duration such as "PT1H30M".
 */
 type StrToDuration struct {
 	UnaryFunctionBase
@@ -2153,6 +2609,15 @@ func (this *StrToDuration) Apply(context Context, first value.Value) (value.Valu
 	}
 
 	str := first.Actual().(string)
+	if isISODuration(str) {
+		d, err := parseISODuration(str)
+		if err != nil || d.years != 0 || d.months != 0 {
+			return value.NULL_VALUE, nil
+		}
+
+		return value.NewValue(d.fixed()), nil
+	}
+
 	d, err := time.ParseDuration(str)
 	if err != nil {
 		return value.NULL_VALUE, nil
@@ -2349,15 +2814,7 @@ and return the time value it represents, and error. The
 Parse method is defined by the time package.
 */
 func strToTime(s string) (time.Time, error) {
-	var t time.Time
-	var err error
-	for _, f := range _DATE_FORMATS {
-		t, err = time.ParseInLocation(f, s, time.Local)
-		if err == nil {
-			return t, nil
-		}
-	}
-
+	t, _, err := strToTimeFormatIn(s, time.Local)
 	return t, err
 }
 
@@ -2367,10 +2824,18 @@ and return the time value it represents, the format and an
 error. The Parse method is defined by the time package.
 */
 func strToTimeFormat(s string) (time.Time, string, error) {
+	return strToTimeFormatIn(s, time.Local)
+}
+
+/*
+Same as strToTimeFormat, but strings without a time zone
+offset are interpreted in the given location.
+*/
+func strToTimeFormatIn(s string, loc *time.Location) (time.Time, string, error) {
 	var t time.Time
 	var err error
 	for _, f := range _DATE_FORMATS {
-		t, err = time.ParseInLocation(f, s, time.Local)
+		t, err = time.ParseInLocation(f, s, loc)
 		if err == nil {
 			return t, f, nil
 		}
@@ -2502,6 +2967,14 @@ Add part to the input time string using AddDate method from the
 time package. n and part are used to define the interval or duration.
 */
 func dateAdd(t time.Time, n int, part string) (time.Time, error) {
+	if isISODuration(part) {
+		d, err := parseISODuration(part)
+		if err != nil {
+			return t, err
+		}
+		return d.addTo(t, int64(n)), nil
+	}
+
 	p := strings.ToLower(part)
 
 	switch p {
@@ -2538,7 +3011,7 @@ func dateAdd(t time.Time, n int, part string) (time.Time, error) {
 Truncate out the part of the date string from the output and return the
 remaining time t.
 */
-func dateTrunc(t time.Time, part string) (time.Time, error) {
+func dateTrunc(t time.Time, part string, start time.Weekday) (time.Time, error) {
 	p := strings.ToLower(part)
 
 	switch p {
@@ -2558,11 +3031,66 @@ func dateTrunc(t time.Time, part string) (time.Time, error) {
 		return t.AddDate(0, -((int(t.Month()) - 1) % 3), 0), nil
 	case "month":
 		return monthTrunc(t), nil
+	case "week":
+		return weekTrunc(t, start), nil
+	case "iso_week":
+		return weekTrunc(t, time.Monday), nil
+	case "iso_year":
+		return isoYearTrunc(t), nil
 	default:
 		return timeTrunc(t, p)
 	}
 }
 
+/*
+This method returns the time t truncated to the most recent
+day that is the given start of the week.
+*/
+func weekTrunc(t time.Time, start time.Weekday) time.Time {
+	t, _ = timeTrunc(t, "day")
+	return t.AddDate(0, 0, -((int(t.Weekday()) - int(start) + 7) % 7))
+}
+
+/*
+This method returns the time t truncated to the Monday of the
+first week of its ISO 8601 year.
+*/
+func isoYearTrunc(t time.Time) time.Time {
+	_, w := t.ISOWeek()
+	return weekTrunc(t, time.Monday).AddDate(0, 0, -7*(w-1))
+}
+
+/*
+Represents the default first day of the week, Sunday.
+*/
+var _DEFAULT_WEEK_START_VALUE = value.NewValue("sunday")
+
+/*
+Convert the input value to a day of the week. The value is either
+a number from 0 (Sunday) to 6 (Saturday), or the English name of
+the day, full or abbreviated to three letters.
+*/
+func weekStart(v value.Value) (time.Weekday, bool) {
+	switch v.Type() {
+	case value.NUMBER:
+		n := v.Actual().(float64)
+		if n != math.Trunc(n) || n < 0 || n > 6 {
+			return time.Sunday, false
+		}
+		return time.Weekday(n), true
+	case value.STRING:
+		s := strings.ToLower(v.Actual().(string))
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			name := strings.ToLower(d.String())
+			if s == name || s == name[:3] {
+				return d, true
+			}
+		}
+	}
+
+	return time.Sunday, false
+}
+
 /*
 This method returns time t that truncates the Day in the
 week that year and returns the Time.
@@ -2618,6 +3146,15 @@ func dateDiff(t1, t2 time.Time, part string) (int64, error) {
 		sign = -1
 	}
 
+	if isISODuration(part) {
+		d, err := parseISODuration(part)
+		if err != nil {
+			return 0, err
+		}
+		n, err := d.count(t2, t1)
+		return n * int64(sign), err
+	}
+
 	diff := diffDates(t1, t2)
 	d, err := diffPart(t1, t2, diff, part)
 	return d * int64(sign), err
@@ -2790,3 +3327,518 @@ be evenly divided by 400.
 func isLeapYear(year int) bool {
 	return year%400 == 0 || (year%4 == 0 && year%100 != 0)
 }
+
+/*
+The type isoDuration is an ISO 8601 duration such as "P1Y2M3DT4H".
+Years, months and days are calendar units, and are added using
+calendar arithmetic; the time components are kept in nanoseconds.
+*/
+type isoDuration struct {
+	years  int
+	months int
+	days   int
+	nanos  int64
+}
+
+var _ISO_DURATION = regexp.MustCompile(`^([-+]?)P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?` +
+	`(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d{1,9})?)S)?)?$`)
+
+/*
+Returns true if the input string looks like an ISO 8601 duration
+rather than a date part name.
+*/
+func isISODuration(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 0 && (s[0] == 'P' || s[0] == 'p')
+}
+
+/*
+Parse an ISO 8601 duration. Weeks are converted to days, and an
+optional leading sign negates the whole duration.
+*/
+func parseISODuration(s string) (*isoDuration, error) {
+	m := _ISO_DURATION.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil || strings.HasSuffix(s, "T") || strings.HasSuffix(s, "t") {
+		return nil, fmt.Errorf("Invalid ISO 8601 duration %s.", s)
+	}
+
+	n := func(i int) int {
+		v, _ := strconv.Atoi(m[i])
+		return v
+	}
+
+	d := &isoDuration{
+		years:  n(2),
+		months: n(3),
+		days:   n(4)*7 + n(5),
+		nanos:  int64(n(6))*int64(time.Hour) + int64(n(7))*int64(time.Minute),
+	}
+
+	if m[8] != "" {
+		secs, _ := strconv.ParseFloat(strings.Replace(m[8], ",", ".", 1), 64)
+		d.nanos += int64(math.Floor(secs*1e9 + 0.5))
+	}
+
+	empty := true
+	for _, c := range m[2:] {
+		if c != "" {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return nil, fmt.Errorf("Invalid ISO 8601 duration %s.", s)
+	}
+
+	if m[1] == "-" {
+		d.years, d.months, d.days, d.nanos = -d.years, -d.months, -d.days, -d.nanos
+	}
+
+	return d, nil
+}
+
+/*
+Returns the duration in nanoseconds, counting days as 24 hours.
+Years and months are ignored.
+*/
+func (this *isoDuration) fixed() int64 {
+	return int64(this.days)*int64(24*time.Hour) + this.nanos
+}
+
+/*
+Add the duration n times to the time t.
+*/
+func (this *isoDuration) addTo(t time.Time, n int64) time.Time {
+	t = t.AddDate(int(n)*this.years, int(n)*this.months, int(n)*this.days)
+	return t.Add(time.Duration(n * this.nanos))
+}
+
+/*
+Returns the number of whole durations that fit between from and
+to, where from is not after to. The count is estimated using the
+average length of a month, and then adjusted with calendar
+arithmetic.
+*/
+func (this *isoDuration) count(from, to time.Time) (int64, error) {
+	d := *this
+	if d.years < 0 || d.months < 0 || d.days < 0 || d.nanos < 0 {
+		d.years, d.months, d.days, d.nanos = -d.years, -d.months, -d.days, -d.nanos
+	}
+
+	approx := (float64(d.years*12+d.months)*30.436875+float64(d.days))*float64(24*time.Hour) + float64(d.nanos)
+	if approx <= 0 {
+		return 0, fmt.Errorf("Unsupported date diff duration of zero length.")
+	}
+
+	n := int64((timeToMillis(to) - timeToMillis(from)) * 1e6 / approx)
+	for n > 0 && d.addTo(from, n).After(to) {
+		n--
+	}
+	for !d.addTo(from, n+1).After(to) {
+		n++
+	}
+
+	return n, nil
+}
+
+/*
+Format a duration in nanoseconds as an ISO 8601 duration, using
+days, hours, minutes and seconds.
+*/
+func formatISODuration(nanos int64) string {
+	if nanos == 0 {
+		return "PT0S"
+	}
+
+	buf := make([]byte, 0, 32)
+	if nanos < 0 {
+		buf = append(buf, '-')
+		nanos = -nanos
+	}
+
+	buf = append(buf, 'P')
+	day := int64(24 * time.Hour)
+	if nanos >= day {
+		buf = strconv.AppendInt(buf, nanos/day, 10)
+		buf = append(buf, 'D')
+		nanos %= day
+	}
+
+	if nanos == 0 {
+		return string(buf)
+	}
+
+	buf = append(buf, 'T')
+	if nanos >= int64(time.Hour) {
+		buf = strconv.AppendInt(buf, nanos/int64(time.Hour), 10)
+		buf = append(buf, 'H')
+		nanos %= int64(time.Hour)
+	}
+
+	if nanos >= int64(time.Minute) {
+		buf = strconv.AppendInt(buf, nanos/int64(time.Minute), 10)
+		buf = append(buf, 'M')
+		nanos %= int64(time.Minute)
+	}
+
+	if nanos > 0 {
+		buf = strconv.AppendInt(buf, nanos/int64(time.Second), 10)
+		if frac := nanos % int64(time.Second); frac > 0 {
+			buf = append(buf, '.')
+			buf = append(buf, strings.TrimRight(fmt.Sprintf("%09d", frac), "0")...)
+		}
+		buf = append(buf, 'S')
+	}
+
+	return string(buf)
+}
+
+/*
+Format the time t using a strftime-style format. The supported
+conversions are listed in the documentation of DATE_FORMAT.
+*/
+func strftime(t time.Time, format string) (string, error) {
+	buf := make([]byte, 0, len(format)+16)
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			buf = append(buf, c)
+			continue
+		}
+
+		i++
+		if i >= len(format) {
+			return "", fmt.Errorf("Incomplete conversion at end of date format %s.", format)
+		}
+
+		switch format[i] {
+		case 'a':
+			buf = append(buf, t.Weekday().String()[:3]...)
+		case 'A':
+			buf = append(buf, t.Weekday().String()...)
+		case 'b', 'h':
+			buf = append(buf, t.Month().String()[:3]...)
+		case 'B':
+			buf = append(buf, t.Month().String()...)
+		case 'c':
+			buf = append(buf, t.Format("Mon Jan _2 15:04:05 2006")...)
+		case 'C':
+			buf = append(buf, fmt.Sprintf("%02d", t.Year()/100)...)
+		case 'd':
+			buf = append(buf, fmt.Sprintf("%02d", t.Day())...)
+		case 'D':
+			buf = append(buf, t.Format("01/02/06")...)
+		case 'e':
+			buf = append(buf, fmt.Sprintf("%2d", t.Day())...)
+		case 'f':
+			buf = append(buf, fmt.Sprintf("%06d", t.Nanosecond()/1000)...)
+		case 'F':
+			buf = append(buf, t.Format("2006-01-02")...)
+		case 'G':
+			y, _ := t.ISOWeek()
+			buf = append(buf, fmt.Sprintf("%04d", y)...)
+		case 'H':
+			buf = append(buf, fmt.Sprintf("%02d", t.Hour())...)
+		case 'I':
+			buf = append(buf, fmt.Sprintf("%02d", hour12(t.Hour()))...)
+		case 'j':
+			buf = append(buf, fmt.Sprintf("%03d", t.YearDay())...)
+		case 'k':
+			buf = append(buf, fmt.Sprintf("%2d", t.Hour())...)
+		case 'l':
+			buf = append(buf, fmt.Sprintf("%2d", hour12(t.Hour()))...)
+		case 'L':
+			buf = append(buf, fmt.Sprintf("%03d", t.Nanosecond()/1000000)...)
+		case 'm':
+			buf = append(buf, fmt.Sprintf("%02d", int(t.Month()))...)
+		case 'M':
+			buf = append(buf, fmt.Sprintf("%02d", t.Minute())...)
+		case 'n':
+			buf = append(buf, '\n')
+		case 'N':
+			buf = append(buf, fmt.Sprintf("%09d", t.Nanosecond())...)
+		case 'p':
+			buf = append(buf, t.Format("PM")...)
+		case 'P':
+			buf = append(buf, t.Format("pm")...)
+		case 'R':
+			buf = append(buf, t.Format("15:04")...)
+		case 's':
+			buf = strconv.AppendInt(buf, t.Unix(), 10)
+		case 'S':
+			buf = append(buf, fmt.Sprintf("%02d", t.Second())...)
+		case 't':
+			buf = append(buf, '\t')
+		case 'T':
+			buf = append(buf, t.Format("15:04:05")...)
+		case 'u':
+			d, _ := datePart(t, "iso_dow")
+			buf = strconv.AppendInt(buf, int64(d), 10)
+		case 'V':
+			_, w := t.ISOWeek()
+			buf = append(buf, fmt.Sprintf("%02d", w)...)
+		case 'w':
+			buf = strconv.AppendInt(buf, int64(t.Weekday()), 10)
+		case 'y':
+			buf = append(buf, fmt.Sprintf("%02d", t.Year()%100)...)
+		case 'Y':
+			buf = append(buf, fmt.Sprintf("%04d", t.Year())...)
+		case 'z':
+			buf = append(buf, t.Format("-0700")...)
+		case 'Z':
+			buf = append(buf, t.Format("MST")...)
+		case '%':
+			buf = append(buf, '%')
+		default:
+			return "", fmt.Errorf("Unsupported conversion %%%c in date format %s.", format[i], format)
+		}
+	}
+
+	return string(buf), nil
+}
+
+func hour12(h int) int {
+	h %= 12
+	if h == 0 {
+		h = 12
+	}
+	return h
+}
+
+/*
+Parse the string s using a strftime-style format. Fields that are
+not present in the format default to those of January 1st of year
+0, midnight. Strings without a time zone are interpreted in loc.
+*/
+func strptime(s, format string, loc *time.Location) (time.Time, error) {
+	year, month, day, yday := 0, 1, 1, 0
+	hour, min, sec, nsec := 0, 0, 0, 0
+	pm, hasPM, hasEpoch := false, false, false
+	var epoch int64
+
+	// Expand the composite conversions first
+	format = strings.NewReplacer("%D", "%m/%d/%y", "%F", "%Y-%m-%d", "%R", "%H:%M",
+		"%T", "%H:%M:%S", "%%", "%%").Replace(format)
+
+	bad := func() (time.Time, error) {
+		return time.Time{}, fmt.Errorf("Date %s does not match format %s.", s, format)
+	}
+
+	num := func(width int, pad bool) (int, bool) {
+		if pad {
+			for width > 1 && len(s) > 0 && s[0] == ' ' {
+				s = s[1:]
+				width--
+			}
+		}
+
+		i := 0
+		for i < width && i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, false
+		}
+
+		v, _ := strconv.Atoi(s[:i])
+		s = s[i:]
+		return v, true
+	}
+
+	signed := func(width int) (int, bool) {
+		neg := len(s) > 0 && s[0] == '-'
+		if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+			s = s[1:]
+		}
+
+		v, ok := num(width, false)
+		if neg {
+			v = -v
+		}
+		return v, ok
+	}
+
+	name := func(names []string) (int, bool) {
+		for i, n := range names {
+			if len(s) >= len(n) && strings.EqualFold(s[:len(n)], n) {
+				s = s[len(n):]
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	var ok bool
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			if len(s) == 0 || s[0] != c {
+				return bad()
+			}
+			s = s[1:]
+			continue
+		}
+
+		i++
+		if i >= len(format) {
+			return bad()
+		}
+
+		switch format[i] {
+		case 'a', 'A':
+			_, ok = name(_WEEKDAY_NAMES)
+		case 'b', 'h', 'B':
+			month, ok = name(_MONTH_NAMES)
+			month = month%12 + 1
+		case 'd', 'e':
+			day, ok = num(2, true)
+		case 'f':
+			start := len(s)
+			nsec, ok = num(9, false)
+			for d := start - len(s); d < 9; d++ {
+				nsec *= 10
+			}
+		case 'H', 'I', 'k', 'l':
+			hour, ok = num(2, true)
+		case 'j':
+			yday, ok = num(3, true)
+		case 'L':
+			nsec, ok = num(3, false)
+			nsec *= 1000000
+		case 'm':
+			month, ok = num(2, true)
+		case 'M':
+			min, ok = num(2, true)
+		case 'n', 't':
+			s = strings.TrimLeft(s, " \t\n")
+			ok = true
+		case 'N':
+			nsec, ok = num(9, false)
+		case 'p', 'P':
+			var p int
+			p, ok = name([]string{"AM", "PM"})
+			pm, hasPM = p == 1, true
+		case 's':
+			var e int
+			e, ok = signed(19)
+			epoch, hasEpoch = int64(e), true
+		case 'S':
+			sec, ok = num(2, true)
+		case 'y':
+			year, ok = num(2, false)
+			if year < 69 {
+				year += 2000
+			} else {
+				year += 1900
+			}
+		case 'Y':
+			year, ok = signed(4)
+		case 'z':
+			loc, ok = parseZoneOffset(&s)
+		case 'Z':
+			j := 0
+			for j < len(s) && ((s[j] >= 'A' && s[j] <= 'Z') || (s[j] >= 'a' && s[j] <= 'z')) {
+				j++
+			}
+			switch strings.ToUpper(s[:j]) {
+			case "UTC", "GMT", "Z":
+				loc, ok = time.UTC, true
+			default:
+				ok = false
+			}
+			s = s[j:]
+		case '%':
+			ok = len(s) > 0 && s[0] == '%'
+			if ok {
+				s = s[1:]
+			}
+		default:
+			return time.Time{}, fmt.Errorf("Unsupported conversion %%%c in date format %s.", format[i], format)
+		}
+
+		if !ok {
+			return bad()
+		}
+	}
+
+	if len(s) > 0 {
+		return bad()
+	}
+
+	if hasEpoch {
+		return time.Unix(epoch, 0).In(loc), nil
+	}
+
+	if hasPM {
+		if hour < 1 || hour > 12 {
+			return bad()
+		}
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || min > 59 || sec > 60 {
+		return bad()
+	}
+
+	if yday > 0 {
+		return time.Date(year, time.January, yday, hour, min, sec, nsec, loc), nil
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, min, sec, nsec, loc)
+	if t.Day() != day {
+		return bad()
+	}
+
+	return t, nil
+}
+
+/*
+Parse a time zone offset such as "Z", "+0530" or "-08:00" from the
+front of the string, returning a fixed time zone.
+*/
+func parseZoneOffset(s *string) (*time.Location, bool) {
+	str := *s
+	if len(str) > 0 && (str[0] == 'Z' || str[0] == 'z') {
+		*s = str[1:]
+		return time.UTC, true
+	}
+
+	if len(str) < 5 || (str[0] != '+' && str[0] != '-') {
+		return nil, false
+	}
+
+	digits := str[1:5]
+	n := 5
+	if str[3] == ':' && len(str) >= 6 {
+		digits = str[1:3] + str[4:6]
+		n = 6
+	}
+
+	v, err := strconv.Atoi(digits)
+	if err != nil || len(digits) != 4 {
+		return nil, false
+	}
+
+	offset := (v/100)*3600 + (v%100)*60
+	if str[0] == '-' {
+		offset = -offset
+	}
+
+	*s = str[n:]
+	return time.FixedZone("", offset), true
+}
+
+var _WEEKDAY_NAMES = []string{
+	"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday",
+	"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat",
+}
+
+var _MONTH_NAMES = []string{
+	"January", "February", "March", "April", "May", "June", "July",
+	"August", "September", "October", "November", "December",
+	"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul",
+	"Aug", "Sep", "Oct", "Nov", "Dec",
+}
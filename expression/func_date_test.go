@@ -0,0 +1,142 @@
+package expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestISODuration(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"P1DT2H", "P1DT2H"},
+		{"PT90M", "PT1H30M"},
+		{"P2W", "P14D"},
+		{"PT0.25S", "PT0.25S"},
+		{"-PT1M30S", "-PT1M30S"},
+		{"PT0S", "PT0S"},
+	}
+
+	for _, test := range tests {
+		d, err := parseISODuration(test.in)
+		if err != nil {
+			t.Errorf("%s: received error %v", test.in, err)
+			continue
+		}
+		if s := formatISODuration(d.fixed()); s != test.out {
+			t.Errorf("%s: expected %s received %s", test.in, test.out, s)
+		}
+	}
+
+	for _, in := range []string{"P", "PT", "P1H", "1D", "P1DT", "PT1.5M"} {
+		if _, err := parseISODuration(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestISODurationCount(t *testing.T) {
+	from := time.Date(2017, time.January, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		to       time.Time
+		duration string
+		count    int64
+	}{
+		{time.Date(2017, time.March, 3, 0, 0, 0, 0, time.UTC), "P1M", 1},
+		{time.Date(2017, time.March, 2, 23, 0, 0, 0, time.UTC), "P1M", 0},
+		{time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC), "P1Y", 1},
+		{time.Date(2017, time.February, 1, 12, 0, 0, 0, time.UTC), "PT6H", 6},
+		{time.Date(2017, time.February, 3, 0, 0, 0, 0, time.UTC), "-P1D", 3},
+	}
+
+	for _, test := range tests {
+		d, _ := parseISODuration(test.duration)
+		n, err := d.count(from, test.to)
+		if err != nil || n != test.count {
+			t.Errorf("%s until %v: expected %d received %d (%v)", test.duration, test.to, test.count, n, err)
+		}
+	}
+}
+
+func TestDateTruncWeek(t *testing.T) {
+	// Thursday
+	d := time.Date(2017, time.June, 15, 13, 14, 15, 0, time.UTC)
+	tests := []struct {
+		part  string
+		start time.Weekday
+		out   time.Time
+	}{
+		{"week", time.Sunday, time.Date(2017, time.June, 11, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Thursday, time.Date(2017, time.June, 15, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Friday, time.Date(2017, time.June, 9, 0, 0, 0, 0, time.UTC)},
+		{"iso_week", time.Sunday, time.Date(2017, time.June, 12, 0, 0, 0, 0, time.UTC)},
+		{"iso_year", time.Sunday, time.Date(2017, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{"quarter", time.Sunday, time.Date(2017, time.April, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		r, err := dateTrunc(d, test.part, test.start)
+		if err != nil || !r.Equal(test.out) {
+			t.Errorf("%s from %v: expected %v received %v (%v)", test.part, test.start, test.out, r, err)
+		}
+	}
+
+	for _, in := range []interface{}{"Monday", "mon", 1} {
+		if ws, ok := weekStart(value.NewValue(in)); !ok || ws != time.Monday {
+			t.Errorf("%v: expected Monday received %v", in, ws)
+		}
+	}
+
+	if _, ok := weekStart(value.NewValue(7)); ok {
+		t.Errorf("7: expected invalid week start")
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	d := time.Date(2017, time.March, 5, 14, 7, 9, 123456789, time.FixedZone("", -5*3600))
+	tests := []struct {
+		format string
+		out    string
+		parse  bool
+	}{
+		{"%Y-%m-%d %H:%M:%S", "2017-03-05 14:07:09", true},
+		{"%a %b %e %Y %I:%M %p", "Sun Mar  5 2017 02:07 PM", true},
+		{"%A, %B %d %Y %H:%M", "Sunday, March 05 2017 14:07", true},
+		{"%F %T.%L%z", "2017-03-05 14:07:09.123-0500", true},
+		{"%j %G-W%V-%u %%", "064 2017-W09-7 %", false},
+		{"%D %R %f", "03/05/17 14:07 123456", true},
+	}
+
+	for _, test := range tests {
+		s, err := strftime(d, test.format)
+		if err != nil || s != test.out {
+			t.Errorf("%s: expected %q received %q (%v)", test.format, test.out, s, err)
+			continue
+		}
+
+		if !test.parse {
+			continue
+		}
+
+		p, err := strptime(s, test.format, d.Location())
+		if err != nil {
+			t.Errorf("%s: received error %v", test.format, err)
+		}
+		if p.Year() != 2017 || p.Month() != time.March || p.Day() != 5 || p.Hour() != 14 || p.Minute() != 7 {
+			t.Errorf("%s: parsed %v", test.format, p)
+		}
+	}
+
+	if _, err := strftime(d, "%Q"); err == nil {
+		t.Errorf("%%Q: expected error")
+	}
+
+	for _, in := range [][2]string{{"2017-02-30", "%Y-%m-%d"}, {"13:00 PM", "%I:%M %p"}, {"2017", "%Y-%m"}} {
+		if _, err := strptime(in[0], in[1], time.UTC); err == nil {
+			t.Errorf("%s %s: expected error", in[0], in[1])
+		}
+	}
+}
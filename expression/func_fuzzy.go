@@ -0,0 +1,528 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"strings"
+
+	"github.com/couchbase/query/value"
+)
+
+///////////////////////////////////////////////////
+//
+// JaroWinkler
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function JARO_WINKLER(expr1, expr2).
+It returns the Jaro-Winkler similarity of the two strings, from 0
+(no similarity) to 1 (identical).
+*/
+type JaroWinkler struct {
+	BinaryFunctionBase
+}
+
+func NewJaroWinkler(first, second Expression) Function {
+	rv := &JaroWinkler{
+		*NewBinaryFunctionBase("jaro_winkler", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *JaroWinkler) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *JaroWinkler) Type() value.Type { return value.NUMBER }
+
+func (this *JaroWinkler) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+func (this *JaroWinkler) Apply(context Context, first, second value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.STRING || second.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(jaroWinkler([]rune(first.Actual().(string)), []rune(second.Actual().(string)))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *JaroWinkler) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewJaroWinkler(operands[0], operands[1])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// Levenshtein
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function LEVENSHTEIN(expr1, expr2).
+It returns the minimum number of single character insertions,
+deletions and substitutions that change one string into the other.
+*/
+type Levenshtein struct {
+	BinaryFunctionBase
+}
+
+func NewLevenshtein(first, second Expression) Function {
+	rv := &Levenshtein{
+		*NewBinaryFunctionBase("levenshtein", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Levenshtein) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Levenshtein) Type() value.Type { return value.NUMBER }
+
+func (this *Levenshtein) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+func (this *Levenshtein) Apply(context Context, first, second value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.STRING || second.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(levenshtein([]rune(first.Actual().(string)), []rune(second.Actual().(string)))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *Levenshtein) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewLevenshtein(operands[0], operands[1])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// Metaphone
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function METAPHONE(expr). It returns
+the Metaphone phonetic code of the English word or name. Characters
+other than the letters A to Z are ignored.
+*/
+type Metaphone struct {
+	UnaryFunctionBase
+}
+
+func NewMetaphone(operand Expression) Function {
+	rv := &Metaphone{
+		*NewUnaryFunctionBase("metaphone", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Metaphone) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Metaphone) Type() value.Type { return value.STRING }
+
+func (this *Metaphone) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *Metaphone) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(metaphone(arg.Actual().(string))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *Metaphone) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewMetaphone(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// Soundex
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function SOUNDEX(expr). It returns the
+four character American Soundex code of the English name, such as
+"R163" for "Robert". Characters other than the letters A to Z are
+ignored.
+*/
+type Soundex struct {
+	UnaryFunctionBase
+}
+
+func NewSoundex(operand Expression) Function {
+	rv := &Soundex{
+		*NewUnaryFunctionBase("soundex", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Soundex) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Soundex) Type() value.Type { return value.STRING }
+
+func (this *Soundex) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *Soundex) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(soundex(arg.Actual().(string))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *Soundex) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewSoundex(operands[0])
+	}
+}
+
+/*
+Levenshtein distance of two strings, keeping only two rows of the
+distance matrix.
+*/
+func levenshtein(s, t []rune) int {
+	if len(s) < len(t) {
+		s, t = t, s
+	}
+
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(t)]
+}
+
+/*
+Jaro-Winkler similarity of two strings, using the standard prefix
+scale of 0.1 and a common prefix of at most 4 characters.
+*/
+func jaroWinkler(s, t []rune) float64 {
+	if len(s) == 0 && len(t) == 0 {
+		return 1.0
+	} else if len(s) == 0 || len(t) == 0 {
+		return 0.0
+	}
+
+	window := maxInt(len(s), len(t))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	sMatched := make([]bool, len(s))
+	tMatched := make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		lo := maxInt(0, i-window)
+		hi := minInt(len(t), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+
+	if matches == 0 {
+		return 0.0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3.0
+
+	prefix := 0
+	for prefix < 4 && prefix < len(s) && prefix < len(t) && s[prefix] == t[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1.0-jaro)
+}
+
+/*
+Returns the letters A to Z of the string, in upper case.
+*/
+func upperLetters(str string) []byte {
+	rv := make([]byte, 0, len(str))
+	for _, r := range strings.ToUpper(str) {
+		if r >= 'A' && r <= 'Z' {
+			rv = append(rv, byte(r))
+		}
+	}
+	return rv
+}
+
+/*
+Soundex digits of the letters A to Z. Vowels, H, W and Y are 0.
+*/
+const _SOUNDEX_CODES = "01230120022455012623010202"
+
+func soundex(str string) string {
+	letters := upperLetters(str)
+	if len(letters) == 0 {
+		return ""
+	}
+
+	rv := []byte{letters[0]}
+	last := _SOUNDEX_CODES[letters[0]-'A']
+	for _, c := range letters[1:] {
+		code := _SOUNDEX_CODES[c-'A']
+		if code != '0' && code != last {
+			rv = append(rv, code)
+			if len(rv) == 4 {
+				break
+			}
+		}
+
+		// H and W do not separate letters with the same code
+		if c != 'H' && c != 'W' {
+			last = code
+		}
+	}
+
+	for len(rv) < 4 {
+		rv = append(rv, '0')
+	}
+
+	return string(rv)
+}
+
+func isVowel(c byte) bool {
+	return c == 'A' || c == 'E' || c == 'I' || c == 'O' || c == 'U'
+}
+
+/*
+The original Metaphone algorithm by Lawrence Philips. "0" is used
+for the "th" sound, and "X" for the "sh" sound.
+*/
+func metaphone(str string) string {
+	w := upperLetters(str)
+	if len(w) == 0 {
+		return ""
+	}
+
+	// Initial letter exceptions
+	switch {
+	case len(w) > 1 && (string(w[:2]) == "AE" || string(w[:2]) == "GN" || string(w[:2]) == "KN" ||
+		string(w[:2]) == "PN" || string(w[:2]) == "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w[0] = 'S'
+	case len(w) > 1 && string(w[:2]) == "WH":
+		w = append([]byte{'W'}, w[2:]...)
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+
+	rv := make([]byte, 0, len(w))
+	for i, c := range w {
+		// Skip duplicate letters, except C
+		if c != 'C' && i > 0 && c == w[i-1] {
+			continue
+		}
+
+		next := at(i + 1)
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				rv = append(rv, c)
+			}
+		case 'B':
+			if !(at(i-1) == 'M' && i == len(w)-1) {
+				rv = append(rv, 'B')
+			}
+		case 'C':
+			if next == 'I' && at(i+2) == 'A' {
+				rv = append(rv, 'X')
+			} else if next == 'H' {
+				if at(i-1) == 'S' {
+					rv = append(rv, 'K')
+				} else {
+					rv = append(rv, 'X')
+				}
+			} else if next == 'I' || next == 'E' || next == 'Y' {
+				if at(i-1) != 'S' {
+					rv = append(rv, 'S')
+				}
+			} else {
+				rv = append(rv, 'K')
+			}
+		case 'D':
+			if next == 'G' && (at(i+2) == 'E' || at(i+2) == 'Y' || at(i+2) == 'I') {
+				rv = append(rv, 'J')
+			} else {
+				rv = append(rv, 'T')
+			}
+		case 'G':
+			if next == 'H' && !(i+2 >= len(w) || isVowel(at(i+2))) {
+				// silent, as in "night"
+			} else if next == 'N' && (i+2 == len(w) || (string(w[i+1:]) == "NED")) {
+				// silent, as in "sign" and "signed"
+			} else if (next == 'I' || next == 'E' || next == 'Y') && at(i-1) != 'G' {
+				rv = append(rv, 'J')
+			} else {
+				rv = append(rv, 'K')
+			}
+		case 'H':
+			prev := at(i - 1)
+			if isVowel(next) && prev != 'C' && prev != 'G' && prev != 'P' && prev != 'S' && prev != 'T' {
+				rv = append(rv, 'H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				rv = append(rv, 'K')
+			}
+		case 'P':
+			if next == 'H' {
+				rv = append(rv, 'F')
+			} else {
+				rv = append(rv, 'P')
+			}
+		case 'Q':
+			rv = append(rv, 'K')
+		case 'S':
+			if next == 'H' {
+				rv = append(rv, 'X')
+			} else if next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A') {
+				rv = append(rv, 'X')
+			} else {
+				rv = append(rv, 'S')
+			}
+		case 'T':
+			if next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A') {
+				rv = append(rv, 'X')
+			} else if next == 'H' {
+				rv = append(rv, '0')
+			} else if !(next == 'C' && at(i+2) == 'H') {
+				rv = append(rv, 'T')
+			}
+		case 'V':
+			rv = append(rv, 'F')
+		case 'W', 'Y':
+			if isVowel(next) {
+				rv = append(rv, c)
+			}
+		case 'X':
+			rv = append(rv, 'K', 'S')
+		case 'Z':
+			rv = append(rv, 'S')
+		default:
+			rv = append(rv, c)
+		}
+	}
+
+	return string(rv)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package expression
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		s, t string
+		d    int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"café", "cafe", 1},
+	}
+
+	for _, test := range tests {
+		if d := levenshtein([]rune(test.s), []rune(test.t)); d != test.d {
+			t.Errorf("%s, %s: expected %d received %d", test.s, test.t, test.d, d)
+		}
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		s, t string
+		d    float64
+	}{
+		{"MARTHA", "MARHTA", 0.9611},
+		{"DWAYNE", "DUANE", 0.84},
+		{"DIXON", "DICKSONX", 0.8133},
+		{"ABCVWXYZ", "CABVWXYZ", 0.9375},
+		{"abc", "abc", 1.0},
+		{"abc", "xyz", 0.0},
+	}
+
+	for _, test := range tests {
+		if d := jaroWinkler([]rune(test.s), []rune(test.t)); math.Abs(d-test.d) > 0.0001 {
+			t.Errorf("%s, %s: expected %v received %v", test.s, test.t, test.d, d)
+		}
+	}
+}
+
+func TestPhonetic(t *testing.T) {
+	soundexes := map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Rubin":    "R150",
+		"Ashcraft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Lee":      "L000",
+		"":         "",
+	}
+
+	for in, out := range soundexes {
+		if s := soundex(in); s != out {
+			t.Errorf("soundex %s: expected %s received %s", in, out, s)
+		}
+	}
+
+	metaphones := map[string]string{
+		"Thompson":  "0MPSN",
+		"knight":    "NT",
+		"Schmidt":   "SKMTT",
+		"Smith":     "SM0",
+		"Xavier":    "SFR",
+		"Philip":    "FLP",
+		"character": "XRKTR",
+	}
+
+	for in, out := range metaphones {
+		if s := metaphone(in); s != out {
+			t.Errorf("metaphone %s: expected %s received %s", in, out, s)
+		}
+	}
+}
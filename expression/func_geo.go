@@ -0,0 +1,1275 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/couchbase/query/value"
+)
+
+/*
+Points are accepted as GeoJSON Point objects, as [lon, lat] arrays
+in GeoJSON order, or as objects with lat and lon (or lng) fields.
+Bounding boxes are [west, south, east, north] arrays; a box whose
+west edge is greater than its east edge crosses the antimeridian.
+*/
+
+/*
+GeoBox is a latitude / longitude bounding box, in degrees.
+*/
+type GeoBox struct {
+	West  float64
+	South float64
+	East  float64
+	North float64
+}
+
+/*
+GeoFunction is implemented by geospatial predicates whose search area
+can be bounded at plan time. The planner uses Bounds() to turn the
+predicate into geohash-prefix spans on an index over GEOHASH(Point()).
+*/
+type GeoFunction interface {
+	Function
+	Point() Expression
+	Bounds() (GeoBox, bool)
+}
+
+///////////////////////////////////////////////////
+//
+// GeoDistance
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial function GEO_DISTANCE(point1, point2
+[, unit]). It returns the great-circle (haversine) distance between
+the two points, in meters or in the given unit.
+*/
+type GeoDistance struct {
+	FunctionBase
+}
+
+func NewGeoDistance(operands ...Expression) Function {
+	rv := &GeoDistance{
+		*NewFunctionBase("geo_distance", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeoDistance) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeoDistance) Type() value.Type { return value.NUMBER }
+
+func (this *GeoDistance) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *GeoDistance) Apply(context Context, args ...value.Value) (value.Value, error) {
+	for _, arg := range args {
+		if arg.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		}
+	}
+
+	lat1, lon1, ok1 := geoPoint(args[0])
+	lat2, lon2, ok2 := geoPoint(args[1])
+	if !ok1 || !ok2 {
+		return value.NULL_VALUE, nil
+	}
+
+	unit := 1.0
+	if len(args) > 2 {
+		var ok bool
+		unit, ok = geoUnit(args[2])
+		if !ok {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	return value.NewValue(haversine(lat1, lon1, lat2, lon2) / unit), nil
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *GeoDistance) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *GeoDistance) MaxArgs() int { return 3 }
+
+/*
+Factory method pattern.
+*/
+func (this *GeoDistance) Constructor() FunctionConstructor {
+	return NewGeoDistance
+}
+
+///////////////////////////////////////////////////
+//
+// GeoWithinRadius
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial predicate GEO_WITHIN_RADIUS(point,
+center, radius [, unit]). It returns true if point is within radius
+(in meters or in the given unit) of center.
+*/
+type GeoWithinRadius struct {
+	FunctionBase
+}
+
+func NewGeoWithinRadius(operands ...Expression) Function {
+	rv := &GeoWithinRadius{
+		*NewFunctionBase("geo_within_radius", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeoWithinRadius) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeoWithinRadius) Type() value.Type { return value.BOOLEAN }
+
+func (this *GeoWithinRadius) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+/*
+If this expression is in the WHERE clause of a partial index, lists
+the Expressions that are implicitly covered.
+
+For boolean functions, simply list this expression.
+*/
+func (this *GeoWithinRadius) FilterCovers(covers map[string]value.Value) map[string]value.Value {
+	covers[this.String()] = value.TRUE_VALUE
+	return covers
+}
+
+func (this *GeoWithinRadius) Apply(context Context, args ...value.Value) (value.Value, error) {
+	for _, arg := range args {
+		if arg.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		}
+	}
+
+	lat, lon, ok := geoPoint(args[0])
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	clat, clon, radius, ok := geoCircle(args[1:])
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(haversine(lat, lon, clat, clon) <= radius), nil
+}
+
+/*
+Minimum input arguments required is 3.
+*/
+func (this *GeoWithinRadius) MinArgs() int { return 3 }
+
+/*
+Maximum input arguments allowed is 4.
+*/
+func (this *GeoWithinRadius) MaxArgs() int { return 4 }
+
+/*
+Factory method pattern.
+*/
+func (this *GeoWithinRadius) Constructor() FunctionConstructor {
+	return NewGeoWithinRadius
+}
+
+func (this *GeoWithinRadius) Point() Expression {
+	return this.operands[0]
+}
+
+/*
+Returns the bounding box of the circle, if center, radius and unit
+are constants.
+*/
+func (this *GeoWithinRadius) Bounds() (GeoBox, bool) {
+	args := make([]value.Value, 0, len(this.operands)-1)
+	for _, op := range this.operands[1:] {
+		val := op.Value()
+		if val == nil {
+			return GeoBox{}, false
+		}
+		args = append(args, val)
+	}
+
+	clat, clon, radius, ok := geoCircle(args)
+	if !ok {
+		return GeoBox{}, false
+	}
+
+	return radiusBox(clat, clon, radius), true
+}
+
+///////////////////////////////////////////////////
+//
+// GeoWithinBBox
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial predicate GEO_WITHIN_BBOX(point, bbox).
+It returns true if point is within the [west, south, east, north]
+bounding box.
+*/
+type GeoWithinBBox struct {
+	BinaryFunctionBase
+}
+
+func NewGeoWithinBBox(first, second Expression) Function {
+	rv := &GeoWithinBBox{
+		*NewBinaryFunctionBase("geo_within_bbox", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeoWithinBBox) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeoWithinBBox) Type() value.Type { return value.BOOLEAN }
+
+func (this *GeoWithinBBox) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+/*
+If this expression is in the WHERE clause of a partial index, lists
+the Expressions that are implicitly covered.
+
+For boolean functions, simply list this expression.
+*/
+func (this *GeoWithinBBox) FilterCovers(covers map[string]value.Value) map[string]value.Value {
+	covers[this.String()] = value.TRUE_VALUE
+	return covers
+}
+
+func (this *GeoWithinBBox) Apply(context Context, first, second value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	}
+
+	lat, lon, ok1 := geoPoint(first)
+	box, ok2 := geoBox(second)
+	if !ok1 || !ok2 {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(box.contains(lat, lon)), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *GeoWithinBBox) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewGeoWithinBBox(operands[0], operands[1])
+	}
+}
+
+func (this *GeoWithinBBox) Point() Expression {
+	return this.operands[0]
+}
+
+/*
+Returns the bounding box, if it is a constant.
+*/
+func (this *GeoWithinBBox) Bounds() (GeoBox, bool) {
+	val := this.operands[1].Value()
+	if val == nil {
+		return GeoBox{}, false
+	}
+
+	return geoBox(val)
+}
+
+///////////////////////////////////////////////////
+//
+// GeoWithinPolygon
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial predicate GEO_WITHIN_POLYGON(point,
+polygon). It returns true if point is within the GeoJSON Polygon or
+MultiPolygon (or a Feature with such a geometry). Holes are honored.
+*/
+type GeoWithinPolygon struct {
+	BinaryFunctionBase
+}
+
+func NewGeoWithinPolygon(first, second Expression) Function {
+	rv := &GeoWithinPolygon{
+		*NewBinaryFunctionBase("geo_within_polygon", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeoWithinPolygon) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeoWithinPolygon) Type() value.Type { return value.BOOLEAN }
+
+func (this *GeoWithinPolygon) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+/*
+If this expression is in the WHERE clause of a partial index, lists
+the Expressions that are implicitly covered.
+
+For boolean functions, simply list this expression.
+*/
+func (this *GeoWithinPolygon) FilterCovers(covers map[string]value.Value) map[string]value.Value {
+	covers[this.String()] = value.TRUE_VALUE
+	return covers
+}
+
+func (this *GeoWithinPolygon) Apply(context Context, first, second value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	}
+
+	lat, lon, ok1 := geoPoint(first)
+	polygons, ok2 := geoPolygons(second)
+	if !ok1 || !ok2 {
+		return value.NULL_VALUE, nil
+	}
+
+	for _, polygon := range polygons {
+		if polygonContains(polygon, lat, lon) {
+			return value.TRUE_VALUE, nil
+		}
+	}
+
+	return value.FALSE_VALUE, nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *GeoWithinPolygon) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewGeoWithinPolygon(operands[0], operands[1])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// GeohashEncode
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial function GEOHASH_ENCODE(point
+[, precision]), also available as GEOHASH(). It returns the geohash
+of point with precision characters (1 to 12, default 12). An index
+on GEOHASH(point, precision) can be used for GEO_WITHIN_RADIUS and
+GEO_WITHIN_BBOX predicates on the same point.
+*/
+type GeohashEncode struct {
+	FunctionBase
+}
+
+func NewGeohashEncode(operands ...Expression) Function {
+	rv := &GeohashEncode{
+		*NewFunctionBase("geohash_encode", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeohashEncode) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeohashEncode) Type() value.Type { return value.STRING }
+
+func (this *GeohashEncode) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *GeohashEncode) Apply(context Context, args ...value.Value) (value.Value, error) {
+	for _, arg := range args {
+		if arg.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		}
+	}
+
+	lat, lon, ok := geoPoint(args[0])
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	precision := _GEOHASH_MAX_PRECISION
+	if len(args) > 1 {
+		precision, ok = geohashPrecision(args[1])
+		if !ok {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	return value.NewValue(geohashEncode(lat, lon, precision)), nil
+}
+
+/*
+Minimum input arguments required is 1.
+*/
+func (this *GeohashEncode) MinArgs() int { return 1 }
+
+/*
+Maximum input arguments allowed is 2.
+*/
+func (this *GeohashEncode) MaxArgs() int { return 2 }
+
+/*
+Factory method pattern.
+*/
+func (this *GeohashEncode) Constructor() FunctionConstructor {
+	return NewGeohashEncode
+}
+
+func (this *GeohashEncode) Point() Expression {
+	return this.operands[0]
+}
+
+/*
+Returns the geohash precision, if it is a constant.
+*/
+func (this *GeohashEncode) Precision() (int, bool) {
+	if len(this.operands) < 2 {
+		return _GEOHASH_MAX_PRECISION, true
+	}
+
+	val := this.operands[1].Value()
+	if val == nil {
+		return 0, false
+	}
+
+	return geohashPrecision(val)
+}
+
+///////////////////////////////////////////////////
+//
+// GeohashDecode
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial function GEOHASH_DECODE(hash). It
+returns an object with the lat and lon of the center of the geohash
+cell, and its [west, south, east, north] bbox.
+*/
+type GeohashDecode struct {
+	UnaryFunctionBase
+}
+
+func NewGeohashDecode(operand Expression) Function {
+	rv := &GeohashDecode{
+		*NewUnaryFunctionBase("geohash_decode", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeohashDecode) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeohashDecode) Type() value.Type { return value.OBJECT }
+
+func (this *GeohashDecode) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *GeohashDecode) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	box, ok := geohashDecode(arg.Actual().(string))
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	rv := map[string]interface{}{
+		"lat":  (box.South + box.North) / 2.0,
+		"lon":  (box.West + box.East) / 2.0,
+		"bbox": []interface{}{box.West, box.South, box.East, box.North},
+	}
+
+	return value.NewValue(rv), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *GeohashDecode) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewGeohashDecode(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// IsGeoJSON
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial function IS_GEOJSON(expr). It returns
+true if expr is a valid GeoJSON geometry, Feature or FeatureCollection.
+*/
+type IsGeoJSON struct {
+	UnaryFunctionBase
+}
+
+func NewIsGeoJSON(operand Expression) Function {
+	rv := &IsGeoJSON{
+		*NewUnaryFunctionBase("is_geojson", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *IsGeoJSON) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *IsGeoJSON) Type() value.Type { return value.BOOLEAN }
+
+func (this *IsGeoJSON) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+/*
+If this expression is in the WHERE clause of a partial index, lists
+the Expressions that are implicitly covered.
+
+For boolean functions, simply list this expression.
+*/
+func (this *IsGeoJSON) FilterCovers(covers map[string]value.Value) map[string]value.Value {
+	covers[this.String()] = value.TRUE_VALUE
+	return covers
+}
+
+func (this *IsGeoJSON) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING || arg.Type() == value.NULL {
+		return arg, nil
+	}
+
+	return value.NewValue(geojsonError(arg) == ""), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *IsGeoJSON) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewIsGeoJSON(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// GeoJSONError
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the geospatial function GEOJSON_ERROR(expr). It
+returns NULL if expr is valid GeoJSON, and otherwise a string
+describing the first problem found.
+*/
+type GeoJSONError struct {
+	UnaryFunctionBase
+}
+
+func NewGeoJSONError(operand Expression) Function {
+	rv := &GeoJSONError{
+		*NewUnaryFunctionBase("geojson_error", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *GeoJSONError) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *GeoJSONError) Type() value.Type { return value.STRING }
+
+func (this *GeoJSONError) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *GeoJSONError) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	}
+
+	reason := geojsonError(arg)
+	if reason == "" {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(reason), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *GeoJSONError) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewGeoJSONError(operands[0])
+	}
+}
+
+/*
+Mean earth radius in meters.
+*/
+const _EARTH_RADIUS = 6371008.8
+
+/*
+Distance units, in meters.
+*/
+var _GEO_UNITS = map[string]float64{
+	"m":  1.0,
+	"km": 1000.0,
+	"mi": 1609.344,
+	"yd": 0.9144,
+	"ft": 0.3048,
+	"nm": 1852.0,
+}
+
+func geoUnit(v value.Value) (float64, bool) {
+	if v.Type() != value.STRING {
+		return 0, false
+	}
+
+	unit, ok := _GEO_UNITS[strings.ToLower(v.Actual().(string))]
+	return unit, ok
+}
+
+func geoNumber(v value.Value, ok bool) (float64, bool) {
+	if !ok || v.Type() != value.NUMBER {
+		return 0, false
+	}
+
+	return v.Actual().(float64), true
+}
+
+/*
+Parse a [lon, lat, ...] GeoJSON position.
+*/
+func geoPosition(v value.Value) (lat, lon float64, ok bool) {
+	if v.Type() != value.ARRAY {
+		return
+	}
+
+	lon, ok = geoNumber(v.Index(0))
+	if !ok {
+		return
+	}
+
+	lat, ok = geoNumber(v.Index(1))
+	if !ok {
+		return
+	}
+
+	ok = validLatLon(lat, lon)
+	return
+}
+
+/*
+Parse a point; see the formats above.
+*/
+func geoPoint(v value.Value) (lat, lon float64, ok bool) {
+	switch v.Type() {
+	case value.ARRAY:
+		return geoPosition(v)
+	case value.OBJECT:
+		if t, found := v.Field("type"); found {
+			if t.Actual() != "Point" {
+				return
+			}
+
+			coords, _ := v.Field("coordinates")
+			return geoPosition(coords)
+		}
+
+		lat, ok = geoNumber(v.Field("lat"))
+		if !ok {
+			return
+		}
+
+		lon, ok = geoNumber(v.Field("lon"))
+		if !ok {
+			lon, ok = geoNumber(v.Field("lng"))
+			if !ok {
+				return
+			}
+		}
+
+		ok = validLatLon(lat, lon)
+	}
+
+	return
+}
+
+func validLatLon(lat, lon float64) bool {
+	return lat >= -90.0 && lat <= 90.0 && lon >= -180.0 && lon <= 180.0
+}
+
+/*
+Parse the center, radius and optional unit of GEO_WITHIN_RADIUS.
+The radius is returned in meters.
+*/
+func geoCircle(args []value.Value) (lat, lon, radius float64, ok bool) {
+	lat, lon, ok = geoPoint(args[0])
+	if !ok {
+		return
+	}
+
+	radius, ok = geoNumber(args[1], true)
+	if !ok || radius < 0.0 {
+		ok = false
+		return
+	}
+
+	if len(args) > 2 {
+		var unit float64
+		unit, ok = geoUnit(args[2])
+		radius *= unit
+	}
+
+	return
+}
+
+/*
+Parse a [west, south, east, north] bounding box.
+*/
+func geoBox(v value.Value) (box GeoBox, ok bool) {
+	if v.Type() != value.ARRAY || len(v.Actual().([]interface{})) != 4 {
+		return
+	}
+
+	edges := [4]*float64{&box.West, &box.South, &box.East, &box.North}
+	for i, edge := range edges {
+		*edge, ok = geoNumber(v.Index(i))
+		if !ok {
+			return
+		}
+	}
+
+	ok = box.South <= box.North && validLatLon(box.South, box.West) && validLatLon(box.North, box.East)
+	return
+}
+
+func (this GeoBox) contains(lat, lon float64) bool {
+	if lat < this.South || lat > this.North {
+		return false
+	}
+
+	if this.West <= this.East {
+		return lon >= this.West && lon <= this.East
+	}
+
+	return lon >= this.West || lon <= this.East
+}
+
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180.0
+	phi2 := lat2 * math.Pi / 180.0
+	dphi := phi2 - phi1
+	dlambda := (lon2 - lon1) * math.Pi / 180.0
+
+	a := math.Sin(dphi/2.0)*math.Sin(dphi/2.0) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dlambda/2.0)*math.Sin(dlambda/2.0)
+	return 2.0 * _EARTH_RADIUS * math.Asin(math.Min(1.0, math.Sqrt(a)))
+}
+
+/*
+Bounding box of the circle of radius meters around lat, lon.
+*/
+func radiusBox(lat, lon, radius float64) GeoBox {
+	angle := radius / _EARTH_RADIUS
+	dlat := angle * 180.0 / math.Pi
+
+	box := GeoBox{-180.0, lat - dlat, 180.0, lat + dlat}
+	if box.South <= -90.0 || box.North >= 90.0 || angle >= math.Pi/2.0 {
+		// The circle contains a pole
+		box.South = math.Max(box.South, -90.0)
+		box.North = math.Min(box.North, 90.0)
+		return box
+	}
+
+	r := math.Sin(angle) / math.Cos(lat*math.Pi/180.0)
+	if r >= 1.0 {
+		return box
+	}
+
+	dlon := math.Asin(r) * 180.0 / math.Pi
+	box.West = lon - dlon
+	if box.West < -180.0 {
+		box.West += 360.0
+	}
+
+	box.East = lon + dlon
+	if box.East > 180.0 {
+		box.East -= 360.0
+	}
+
+	return box
+}
+
+/*
+A polygon is a list of [lon, lat] rings; the first is the exterior
+and the rest are holes.
+*/
+type geoRing [][2]float64
+
+func geoPolygons(v value.Value) ([][]geoRing, bool) {
+	if v.Type() != value.OBJECT {
+		return nil, false
+	}
+
+	t, _ := v.Field("type")
+	switch t.Actual() {
+	case "Feature":
+		geometry, _ := v.Field("geometry")
+		return geoPolygons(geometry)
+	case "Polygon":
+		coords, _ := v.Field("coordinates")
+		polygon, ok := geoRings(coords)
+		if !ok {
+			return nil, false
+		}
+		return [][]geoRing{polygon}, true
+	case "MultiPolygon":
+		coords, _ := v.Field("coordinates")
+		if coords.Type() != value.ARRAY {
+			return nil, false
+		}
+
+		n := len(coords.Actual().([]interface{}))
+		polygons := make([][]geoRing, 0, n)
+		for i := 0; i < n; i++ {
+			c, _ := coords.Index(i)
+			polygon, ok := geoRings(c)
+			if !ok {
+				return nil, false
+			}
+			polygons = append(polygons, polygon)
+		}
+		return polygons, true
+	}
+
+	return nil, false
+}
+
+func geoRings(v value.Value) ([]geoRing, bool) {
+	if geojsonRings(v) != "" {
+		return nil, false
+	}
+
+	n := len(v.Actual().([]interface{}))
+	rings := make([]geoRing, n)
+	for i := range rings {
+		r, _ := v.Index(i)
+		m := len(r.Actual().([]interface{}))
+		rings[i] = make(geoRing, m)
+		for j := range rings[i] {
+			p, _ := r.Index(j)
+			lat, lon, _ := geoPosition(p)
+			rings[i][j] = [2]float64{lon, lat}
+		}
+	}
+
+	return rings, true
+}
+
+func polygonContains(polygon []geoRing, lat, lon float64) bool {
+	if !ringContains(polygon[0], lat, lon) {
+		return false
+	}
+
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, lat, lon) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Even-odd ray casting, treating coordinates as planar.
+*/
+func ringContains(ring geoRing, lat, lon float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+
+	return in
+}
+
+/*
+Return a description of the first problem found in GeoJSON v, or
+the empty string if v is valid.
+*/
+func geojsonError(v value.Value) string {
+	if v.Type() != value.OBJECT {
+		return "GeoJSON must be an object"
+	}
+
+	t, _ := v.Field("type")
+	typ, ok := t.Actual().(string)
+	if !ok {
+		return "GeoJSON type must be a string"
+	}
+
+	switch typ {
+	case "Feature":
+		geometry, ok := v.Field("geometry")
+		if !ok {
+			return "Feature must have a geometry"
+		}
+		if geometry.Type() == value.NULL {
+			return ""
+		}
+		return geojsonGeometry(geometry)
+	case "FeatureCollection":
+		features, _ := v.Field("features")
+		if features.Type() != value.ARRAY {
+			return "FeatureCollection features must be an array"
+		}
+		for _, f := range features.Actual().([]interface{}) {
+			fv := value.NewValue(f)
+			if t, _ := fv.Field("type"); t.Actual() != "Feature" {
+				return "FeatureCollection features must be Features"
+			}
+			if reason := geojsonError(fv); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+
+	return geojsonGeometry(v)
+}
+
+func geojsonGeometry(v value.Value) string {
+	if v.Type() != value.OBJECT {
+		return "geometry must be an object"
+	}
+
+	t, _ := v.Field("type")
+	typ, _ := t.Actual().(string)
+
+	if typ == "GeometryCollection" {
+		geometries, _ := v.Field("geometries")
+		if geometries.Type() != value.ARRAY {
+			return "GeometryCollection geometries must be an array"
+		}
+		for _, g := range geometries.Actual().([]interface{}) {
+			if reason := geojsonGeometry(value.NewValue(g)); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+
+	coords, _ := v.Field("coordinates")
+	switch typ {
+	case "Point":
+		if _, _, ok := geoPosition(coords); !ok {
+			return "Point coordinates must be a valid position"
+		}
+		return ""
+	case "MultiPoint":
+		return geojsonPositions(typ, coords, 0)
+	case "LineString":
+		return geojsonPositions(typ, coords, 2)
+	case "MultiLineString":
+		return geojsonEach(typ, coords, func(c value.Value) string {
+			return geojsonPositions(typ, c, 2)
+		})
+	case "Polygon":
+		return geojsonRings(coords)
+	case "MultiPolygon":
+		return geojsonEach(typ, coords, geojsonRings)
+	}
+
+	return "unknown GeoJSON type " + typ
+}
+
+func geojsonEach(typ string, v value.Value, check func(value.Value) string) string {
+	if v.Type() != value.ARRAY {
+		return typ + " coordinates must be an array"
+	}
+
+	for _, c := range v.Actual().([]interface{}) {
+		if reason := check(value.NewValue(c)); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+func geojsonPositions(typ string, v value.Value, min int) string {
+	if v.Type() != value.ARRAY {
+		return typ + " coordinates must be an array"
+	}
+
+	positions := v.Actual().([]interface{})
+	if len(positions) < min {
+		return typ + " has too few positions"
+	}
+
+	for _, p := range positions {
+		if _, _, ok := geoPosition(value.NewValue(p)); !ok {
+			return typ + " coordinates must be valid positions"
+		}
+	}
+
+	return ""
+}
+
+func geojsonRings(v value.Value) string {
+	return geojsonEach("Polygon", v, func(r value.Value) string {
+		if reason := geojsonPositions("Polygon ring", r, 4); reason != "" {
+			return reason
+		}
+
+		ring := r.Actual().([]interface{})
+		first := value.NewValue(ring[0])
+		last := value.NewValue(ring[len(ring)-1])
+		if !first.Equals(last).Truth() {
+			return "Polygon ring must be closed"
+		}
+
+		return ""
+	})
+}
+
+const _GEOHASH_BASE32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+const _GEOHASH_MAX_PRECISION = 12
+
+/*
+Maximum number of geohash cells GeohashCover will return.
+*/
+const _GEOHASH_COVER_LIMIT = 32
+
+func geohashPrecision(v value.Value) (int, bool) {
+	if v.Type() != value.NUMBER {
+		return 0, false
+	}
+
+	p := v.Actual().(float64)
+	if p != math.Trunc(p) || p < 1 || p > _GEOHASH_MAX_PRECISION {
+		return 0, false
+	}
+
+	return int(p), true
+}
+
+func geohashEncode(lat, lon float64, precision int) string {
+	box := GeoBox{-180.0, -90.0, 180.0, 90.0}
+	hash := make([]byte, precision)
+	even := true
+
+	for i := range hash {
+		ch := 0
+		for bit := 0; bit < 5; bit++ {
+			ch <<= 1
+			if even {
+				mid := (box.West + box.East) / 2.0
+				if lon >= mid {
+					ch |= 1
+					box.West = mid
+				} else {
+					box.East = mid
+				}
+			} else {
+				mid := (box.South + box.North) / 2.0
+				if lat >= mid {
+					ch |= 1
+					box.South = mid
+				} else {
+					box.North = mid
+				}
+			}
+			even = !even
+		}
+		hash[i] = _GEOHASH_BASE32[ch]
+	}
+
+	return string(hash)
+}
+
+func geohashDecode(hash string) (GeoBox, bool) {
+	box := GeoBox{-180.0, -90.0, 180.0, 90.0}
+	if hash == "" {
+		return box, false
+	}
+
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		ch := strings.IndexRune(_GEOHASH_BASE32, c)
+		if ch < 0 {
+			return box, false
+		}
+
+		for bit := 4; bit >= 0; bit-- {
+			on := ch&(1<<uint(bit)) != 0
+			if even {
+				mid := (box.West + box.East) / 2.0
+				if on {
+					box.West = mid
+				} else {
+					box.East = mid
+				}
+			} else {
+				mid := (box.South + box.North) / 2.0
+				if on {
+					box.South = mid
+				} else {
+					box.North = mid
+				}
+			}
+			even = !even
+		}
+	}
+
+	return box, true
+}
+
+/*
+Return the sorted geohash cells, of at most precision characters,
+that cover box. The longest cells are used for which the cover does
+not exceed a fixed number of cells.
+*/
+func GeohashCover(box GeoBox, precision int) []string {
+	for p := precision; p > 0; p-- {
+		lonBits := uint(5*p+1) / 2
+		latBits := uint(5*p) / 2
+		width := 360.0 / float64(int64(1)<<lonBits)
+		height := 180.0 / float64(int64(1)<<latBits)
+
+		cell := func(x float64, size float64, bits uint) int64 {
+			i := int64(math.Floor(x / size))
+			if max := int64(1)<<bits - 1; i > max {
+				return max
+			}
+			return i
+		}
+
+		lat0 := cell(box.South+90.0, height, latBits)
+		lat1 := cell(box.North+90.0, height, latBits)
+		lon0 := cell(box.West+180.0, width, lonBits)
+		lon1 := cell(box.East+180.0, width, lonBits)
+
+		lons := [][2]int64{{lon0, lon1}}
+		if lon0 > lon1 {
+			// Crosses the antimeridian
+			lons = [][2]int64{{0, lon1}, {lon0, int64(1)<<lonBits - 1}}
+		}
+
+		count := int64(0)
+		for _, r := range lons {
+			count += r[1] - r[0] + 1
+		}
+		count *= lat1 - lat0 + 1
+
+		if count > _GEOHASH_COVER_LIMIT && p > 1 {
+			continue
+		}
+
+		cells := make([]string, 0, count)
+		for _, r := range lons {
+			for i := r[0]; i <= r[1]; i++ {
+				for j := lat0; j <= lat1; j++ {
+					lat := -90.0 + (float64(j)+0.5)*height
+					lon := -180.0 + (float64(i)+0.5)*width
+					cells = append(cells, geohashEncode(lat, lon, p))
+				}
+			}
+		}
+
+		sort.Strings(cells)
+		return cells
+	}
+
+	return nil
+}
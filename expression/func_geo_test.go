@@ -0,0 +1,162 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestHaversine(t *testing.T) {
+	// London to Paris
+	d := haversine(51.5074, -0.1278, 48.8566, 2.3522)
+	if math.Abs(d-343556) > 500 {
+		t.Errorf("expected about 343.5km received %v", d)
+	}
+
+	if d := haversine(10, 20, 10, 20); d != 0 {
+		t.Errorf("expected 0 received %v", d)
+	}
+}
+
+func TestGeohash(t *testing.T) {
+	if h := geohashEncode(57.64911, 10.40744, 11); h != "u4pruydqqvj" {
+		t.Errorf("expected u4pruydqqvj received %s", h)
+	}
+
+	box, ok := geohashDecode("u4pruydqqvj")
+	if !ok || !box.contains(57.64911, 10.40744) {
+		t.Errorf("decoded box %v does not contain point", box)
+	}
+
+	if _, ok := geohashDecode("u4pa"); ok {
+		t.Errorf("expected invalid geohash")
+	}
+}
+
+func TestGeohashCover(t *testing.T) {
+	boxes := []GeoBox{
+		{-0.5, 51.3, 0.3, 51.7},
+		{179.5, -10, -179.5, 10},
+		radiusBox(48.8566, 2.3522, 5000),
+		radiusBox(89.9, 0, 50000),
+	}
+
+	for _, box := range boxes {
+		cells := GeohashCover(box, 7)
+		if len(cells) == 0 || len(cells) > _GEOHASH_COVER_LIMIT {
+			t.Errorf("%v: received %d cells", box, len(cells))
+			continue
+		}
+
+		for _, pt := range [][2]float64{
+			{box.South, box.West}, {box.North, box.East},
+			{box.South, box.East}, {box.North, box.West},
+		} {
+			hash := geohashEncode(pt[0], pt[1], 7)
+			covered := false
+			for _, cell := range cells {
+				if strings.HasPrefix(hash, cell) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				t.Errorf("%v: %s not covered by %v", box, hash, cells)
+			}
+		}
+	}
+
+	box := radiusBox(48.8566, 2.3522, 5000)
+	for _, bearing := range []float64{0, 90, 180, 270} {
+		lat, lon := destination(48.8566, 2.3522, bearing, 4999)
+		if !box.contains(lat, lon) {
+			t.Errorf("bearing %v: (%v, %v) outside %v", bearing, lat, lon, box)
+		}
+	}
+}
+
+func TestGeoPolygon(t *testing.T) {
+	polygon, ok := geoPolygons(value.NewValue(map[string]interface{}{
+		"type": "Polygon",
+		"coordinates": []interface{}{
+			[]interface{}{[]interface{}{0, 0}, []interface{}{10, 0}, []interface{}{10, 10}, []interface{}{0, 10}, []interface{}{0, 0}},
+			[]interface{}{[]interface{}{4, 4}, []interface{}{6, 4}, []interface{}{6, 6}, []interface{}{4, 6}, []interface{}{4, 4}},
+		},
+	}))
+	if !ok {
+		t.Fatalf("expected valid polygon")
+	}
+
+	tests := []struct {
+		lat, lon float64
+		in       bool
+	}{
+		{2, 2, true},
+		{5, 5, false},
+		{11, 5, false},
+		{8, 5, true},
+	}
+
+	for _, test := range tests {
+		if in := polygonContains(polygon[0], test.lat, test.lon); in != test.in {
+			t.Errorf("(%v, %v): expected %v received %v", test.lat, test.lon, test.in, in)
+		}
+	}
+}
+
+func TestGeoJSONError(t *testing.T) {
+	valid := []string{
+		`{"type":"Point","coordinates":[1,2]}`,
+		`{"type":"LineString","coordinates":[[1,2],[3,4]]}`,
+		`{"type":"Feature","geometry":null,"properties":{}}`,
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"MultiPoint","coordinates":[]}}]}`,
+		`{"type":"GeometryCollection","geometries":[{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}]}`,
+	}
+
+	for _, v := range valid {
+		if reason := geojsonError(value.NewValue([]byte(v))); reason != "" {
+			t.Errorf("%s: received %s", v, reason)
+		}
+	}
+
+	invalid := []string{
+		`[1,2]`,
+		`{"type":"Point","coordinates":[200,2]}`,
+		`{"type":"LineString","coordinates":[[1,2]]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`,
+		`{"type":"Circle","coordinates":[1,2]}`,
+		`{"type":"Feature"}`,
+	}
+
+	for _, v := range invalid {
+		if reason := geojsonError(value.NewValue([]byte(v))); reason == "" {
+			t.Errorf("%s: expected error", v)
+		}
+	}
+}
+
+/*
+Point at distance meters from lat, lon along bearing degrees.
+*/
+func destination(lat, lon, bearing, distance float64) (float64, float64) {
+	phi := lat * math.Pi / 180.0
+	lambda := lon * math.Pi / 180.0
+	theta := bearing * math.Pi / 180.0
+	delta := distance / _EARTH_RADIUS
+
+	phi2 := math.Asin(math.Sin(phi)*math.Cos(delta) + math.Cos(phi)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi),
+		math.Cos(delta)-math.Sin(phi)*math.Sin(phi2))
+	return phi2 * 180.0 / math.Pi, lambda2 * 180.0 / math.Pi
+}
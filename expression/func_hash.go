@@ -0,0 +1,941 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/couchbase/query/value"
+)
+
+///////////////////////////////////////////////////
+//
+// MD5
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function MD5(expr). It returns the MD5
+digest of expr as a hexadecimal string.
+*/
+type MD5 struct {
+	UnaryFunctionBase
+}
+
+func NewMD5(operand Expression) Function {
+	rv := &MD5{
+		*NewUnaryFunctionBase("md5", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *MD5) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *MD5) Type() value.Type { return value.STRING }
+
+func (this *MD5) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *MD5) Apply(context Context, arg value.Value) (value.Value, error) {
+	return digest(md5.New(), arg), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *MD5) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewMD5(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// SHA1
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function SHA1(expr). It returns the SHA-1
+digest of expr as a hexadecimal string.
+*/
+type SHA1 struct {
+	UnaryFunctionBase
+}
+
+func NewSHA1(operand Expression) Function {
+	rv := &SHA1{
+		*NewUnaryFunctionBase("sha1", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *SHA1) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *SHA1) Type() value.Type { return value.STRING }
+
+func (this *SHA1) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *SHA1) Apply(context Context, arg value.Value) (value.Value, error) {
+	return digest(sha1.New(), arg), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *SHA1) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewSHA1(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// SHA256
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function SHA256(expr). It returns the
+SHA-256 digest of expr as a hexadecimal string.
+*/
+type SHA256 struct {
+	UnaryFunctionBase
+}
+
+func NewSHA256(operand Expression) Function {
+	rv := &SHA256{
+		*NewUnaryFunctionBase("sha256", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *SHA256) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *SHA256) Type() value.Type { return value.STRING }
+
+func (this *SHA256) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *SHA256) Apply(context Context, arg value.Value) (value.Value, error) {
+	return digest(sha256.New(), arg), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *SHA256) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewSHA256(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// SHA512
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function SHA512(expr). It returns the
+SHA-512 digest of expr as a hexadecimal string.
+*/
+type SHA512 struct {
+	UnaryFunctionBase
+}
+
+func NewSHA512(operand Expression) Function {
+	rv := &SHA512{
+		*NewUnaryFunctionBase("sha512", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *SHA512) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *SHA512) Type() value.Type { return value.STRING }
+
+func (this *SHA512) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *SHA512) Apply(context Context, arg value.Value) (value.Value, error) {
+	return digest(sha512.New(), arg), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *SHA512) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewSHA512(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// HMAC
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function HMAC(alg, key, expr). It returns
+the keyed-hash message authentication code of expr as a hexadecimal
+string. alg is one of "md5", "sha1", "sha256" or "sha512".
+*/
+type HMAC struct {
+	TernaryFunctionBase
+}
+
+func NewHMAC(first, second, third Expression) Function {
+	rv := &HMAC{
+		*NewTernaryFunctionBase("hmac", first, second, third),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *HMAC) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *HMAC) Type() value.Type { return value.STRING }
+
+func (this *HMAC) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.TernaryEval(this, item, context)
+}
+
+func (this *HMAC) Apply(context Context, alg, key, arg value.Value) (value.Value, error) {
+	if alg.Type() == value.MISSING || key.Type() == value.MISSING || arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if alg.Type() != value.STRING || (key.Type() != value.STRING && key.Type() != value.BINARY) {
+		return value.NULL_VALUE, nil
+	}
+
+	newHash, ok := _HMAC_HASHES[strings.ToLower(alg.Actual().(string))]
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	return digest(hmac.New(newHash, hashBytes(key)), arg), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *HMAC) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewHMAC(operands[0], operands[1], operands[2])
+	}
+}
+
+var _HMAC_HASHES = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+///////////////////////////////////////////////////
+//
+// CRC32
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function CRC32(expr). It returns the IEEE
+CRC-32 checksum of expr as a number.
+*/
+type CRC32 struct {
+	UnaryFunctionBase
+}
+
+func NewCRC32(operand Expression) Function {
+	rv := &CRC32{
+		*NewUnaryFunctionBase("crc32", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *CRC32) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *CRC32) Type() value.Type { return value.NUMBER }
+
+func (this *CRC32) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *CRC32) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() == value.NULL {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(int64(crc32.ChecksumIEEE(hashBytes(arg)))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *CRC32) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewCRC32(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// Hash64
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the hash function HASH64(expr). It returns the
+64-bit xxHash of the canonical JSON encoding of expr, as a signed
+integer. Object fields are encoded in sorted order, so equal values
+always have equal hashes. BINARY values are hashed as raw bytes.
+*/
+type Hash64 struct {
+	UnaryFunctionBase
+}
+
+func NewHash64(operand Expression) Function {
+	rv := &Hash64{
+		*NewUnaryFunctionBase("hash64", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Hash64) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Hash64) Type() value.Type { return value.NUMBER }
+
+func (this *Hash64) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *Hash64) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() == value.NULL {
+		return value.NULL_VALUE, nil
+	}
+
+	var bytes []byte
+	if arg.Type() == value.BINARY {
+		bytes = arg.Actual().([]byte)
+	} else {
+		var err error
+		bytes, err = arg.MarshalJSON()
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	return value.NewValue(int64(xxhash64(bytes, 0))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *Hash64) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewHash64(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// HexEncode
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the function HEX_ENCODE(expr). It returns the
+hexadecimal encoding of expr.
+*/
+type HexEncode struct {
+	UnaryFunctionBase
+}
+
+func NewHexEncode(operand Expression) Function {
+	rv := &HexEncode{
+		*NewUnaryFunctionBase("hex_encode", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *HexEncode) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *HexEncode) Type() value.Type { return value.STRING }
+
+func (this *HexEncode) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *HexEncode) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() == value.NULL {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(hex.EncodeToString(hashBytes(arg))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *HexEncode) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewHexEncode(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// HexDecode
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the function HEX_DECODE(expr). It returns the
+hexadecimal decoding of expr, as a string if the decoded bytes
+are valid UTF-8, and as a BINARY value otherwise.
+*/
+type HexDecode struct {
+	UnaryFunctionBase
+}
+
+func NewHexDecode(operand Expression) Function {
+	rv := &HexDecode{
+		*NewUnaryFunctionBase("hex_decode", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *HexDecode) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *HexDecode) Type() value.Type { return value.STRING }
+
+func (this *HexDecode) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *HexDecode) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	bytes, err := hex.DecodeString(arg.Actual().(string))
+	if err != nil {
+		return value.NULL_VALUE, nil
+	} else if !utf8.Valid(bytes) {
+		return value.NewBinaryValue(bytes), nil
+	}
+
+	return value.NewValue(string(bytes)), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *HexDecode) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewHexDecode(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// URLEncode
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the function URL_ENCODE(expr). It escapes the
+string so that it can be safely placed inside a URL query.
+*/
+type URLEncode struct {
+	UnaryFunctionBase
+}
+
+func NewURLEncode(operand Expression) Function {
+	rv := &URLEncode{
+		*NewUnaryFunctionBase("url_encode", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *URLEncode) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *URLEncode) Type() value.Type { return value.STRING }
+
+func (this *URLEncode) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *URLEncode) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(url.QueryEscape(arg.Actual().(string))), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *URLEncode) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewURLEncode(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// URLDecode
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the function URL_DECODE(expr). It is the inverse
+of URL_ENCODE, and returns null if expr is not correctly escaped.
+*/
+type URLDecode struct {
+	UnaryFunctionBase
+}
+
+func NewURLDecode(operand Expression) Function {
+	rv := &URLDecode{
+		*NewUnaryFunctionBase("url_decode", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *URLDecode) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *URLDecode) Type() value.Type { return value.STRING }
+
+func (this *URLDecode) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *URLDecode) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	str, err := url.QueryUnescape(arg.Actual().(string))
+	if err != nil {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(str), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *URLDecode) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewURLDecode(operands[0])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// Redact
+//
+///////////////////////////////////////////////////
+
+const (
+	REDACT_HASH    = "hash"
+	REDACT_PARTIAL = "partial"
+	REDACT_NULL    = "null"
+)
+
+/*
+The key of the HMAC that "hash" masks with, so that values with few
+possibilities cannot be recovered by hashing them all. It is random,
+unless configured, in which case hashes can be compared across nodes
+and restarts.
+*/
+var redactKey = newRedactKey()
+
+func newRedactKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+/*
+Set the key REDACT hashes with. Meant to be called at startup.
+*/
+func SetRedactKey(key []byte) {
+	redactKey = key
+}
+
+/*
+This represents the function REDACT(expr, masks). masks is an
+object that maps dotted paths within expr to masking methods: "hash"
+replaces a value with its HMAC-SHA256 digest, keyed with the server's
+redaction key, "partial" replaces all but
+the last four characters of a string with "*", and "null" replaces a
+value with null. The empty path masks expr itself. Arrays along a path
+are masked element by element.
+*/
+type Redact struct {
+	FunctionBase
+}
+
+func NewRedact(operands ...Expression) Function {
+	rv := &Redact{
+		*NewFunctionBase("redact", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Redact) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Redact) Type() value.Type { return value.JSON }
+
+func (this *Redact) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+/*
+A redacted expression keeps the alias of the expression it masks,
+so that masking a projected field does not rename it.
+*/
+func (this *Redact) Alias() string {
+	return this.operands[0].Alias()
+}
+
+func (this *Redact) Apply(context Context, args ...value.Value) (value.Value, error) {
+	first := args[0]
+	masks := args[1]
+	if first.Type() == value.MISSING || masks.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if masks.Type() != value.OBJECT {
+		return value.NULL_VALUE, nil
+	}
+
+	fields := masks.Fields()
+	paths := make([]string, 0, len(fields))
+	for path, _ := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	rv := first
+	for _, path := range paths {
+		method, _ := masks.Field(path)
+		if method.Type() != value.STRING {
+			return value.NULL_VALUE, nil
+		}
+
+		var names []string
+		if path != "" {
+			names = strings.Split(path, ".")
+		}
+		rv = RedactValue(rv, names, method.Actual().(string))
+	}
+
+	return rv, nil
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *Redact) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 2.
+*/
+func (this *Redact) MaxArgs() int { return 2 }
+
+/*
+Factory method pattern.
+*/
+func (this *Redact) Constructor() FunctionConstructor {
+	return NewRedact
+}
+
+/*
+Mask the value found at path within val, copying the objects and
+arrays along the path so that val itself is unchanged. Unknown
+methods null the value out.
+*/
+func RedactValue(val value.Value, path []string, method string) value.Value {
+	if val.Type() == value.MISSING {
+		return val
+	}
+
+	if len(path) == 0 {
+		switch method {
+		case REDACT_HASH:
+			return digest(hmac.New(sha256.New, redactKey), val)
+		case REDACT_PARTIAL:
+			if val.Type() != value.STRING {
+				return value.NULL_VALUE
+			}
+
+			runes := []rune(val.Actual().(string))
+			n := len(runes)
+			if n > 4 {
+				n -= 4
+			}
+			for i := 0; i < n; i++ {
+				runes[i] = '*'
+			}
+			return value.NewValue(string(runes))
+		default:
+			return value.NULL_VALUE
+		}
+	}
+
+	switch val.Type() {
+	case value.OBJECT:
+		child, ok := val.Field(path[0])
+		if !ok {
+			return val
+		}
+
+		rv := val.Copy()
+		rv.SetField(path[0], RedactValue(child, path[1:], method))
+		return rv
+	case value.ARRAY:
+		elems := val.Actual().([]interface{})
+		rv := make([]interface{}, len(elems))
+		for i, elem := range elems {
+			rv[i] = RedactValue(value.NewValue(elem), path, method)
+		}
+		return value.NewValue(rv)
+	}
+
+	return val
+}
+
+/*
+Returns the hexadecimal digest of the bytes of arg, or missing
+if arg is missing, and null if arg is null.
+*/
+func digest(h hash.Hash, arg value.Value) value.Value {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE
+	} else if arg.Type() == value.NULL {
+		return value.NULL_VALUE
+	}
+
+	h.Write(hashBytes(arg))
+	return value.NewValue(hex.EncodeToString(h.Sum(nil)))
+}
+
+/*
+Returns the bytes that are hashed or encoded for a value. Strings
+and BINARY values use their raw bytes, and all other values use
+their canonical JSON encoding.
+*/
+func hashBytes(arg value.Value) []byte {
+	switch arg.Type() {
+	case value.STRING:
+		return []byte(arg.Actual().(string))
+	case value.BINARY:
+		return arg.Actual().([]byte)
+	default:
+		bytes, _ := arg.MarshalJSON()
+		return bytes
+	}
+}
+
+const (
+	_XXH_PRIME1 uint64 = 11400714785074694791
+	_XXH_PRIME2 uint64 = 14029467366897019727
+	_XXH_PRIME3 uint64 = 1609587929392839161
+	_XXH_PRIME4 uint64 = 9650029242287828579
+	_XXH_PRIME5 uint64 = 2870177450012600261
+)
+
+/*
+The 64-bit xxHash of b. The algorithm is fixed, so that hashes can
+be stored in documents and indexes.
+*/
+func xxhash64(b []byte, seed uint64) uint64 {
+	n := len(b)
+	var h uint64
+
+	if n >= 32 {
+		v1 := seed + _XXH_PRIME1 + _XXH_PRIME2
+		v2 := seed + _XXH_PRIME2
+		v3 := seed
+		v4 := seed - _XXH_PRIME1
+		for len(b) >= 32 {
+			v1 = xxhRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxhRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxhRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxhRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+			b = b[32:]
+		}
+
+		h = rotl64(v1, 1) + rotl64(v2, 7) + rotl64(v3, 12) + rotl64(v4, 18)
+		h = xxhMerge(h, v1)
+		h = xxhMerge(h, v2)
+		h = xxhMerge(h, v3)
+		h = xxhMerge(h, v4)
+	} else {
+		h = seed + _XXH_PRIME5
+	}
+
+	h += uint64(n)
+
+	for len(b) >= 8 {
+		h ^= xxhRound(0, binary.LittleEndian.Uint64(b[0:8]))
+		h = rotl64(h, 27)*_XXH_PRIME1 + _XXH_PRIME4
+		b = b[8:]
+	}
+
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b[0:4])) * _XXH_PRIME1
+		h = rotl64(h, 23)*_XXH_PRIME2 + _XXH_PRIME3
+		b = b[4:]
+	}
+
+	for _, c := range b {
+		h ^= uint64(c) * _XXH_PRIME5
+		h = rotl64(h, 11) * _XXH_PRIME1
+	}
+
+	h ^= h >> 33
+	h *= _XXH_PRIME2
+	h ^= h >> 29
+	h *= _XXH_PRIME3
+	h ^= h >> 32
+	return h
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * _XXH_PRIME2
+	return rotl64(acc, 31) * _XXH_PRIME1
+}
+
+func xxhMerge(acc, val uint64) uint64 {
+	acc ^= xxhRound(0, val)
+	return acc*_XXH_PRIME1 + _XXH_PRIME4
+}
+
+func rotl64(x uint64, r uint) uint64 {
+	return (x << r) | (x >> (64 - r))
+}
@@ -0,0 +1,131 @@
+package expression
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestXXHash64(t *testing.T) {
+	tests := []struct {
+		in  string
+		out uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+
+	for _, test := range tests {
+		if h := xxhash64([]byte(test.in), 0); h != test.out {
+			t.Errorf("%q: expected %x received %x", test.in, test.out, h)
+		}
+	}
+}
+
+func TestHashBinary(t *testing.T) {
+	bin := value.NewBinaryValue([]byte{0xff, 0x00, 0x61})
+	rv, _ := NewMD5(NewConstant(bin)).Evaluate(nil, nil)
+	if rv.Actual().(string) != "310e56cdb9dccaf757dbcab30054500e" {
+		t.Errorf("unexpected MD5 of binary %v", rv.Actual())
+	}
+
+	rv, _ = NewHexEncode(NewConstant(bin)).Evaluate(nil, nil)
+	if rv.Actual().(string) != "ff0061" {
+		t.Errorf("expected ff0061 received %v", rv.Actual())
+	}
+
+	rv, _ = NewHexDecode(NewConstant("ff0061")).Evaluate(nil, nil)
+	if rv.Type() != value.BINARY || !rv.EquivalentTo(bin) {
+		t.Errorf("expected binary round trip received %v", rv)
+	}
+
+	h1, _ := NewHash64(NewConstant(bin)).Evaluate(nil, nil)
+	h2, _ := NewHash64(NewConstant(value.NewBinaryValue([]byte{0xff, 0x00, 0x62}))).Evaluate(nil, nil)
+	if h1.Equals(h2).Truth() {
+		t.Errorf("expected distinct hashes for distinct binary values")
+	}
+}
+
+func TestHashNullMissing(t *testing.T) {
+	key := NewConstant("key")
+	for _, arg := range []value.Value{value.NULL_VALUE, value.MISSING_VALUE} {
+		operand := NewConstant(arg)
+		hashes := []Function{
+			NewMD5(operand),
+			NewSHA1(operand),
+			NewSHA256(operand),
+			NewSHA512(operand),
+			NewHMAC(NewConstant("sha256"), key, operand),
+			NewCRC32(operand),
+			NewHash64(operand),
+			NewHexEncode(operand),
+		}
+
+		for _, h := range hashes {
+			rv, _ := h.Evaluate(nil, nil)
+			if rv.Type() != arg.Type() {
+				t.Errorf("%s(%v): expected %v received %v", h.Name(), arg, arg, rv)
+			}
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	doc := map[string]interface{}{
+		"name":  "ann",
+		"email": "ann@example.com",
+		"card":  "4111111111111111",
+		"addresses": []interface{}{
+			map[string]interface{}{"zip": "94040"},
+			map[string]interface{}{"zip": "10001"},
+		},
+	}
+	masks := map[string]interface{}{
+		"email":         REDACT_HASH,
+		"card":          REDACT_PARTIAL,
+		"addresses.zip": REDACT_NULL,
+	}
+
+	rv, _ := NewRedact(NewConstant(doc), NewConstant(masks)).Evaluate(nil, nil)
+	expected := value.NewValue(map[string]interface{}{
+		"name":  "ann",
+		"email": digest(hmac.New(sha256.New, redactKey), value.NewValue("ann@example.com")).Actual(),
+		"card":  "************1111",
+		"addresses": []interface{}{
+			map[string]interface{}{"zip": nil},
+			map[string]interface{}{"zip": nil},
+		},
+	})
+	if !rv.EquivalentTo(expected) {
+		t.Errorf("expected %v received %v", expected, rv)
+	}
+
+	// the document itself is unchanged
+	if doc["email"] != "ann@example.com" || doc["addresses"].([]interface{})[0].(map[string]interface{})["zip"] != "94040" {
+		t.Errorf("expected the redacted document to be copied, found %v", doc)
+	}
+
+	rv, _ = NewRedact(NewConstant("abc"), NewConstant(map[string]interface{}{"": REDACT_PARTIAL})).Evaluate(nil, nil)
+	if rv.Actual().(string) != "***" {
+		t.Errorf("expected short strings to be fully masked, received %v", rv)
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	defer SetRedactKey(redactKey)
+
+	masks := NewConstant(map[string]interface{}{"": REDACT_HASH})
+	SetRedactKey([]byte("key1"))
+	rv1, _ := NewRedact(NewConstant("1234"), masks).Evaluate(nil, nil)
+	SetRedactKey([]byte("key2"))
+	rv2, _ := NewRedact(NewConstant("1234"), masks).Evaluate(nil, nil)
+
+	plain := digest(sha256.New(), value.NewValue("1234"))
+	if rv1.Equals(rv2).Truth() || rv1.Equals(plain).Truth() {
+		t.Errorf("expected hashes to depend on the key, received %v and %v", rv1, rv2)
+	}
+}
@@ -112,13 +112,16 @@ var _FUNCTIONS = map[string]Function{
 	"date_add_str":        &DateAddStr{},
 	"date_diff_millis":    &DateDiffMillis{},
 	"date_diff_str":       &DateDiffStr{},
+	"date_format":         &DateFormat{},
 	"date_format_str":     &DateFormatStr{},
 	"date_part_millis":    &DatePartMillis{},
 	"date_part_str":       &DatePartStr{},
 	"date_range_millis":   &DateRangeMillis{},
 	"date_range_str":      &DateRangeStr{},
+	"date_range_tz":       &DateRangeTZ{},
 	"date_trunc_millis":   &DateTruncMillis{},
 	"date_trunc_str":      &DateTruncStr{},
+	"duration_to_iso":     &DurationToISO{},
 	"duration_to_str":     &DurationToStr{},
 	"millis":              &StrToMillis{},
 	"millis_to_local":     &MillisToStr{},
@@ -131,6 +134,7 @@ var _FUNCTIONS = map[string]Function{
 	"now_str":             &NowStr{},
 	"now_tz":              &NowTZ{},
 	"now_utc":             &NowUTC{},
+	"str_to_date":         &StrToDate{},
 	"str_to_duration":     &StrToDuration{},
 	"str_to_millis":       &StrToMillis{},
 	"str_to_tz":           &StrToZoneName{},
@@ -140,29 +144,42 @@ var _FUNCTIONS = map[string]Function{
 	"weekday_str":         &WeekdayStr{},
 
 	// String
-	"contains":  &Contains{},
-	"initcap":   &Title{},
-	"length":    &Length{},
-	"lower":     &Lower{},
-	"ltrim":     &LTrim{},
-	"position":  &Position0{},
-	"pos":       &Position0{},
-	"position0": &Position0{},
-	"pos0":      &Position0{},
-	"position1": &Position1{},
-	"pos1":      &Position1{},
-	"repeat":    &Repeat{},
-	"replace":   &Replace{},
-	"reverse":   &Reverse{},
-	"rtrim":     &RTrim{},
-	"split":     &Split{},
-	"substr":    &Substr0{},
-	"substr0":   &Substr0{},
-	"substr1":   &Substr1{},
-	"suffixes":  &Suffixes{},
-	"title":     &Title{},
-	"trim":      &Trim{},
-	"upper":     &Upper{},
+	"char_length":      &CharLength{},
+	"character_length": &CharLength{},
+	"contains":         &Contains{},
+	"edit_distance":    &Levenshtein{},
+	"format_number":    &FormatNumber{},
+	"initcap":          &Title{},
+	"jaro_winkler":     &JaroWinkler{},
+	"length":           &Length{},
+	"levenshtein":      &Levenshtein{},
+	"lower":            &Lower{},
+	"lpad":             &LPad{},
+	"ltrim":            &LTrim{},
+	"mask":             &Mask{},
+	"metaphone":        &Metaphone{},
+	"normalize":        &Normalize{},
+	"octet_length":     &OctetLength{},
+	"position":         &Position0{},
+	"pos":              &Position0{},
+	"position0":        &Position0{},
+	"pos0":             &Position0{},
+	"position1":        &Position1{},
+	"pos1":             &Position1{},
+	"repeat":           &Repeat{},
+	"replace":          &Replace{},
+	"reverse":          &Reverse{},
+	"rpad":             &RPad{},
+	"rtrim":            &RTrim{},
+	"soundex":          &Soundex{},
+	"split":            &Split{},
+	"substr":           &Substr0{},
+	"substr0":          &Substr0{},
+	"substr1":          &Substr1{},
+	"suffixes":         &Suffixes{},
+	"title":            &Title{},
+	"trim":             &Trim{},
+	"upper":            &Upper{},
 
 	// Regular expressions
 	"contains_regex":   &RegexpContains{},
@@ -299,6 +316,31 @@ var _FUNCTIONS = map[string]Function{
 	"decode_base64": &Base64Decode{},
 	"encode_base64": &Base64Encode{},
 
+	// Hash and encoding
+	"crc32":      &CRC32{},
+	"hash64":     &Hash64{},
+	"hex_decode": &HexDecode{},
+	"hex_encode": &HexEncode{},
+	"hmac":       &HMAC{},
+	"md5":        &MD5{},
+	"redact":     &Redact{},
+	"sha1":       &SHA1{},
+	"sha256":     &SHA256{},
+	"sha512":     &SHA512{},
+	"url_decode": &URLDecode{},
+	"url_encode": &URLEncode{},
+
+	// Geospatial
+	"geo_distance":       &GeoDistance{},
+	"geo_within_bbox":    &GeoWithinBBox{},
+	"geo_within_polygon": &GeoWithinPolygon{},
+	"geo_within_radius":  &GeoWithinRadius{},
+	"geohash":            &GeohashEncode{},
+	"geohash_decode":     &GeohashDecode{},
+	"geohash_encode":     &GeohashEncode{},
+	"geojson_error":      &GeoJSONError{},
+	"is_geojson":         &IsGeoJSON{},
+
 	// Comparison
 	"greatest":  &Greatest{},
 	"least":     &Least{},
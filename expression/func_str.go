@@ -11,13 +11,74 @@ package expression
 
 import (
 	"math"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
+	"golang.org/x/text/unicode/norm"
 )
 
+///////////////////////////////////////////////////
+//
+// CharLength
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function CHAR_LENGTH(expr). It returns
+the number of Unicode characters in the string value, whereas
+LENGTH returns the number of bytes.
+*/
+type CharLength struct {
+	UnaryFunctionBase
+}
+
+func NewCharLength(operand Expression) Function {
+	rv := &CharLength{
+		*NewUnaryFunctionBase("char_length", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *CharLength) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *CharLength) Type() value.Type { return value.NUMBER }
+
+func (this *CharLength) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *CharLength) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	rv := utf8.RuneCountInString(arg.Actual().(string))
+	return value.NewValue(rv), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *CharLength) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewCharLength(operands[0])
+	}
+}
+
 ///////////////////////////////////////////////////
 //
 // Contains
@@ -91,6 +152,127 @@ func (this *Contains) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// FormatNumber
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function
+FORMAT_NUMBER(expr [, decimals [, thousands [, point ]]]).
+It returns the number rounded to the given number of decimals
+(0 by default), with thousands separated by the thousands string
+("," by default) and the decimals introduced by the point string
+("." by default).
+*/
+type FormatNumber struct {
+	FunctionBase
+}
+
+func NewFormatNumber(operands ...Expression) Function {
+	rv := &FormatNumber{
+		*NewFunctionBase("format_number", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *FormatNumber) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *FormatNumber) Type() value.Type { return value.STRING }
+
+func (this *FormatNumber) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *FormatNumber) Apply(context Context, args ...value.Value) (value.Value, error) {
+	null := false
+
+	for i, a := range args {
+		if a.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		} else if (i < 2 && a.Type() != value.NUMBER) || (i >= 2 && a.Type() != value.STRING) {
+			null = true
+		}
+	}
+
+	if null {
+		return value.NULL_VALUE, nil
+	}
+
+	decimals := 0.0
+	if len(args) > 1 {
+		decimals = args[1].Actual().(float64)
+		if decimals < 0 || decimals > 20 || decimals != math.Trunc(decimals) {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	thousands := ","
+	if len(args) > 2 {
+		thousands = args[2].Actual().(string)
+	}
+
+	point := "."
+	if len(args) > 3 {
+		point = args[3].Actual().(string)
+	}
+
+	num := args[0].Actual().(float64)
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		return value.NULL_VALUE, nil
+	}
+
+	str := strconv.FormatFloat(math.Abs(num), 'f', int(decimals), 64)
+	whole, frac := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		whole, frac = str[:i], str[i+1:]
+	}
+
+	buf := make([]byte, 0, len(str)+len(str)/3*len(thousands)+len(point)+1)
+	if num < 0 && strings.Trim(str, "0.") != "" {
+		buf = append(buf, '-')
+	}
+
+	for i := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			buf = append(buf, thousands...)
+		}
+		buf = append(buf, whole[i])
+	}
+
+	if frac != "" {
+		buf = append(buf, point...)
+		buf = append(buf, frac...)
+	}
+
+	return value.NewValue(string(buf)), nil
+}
+
+/*
+Minimum input arguments required is 1.
+*/
+func (this *FormatNumber) MinArgs() int { return 1 }
+
+/*
+Maximum input arguments allowed is 4.
+*/
+func (this *FormatNumber) MaxArgs() int { return 4 }
+
+/*
+Factory method pattern.
+*/
+func (this *FormatNumber) Constructor() FunctionConstructor {
+	return NewFormatNumber
+}
+
 ///////////////////////////////////////////////////
 //
 // Length
@@ -214,6 +396,113 @@ func (this *Lower) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// LPad
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function LPAD(expr, n [, pad ]).
+It returns the string left-padded with pad (a space by default)
+to n characters. Longer strings are truncated to n characters.
+*/
+type LPad struct {
+	FunctionBase
+}
+
+func NewLPad(operands ...Expression) Function {
+	rv := &LPad{
+		*NewFunctionBase("lpad", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *LPad) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *LPad) Type() value.Type { return value.STRING }
+
+func (this *LPad) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *LPad) Apply(context Context, args ...value.Value) (value.Value, error) {
+	return padApply("LPAD()", args, true)
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *LPad) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *LPad) MaxArgs() int { return 3 }
+
+/*
+Factory method pattern.
+*/
+func (this *LPad) Constructor() FunctionConstructor {
+	return NewLPad
+}
+
+/*
+Pad or truncate the string in args[0] to args[1] characters,
+using the optional pad string in args[2].
+*/
+func padApply(name string, args []value.Value, left bool) (value.Value, error) {
+	pad := _SPACE
+	if len(args) > 2 {
+		pad = args[2]
+	}
+
+	if args[0].Type() == value.MISSING || args[1].Type() == value.MISSING || pad.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if args[0].Type() != value.STRING || args[1].Type() != value.NUMBER || pad.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	nf := args[1].Actual().(float64)
+	if nf < 0.0 || nf != math.Trunc(nf) {
+		return value.NULL_VALUE, nil
+	}
+
+	n := int(nf)
+	if n > RANGE_LIMIT {
+		return nil, errors.NewRangeError(name)
+	}
+
+	str := []rune(args[0].Actual().(string))
+	if len(str) >= n {
+		return value.NewValue(string(str[:n])), nil
+	}
+
+	p := []rune(pad.Actual().(string))
+	if len(p) == 0 {
+		return value.NULL_VALUE, nil
+	}
+
+	fill := make([]rune, n-len(str))
+	for i := range fill {
+		fill[i] = p[i%len(p)]
+	}
+
+	if left {
+		return value.NewValue(string(fill) + string(str)), nil
+	}
+	return value.NewValue(string(str) + string(fill)), nil
+}
+
+var _SPACE = value.NewValue(" ")
+
 ///////////////////////////////////////////////////
 //
 // LTrim
@@ -300,6 +589,269 @@ Define variable whitespace that constructs a value from
 */
 var _WHITESPACE = value.NewValue(" \t\n\f\r")
 
+///////////////////////////////////////////////////
+//
+// Mask
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function MASK(expr, pattern [, mask ]).
+It replaces every character matched by the regular expression
+pattern with the mask character ("*" by default). If the pattern
+contains capturing groups, only the characters matched by the groups
+are replaced, so that the rest of each match is kept.
+*/
+type Mask struct {
+	FunctionBase
+	re *regexp.Regexp
+}
+
+func NewMask(operands ...Expression) Function {
+	rv := &Mask{
+		*NewFunctionBase("mask", operands...),
+		nil,
+	}
+
+	rv.re, _ = precompileRegexp(operands[1].Value(), false)
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Mask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Mask) Type() value.Type { return value.STRING }
+
+func (this *Mask) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *Mask) Apply(context Context, args ...value.Value) (value.Value, error) {
+	null := false
+
+	for _, a := range args {
+		if a.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		} else if a.Type() != value.STRING {
+			null = true
+		}
+	}
+
+	if null {
+		return value.NULL_VALUE, nil
+	}
+
+	mask := "*"
+	if len(args) > 2 {
+		mask = args[2].Actual().(string)
+		if utf8.RuneCountInString(mask) != 1 {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	re := this.re
+	if re == nil {
+		var err error
+		re, err = regexp.Compile(args[1].Actual().(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	str := args[0].Actual().(string)
+	masked := make([]bool, len(str))
+	for _, m := range re.FindAllStringSubmatchIndex(str, -1) {
+		spans := m[2:]
+		if len(spans) == 0 {
+			spans = m[:2]
+		}
+
+		for i := 0; i+1 < len(spans); i += 2 {
+			for j := spans[i]; j >= 0 && j < spans[i+1]; j++ {
+				masked[j] = true
+			}
+		}
+	}
+
+	buf := make([]byte, 0, len(str))
+	for i, r := range str {
+		if masked[i] {
+			buf = append(buf, mask...)
+		} else {
+			buf = append(buf, string(r)...)
+		}
+	}
+
+	return value.NewValue(string(buf)), nil
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *Mask) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *Mask) MaxArgs() int { return 3 }
+
+/*
+Factory method pattern.
+*/
+func (this *Mask) Constructor() FunctionConstructor {
+	return NewMask
+}
+
+///////////////////////////////////////////////////
+//
+// Normalize
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function NORMALIZE(expr [, form ]).
+It returns the Unicode normalization of the string, in one of the
+forms "NFC" (the default), "NFD", "NFKC" or "NFKD".
+*/
+type Normalize struct {
+	FunctionBase
+}
+
+func NewNormalize(operands ...Expression) Function {
+	rv := &Normalize{
+		*NewFunctionBase("normalize", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *Normalize) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Normalize) Type() value.Type { return value.STRING }
+
+func (this *Normalize) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *Normalize) Apply(context Context, args ...value.Value) (value.Value, error) {
+	null := false
+
+	for _, a := range args {
+		if a.Type() == value.MISSING {
+			return value.MISSING_VALUE, nil
+		} else if a.Type() != value.STRING {
+			null = true
+		}
+	}
+
+	if null {
+		return value.NULL_VALUE, nil
+	}
+
+	form := norm.NFC
+	if len(args) > 1 {
+		var ok bool
+		form, ok = _NORMAL_FORMS[strings.ToUpper(args[1].Actual().(string))]
+		if !ok {
+			return value.NULL_VALUE, nil
+		}
+	}
+
+	return value.NewValue(form.String(args[0].Actual().(string))), nil
+}
+
+/*
+Minimum input arguments required is 1.
+*/
+func (this *Normalize) MinArgs() int { return 1 }
+
+/*
+Maximum input arguments allowed is 2.
+*/
+func (this *Normalize) MaxArgs() int { return 2 }
+
+/*
+Factory method pattern.
+*/
+func (this *Normalize) Constructor() FunctionConstructor {
+	return NewNormalize
+}
+
+var _NORMAL_FORMS = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+///////////////////////////////////////////////////
+//
+// OctetLength
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function OCTET_LENGTH(expr). It returns
+the number of bytes in the UTF-8 encoding of the string value, and
+is the same as LENGTH.
+*/
+type OctetLength struct {
+	UnaryFunctionBase
+}
+
+func NewOctetLength(operand Expression) Function {
+	rv := &OctetLength{
+		*NewUnaryFunctionBase("octet_length", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *OctetLength) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *OctetLength) Type() value.Type { return value.NUMBER }
+
+func (this *OctetLength) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *OctetLength) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	rv := len(arg.Actual().(string))
+	return value.NewValue(rv), nil
+}
+
+/*
+Factory method pattern.
+*/
+func (this *OctetLength) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewOctetLength(operands[0])
+	}
+}
+
 ///////////////////////////////////////////////////
 //
 // Position0
@@ -616,6 +1168,64 @@ func (this *Reverse) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// RPad
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the String function RPAD(expr, n [, pad ]).
+It returns the string right-padded with pad (a space by default)
+to n characters. Longer strings are truncated to n characters.
+*/
+type RPad struct {
+	FunctionBase
+}
+
+func NewRPad(operands ...Expression) Function {
+	rv := &RPad{
+		*NewFunctionBase("rpad", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+Visitor pattern.
+*/
+func (this *RPad) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *RPad) Type() value.Type { return value.STRING }
+
+func (this *RPad) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+func (this *RPad) Apply(context Context, args ...value.Value) (value.Value, error) {
+	return padApply("RPAD()", args, false)
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *RPad) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 3.
+*/
+func (this *RPad) MaxArgs() int { return 3 }
+
+/*
+Factory method pattern.
+*/
+func (this *RPad) Constructor() FunctionConstructor {
+	return NewRPad
+}
+
 ///////////////////////////////////////////////////
 //
 // RTrim
@@ -11434,6 +11434,213 @@ var dfas = []dfa{
 		},
 	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1, -1}, nil},
 
+	// [fF][oO][rR][mM][aA][tT]
+	{[]bool{false, false, false, false, false, false, true}, []func(rune) int{ // Transitions
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 70:
+				return 1
+			case 77:
+				return -1
+			case 79:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 102:
+				return 1
+			case 109:
+				return -1
+			case 111:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 70:
+				return -1
+			case 77:
+				return -1
+			case 79:
+				return 2
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 102:
+				return -1
+			case 109:
+				return -1
+			case 111:
+				return 2
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 70:
+				return -1
+			case 77:
+				return -1
+			case 79:
+				return -1
+			case 82:
+				return 3
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 102:
+				return -1
+			case 109:
+				return -1
+			case 111:
+				return -1
+			case 114:
+				return 3
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 70:
+				return -1
+			case 77:
+				return 4
+			case 79:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 102:
+				return -1
+			case 109:
+				return 4
+			case 111:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return 5
+			case 70:
+				return -1
+			case 77:
+				return -1
+			case 79:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return 5
+			case 102:
+				return -1
+			case 109:
+				return -1
+			case 111:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 70:
+				return -1
+			case 77:
+				return -1
+			case 79:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return 6
+			case 97:
+				return -1
+			case 102:
+				return -1
+			case 109:
+				return -1
+			case 111:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return 6
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 70:
+				return -1
+			case 77:
+				return -1
+			case 79:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 102:
+				return -1
+			case 109:
+				return -1
+			case 111:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1, -1, -1}, nil},
+
 	// [fF][rR][oO][mM]
 	{[]bool{false, false, false, false, true}, []func(rune) int{ // Transitions
 		func(r rune) int {
@@ -15729,24 +15936,28 @@ var dfas = []dfa{
 		},
 	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1}, nil},
 
-	// [lL][eE][fF][tT]
-	{[]bool{false, false, false, false, true}, []func(rune) int{ // Transitions
+	// [lL][aA][tT][eE][rR][aA][lL]
+	{[]bool{false, false, false, false, false, false, false, true}, []func(rune) int{ // Transitions
 		func(r rune) int {
 			switch r {
-			case 69:
+			case 65:
 				return -1
-			case 70:
+			case 69:
 				return -1
 			case 76:
 				return 1
+			case 82:
+				return -1
 			case 84:
 				return -1
-			case 101:
+			case 97:
 				return -1
-			case 102:
+			case 101:
 				return -1
 			case 108:
 				return 1
+			case 114:
+				return -1
 			case 116:
 				return -1
 			}
@@ -15754,20 +15965,24 @@ var dfas = []dfa{
 		},
 		func(r rune) int {
 			switch r {
-			case 69:
+			case 65:
 				return 2
-			case 70:
+			case 69:
 				return -1
 			case 76:
 				return -1
+			case 82:
+				return -1
 			case 84:
 				return -1
-			case 101:
+			case 97:
 				return 2
-			case 102:
+			case 101:
 				return -1
 			case 108:
 				return -1
+			case 114:
+				return -1
 			case 116:
 				return -1
 			}
@@ -15775,35 +15990,231 @@ var dfas = []dfa{
 		},
 		func(r rune) int {
 			switch r {
+			case 65:
+				return -1
 			case 69:
 				return -1
-			case 70:
-				return 3
 			case 76:
 				return -1
+			case 82:
+				return -1
 			case 84:
+				return 3
+			case 97:
 				return -1
 			case 101:
 				return -1
-			case 102:
-				return 3
 			case 108:
 				return -1
-			case 116:
+			case 114:
 				return -1
+			case 116:
+				return 3
 			}
 			return -1
 		},
 		func(r rune) int {
 			switch r {
-			case 69:
-				return -1
-			case 70:
+			case 65:
 				return -1
+			case 69:
+				return 4
 			case 76:
 				return -1
+			case 82:
+				return -1
 			case 84:
-				return 4
+				return -1
+			case 97:
+				return -1
+			case 101:
+				return 4
+			case 108:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 69:
+				return -1
+			case 76:
+				return -1
+			case 82:
+				return 5
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 101:
+				return -1
+			case 108:
+				return -1
+			case 114:
+				return 5
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return 6
+			case 69:
+				return -1
+			case 76:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return 6
+			case 101:
+				return -1
+			case 108:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 69:
+				return -1
+			case 76:
+				return 7
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 101:
+				return -1
+			case 108:
+				return 7
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 69:
+				return -1
+			case 76:
+				return -1
+			case 82:
+				return -1
+			case 84:
+				return -1
+			case 97:
+				return -1
+			case 101:
+				return -1
+			case 108:
+				return -1
+			case 114:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1, -1, -1, -1}, nil},
+
+	// [lL][eE][fF][tT]
+	{[]bool{false, false, false, false, true}, []func(rune) int{ // Transitions
+		func(r rune) int {
+			switch r {
+			case 69:
+				return -1
+			case 70:
+				return -1
+			case 76:
+				return 1
+			case 84:
+				return -1
+			case 101:
+				return -1
+			case 102:
+				return -1
+			case 108:
+				return 1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 69:
+				return 2
+			case 70:
+				return -1
+			case 76:
+				return -1
+			case 84:
+				return -1
+			case 101:
+				return 2
+			case 102:
+				return -1
+			case 108:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 69:
+				return -1
+			case 70:
+				return 3
+			case 76:
+				return -1
+			case 84:
+				return -1
+			case 101:
+				return -1
+			case 102:
+				return 3
+			case 108:
+				return -1
+			case 116:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 69:
+				return -1
+			case 70:
+				return -1
+			case 76:
+				return -1
+			case 84:
+				return 4
 			case 101:
 				return -1
 			case 102:
@@ -16765,6 +17176,115 @@ var dfas = []dfa{
 		},
 	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1, -1, -1, -1}, nil},
 
+	// [mM][aA][sS][kK]
+	{[]bool{false, false, false, false, true}, []func(rune) int{ // Transitions
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 75:
+				return -1
+			case 77:
+				return 1
+			case 83:
+				return -1
+			case 97:
+				return -1
+			case 107:
+				return -1
+			case 109:
+				return 1
+			case 115:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return 2
+			case 75:
+				return -1
+			case 77:
+				return -1
+			case 83:
+				return -1
+			case 97:
+				return 2
+			case 107:
+				return -1
+			case 109:
+				return -1
+			case 115:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 75:
+				return -1
+			case 77:
+				return -1
+			case 83:
+				return 3
+			case 97:
+				return -1
+			case 107:
+				return -1
+			case 109:
+				return -1
+			case 115:
+				return 3
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 75:
+				return 4
+			case 77:
+				return -1
+			case 83:
+				return -1
+			case 97:
+				return -1
+			case 107:
+				return 4
+			case 109:
+				return -1
+			case 115:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 65:
+				return -1
+			case 75:
+				return -1
+			case 77:
+				return -1
+			case 83:
+				return -1
+			case 97:
+				return -1
+			case 107:
+				return -1
+			case 109:
+				return -1
+			case 115:
+				return -1
+			}
+			return -1
+		},
+	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1}, nil},
+
 	// [mM][aA][tT][cC][hH][eE][dD]
 	{[]bool{false, false, false, false, false, false, false, true}, []func(rune) int{ // Transitions
 		func(r rune) int {
@@ -20797,6 +21317,213 @@ var dfas = []dfa{
 		},
 	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1}, nil},
 
+	// [pP][oO][lL][iI][cC][yY]
+	{[]bool{false, false, false, false, false, false, true}, []func(rune) int{ // Transitions
+		func(r rune) int {
+			switch r {
+			case 67:
+				return -1
+			case 73:
+				return -1
+			case 76:
+				return -1
+			case 79:
+				return -1
+			case 80:
+				return 1
+			case 89:
+				return -1
+			case 99:
+				return -1
+			case 105:
+				return -1
+			case 108:
+				return -1
+			case 111:
+				return -1
+			case 112:
+				return 1
+			case 121:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 67:
+				return -1
+			case 73:
+				return -1
+			case 76:
+				return -1
+			case 79:
+				return 2
+			case 80:
+				return -1
+			case 89:
+				return -1
+			case 99:
+				return -1
+			case 105:
+				return -1
+			case 108:
+				return -1
+			case 111:
+				return 2
+			case 112:
+				return -1
+			case 121:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 67:
+				return -1
+			case 73:
+				return -1
+			case 76:
+				return 3
+			case 79:
+				return -1
+			case 80:
+				return -1
+			case 89:
+				return -1
+			case 99:
+				return -1
+			case 105:
+				return -1
+			case 108:
+				return 3
+			case 111:
+				return -1
+			case 112:
+				return -1
+			case 121:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 67:
+				return -1
+			case 73:
+				return 4
+			case 76:
+				return -1
+			case 79:
+				return -1
+			case 80:
+				return -1
+			case 89:
+				return -1
+			case 99:
+				return -1
+			case 105:
+				return 4
+			case 108:
+				return -1
+			case 111:
+				return -1
+			case 112:
+				return -1
+			case 121:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 67:
+				return 5
+			case 73:
+				return -1
+			case 76:
+				return -1
+			case 79:
+				return -1
+			case 80:
+				return -1
+			case 89:
+				return -1
+			case 99:
+				return 5
+			case 105:
+				return -1
+			case 108:
+				return -1
+			case 111:
+				return -1
+			case 112:
+				return -1
+			case 121:
+				return -1
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 67:
+				return -1
+			case 73:
+				return -1
+			case 76:
+				return -1
+			case 79:
+				return -1
+			case 80:
+				return -1
+			case 89:
+				return 6
+			case 99:
+				return -1
+			case 105:
+				return -1
+			case 108:
+				return -1
+			case 111:
+				return -1
+			case 112:
+				return -1
+			case 121:
+				return 6
+			}
+			return -1
+		},
+		func(r rune) int {
+			switch r {
+			case 67:
+				return -1
+			case 73:
+				return -1
+			case 76:
+				return -1
+			case 79:
+				return -1
+			case 80:
+				return -1
+			case 89:
+				return -1
+			case 99:
+				return -1
+			case 105:
+				return -1
+			case 108:
+				return -1
+			case 111:
+				return -1
+			case 112:
+				return -1
+			case 121:
+				return -1
+			}
+			return -1
+		},
+	}, []int{ /* Start-of-input transitions */ -1, -1, -1, -1, -1, -1, -1}, []int{ /* End-of-input transitions */ -1, -1, -1, -1, -1, -1, -1}, nil},
+
 	// [pP][oO][oO][lL]
 	{[]bool{false, false, false, false, true}, []func(rune) int{ // Transitions
 		func(r rune) int {
@@ -31562,6 +32289,7 @@ OUTER0:
 		case 38:
 			{
 				yylex.logToken(yylex.Text(), "ANALYZE")
+				lval.tokOffset = yylex.curOffset
 				return ANALYZE
 			}
 		case 39:
@@ -31832,624 +32560,645 @@ OUTER0:
 				return FORCE
 			}
 		case 92:
+			{
+				yylex.logToken(yylex.Text(), "FORMAT")
+				return FORMAT
+			}
+		case 93:
 			{
 				yylex.logToken(yylex.Text(), "FROM")
 				lval.tokOffset = yylex.curOffset
 				return FROM
 			}
-		case 93:
+		case 94:
 			{
 				yylex.logToken(yylex.Text(), "FTS")
 				return FTS
 			}
-		case 94:
+		case 95:
 			{
 				yylex.logToken(yylex.Text(), "FUNCTION")
 				return FUNCTION
 			}
-		case 95:
+		case 96:
 			{
 				yylex.logToken(yylex.Text(), "GRANT")
 				return GRANT
 			}
-		case 96:
+		case 97:
 			{
 				yylex.logToken(yylex.Text(), "GROUP")
 				return GROUP
 			}
-		case 97:
+		case 98:
 			{
 				yylex.logToken(yylex.Text(), "GSI")
 				return GSI
 			}
-		case 98:
+		case 99:
 			{
 				yylex.logToken(yylex.Text(), "HASH")
 				return HASH
 			}
-		case 99:
+		case 100:
 			{
 				yylex.logToken(yylex.Text(), "HAVING")
 				return HAVING
 			}
-		case 100:
+		case 101:
 			{
 				yylex.logToken(yylex.Text(), "IF")
 				return IF
 			}
-		case 101:
+		case 102:
 			{
 				yylex.logToken(yylex.Text(), "IGNORE")
 				return IGNORE
 			}
-		case 102:
+		case 103:
 			{
 				yylex.logToken(yylex.Text(), "ILIKE")
 				return ILIKE
 			}
-		case 103:
+		case 104:
 			{
 				yylex.logToken(yylex.Text(), "IN")
 				return IN
 			}
-		case 104:
+		case 105:
 			{
 				yylex.logToken(yylex.Text(), "INCLUDE")
 				return INCLUDE
 			}
-		case 105:
+		case 106:
 			{
 				yylex.logToken(yylex.Text(), "INCREMENT")
 				return INCREMENT
 			}
-		case 106:
+		case 107:
 			{
 				yylex.logToken(yylex.Text(), "INDEX")
 				return INDEX
 			}
-		case 107:
+		case 108:
 			{
 				yylex.logToken(yylex.Text(), "INFER")
 				return INFER
 			}
-		case 108:
+		case 109:
 			{
 				yylex.logToken(yylex.Text(), "INLINE")
 				return INLINE
 			}
-		case 109:
+		case 110:
 			{
 				yylex.logToken(yylex.Text(), "INNER")
 				return INNER
 			}
-		case 110:
+		case 111:
 			{
 				yylex.logToken(yylex.Text(), "INSERT")
 				return INSERT
 			}
-		case 111:
+		case 112:
 			{
 				yylex.logToken(yylex.Text(), "INTERSECT")
 				return INTERSECT
 			}
-		case 112:
+		case 113:
 			{
 				yylex.logToken(yylex.Text(), "INTO")
 				return INTO
 			}
-		case 113:
+		case 114:
 			{
 				yylex.logToken(yylex.Text(), "IS")
 				return IS
 			}
-		case 114:
+		case 115:
 			{
 				yylex.logToken(yylex.Text(), "JOIN")
 				return JOIN
 			}
-		case 115:
+		case 116:
 			{
 				yylex.logToken(yylex.Text(), "KEY")
 				return KEY
 			}
-		case 116:
+		case 117:
 			{
 				yylex.logToken(yylex.Text(), "KEYS")
 				return KEYS
 			}
-		case 117:
+		case 118:
 			{
 				yylex.logToken(yylex.Text(), "KEYSPACE")
 				return KEYSPACE
 			}
-		case 118:
+		case 119:
 			{
 				yylex.logToken(yylex.Text(), "KNOWN")
 				return KNOWN
 			}
-		case 119:
+		case 120:
 			{
 				yylex.logToken(yylex.Text(), "LAST")
 				return LAST
 			}
-		case 120:
+		case 121:
+			{
+				yylex.logToken(yylex.Text(), "LATERAL")
+				return LATERAL
+			}
+		case 122:
 			{
 				yylex.logToken(yylex.Text(), "LEFT")
 				return LEFT
 			}
-		case 121:
+		case 123:
 			{
 				yylex.logToken(yylex.Text(), "LET")
 				return LET
 			}
-		case 122:
+		case 124:
 			{
 				yylex.logToken(yylex.Text(), "LETTING")
 				return LETTING
 			}
-		case 123:
+		case 125:
 			{
 				yylex.logToken(yylex.Text(), "LIKE")
 				return LIKE
 			}
-		case 124:
+		case 126:
 			{
 				yylex.logToken(yylex.Text(), "LIMIT")
 				return LIMIT
 			}
-		case 125:
+		case 127:
 			{
 				yylex.logToken(yylex.Text(), "LSM")
 				return LSM
 			}
-		case 126:
+		case 128:
 			{
 				yylex.logToken(yylex.Text(), "MAP")
 				return MAP
 			}
-		case 127:
+		case 129:
 			{
 				yylex.logToken(yylex.Text(), "MAPPING")
 				return MAPPING
 			}
-		case 128:
+		case 130:
+			{
+				yylex.logToken(yylex.Text(), "MASK")
+				return MASK
+			}
+		case 131:
 			{
 				yylex.logToken(yylex.Text(), "MATCHED")
 				return MATCHED
 			}
-		case 129:
+		case 132:
 			{
 				yylex.logToken(yylex.Text(), "MATERIALIZED")
 				return MATERIALIZED
 			}
-		case 130:
+		case 133:
 			{
 				yylex.logToken(yylex.Text(), "MERGE")
 				return MERGE
 			}
-		case 131:
+		case 134:
 			{
 				yylex.logToken(yylex.Text(), "MINUS")
 				return MINUS
 			}
-		case 132:
+		case 135:
 			{
 				yylex.logToken(yylex.Text(), "MISSING")
 				return MISSING
 			}
-		case 133:
+		case 136:
 			{
 				yylex.logToken(yylex.Text(), "NAMESPACE")
 				return NAMESPACE
 			}
-		case 134:
+		case 137:
 			{
 				yylex.logToken(yylex.Text(), "NEST")
 				return NEST
 			}
-		case 135:
+		case 138:
 			{
 				yylex.logToken(yylex.Text(), "NOT")
 				return NOT
 			}
-		case 136:
+		case 139:
 			{
 				yylex.logToken(yylex.Text(), "NULL")
 				return NULL
 			}
-		case 137:
+		case 140:
 			{
 				yylex.logToken(yylex.Text(), "NUMBER")
 				return NUMBER
 			}
-		case 138:
+		case 141:
 			{
 				yylex.logToken(yylex.Text(), "OBJECT")
 				return OBJECT
 			}
-		case 139:
+		case 142:
 			{
 				yylex.logToken(yylex.Text(), "OFFSET")
 				return OFFSET
 			}
-		case 140:
+		case 143:
 			{
 				yylex.logToken(yylex.Text(), "ON")
 				return ON
 			}
-		case 141:
+		case 144:
 			{
 				yylex.logToken(yylex.Text(), "OPTION")
 				return OPTION
 			}
-		case 142:
+		case 145:
 			{
 				yylex.logToken(yylex.Text(), "OR")
 				return OR
 			}
-		case 143:
+		case 146:
 			{
 				yylex.logToken(yylex.Text(), "ORDER")
 				return ORDER
 			}
-		case 144:
+		case 147:
 			{
 				yylex.logToken(yylex.Text(), "OUTER")
 				return OUTER
 			}
-		case 145:
+		case 148:
 			{
 				yylex.logToken(yylex.Text(), "OVER")
 				return OVER
 			}
-		case 146:
+		case 149:
 			{
 				yylex.logToken(yylex.Text(), "PARSE")
 				return PARSE
 			}
-		case 147:
+		case 150:
 			{
 				yylex.logToken(yylex.Text(), "PARTITION")
 				return PARTITION
 			}
-		case 148:
+		case 151:
 			{
 				yylex.logToken(yylex.Text(), "PASSWORD")
 				return PASSWORD
 			}
-		case 149:
+		case 152:
 			{
 				yylex.logToken(yylex.Text(), "PATH")
 				return PATH
 			}
-		case 150:
+		case 153:
+			{
+				yylex.logToken(yylex.Text(), "POLICY")
+				return POLICY
+			}
+		case 154:
 			{
 				yylex.logToken(yylex.Text(), "POOL")
 				return POOL
 			}
-		case 151:
+		case 155:
 			{
 				yylex.logToken(yylex.Text(), "PREPARE")
 				lval.tokOffset = yylex.curOffset
 				return PREPARE
 			}
-		case 152:
+		case 156:
 			{
 				yylex.logToken(yylex.Text(), "PRIMARY")
 				return PRIMARY
 			}
-		case 153:
+		case 157:
 			{
 				yylex.logToken(yylex.Text(), "PRIVATE")
 				return PRIVATE
 			}
-		case 154:
+		case 158:
 			{
 				yylex.logToken(yylex.Text(), "PRIVILEGE")
 				return PRIVILEGE
 			}
-		case 155:
+		case 159:
 			{
 				yylex.logToken(yylex.Text(), "PROCEDURE")
 				return PROCEDURE
 			}
-		case 156:
+		case 160:
 			{
 				yylex.logToken(yylex.Text(), "PUBLIC")
 				return PUBLIC
 			}
-		case 157:
+		case 161:
 			{
 				yylex.logToken(yylex.Text(), "RAW")
 				return RAW
 			}
-		case 158:
+		case 162:
 			{
 				yylex.logToken(yylex.Text(), "REALM")
 				return REALM
 			}
-		case 159:
+		case 163:
 			{
 				yylex.logToken(yylex.Text(), "REDUCE")
 				return REDUCE
 			}
-		case 160:
+		case 164:
 			{
 				yylex.logToken(yylex.Text(), "RENAME")
 				return RENAME
 			}
-		case 161:
+		case 165:
 			{
 				yylex.logToken(yylex.Text(), "RETURN")
 				return RETURN
 			}
-		case 162:
+		case 166:
 			{
 				yylex.logToken(yylex.Text(), "RETURNING")
 				return RETURNING
 			}
-		case 163:
+		case 167:
 			{
 				yylex.logToken(yylex.Text(), "REVOKE")
 				return REVOKE
 			}
-		case 164:
+		case 168:
 			{
 				yylex.logToken(yylex.Text(), "RIGHT")
 				return RIGHT
 			}
-		case 165:
+		case 169:
 			{
 				yylex.logToken(yylex.Text(), "ROLE")
 				return ROLE
 			}
-		case 166:
+		case 170:
 			{
 				yylex.logToken(yylex.Text(), "ROLLBACK")
 				return ROLLBACK
 			}
-		case 167:
+		case 171:
 			{
 				yylex.logToken(yylex.Text(), "SATISFIES")
 				return SATISFIES
 			}
-		case 168:
+		case 172:
 			{
 				yylex.logToken(yylex.Text(), "SCHEMA")
 				return SCHEMA
 			}
-		case 169:
+		case 173:
 			{
 				yylex.logToken(yylex.Text(), "SELECT")
 				return SELECT
 			}
-		case 170:
+		case 174:
 			{
 				yylex.logToken(yylex.Text(), "SELF")
 				return SELF
 			}
-		case 171:
+		case 175:
 			{
 				yylex.logToken(yylex.Text(), "SET")
 				return SET
 			}
-		case 172:
+		case 176:
 			{
 				yylex.logToken(yylex.Text(), "SHOW")
 				return SHOW
 			}
-		case 173:
+		case 177:
 			{
 				yylex.logToken(yylex.Text(), "SOME")
 				return SOME
 			}
-		case 174:
+		case 178:
 			{
 				yylex.logToken(yylex.Text(), "START")
 				return START
 			}
-		case 175:
+		case 179:
 			{
 				yylex.logToken(yylex.Text(), "STATISTICS")
 				return STATISTICS
 			}
-		case 176:
+		case 180:
 			{
 				yylex.logToken(yylex.Text(), "STRING")
 				return STRING
 			}
-		case 177:
+		case 181:
 			{
 				yylex.logToken(yylex.Text(), "SYSTEM")
 				return SYSTEM
 			}
-		case 178:
+		case 182:
 			{
 				yylex.logToken(yylex.Text(), "THEN")
 				return THEN
 			}
-		case 179:
+		case 183:
 			{
 				yylex.logToken(yylex.Text(), "TO")
 				return TO
 			}
-		case 180:
+		case 184:
 			{
 				yylex.logToken(yylex.Text(), "TRANSACTION")
 				return TRANSACTION
 			}
-		case 181:
+		case 185:
 			{
 				yylex.logToken(yylex.Text(), "TRIGGER")
 				return TRIGGER
 			}
-		case 182:
+		case 186:
 			{
 				yylex.logToken(yylex.Text(), "TRUE")
 				return TRUE
 			}
-		case 183:
+		case 187:
 			{
 				yylex.logToken(yylex.Text(), "TRUNCATE")
 				return TRUNCATE
 			}
-		case 184:
+		case 188:
 			{
 				yylex.logToken(yylex.Text(), "UNDER")
 				return UNDER
 			}
-		case 185:
+		case 189:
 			{
 				yylex.logToken(yylex.Text(), "UNION")
 				return UNION
 			}
-		case 186:
+		case 190:
 			{
 				yylex.logToken(yylex.Text(), "UNIQUE")
 				return UNIQUE
 			}
-		case 187:
+		case 191:
 			{
 				yylex.logToken(yylex.Text(), "UNKNOWN")
 				return UNKNOWN
 			}
-		case 188:
+		case 192:
 			{
 				yylex.logToken(yylex.Text(), "UNNEST")
 				return UNNEST
 			}
-		case 189:
+		case 193:
 			{
 				yylex.logToken(yylex.Text(), "UNSET")
 				return UNSET
 			}
-		case 190:
+		case 194:
 			{
 				yylex.logToken(yylex.Text(), "UPDATE")
 				return UPDATE
 			}
-		case 191:
+		case 195:
 			{
 				yylex.logToken(yylex.Text(), "UPSERT")
 				return UPSERT
 			}
-		case 192:
+		case 196:
 			{
 				yylex.logToken(yylex.Text(), "USE")
 				return USE
 			}
-		case 193:
+		case 197:
 			{
 				yylex.logToken(yylex.Text(), "USER")
 				return USER
 			}
-		case 194:
+		case 198:
 			{
 				yylex.logToken(yylex.Text(), "USING")
 				return USING
 			}
-		case 195:
+		case 199:
 			{
 				yylex.logToken(yylex.Text(), "VALIDATE")
 				return VALIDATE
 			}
-		case 196:
+		case 200:
 			{
 				yylex.logToken(yylex.Text(), "VALUE")
 				return VALUE
 			}
-		case 197:
+		case 201:
 			{
 				yylex.logToken(yylex.Text(), "VALUED")
 				return VALUED
 			}
-		case 198:
+		case 202:
 			{
 				yylex.logToken(yylex.Text(), "VALUES")
 				return VALUES
 			}
-		case 199:
+		case 203:
 			{
 				yylex.logToken(yylex.Text(), "VIA")
 				return VIA
 			}
-		case 200:
+		case 204:
 			{
 				yylex.logToken(yylex.Text(), "VIEW")
 				return VIEW
 			}
-		case 201:
+		case 205:
 			{
 				yylex.logToken(yylex.Text(), "WHEN")
 				return WHEN
 			}
-		case 202:
+		case 206:
 			{
 				yylex.logToken(yylex.Text(), "WHERE")
 				return WHERE
 			}
-		case 203:
+		case 207:
 			{
 				yylex.logToken(yylex.Text(), "WHILE")
 				return WHILE
 			}
-		case 204:
+		case 208:
 			{
 				yylex.logToken(yylex.Text(), "WITH")
 				return WITH
 			}
-		case 205:
+		case 209:
 			{
 				yylex.logToken(yylex.Text(), "WITHIN")
 				return WITHIN
 			}
-		case 206:
+		case 210:
 			{
 				yylex.logToken(yylex.Text(), "WORK")
 				return WORK
 			}
-		case 207:
+		case 211:
 			{
 				yylex.logToken(yylex.Text(), "XOR")
 				return XOR
 			}
-		case 208:
+		case 212:
 			{
 				lval.s = yylex.Text()
 				yylex.logToken(yylex.Text(), "IDENT - %s", lval.s)
+				lval.tokOffset = yylex.curOffset
 				return IDENT
 			}
-		case 209:
+		case 213:
 			{
 				lval.s = yylex.Text()[1:]
 				yylex.logToken(yylex.Text(), "NAMED_PARAM - %s", lval.s)
 				return NAMED_PARAM
 			}
-		case 210:
+		case 214:
 			{
 				lval.n, _ = strconv.ParseInt(yylex.Text()[1:], 10, 64)
 				yylex.logToken(yylex.Text(), "POSITIONAL_PARAM - %d", lval.n)
 				return POSITIONAL_PARAM
 			}
-		case 211:
+		case 215:
 			{
 				lval.n = 0 // Handled by parser
 				yylex.logToken(yylex.Text(), "NEXT_PARAM - ?")
 				return NEXT_PARAM
 			}
-		case 212:
+		case 216:
 			{
 				yylex.curOffset++
 			}
-		case 213:
+		case 217:
 			{
 				yylex.curOffset++
 			}
-		case 214:
+		case 218:
 			{
 				yylex.curOffset++
 			}
-		case 215:
+		case 219:
 			{
 				/* this we don't know what it is: we'll let
 				   the parser handle it (and most probably throw a syntax error
@@ -136,6 +136,7 @@ tokOffset	 int
 %token FLATTEN
 %token FOR
 %token FORCE
+%token FORMAT
 %token FROM
 %token FTS
 %token FUNCTION
@@ -164,6 +165,7 @@ tokOffset	 int
 %token KEYSPACE
 %token KNOWN
 %token LAST
+%token LATERAL
 %token LEFT
 %token LET
 %token LETTING
@@ -172,6 +174,7 @@ tokOffset	 int
 %token LSM
 %token MAP
 %token MAPPING
+%token MASK
 %token MATCHED
 %token MATERIALIZED
 %token MERGE
@@ -195,6 +198,7 @@ tokOffset	 int
 %token PARTITION
 %token PASSWORD
 %token PATH
+%token POLICY
 %token POOL
 %token PREPARE
 %token PRIMARY
@@ -355,6 +359,7 @@ tokOffset	 int
 %type <statement>        insert upsert delete update merge
 %type <statement>        index_stmt create_index drop_index alter_index build_index
 %type <statement>        role_stmt grant_role revoke_role
+%type <statement>        policy_stmt create_policy drop_policy create_mask drop_mask
 
 %type <keyspaceRef>      keyspace_ref
 %type <pairs>            values values_list next_values
@@ -394,6 +399,8 @@ tokOffset	 int
 %type <ss>               role_list
 %type <s>                role_name
 %type <s>                user
+%type <ss>               opt_policy_for policy_ops opt_policy_to grantee_list
+%type <s>                policy_op grantee mask_method
 
 %start input
 
@@ -440,7 +447,38 @@ role_stmt
 explain:
 EXPLAIN stmt
 {
-    $$ = algebra.NewExplain($2, yylex.(*lexer).Remainder($<tokOffset>1))
+    $$ = algebra.NewExplain($2, yylex.(*lexer).Remainder($<tokOffset>1), false, algebra.EXPLAIN_FORMAT_JSON)
+}
+|
+EXPLAIN FORMAT IDENT stmt
+{
+    format := strings.ToLower($3)
+    if !algebra.IsExplainFormat(format) {
+        yylex.Error(fmt.Sprintf("Invalid EXPLAIN format %s.", $3))
+    }
+    $$ = algebra.NewExplain($4, yylex.(*lexer).Remainder($<tokOffset>3), false, format)
+}
+|
+EXPLAIN FORMAT IDENT ANALYZE stmt
+{
+    yylex.Error("EXPLAIN FORMAT cannot be combined with ANALYZE; the analyzed plan is only returned as JSON.")
+    $$ = algebra.NewExplain($5, yylex.(*lexer).Remainder($<tokOffset>4), true, algebra.EXPLAIN_FORMAT_JSON)
+}
+|
+EXPLAIN ANALYZE FORMAT IDENT stmt
+{
+    yylex.Error("EXPLAIN FORMAT cannot be combined with ANALYZE; the analyzed plan is only returned as JSON.")
+    $$ = algebra.NewExplain($5, yylex.(*lexer).Remainder($<tokOffset>4), true, algebra.EXPLAIN_FORMAT_JSON)
+}
+|
+EXPLAIN ANALYZE select_stmt
+{
+    $$ = algebra.NewExplain($3, yylex.(*lexer).Remainder($<tokOffset>2), true, algebra.EXPLAIN_FORMAT_JSON)
+}
+|
+EXPLAIN ANALYZE dml_stmt
+{
+    $$ = algebra.NewExplain($3, yylex.(*lexer).Remainder($<tokOffset>2), true, algebra.EXPLAIN_FORMAT_JSON)
 }
 ;
 
@@ -547,6 +585,8 @@ merge
 
 ddl_stmt:
 index_stmt
+|
+policy_stmt
 ;
 
 role_stmt:
@@ -565,6 +605,16 @@ alter_index
 build_index
 ;
 
+policy_stmt:
+create_policy
+|
+drop_policy
+|
+create_mask
+|
+drop_mask
+;
+
 fullselect:
 select_terms opt_order_by
 {
@@ -933,6 +983,36 @@ from_term opt_join_type NEST simple_from_join_term ON expr
     }
 }
 |
+from_term COMMA LATERAL subquery_expr opt_as_alias
+{
+    if $5 == "" {
+        yylex.Error("LATERAL subquery in FROM clause must have an alias.")
+    }
+    right := algebra.NewSubqueryTerm($4.Select(), $5)
+    right.SetLateral()
+    $$ = algebra.NewAnsiJoin($1, false, right, expression.TRUE_EXPR)
+}
+|
+from_term opt_join_type JOIN LATERAL subquery_expr opt_as_alias
+{
+    if $6 == "" {
+        yylex.Error("LATERAL subquery in FROM clause must have an alias.")
+    }
+    right := algebra.NewSubqueryTerm($5.Select(), $6)
+    right.SetLateral()
+    $$ = algebra.NewAnsiJoin($1, $2, right, expression.TRUE_EXPR)
+}
+|
+from_term opt_join_type JOIN LATERAL subquery_expr opt_as_alias ON expr
+{
+    if $6 == "" {
+        yylex.Error("LATERAL subquery in FROM clause must have an alias.")
+    }
+    right := algebra.NewSubqueryTerm($5.Select(), $6)
+    right.SetLateral()
+    $$ = algebra.NewAnsiJoin($1, $2, right, $8)
+}
+|
 simple_from_join_term RIGHT opt_outer JOIN simple_from_term ON expr
 {
     if second, ok := $1.(*algebra.KeyspaceTerm); ok {
@@ -1896,6 +1976,143 @@ REVOKE role_list ON keyspace_list FROM user_list
 }
 ;
 
+/*************************************************
+ *
+ * CREATE POLICY
+ *
+ *************************************************/
+
+create_policy:
+CREATE POLICY IDENT ON named_keyspace_ref opt_policy_for USING LPAREN expr RPAREN opt_policy_to
+{
+    $$ = algebra.NewCreatePolicy($5, $3, $6, $9, $11)
+}
+;
+
+opt_policy_for:
+/* empty */
+{
+    $$ = nil
+}
+|
+FOR policy_ops
+{
+    $$ = $2
+}
+;
+
+policy_ops:
+policy_op
+{
+    $$ = []string{ $1 }
+}
+|
+policy_ops COMMA policy_op
+{
+    $$ = append($1, $3)
+}
+;
+
+policy_op:
+SELECT
+{
+    $$ = "select"
+}
+|
+UPDATE
+{
+    $$ = "update"
+}
+|
+DELETE
+{
+    $$ = "delete"
+}
+;
+
+opt_policy_to:
+/* empty */
+{
+    $$ = nil
+}
+|
+TO grantee_list
+{
+    $$ = $2
+}
+;
+
+grantee_list:
+grantee
+{
+    $$ = []string{ $1 }
+}
+|
+grantee_list COMMA grantee
+{
+    $$ = append($1, $3)
+}
+;
+
+grantee:
+role_name
+|
+IDENT COLON IDENT
+{
+    $$ = $1 + ":" + $3
+}
+;
+
+/*************************************************
+ *
+ * CREATE MASK
+ *
+ *************************************************/
+
+create_mask:
+CREATE MASK IDENT ON named_keyspace_ref LPAREN expr RPAREN USING mask_method opt_policy_to
+{
+    $$ = algebra.NewCreateMask($5, $3, $7, $10, $11)
+}
+;
+
+mask_method:
+HASH
+{
+    $$ = "hash"
+}
+|
+NULL
+{
+    $$ = "null"
+}
+|
+IDENT
+{
+    $$ = strings.ToLower($1)
+}
+;
+
+/*************************************************
+ *
+ * DROP POLICY and DROP MASK
+ *
+ *************************************************/
+
+drop_policy:
+DROP POLICY IDENT ON named_keyspace_ref
+{
+    $$ = algebra.NewDropPolicy($5, $3)
+}
+;
+
+drop_mask:
+DROP MASK IDENT ON named_keyspace_ref
+{
+    $$ = algebra.NewDropMask($5, $3)
+}
+;
+
 /*************************************************
  *
  * CREATE INDEX
@@ -2780,6 +2997,11 @@ function_name LPAREN STAR RPAREN
 
 function_name:
 IDENT
+|
+MASK
+{
+    $$ = "mask"
+}
 ;
 
 
@@ -17,8 +17,9 @@ import (
 
 type Authorize struct {
 	readonly
-	privs *auth.Privileges `json:"privileges"`
-	child Operator         `json:"~child"`
+	privs       *auth.Privileges  `json:"privileges"`
+	rowPolicies *auth.RowPolicies `json:"row_policies"`
+	child       Operator          `json:"~child"`
 }
 
 func NewAuthorize(privs *auth.Privileges, child Operator) *Authorize {
@@ -40,6 +41,14 @@ func (this *Authorize) Privileges() *auth.Privileges {
 	return this.privs
 }
 
+func (this *Authorize) RowPolicies() *auth.RowPolicies {
+	return this.rowPolicies
+}
+
+func (this *Authorize) SetRowPolicies(rowPolicies *auth.RowPolicies) {
+	this.rowPolicies = rowPolicies
+}
+
 func (this *Authorize) Readonly() bool {
 	return this.child.Readonly()
 }
@@ -55,6 +64,9 @@ func (this *Authorize) MarshalJSON() ([]byte, error) {
 func (this *Authorize) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
 	r := map[string]interface{}{"#operator": "Authorize"}
 	r["privileges"] = this.privs
+	if this.rowPolicies != nil {
+		r["row_policies"] = this.rowPolicies
+	}
 	if f != nil {
 		f(r)
 	} else {
@@ -65,9 +77,10 @@ func (this *Authorize) MarshalBase(f func(map[string]interface{})) map[string]in
 
 func (this *Authorize) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_     string           `json:"#operator"`
-		Privs *auth.Privileges `json:"privileges"`
-		Child json.RawMessage  `json:"~child"`
+		_           string            `json:"#operator"`
+		Privs       *auth.Privileges  `json:"privileges"`
+		RowPolicies *auth.RowPolicies `json:"row_policies"`
+		Child       json.RawMessage   `json:"~child"`
 	}
 	var child_type struct {
 		Operator string `json:"#operator"`
@@ -77,6 +90,7 @@ func (this *Authorize) UnmarshalJSON(body []byte) error {
 		return err
 	}
 	this.privs = _unmarshalled.Privs
+	this.rowPolicies = _unmarshalled.RowPolicies
 
 	err = json.Unmarshal(_unmarshalled.Child, &child_type)
 	if err != nil {
@@ -11,18 +11,26 @@ package plan
 
 import (
 	"encoding/json"
+
+	"github.com/couchbase/query/algebra"
 )
 
 type Explain struct {
 	readonly
-	op   Operator
-	text string
+	op       Operator
+	text     string
+	analyze  bool
+	format   string
+	policies []string
+	masks    []string
 }
 
-func NewExplain(op Operator, text string) *Explain {
+func NewExplain(op Operator, text string, analyze bool, format string) *Explain {
 	return &Explain{
-		op:   op,
-		text: text,
+		op:      op,
+		text:    text,
+		analyze: analyze,
+		format:  format,
 	}
 }
 
@@ -38,6 +46,52 @@ func (this *Explain) Operator() Operator {
 	return this.op
 }
 
+func (this *Explain) Analyze() bool {
+	return this.analyze
+}
+
+func (this *Explain) Format() string {
+	return this.format
+}
+
+// The row security policies applied to the statement explained
+func (this *Explain) Policies() []string {
+	return this.policies
+}
+
+func (this *Explain) SetPolicies(policies []string) {
+	this.policies = policies
+}
+
+// The column masks applied to the statement explained
+func (this *Explain) Masks() []string {
+	return this.masks
+}
+
+func (this *Explain) SetMasks(masks []string) {
+	this.masks = masks
+}
+
+// The explain output, with the plan rendered in the requested format
+func (this *Explain) MarshalFormat() ([]byte, error) {
+	r := this.MarshalBase(nil)
+	switch this.format {
+	case algebra.EXPLAIN_FORMAT_TEXT:
+		lines, err := ExplainText(this.op)
+		if err != nil {
+			return nil, err
+		}
+		r["plan"] = lines
+	case algebra.EXPLAIN_FORMAT_DOT:
+		graph, err := ExplainDot(this.op)
+		if err != nil {
+			return nil, err
+		}
+		r["plan"] = graph
+	}
+	return json.Marshal(r)
+}
+
 func (this *Explain) MarshalJSON() ([]byte, error) {
 	return json.Marshal(this.MarshalBase(nil))
 }
@@ -46,6 +100,18 @@ func (this *Explain) MarshalBase(f func(map[string]interface{})) map[string]inte
 	r := make(map[string]interface{}, 2)
 	r["plan"] = this.op
 	r["text"] = this.text
+	if this.analyze {
+		r["analyze"] = this.analyze
+	}
+	if this.format != "" && this.format != algebra.EXPLAIN_FORMAT_JSON {
+		r["format"] = this.format
+	}
+	if len(this.policies) > 0 {
+		r["row_policies"] = this.policies
+	}
+	if len(this.masks) > 0 {
+		r["column_masks"] = this.masks
+	}
 	if f != nil {
 		f(r)
 	} else {
@@ -56,8 +122,12 @@ func (this *Explain) MarshalBase(f func(map[string]interface{})) map[string]inte
 
 func (this *Explain) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		Op   json.RawMessage `json:"plan"`
-		Text string          `json:"text"`
+		Op       json.RawMessage `json:"plan"`
+		Text     string          `json:"text"`
+		Analyze  bool            `json:"analyze"`
+		Format   string          `json:"format"`
+		Policies []string        `json:"row_policies"`
+		Masks    []string        `json:"column_masks"`
 	}
 
 	var op_type struct {
@@ -75,6 +145,10 @@ func (this *Explain) UnmarshalJSON(body []byte) error {
 	}
 
 	this.text = _unmarshalled.Text
+	this.analyze = _unmarshalled.Analyze
+	this.format = _unmarshalled.Format
+	this.policies = _unmarshalled.Policies
+	this.masks = _unmarshalled.Masks
 
 	err = json.Unmarshal(_unmarshalled.Op, &op_type)
 	if err != nil {
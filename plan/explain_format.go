@@ -0,0 +1,611 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+)
+
+// An operator as rendered by EXPLAIN FORMAT TEXT and DOT: its name,
+// a few key details, and the operators it feeds off
+type explainNode struct {
+	operator string
+	details  []string
+	children []*explainNode
+}
+
+// Render the plan as an indented operator tree, one operator per line
+func ExplainText(op Operator) ([]string, error) {
+	node, err := describe(op)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, 16)
+	node.text(&lines, 0)
+	return lines, nil
+}
+
+func (this *explainNode) text(lines *[]string, depth int) {
+	line := strings.Repeat("  ", depth) + this.operator
+	if len(this.details) > 0 {
+		line += " (" + strings.Join(this.details, ", ") + ")"
+	}
+	*lines = append(*lines, line)
+	for _, child := range this.children {
+		child.text(lines, depth+1)
+	}
+}
+
+// Render the plan as a Graphviz digraph
+func ExplainDot(op Operator) (string, error) {
+	node, err := describe(op)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("digraph plan {\n")
+	buf.WriteString("  rankdir=BT;\n")
+	buf.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	id := 0
+	node.dot(buf, &id)
+	buf.WriteString("}\n")
+	return buf.String(), nil
+}
+
+func (this *explainNode) dot(buf *bytes.Buffer, id *int) int {
+	me := *id
+	*id++
+
+	label := this.operator
+	for _, detail := range this.details {
+		label += "\n" + detail
+	}
+	fmt.Fprintf(buf, "  n%d [label=%s];\n", me, strconv.Quote(label))
+	for _, child := range this.children {
+		fmt.Fprintf(buf, "  n%d -> n%d;\n", child.dot(buf, id), me)
+	}
+	return me
+}
+
+func describe(op Operator) (*explainNode, error) {
+	node, err := op.Accept(&explainDescriber{})
+	if err != nil {
+		return nil, err
+	}
+	return node.(*explainNode), nil
+}
+
+type explainDescriber struct {
+}
+
+func (this *explainDescriber) node(operator string, children []Operator, details ...string) (interface{}, error) {
+	rv := &explainNode{
+		operator: operator,
+		details:  make([]string, 0, len(details)),
+		children: make([]*explainNode, 0, len(children)),
+	}
+	for _, detail := range details {
+		if detail != "" {
+			rv.details = append(rv.details, detail)
+		}
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		node, err := describe(child)
+		if err != nil {
+			return nil, err
+		}
+		rv.children = append(rv.children, node)
+	}
+	return rv, nil
+}
+
+// details
+
+func termDetail(term *algebra.KeyspaceTerm) string {
+	if term == nil {
+		return ""
+	}
+	rv := "keyspace=" + term.Namespace() + ":" + term.Keyspace()
+	if term.As() != "" {
+		rv += " as " + term.As()
+	}
+	return rv
+}
+
+func keyspaceDetail(keyspace datastore.Keyspace) string {
+	if keyspace == nil {
+		return ""
+	}
+	return "keyspace=" + keyspace.NamespaceId() + ":" + keyspace.Name()
+}
+
+func indexDetail(index datastore.Index) string {
+	if index == nil {
+		return ""
+	}
+	return "index=" + index.Name()
+}
+
+func policiesDetail(rowPolicies *auth.RowPolicies) string {
+	if rowPolicies == nil {
+		return ""
+	}
+	details := make([]string, 0, 2)
+	if len(rowPolicies.Applied) > 0 {
+		details = append(details, "policies="+strings.Join(rowPolicies.Names(), ","))
+	}
+	if len(rowPolicies.Masks) > 0 {
+		details = append(details, "masks="+strings.Join(rowPolicies.MaskNames(), ","))
+	}
+	return strings.Join(details, " ")
+}
+
+func exprDetail(name string, expr expression.Expression) string {
+	if expr == nil {
+		return ""
+	}
+	return name + "=" + expr.String()
+}
+
+func exprsDetail(name string, exprs expression.Expressions) string {
+	if len(exprs) == 0 {
+		return ""
+	}
+	terms := make([]string, len(exprs))
+	for i, expr := range exprs {
+		terms[i] = expr.String()
+	}
+	return name + "=[" + strings.Join(terms, ", ") + "]"
+}
+
+func coversDetail(covers expression.Covers) string {
+	if len(covers) == 0 {
+		return ""
+	}
+	terms := make([]string, len(covers))
+	for i, cover := range covers {
+		terms[i] = cover.String()
+	}
+	return "covers=[" + strings.Join(terms, ", ") + "]"
+}
+
+func aggregatesDetail(aggs algebra.Aggregates) string {
+	if len(aggs) == 0 {
+		return ""
+	}
+	terms := make([]string, len(aggs))
+	for i, agg := range aggs {
+		terms[i] = agg.String()
+	}
+	return "aggregates=[" + strings.Join(terms, ", ") + "]"
+}
+
+func spansDetail(spans interface{}) string {
+	b, err := json.Marshal(spans)
+	if err != nil || len(b) == 0 || string(b) == "null" {
+		return ""
+	}
+	return "spans=" + string(b)
+}
+
+func flagDetail(name string, flag bool) string {
+	if !flag {
+		return ""
+	}
+	return name
+}
+
+func aliasDetail(alias string) string {
+	if alias == "" {
+		return ""
+	}
+	return "as=" + alias
+}
+
+func scansChildren(scans []SecondaryScan) []Operator {
+	rv := make([]Operator, len(scans))
+	for i, scan := range scans {
+		rv[i] = scan
+	}
+	return rv
+}
+
+// Scan
+
+func (this *explainDescriber) VisitPrimaryScan(op *PrimaryScan) (interface{}, error) {
+	return this.node("PrimaryScan", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitPrimaryScan3(op *PrimaryScan3) (interface{}, error) {
+	return this.node("PrimaryScan3", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		exprDetail("offset", op.Offset()), exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitParentScan(op *ParentScan) (interface{}, error) {
+	return this.node("ParentScan", nil)
+}
+
+func (this *explainDescriber) VisitIndexScan(op *IndexScan) (interface{}, error) {
+	return this.node("IndexScan", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		spansDetail(op.Spans()), coversDetail(op.Covers()), flagDetail("distinct", op.Distinct()),
+		exprDetail("offset", op.Offset()), exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitIndexScan2(op *IndexScan2) (interface{}, error) {
+	return this.node("IndexScan2", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		spansDetail(op.Spans()), coversDetail(op.Covers()), flagDetail("distinct", op.Distinct()),
+		flagDetail("reverse", op.Reverse()), exprDetail("offset", op.Offset()), exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitIndexScan3(op *IndexScan3) (interface{}, error) {
+	return this.node("IndexScan3", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		spansDetail(op.Spans()), coversDetail(op.Covers()), flagDetail("distinct", op.Distinct()),
+		flagDetail("reverse", op.Reverse()), exprDetail("offset", op.Offset()), exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitKeyScan(op *KeyScan) (interface{}, error) {
+	return this.node("KeyScan", nil, exprDetail("keys", op.Keys()))
+}
+
+func (this *explainDescriber) VisitValueScan(op *ValueScan) (interface{}, error) {
+	return this.node("ValueScan", nil, fmt.Sprintf("values=%d", len(op.Values())))
+}
+
+func (this *explainDescriber) VisitDummyScan(op *DummyScan) (interface{}, error) {
+	return this.node("DummyScan", nil)
+}
+
+func (this *explainDescriber) VisitCountScan(op *CountScan) (interface{}, error) {
+	return this.node("CountScan", nil, termDetail(op.Term()))
+}
+
+func (this *explainDescriber) VisitIndexCountScan(op *IndexCountScan) (interface{}, error) {
+	return this.node("IndexCountScan", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		spansDetail(op.Spans()), coversDetail(op.Covers()))
+}
+
+func (this *explainDescriber) VisitIndexCountScan2(op *IndexCountScan2) (interface{}, error) {
+	return this.node("IndexCountScan2", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		spansDetail(op.Spans()), coversDetail(op.Covers()))
+}
+
+func (this *explainDescriber) VisitIndexCountDistinctScan2(op *IndexCountDistinctScan2) (interface{}, error) {
+	return this.node("IndexCountDistinctScan2", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		spansDetail(op.Spans()), coversDetail(op.Covers()))
+}
+
+func (this *explainDescriber) VisitDistinctScan(op *DistinctScan) (interface{}, error) {
+	return this.node("DistinctScan", []Operator{op.Scan()}, exprDetail("offset", op.Offset()),
+		exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitUnionScan(op *UnionScan) (interface{}, error) {
+	return this.node("UnionScan", scansChildren(op.Scans()), coversDetail(op.Covers()),
+		exprDetail("offset", op.Offset()), exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitIntersectScan(op *IntersectScan) (interface{}, error) {
+	return this.node("IntersectScan", scansChildren(op.Scans()), coversDetail(op.Covers()),
+		exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitOrderedIntersectScan(op *OrderedIntersectScan) (interface{}, error) {
+	return this.node("OrderedIntersectScan", scansChildren(op.Scans()), coversDetail(op.Covers()),
+		exprDetail("limit", op.Limit()))
+}
+
+func (this *explainDescriber) VisitExpressionScan(op *ExpressionScan) (interface{}, error) {
+	return this.node("ExpressionScan", nil, exprDetail("expr", op.FromExpr()), aliasDetail(op.Alias()))
+}
+
+// Fetch
+
+func (this *explainDescriber) VisitFetch(op *Fetch) (interface{}, error) {
+	return this.node("Fetch", nil, termDetail(op.Term()))
+}
+
+func (this *explainDescriber) VisitDummyFetch(op *DummyFetch) (interface{}, error) {
+	return this.node("DummyFetch", nil, termDetail(op.Term()))
+}
+
+// Join
+
+func (this *explainDescriber) VisitJoin(op *Join) (interface{}, error) {
+	return this.node("Join", nil, termDetail(op.Term()), flagDetail("outer", op.Outer()))
+}
+
+func (this *explainDescriber) VisitIndexJoin(op *IndexJoin) (interface{}, error) {
+	return this.node("IndexJoin", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		flagDetail("outer", op.Outer()), "for="+op.For(), coversDetail(op.Covers()))
+}
+
+func (this *explainDescriber) VisitNest(op *Nest) (interface{}, error) {
+	return this.node("Nest", nil, termDetail(op.Term()), flagDetail("outer", op.Outer()))
+}
+
+func (this *explainDescriber) VisitIndexNest(op *IndexNest) (interface{}, error) {
+	return this.node("IndexNest", nil, indexDetail(op.Index()), termDetail(op.Term()),
+		flagDetail("outer", op.Outer()), "for="+op.For())
+}
+
+func (this *explainDescriber) VisitUnnest(op *Unnest) (interface{}, error) {
+	return this.node("Unnest", nil, exprDetail("expr", op.Term().Expression()), aliasDetail(op.Alias()),
+		flagDetail("outer", op.Term().Outer()))
+}
+
+func (this *explainDescriber) VisitAnsiJoin(op *AnsiJoin) (interface{}, error) {
+	return this.node("AnsiJoin", []Operator{op.Child()}, exprDetail("on", op.Onclause()),
+		aliasDetail(op.Alias()), flagDetail("outer", op.Outer()), flagDetail("lateral", op.Lateral()))
+}
+
+func (this *explainDescriber) VisitAnsiNest(op *AnsiNest) (interface{}, error) {
+	return this.node("AnsiNest", []Operator{op.Child()}, exprDetail("on", op.Onclause()),
+		aliasDetail(op.Alias()), flagDetail("outer", op.Outer()))
+}
+
+// Let + Letting
+
+func (this *explainDescriber) VisitLet(op *Let) (interface{}, error) {
+	bindings := make([]string, len(op.Bindings()))
+	for i, b := range op.Bindings() {
+		bindings[i] = b.Variable() + "=" + b.Expression().String()
+	}
+	return this.node("Let", nil, strings.Join(bindings, ", "))
+}
+
+// Filter
+
+func (this *explainDescriber) VisitFilter(op *Filter) (interface{}, error) {
+	return this.node("Filter", nil, exprDetail("condition", op.Condition()))
+}
+
+// Group
+
+func (this *explainDescriber) VisitInitialGroup(op *InitialGroup) (interface{}, error) {
+	return this.node("InitialGroup", nil, exprsDetail("keys", op.Keys()), aggregatesDetail(op.Aggregates()))
+}
+
+func (this *explainDescriber) VisitIntermediateGroup(op *IntermediateGroup) (interface{}, error) {
+	return this.node("IntermediateGroup", nil, exprsDetail("keys", op.Keys()), aggregatesDetail(op.Aggregates()))
+}
+
+func (this *explainDescriber) VisitFinalGroup(op *FinalGroup) (interface{}, error) {
+	return this.node("FinalGroup", nil, exprsDetail("keys", op.Keys()), aggregatesDetail(op.Aggregates()))
+}
+
+// Project
+
+func (this *explainDescriber) VisitInitialProject(op *InitialProject) (interface{}, error) {
+	return this.node("InitialProject", nil, "projection="+op.Projection().String())
+}
+
+func (this *explainDescriber) VisitFinalProject(op *FinalProject) (interface{}, error) {
+	return this.node("FinalProject", nil)
+}
+
+func (this *explainDescriber) VisitIndexCountProject(op *IndexCountProject) (interface{}, error) {
+	return this.node("IndexCountProject", nil, "projection="+op.Projection().String())
+}
+
+// Distinct
+
+func (this *explainDescriber) VisitDistinct(op *Distinct) (interface{}, error) {
+	return this.node("Distinct", nil)
+}
+
+// Set operators
+
+func (this *explainDescriber) VisitUnionAll(op *UnionAll) (interface{}, error) {
+	return this.node("UnionAll", op.Children())
+}
+
+func (this *explainDescriber) VisitIntersectAll(op *IntersectAll) (interface{}, error) {
+	return this.node("IntersectAll", []Operator{op.First(), op.Second()})
+}
+
+func (this *explainDescriber) VisitExceptAll(op *ExceptAll) (interface{}, error) {
+	return this.node("ExceptAll", []Operator{op.First(), op.Second()})
+}
+
+// Order
+
+func (this *explainDescriber) VisitOrder(op *Order) (interface{}, error) {
+	terms := make([]string, len(op.Terms()))
+	for i, term := range op.Terms() {
+		terms[i] = term.String()
+	}
+	var limit, offset string
+	if op.Limit() != nil {
+		limit = exprDetail("limit", op.Limit().Expression())
+	}
+	if op.Offset() != nil {
+		offset = exprDetail("offset", op.Offset().Expression())
+	}
+	return this.node("Order", nil, "terms=["+strings.Join(terms, ", ")+"]", offset, limit)
+}
+
+// Paging
+
+func (this *explainDescriber) VisitOffset(op *Offset) (interface{}, error) {
+	return this.node("Offset", nil, exprDetail("expr", op.Expression()))
+}
+
+func (this *explainDescriber) VisitLimit(op *Limit) (interface{}, error) {
+	return this.node("Limit", nil, exprDetail("expr", op.Expression()))
+}
+
+// Insert
+
+func (this *explainDescriber) VisitSendInsert(op *SendInsert) (interface{}, error) {
+	return this.node("SendInsert", nil, keyspaceDetail(op.Keyspace()), aliasDetail(op.Alias()),
+		exprDetail("limit", op.Limit()))
+}
+
+// Upsert
+
+func (this *explainDescriber) VisitSendUpsert(op *SendUpsert) (interface{}, error) {
+	return this.node("SendUpsert", nil, keyspaceDetail(op.Keyspace()), aliasDetail(op.Alias()))
+}
+
+// Delete
+
+func (this *explainDescriber) VisitSendDelete(op *SendDelete) (interface{}, error) {
+	return this.node("SendDelete", nil, keyspaceDetail(op.Keyspace()), aliasDetail(op.Alias()),
+		exprDetail("limit", op.Limit()))
+}
+
+// Update
+
+func (this *explainDescriber) VisitClone(op *Clone) (interface{}, error) {
+	return this.node("Clone", nil, aliasDetail(op.Alias()))
+}
+
+func (this *explainDescriber) VisitSet(op *Set) (interface{}, error) {
+	return this.node("Set", nil, exprsDetail("terms", op.Node().Expressions()))
+}
+
+func (this *explainDescriber) VisitUnset(op *Unset) (interface{}, error) {
+	return this.node("Unset", nil, exprsDetail("terms", op.Node().Expressions()))
+}
+
+func (this *explainDescriber) VisitSendUpdate(op *SendUpdate) (interface{}, error) {
+	return this.node("SendUpdate", nil, keyspaceDetail(op.Keyspace()), aliasDetail(op.Alias()),
+		exprDetail("limit", op.Limit()))
+}
+
+// Merge
+
+func (this *explainDescriber) VisitMerge(op *Merge) (interface{}, error) {
+	return this.node("Merge", []Operator{op.Update(), op.Delete(), op.Insert()},
+		keyspaceDetail(op.Keyspace()), exprDetail("key", op.Key()))
+}
+
+// Framework
+
+func (this *explainDescriber) VisitAlias(op *Alias) (interface{}, error) {
+	return this.node("Alias", nil, aliasDetail(op.Alias()))
+}
+
+func (this *explainDescriber) VisitAuthorize(op *Authorize) (interface{}, error) {
+	return this.node("Authorize", []Operator{op.Child()}, policiesDetail(op.RowPolicies()))
+}
+
+func (this *explainDescriber) VisitParallel(op *Parallel) (interface{}, error) {
+	var maxParallelism string
+	if op.MaxParallelism() > 0 {
+		maxParallelism = fmt.Sprintf("maxParallelism=%d", op.MaxParallelism())
+	}
+	return this.node("Parallel", []Operator{op.Child()}, maxParallelism)
+}
+
+func (this *explainDescriber) VisitSequence(op *Sequence) (interface{}, error) {
+	return this.node("Sequence", op.Children())
+}
+
+func (this *explainDescriber) VisitDiscard(op *Discard) (interface{}, error) {
+	return this.node("Discard", nil)
+}
+
+func (this *explainDescriber) VisitStream(op *Stream) (interface{}, error) {
+	return this.node("Stream", nil)
+}
+
+func (this *explainDescriber) VisitCollect(op *Collect) (interface{}, error) {
+	return this.node("Collect", nil)
+}
+
+// Index DDL
+
+func (this *explainDescriber) VisitCreatePrimaryIndex(op *CreatePrimaryIndex) (interface{}, error) {
+	return this.node("CreatePrimaryIndex", nil, keyspaceDetail(op.Keyspace()))
+}
+
+func (this *explainDescriber) VisitCreateIndex(op *CreateIndex) (interface{}, error) {
+	return this.node("CreateIndex", nil, "index="+op.Node().Name(), keyspaceDetail(op.Keyspace()))
+}
+
+func (this *explainDescriber) VisitDropIndex(op *DropIndex) (interface{}, error) {
+	return this.node("DropIndex", nil, indexDetail(op.Index()))
+}
+
+func (this *explainDescriber) VisitAlterIndex(op *AlterIndex) (interface{}, error) {
+	return this.node("AlterIndex", nil, indexDetail(op.Index()), keyspaceDetail(op.Keyspace()))
+}
+
+func (this *explainDescriber) VisitBuildIndexes(op *BuildIndexes) (interface{}, error) {
+	return this.node("BuildIndexes", nil, keyspaceDetail(op.Keyspace()))
+}
+
+// Roles
+
+func (this *explainDescriber) VisitGrantRole(op *GrantRole) (interface{}, error) {
+	return this.node("GrantRole", nil)
+}
+
+func (this *explainDescriber) VisitRevokeRole(op *RevokeRole) (interface{}, error) {
+	return this.node("RevokeRole", nil)
+}
+
+// Row security policies
+
+func (this *explainDescriber) VisitCreatePolicy(op *CreatePolicy) (interface{}, error) {
+	return this.node("CreatePolicy", nil, "policy="+op.Node().Name())
+}
+
+func (this *explainDescriber) VisitDropPolicy(op *DropPolicy) (interface{}, error) {
+	return this.node("DropPolicy", nil, "policy="+op.Node().Name())
+}
+
+// Column masks
+
+func (this *explainDescriber) VisitCreateMask(op *CreateMask) (interface{}, error) {
+	return this.node("CreateMask", nil, "mask="+op.Node().Name())
+}
+
+func (this *explainDescriber) VisitDropMask(op *DropMask) (interface{}, error) {
+	return this.node("DropMask", nil, "mask="+op.Node().Name())
+}
+
+// Explain
+
+func (this *explainDescriber) VisitExplain(op *Explain) (interface{}, error) {
+	return this.node("Explain", []Operator{op.Operator()})
+}
+
+// Prepare
+
+func (this *explainDescriber) VisitPrepare(op *Prepare) (interface{}, error) {
+	var children []Operator
+	if op.Plan() != nil {
+		children = []Operator{op.Plan()}
+	}
+	return this.node("Prepare", children)
+}
+
+// Infer
+
+func (this *explainDescriber) VisitInferKeyspace(op *InferKeyspace) (interface{}, error) {
+	return this.node("InferKeyspace", nil, keyspaceDetail(op.Keyspace()))
+}
@@ -61,6 +61,10 @@ func (this *InferKeyspace) MarshalBase(f func(map[string]interface{})) map[strin
 		r["with"] = this.node.With()
 	}
 
+	if len(this.node.Masks()) > 0 {
+		r["masks"] = this.node.Masks()
+	}
+
 	if f != nil {
 		f(r)
 	}
@@ -74,6 +78,7 @@ func (this *InferKeyspace) UnmarshalJSON(body []byte) error {
 		Namesp string                  `json:"namespace"`
 		Using  datastore.InferenceType `json:"using"`
 		With   json.RawMessage         `json:"with"`
+		Masks  map[string]string       `json:"masks"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -94,5 +99,6 @@ func (this *InferKeyspace) UnmarshalJSON(body []byte) error {
 	}
 
 	this.node = algebra.NewInferKeyspace(ksref, _unmarshalled.Using, with)
+	this.node.SetMasks(_unmarshalled.Masks)
 	return nil
 }
@@ -20,6 +20,7 @@ import (
 type AnsiJoin struct {
 	readonly
 	outer    bool
+	lateral  bool
 	alias    string
 	onclause expression.Expression
 	child    Operator
@@ -33,6 +34,10 @@ func NewAnsiJoin(join *algebra.AnsiJoin, child Operator) *AnsiJoin {
 		child:    child,
 	}
 
+	if right, ok := join.Right().(*algebra.SubqueryTerm); ok {
+		rv.lateral = right.IsLateral()
+	}
+
 	return rv
 }
 
@@ -48,6 +53,10 @@ func (this *AnsiJoin) Outer() bool {
 	return this.outer
 }
 
+func (this *AnsiJoin) Lateral() bool {
+	return this.lateral
+}
+
 func (this *AnsiJoin) Alias() string {
 	return this.alias
 }
@@ -73,6 +82,10 @@ func (this *AnsiJoin) MarshalBase(f func(map[string]interface{})) map[string]int
 		r["outer"] = this.outer
 	}
 
+	if this.lateral {
+		r["lateral"] = this.lateral
+	}
+
 	r["~child"] = this.child
 
 	if f != nil {
@@ -86,6 +99,7 @@ func (this *AnsiJoin) UnmarshalJSON(body []byte) error {
 		_        string          `json:"#operator"`
 		Onclause string          `json:"on_clause"`
 		Outer    bool            `json:"outer"`
+		Lateral  bool            `json:"lateral"`
 		Alias    string          `json:"alias"`
 		Child    json.RawMessage `json:"~child"`
 	}
@@ -103,6 +117,7 @@ func (this *AnsiJoin) UnmarshalJSON(body []byte) error {
 	}
 
 	this.outer = _unmarshalled.Outer
+	this.lateral = _unmarshalled.Lateral
 	this.alias = _unmarshalled.Alias
 
 	raw_child := _unmarshalled.Child
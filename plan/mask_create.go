@@ -0,0 +1,87 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+)
+
+// Create column mask
+type CreateMask struct {
+	readwrite
+	node *algebra.CreateMask
+}
+
+func NewCreateMask(node *algebra.CreateMask) *CreateMask {
+	return &CreateMask{
+		node: node,
+	}
+}
+
+func (this *CreateMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateMask(this)
+}
+
+func (this *CreateMask) New() Operator {
+	return &CreateMask{}
+}
+
+func (this *CreateMask) Node() *algebra.CreateMask {
+	return this.node
+}
+
+func (this *CreateMask) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.MarshalBase(nil))
+}
+
+func (this *CreateMask) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
+	r := map[string]interface{}{"#operator": "CreateMask"}
+	r["namespace"] = this.node.Keyspace().Namespace()
+	r["keyspace"] = this.node.Keyspace().Keyspace()
+	r["name"] = this.node.Name()
+	r["path"] = expression.NewStringer().Visit(this.node.Path())
+	r["method"] = this.node.Method()
+	r["to"] = this.node.To()
+	if f != nil {
+		f(r)
+	}
+	return r
+}
+
+func (this *CreateMask) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string   `json:"#operator"`
+		Namespace string   `json:"namespace"`
+		Keyspace  string   `json:"keyspace"`
+		Name      string   `json:"name"`
+		Path      string   `json:"path"`
+		Method    string   `json:"method"`
+		To        []string `json:"to"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	path, err := parser.Parse(_unmarshalled.Path)
+	if err != nil {
+		return err
+	}
+
+	ksref := algebra.NewKeyspaceRef(_unmarshalled.Namespace, _unmarshalled.Keyspace, "")
+	this.node = algebra.NewCreateMask(ksref, _unmarshalled.Name, path,
+		_unmarshalled.Method, _unmarshalled.To)
+	return nil
+}
@@ -0,0 +1,73 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/algebra"
+)
+
+// Drop column mask
+type DropMask struct {
+	readwrite
+	node *algebra.DropMask
+}
+
+func NewDropMask(node *algebra.DropMask) *DropMask {
+	return &DropMask{
+		node: node,
+	}
+}
+
+func (this *DropMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropMask(this)
+}
+
+func (this *DropMask) New() Operator {
+	return &DropMask{}
+}
+
+func (this *DropMask) Node() *algebra.DropMask {
+	return this.node
+}
+
+func (this *DropMask) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.MarshalBase(nil))
+}
+
+func (this *DropMask) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
+	r := map[string]interface{}{"#operator": "DropMask"}
+	r["namespace"] = this.node.Keyspace().Namespace()
+	r["keyspace"] = this.node.Keyspace().Keyspace()
+	r["name"] = this.node.Name()
+	if f != nil {
+		f(r)
+	}
+	return r
+}
+
+func (this *DropMask) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string `json:"#operator"`
+		Namespace string `json:"namespace"`
+		Keyspace  string `json:"keyspace"`
+		Name      string `json:"name"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	ksref := algebra.NewKeyspaceRef(_unmarshalled.Namespace, _unmarshalled.Keyspace, "")
+	this.node = algebra.NewDropMask(ksref, _unmarshalled.Name)
+	return nil
+}
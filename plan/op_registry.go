@@ -144,6 +144,14 @@ var _OPERATORS = map[string]Operator{
 	"GrantRole":  &GrantRole{},
 	"RevokeRole": &RevokeRole{},
 
+	// Row security policies
+	"CreatePolicy": &CreatePolicy{},
+	"DropPolicy":   &DropPolicy{},
+
+	// Column masks
+	"CreateMask": &CreateMask{},
+	"DropMask":   &DropMask{},
+
 	// Explain
 	"Explain": &Explain{},
 
@@ -0,0 +1,87 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+)
+
+// Create row security policy
+type CreatePolicy struct {
+	readwrite
+	node *algebra.CreatePolicy
+}
+
+func NewCreatePolicy(node *algebra.CreatePolicy) *CreatePolicy {
+	return &CreatePolicy{
+		node: node,
+	}
+}
+
+func (this *CreatePolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreatePolicy(this)
+}
+
+func (this *CreatePolicy) New() Operator {
+	return &CreatePolicy{}
+}
+
+func (this *CreatePolicy) Node() *algebra.CreatePolicy {
+	return this.node
+}
+
+func (this *CreatePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.MarshalBase(nil))
+}
+
+func (this *CreatePolicy) MarshalBase(f func(map[string]interface{})) map[string]interface{} {
+	r := map[string]interface{}{"#operator": "CreatePolicy"}
+	r["namespace"] = this.node.Keyspace().Namespace()
+	r["keyspace"] = this.node.Keyspace().Keyspace()
+	r["name"] = this.node.Name()
+	r["operations"] = this.node.Operations()
+	r["using"] = expression.NewStringer().Visit(this.node.Using())
+	r["to"] = this.node.To()
+	if f != nil {
+		f(r)
+	}
+	return r
+}
+
+func (this *CreatePolicy) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_          string   `json:"#operator"`
+		Namespace  string   `json:"namespace"`
+		Keyspace   string   `json:"keyspace"`
+		Name       string   `json:"name"`
+		Operations []string `json:"operations"`
+		Using      string   `json:"using"`
+		To         []string `json:"to"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	using, err := parser.Parse(_unmarshalled.Using)
+	if err != nil {
+		return err
+	}
+
+	ksref := algebra.NewKeyspaceRef(_unmarshalled.Namespace, _unmarshalled.Keyspace, "")
+	this.node = algebra.NewCreatePolicy(ksref, _unmarshalled.Name, _unmarshalled.Operations,
+		using, _unmarshalled.To)
+	return nil
+}
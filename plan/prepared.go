@@ -32,6 +32,13 @@ const (
 	OPT_REMOTE             // check with remote node, if available
 )
 
+// state of prepared statements reloaded at startup
+const (
+	PREPARED_RESTORED   = "restored"   // plan decoded as persisted
+	PREPARED_REPREPARED = "reprepared" // plan built again from the text
+	PREPARED_FAILED     = "failed"     // no plan, cannot be executed
+)
+
 type Prepared struct {
 	Operator
 	signature    value.Value
@@ -154,6 +161,8 @@ type CacheEntry struct {
 	MinRequestTime atomic.AlignedUint64
 	MaxServiceTime atomic.AlignedUint64
 	MaxRequestTime atomic.AlignedUint64
+	State          string       // only set for statements reloaded at startup
+	ReloadError    errors.Error // why a failed statement could not be reloaded
 	// FIXME add moving averages, latency
 	// This requires the use of metrics
 }
@@ -164,6 +173,11 @@ var prepareds = &preparedCache{}
 
 func PreparedsInit(limit int) {
 	prepareds.cache = util.NewGenCache(limit)
+
+	// statements evicted are no longer persisted
+	prepareds.cache.SetEvicted(func(name string, entry interface{}) {
+		unpersistPrepared(name)
+	})
 }
 
 // configure prepareds cache
@@ -190,7 +204,7 @@ func (this *preparedCache) get(name value.Value, track bool) *Prepared {
 		cv = prepareds.cache.Get(n, nil)
 	}
 	rv, ok := cv.(*CacheEntry)
-	if ok {
+	if ok && rv.State != PREPARED_FAILED {
 		if track {
 			atomic.AddInt32(&rv.Uses, 1)
 
@@ -223,6 +237,8 @@ func (this *preparedCache) add(prepared *Prepared, process func(*CacheEntry) boo
 		}
 		if cont {
 			oldEntry.Prepared = prepared
+			oldEntry.State = ""
+			oldEntry.ReloadError = nil
 		} else {
 			op = util.IGNORE
 		}
@@ -262,7 +278,9 @@ func AddPrepared(prepared *Prepared) errors.Error {
 	added := true
 
 	prepareds.add(prepared, func(ce *CacheEntry) bool {
-		if ce.Prepared.Text() != prepared.Text() {
+
+		// statements that failed to reload can be prepared anew
+		if ce.State != PREPARED_FAILED && ce.Prepared.Text() != prepared.Text() {
 			added = false
 		}
 		return added
@@ -277,6 +295,7 @@ func AddPrepared(prepared *Prepared) errors.Error {
 
 func DeletePrepared(name string) errors.Error {
 	if prepareds.cache.Delete(name, nil) {
+		unpersistPrepared(name)
 		return nil
 	}
 	return errors.NewNoSuchPreparedError(name)
@@ -304,7 +323,13 @@ func GetPrepared(prepared_stmt value.Value, options uint32) (*Prepared, errors.E
 				}, distributed.NO_CREDS, "")
 		}
 		if prepared == nil {
-			return nil, errors.NewNoSuchPreparedError(name)
+			var err errors.Error = errors.NewNoSuchPreparedError(name)
+			PreparedDo(name, func(ce *CacheEntry) {
+				if ce.State == PREPARED_FAILED {
+					err = errors.NewPreparedReloadError(name, ce.ReloadError)
+				}
+			})
+			return nil, err
 		}
 		return prepared, nil
 	case value.OBJECT:
@@ -353,27 +378,11 @@ func RecordPreparedMetrics(prepared *Prepared, requestTime, serviceTime time.Dur
 func DecodePrepared(prepared_name string, prepared_stmt string, track bool) (*Prepared, errors.Error) {
 	added := true
 
-	decoded, err := base64.StdEncoding.DecodeString(prepared_stmt)
-	if err != nil {
-		return nil, errors.NewPreparedDecodingError(err)
-	}
-	var buf bytes.Buffer
-	buf.Write(decoded)
-	reader, err := gzip.NewReader(&buf)
-	if err != nil {
-		return nil, errors.NewPreparedDecodingError(err)
-	}
-	prepared_bytes, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return nil, errors.NewPreparedDecodingError(err)
-	}
-	prepared, err := unmarshalPrepared(prepared_bytes)
+	prepared, err := decodePrepared(prepared_stmt)
 	if err != nil {
-		return nil, errors.NewPreparedDecodingError(err)
+		return nil, err
 	}
 
-	prepared.SetEncodedPlan(prepared_stmt)
-
 	// MB-19509 we now have to check that the encoded plan matches
 	// the prepared statement named in the rest API
 	if prepared.Name() != "" && prepared_name != "" &&
@@ -392,6 +401,7 @@ func DecodePrepared(prepared_name string, prepared_stmt string, track bool) (*Pr
 			// MB-19509: if the entry exists already, the new plan must
 			// also be for the same statement as we have in the cache
 			if oldEntry.Prepared != prepared &&
+				oldEntry.State != PREPARED_FAILED &&
 				oldEntry.Prepared.text != prepared.text {
 				added = false
 				return added
@@ -419,6 +429,31 @@ func DecodePrepared(prepared_name string, prepared_stmt string, track bool) (*Pr
 	}
 }
 
+// decode a plan without adding it to the cache
+func decodePrepared(prepared_stmt string) (*Prepared, errors.Error) {
+	decoded, err := base64.StdEncoding.DecodeString(prepared_stmt)
+	if err != nil {
+		return nil, errors.NewPreparedDecodingError(err)
+	}
+	var buf bytes.Buffer
+	buf.Write(decoded)
+	reader, err := gzip.NewReader(&buf)
+	if err != nil {
+		return nil, errors.NewPreparedDecodingError(err)
+	}
+	prepared_bytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.NewPreparedDecodingError(err)
+	}
+	prepared, err := unmarshalPrepared(prepared_bytes)
+	if err != nil {
+		return nil, errors.NewPreparedDecodingError(err)
+	}
+
+	prepared.SetEncodedPlan(prepared_stmt)
+	return prepared, nil
+}
+
 func unmarshalPrepared(bytes []byte) (*Prepared, errors.Error) {
 	prepared := &Prepared{}
 	err := prepared.UnmarshalJSON(bytes)
@@ -0,0 +1,315 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+)
+
+/*
+Named prepared statements can be persisted to a directory, one JSON file
+per statement, so that they survive restarts.
+Statements are handed to a single writer, off the request path, and only
+when their text, plan, namespace or users changed since they were last
+written. Statements deleted or evicted from the cache are removed.
+At startup, the persisted plans are decoded again, which fails if an index
+they use has been dropped or rebuilt since. Those statements are prepared
+again from their text, and the ones that cannot be are kept in the cache
+as failed, for system:prepareds to report them.
+*/
+
+const _PERSIST_SUFFIX = ".json"
+
+// what is kept of a prepared statement
+type PreparedRecord struct {
+	Name        string    `json:"name"`
+	Text        string    `json:"text"`
+	EncodedPlan string    `json:"encoded_plan"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Users       []string  `json:"users,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+type preparedStore struct {
+	sync.Mutex
+	dir     string
+	written map[string][sha256.Size]byte // digests of the records written, or about to be
+	pending map[string]*PreparedRecord   // records to write, nil for files to remove
+	wake    chan bool
+	writing sync.Mutex
+	once    sync.Once
+}
+
+var persisted = &preparedStore{
+	wake: make(chan bool, 1),
+}
+
+// init the persisted statements directory
+// an empty directory disables persistence
+
+func PreparedsPersistInit(dir string) errors.Error {
+	persisted.Lock()
+	defer persisted.Unlock()
+	persisted.dir = ""
+	persisted.written = make(map[string][sha256.Size]byte)
+	persisted.pending = make(map[string]*PreparedRecord)
+	if dir == "" {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.NewPreparedPersistError(err, dir)
+	}
+	persisted.dir = dir
+	persisted.once.Do(func() {
+		go persisted.writer()
+	})
+	return nil
+}
+
+func PreparedsPersistDir() string {
+	persisted.Lock()
+	defer persisted.Unlock()
+	return persisted.dir
+}
+
+// persist a statement, as prepared by the users in the namespace,
+// if it is still in the cache
+// errors are logged, the statement being usable until restart anyway
+func PersistPrepared(prepared *Prepared, namespace string, users []string) {
+	if prepared.Name() == "" {
+		return
+	}
+	record := &PreparedRecord{
+		Name:        prepared.Name(),
+		Text:        prepared.Text(),
+		EncodedPlan: prepared.EncodedPlan(),
+		Namespace:   namespace,
+		Users:       users,
+		Created:     time.Now(),
+	}
+	digest := record.digest()
+
+	persisted.Lock()
+	defer persisted.Unlock()
+	if persisted.dir == "" {
+		return
+	}
+
+	// the cache may have evicted the statement as it was added, and an
+	// eviction under way waits for the lock, so removes what is written
+	cached := false
+	PreparedDo(record.Name, func(ce *CacheEntry) {
+		cached = ce.Prepared == prepared
+	})
+	if !cached {
+		return
+	}
+	if written, ok := persisted.written[record.Name]; ok && written == digest {
+		return
+	}
+	persisted.written[record.Name] = digest
+	persisted.pending[record.Name] = record
+	persisted.signal()
+}
+
+func unpersistPrepared(name string) {
+	persisted.Lock()
+	defer persisted.Unlock()
+	if persisted.dir == "" {
+		return
+	}
+	delete(persisted.written, name)
+	persisted.pending[name] = nil
+	persisted.signal()
+}
+
+/*
+Reload the persisted statements into the cache, oldest first, so that
+the most recent ones are kept if there are more than the cache holds.
+Statements whose plan no longer decodes are handed to reprepare, and
+persisted again if it succeeds.
+*/
+func PreparedsReload(reprepare func(*PreparedRecord) (*Prepared, errors.Error)) {
+	persisted.Lock()
+	dir := persisted.dir
+	persisted.Unlock()
+	if dir == "" {
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+_PERSIST_SUFFIX))
+	if err != nil {
+		logging.Errorf("Unable to list persisted prepared statements in %v: %v", dir, err)
+		return
+	}
+
+	records := make([]*PreparedRecord, 0, len(files))
+	for _, file := range files {
+		record, err := readRecord(file)
+		if err != nil {
+			logging.Errorf("Ignoring persisted prepared statement %v: %v", file, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Sort(recordsByAge(records))
+
+	counts := make(map[string]int, 3)
+	for _, record := range records {
+		state := PREPARED_RESTORED
+		prepared, rerr := decodePrepared(record.EncodedPlan)
+		if rerr == nil && prepared.Name() != record.Name {
+			rerr = errors.NewEncodingNameMismatchError(record.Name)
+		}
+		if rerr != nil {
+			state = PREPARED_REPREPARED
+			prepared, rerr = reprepare(record)
+		}
+		if rerr != nil {
+			state = PREPARED_FAILED
+			logging.Errorf("Prepared statement %v could not be reloaded: %v", record.Name, rerr)
+			prepared = &Prepared{name: record.Name, text: record.Text}
+		}
+
+		prepareds.add(prepared, nil)
+		PreparedDo(record.Name, func(ce *CacheEntry) {
+			ce.State = state
+			ce.ReloadError = rerr
+		})
+		switch state {
+		case PREPARED_RESTORED:
+			persisted.Lock()
+			persisted.written[record.Name] = record.digest()
+			persisted.Unlock()
+		case PREPARED_REPREPARED:
+			PersistPrepared(prepared, record.Namespace, record.Users)
+		}
+		counts[state]++
+	}
+	logging.Infof("Reloaded prepared statements from %v: %v restored, %v reprepared, %v failed", dir,
+		counts[PREPARED_RESTORED], counts[PREPARED_REPREPARED], counts[PREPARED_FAILED])
+}
+
+func readRecord(file string) (*PreparedRecord, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	record := &PreparedRecord{}
+	err = json.Unmarshal(data, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// what tells whether a statement needs writing again
+func (this *PreparedRecord) digest() [sha256.Size]byte {
+	return sha256.Sum256([]byte(this.Text + "\x00" + this.EncodedPlan + "\x00" +
+		this.Namespace + "\x00" + strings.Join(this.Users, "\x00")))
+}
+
+type recordsByAge []*PreparedRecord
+
+func (this recordsByAge) Len() int           { return len(this) }
+func (this recordsByAge) Less(i, j int) bool { return this[i].Created.Before(this[j].Created) }
+func (this recordsByAge) Swap(i, j int)      { this[i], this[j] = this[j], this[i] }
+
+func (this *preparedStore) signal() {
+	select {
+	case this.wake <- true:
+	default:
+	}
+}
+
+func (this *preparedStore) writer() {
+	for range this.wake {
+		this.flush()
+	}
+}
+
+// write the pending records, and remove the files of the statements
+// that have gone
+func (this *preparedStore) flush() {
+	this.writing.Lock()
+	defer this.writing.Unlock()
+
+	this.Lock()
+	dir, pending := this.dir, this.pending
+	this.pending = make(map[string]*PreparedRecord)
+	this.Unlock()
+	if dir == "" {
+		return
+	}
+
+	for name, record := range pending {
+		if record == nil {
+			err := os.Remove(recordFile(dir, name))
+			if err != nil && !os.IsNotExist(err) {
+				logging.Errorf("Unable to remove persisted prepared statement %v: %v", name, err)
+			}
+			continue
+		}
+		err := writeRecord(dir, record)
+		if err != nil {
+			logging.Errorf("%v", errors.NewPreparedPersistError(err, name))
+
+			// try again next time the statement is prepared
+			this.Lock()
+			delete(this.written, name)
+			this.Unlock()
+		}
+	}
+}
+
+// names may hold any character, so files are named after their hash
+func recordFile(dir, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+_PERSIST_SUFFIX)
+}
+
+// written to a temporary file that replaces the previous one
+func writeRecord(dir string, record *PreparedRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".prepared-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), recordFile(dir, record.Name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
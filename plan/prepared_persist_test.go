@@ -0,0 +1,228 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+// a prepared statement, encoded as the planner does
+func newTestPrepared(t *testing.T, name, text string) *Prepared {
+	prepared := NewPrepared(NewSequence(NewDummyScan()), nil)
+	prepared.SetName(name)
+	prepared.SetText(text)
+
+	bytes_json, err := prepared.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Unable to marshal %v: %v", name, err)
+	}
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(bytes_json)
+	w.Close()
+	prepared.SetEncodedPlan(base64.StdEncoding.EncodeToString(b.Bytes()))
+	return prepared
+}
+
+func initTestPersist(t *testing.T, limit int) string {
+	dir, err := ioutil.TempDir("", "prepareds")
+	if err != nil {
+		t.Fatalf("Unable to create directory: %v", err)
+	}
+	PreparedsInit(limit)
+	if err := PreparedsPersistInit(dir); err != nil {
+		t.Fatalf("Unable to init persistence: %v", err)
+	}
+	return dir
+}
+
+func persistTestPrepared(t *testing.T, prepared *Prepared) {
+	if err := AddPrepared(prepared); err != nil {
+		t.Fatalf("Unable to add %v: %v", prepared.Name(), err)
+	}
+	PersistPrepared(prepared, "default", []string{"joe"})
+	persisted.flush()
+}
+
+func isPersisted(dir, name string) bool {
+	_, err := os.Stat(recordFile(dir, name))
+	return err == nil
+}
+
+func reloadState(name string) string {
+	var state string
+	PreparedDo(name, func(ce *CacheEntry) {
+		state = ce.State
+	})
+	return state
+}
+
+func noReprepare(t *testing.T) func(*PreparedRecord) (*Prepared, errors.Error) {
+	return func(record *PreparedRecord) (*Prepared, errors.Error) {
+		t.Errorf("Unexpected reprepare of %v", record.Name)
+		return nil, errors.NewPlanError(nil, "unexpected")
+	}
+}
+
+func TestPersistRestored(t *testing.T) {
+	dir := initTestPersist(t, 100)
+	defer os.RemoveAll(dir)
+
+	prepared := newTestPrepared(t, "p1", "PREPARE p1 FROM SELECT 1")
+	persistTestPrepared(t, prepared)
+	if !isPersisted(dir, "p1") {
+		t.Fatalf("Expected p1 to be persisted")
+	}
+
+	// preparing the same statement again writes nothing
+	PersistPrepared(prepared, "default", []string{"joe"})
+	persisted.Lock()
+	pending := len(persisted.pending)
+	persisted.Unlock()
+	if pending != 0 {
+		t.Errorf("Expected nothing to write, found %v records", pending)
+	}
+
+	PreparedsInit(100)
+	PreparedsReload(noReprepare(t))
+	rv, err := GetPrepared(value.NewValue("p1"), 0)
+	if err != nil || rv == nil || rv.Text() != prepared.Text() {
+		t.Fatalf("Expected p1 to be reloaded, found %v, error %v", rv, err)
+	}
+	if state := reloadState("p1"); state != PREPARED_RESTORED {
+		t.Errorf("Expected state %v, found %v", PREPARED_RESTORED, state)
+	}
+}
+
+func TestPersistReprepared(t *testing.T) {
+	dir := initTestPersist(t, 100)
+	defer os.RemoveAll(dir)
+
+	text := "PREPARE p2 FROM SELECT 2"
+	err := writeRecord(dir, &PreparedRecord{Name: "p2", Text: text, EncodedPlan: "not a plan"})
+	if err != nil {
+		t.Fatalf("Unable to write p2: %v", err)
+	}
+
+	reprepared := newTestPrepared(t, "p2", text)
+	PreparedsReload(func(record *PreparedRecord) (*Prepared, errors.Error) {
+		if record.Text != text {
+			t.Errorf("Expected text %v, found %v", text, record.Text)
+		}
+		return reprepared, nil
+	})
+	rv, rerr := GetPrepared(value.NewValue("p2"), 0)
+	if rerr != nil || rv != reprepared {
+		t.Fatalf("Expected p2 to be reprepared, found %v, error %v", rv, rerr)
+	}
+	if state := reloadState("p2"); state != PREPARED_REPREPARED {
+		t.Errorf("Expected state %v, found %v", PREPARED_REPREPARED, state)
+	}
+
+	// the new plan replaces the old one
+	persisted.flush()
+	record, err := readRecord(recordFile(dir, "p2"))
+	if err != nil || record.EncodedPlan != reprepared.EncodedPlan() {
+		t.Errorf("Expected the new plan to be persisted, found %v, error %v", record, err)
+	}
+}
+
+func TestPersistFailed(t *testing.T) {
+	dir := initTestPersist(t, 100)
+	defer os.RemoveAll(dir)
+
+	err := writeRecord(dir, &PreparedRecord{Name: "p3", Text: "PREPARE p3 FROM SELECT 3", EncodedPlan: "not a plan"})
+	if err != nil {
+		t.Fatalf("Unable to write p3: %v", err)
+	}
+
+	PreparedsReload(func(record *PreparedRecord) (*Prepared, errors.Error) {
+		return nil, errors.NewPlanError(nil, "no index")
+	})
+	if state := reloadState("p3"); state != PREPARED_FAILED {
+		t.Errorf("Expected state %v, found %v", PREPARED_FAILED, state)
+	}
+	rv, rerr := GetPrepared(value.NewValue("p3"), 0)
+	if rv != nil || rerr == nil || rerr.Code() != errors.PREPARED_RELOAD {
+		t.Fatalf("Expected reload error for p3, found %v, error %v", rv, rerr)
+	}
+
+	// a fresh PREPARE replaces the failed statement, whatever its text
+	prepared := newTestPrepared(t, "p3", "PREPARE p3 FROM SELECT 33")
+	persistTestPrepared(t, prepared)
+	rv, rerr = GetPrepared(value.NewValue("p3"), 0)
+	if rerr != nil || rv != prepared {
+		t.Fatalf("Expected p3 to be prepared anew, found %v, error %v", rv, rerr)
+	}
+	if state := reloadState("p3"); state != "" {
+		t.Errorf("Expected no reload state, found %v", state)
+	}
+	record, err := readRecord(recordFile(dir, "p3"))
+	if err != nil || record.Text != prepared.Text() {
+		t.Errorf("Expected the new statement to be persisted, found %v, error %v", record, err)
+	}
+}
+
+func TestPersistDeleted(t *testing.T) {
+	dir := initTestPersist(t, 100)
+	defer os.RemoveAll(dir)
+
+	persistTestPrepared(t, newTestPrepared(t, "p4", "PREPARE p4 FROM SELECT 4"))
+	if err := DeletePrepared("p4"); err != nil {
+		t.Fatalf("Unable to delete p4: %v", err)
+	}
+	persisted.flush()
+	if isPersisted(dir, "p4") {
+		t.Errorf("Expected p4 to be removed")
+	}
+}
+
+func TestPersistEvicted(t *testing.T) {
+	dir := initTestPersist(t, 1)
+	defer os.RemoveAll(dir)
+
+	// which of the two the cache keeps depends on where their names
+	// hash to, but only the one it keeps is persisted
+	persistTestPrepared(t, newTestPrepared(t, "p5", "PREPARE p5 FROM SELECT 5"))
+	persistTestPrepared(t, newTestPrepared(t, "p6", "PREPARE p6 FROM SELECT 6"))
+	kept := 0
+	for _, name := range []string{"p5", "p6"} {
+		rv, _ := GetPrepared(value.NewValue(name), 0)
+		if rv != nil {
+			kept++
+		}
+		if cached, onDisk := rv != nil, isPersisted(dir, name); cached != onDisk {
+			t.Errorf("Expected %v to be persisted %v, found %v", name, cached, onDisk)
+		}
+	}
+	if kept != 1 {
+		t.Errorf("Expected one statement in the cache, found %v", kept)
+	}
+
+	// shrinking the cache removes what it evicts
+	PreparedsSetLimit(100)
+	persistTestPrepared(t, newTestPrepared(t, "p7", "PREPARE p7 FROM SELECT 7"))
+	PreparedsSetLimit(1)
+	persisted.flush()
+	for _, name := range []string{"p5", "p6", "p7"} {
+		rv, _ := GetPrepared(value.NewValue(name), 0)
+		if cached, onDisk := rv != nil, isPersisted(dir, name); cached != onDisk {
+			t.Errorf("Expected %v to be persisted %v, found %v", name, cached, onDisk)
+		}
+	}
+}
@@ -101,6 +101,10 @@ func (this *InitialProject) MarshalBase(f func(map[string]interface{})) map[stri
 		s = append(s, t)
 	}
 	r["result_terms"] = s
+
+	if this.projection.Mask() != nil {
+		r["mask"] = expression.NewStringer().Visit(this.projection.Mask())
+	}
 	if f != nil {
 		f(r)
 	}
@@ -115,8 +119,9 @@ func (this *InitialProject) UnmarshalJSON(body []byte) error {
 			As   string `json:"as"`
 			Star bool   `json:"star"`
 		} `json:"result_terms"`
-		Distinct bool `json:"distinct"`
-		Raw      bool `json:"raw"`
+		Distinct bool   `json:"distinct"`
+		Raw      bool   `json:"raw"`
+		Mask     string `json:"mask"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -137,6 +142,13 @@ func (this *InitialProject) UnmarshalJSON(body []byte) error {
 	}
 	projection := algebra.NewProjection(_unmarshalled.Distinct, terms)
 	projection.SetRaw(_unmarshalled.Raw)
+	if _unmarshalled.Mask != "" {
+		mask, err := parser.Parse(_unmarshalled.Mask)
+		if err != nil {
+			return err
+		}
+		projection.SetMask(mask)
+	}
 	results := projection.Terms()
 	project_terms := make(ProjectTerms, len(results))
 
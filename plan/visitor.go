@@ -112,6 +112,14 @@ type Visitor interface {
 	VisitGrantRole(op *GrantRole) (interface{}, error)
 	VisitRevokeRole(op *RevokeRole) (interface{}, error)
 
+	// Row security policies
+	VisitCreatePolicy(op *CreatePolicy) (interface{}, error)
+	VisitDropPolicy(op *DropPolicy) (interface{}, error)
+
+	// Column masks
+	VisitCreateMask(op *CreateMask) (interface{}, error)
+	VisitDropMask(op *DropMask) (interface{}, error)
+
 	// Explain
 	VisitExplain(op *Explain) (interface{}, error)
 
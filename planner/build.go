@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/plan"
@@ -50,7 +51,14 @@ func Build(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
 		// query is against secured tables anyway, and would therefore
 		// have privileges that need verification, meaning the Authorize
 		// operator would have been present in any case.
-		op = plan.NewAuthorize(privs, op)
+		authorize := plan.NewAuthorize(privs, op)
+
+		// Carry the row security policies the statement was rewritten
+		// with, so that they can be checked against the users running it.
+		if rp, ok := stmt.(rowPolicied); ok {
+			authorize.SetRowPolicies(rp.RowPolicies())
+		}
+		op = authorize
 
 		return plan.NewSequence(op, plan.NewStream()), nil
 	} else {
@@ -58,6 +66,10 @@ func Build(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
 	}
 }
 
+type rowPolicied interface {
+	RowPolicies() *auth.RowPolicies
+}
+
 var _MAP_KEYSPACE_CAP = 4
 
 const (
@@ -20,5 +20,10 @@ func (this *builder) VisitExplain(stmt *algebra.Explain) (interface{}, error) {
 		return nil, err
 	}
 
-	return plan.NewExplain(op.(plan.Operator), stmt.Text()), nil
+	explain := plan.NewExplain(op.(plan.Operator), stmt.Text(), stmt.Analyze(), stmt.Format())
+	if rp := stmt.RowPolicies(); rp != nil {
+		explain.SetPolicies(rp.Names())
+		explain.SetMasks(rp.MaskNames())
+	}
+	return explain, nil
 }
@@ -52,11 +52,43 @@ func (this *builder) buildAnsiJoin(node *algebra.AnsiJoin) (op plan.Operator, er
 		newKeyspaceTerm := algebra.NewKeyspaceTerm(right.Namespace(), right.Keyspace(), right.As(), primaryJoinKeys, right.Indexes())
 		newKeyspaceTerm.SetProperty(right.Property())
 		return plan.NewJoinFromAnsi(keyspace, newKeyspaceTerm, node.Outer()), nil
+	case *algebra.SubqueryTerm:
+		if !right.IsLateral() {
+			return nil, errors.NewPlanInternalError(fmt.Sprintf("buildAnsiJoin: ANSI JOIN on %s must be a keyspace", node.Alias()))
+		}
+
+		child, err := this.buildLateralSubquery(right)
+		if err != nil {
+			return nil, err
+		}
+
+		return plan.NewAnsiJoin(node, child), nil
 	default:
 		return nil, errors.NewPlanInternalError(fmt.Sprintf("buildAnsiJoin: ANSI JOIN on %s must be a keyspace", node.Alias()))
 	}
 }
 
+/*
+Build the plan of a LATERAL subquery. The resulting operator is run as
+the child of a nested-loop ANSI JOIN, once per item of the left hand
+side, with that item as its parent value.
+*/
+func (this *builder) buildLateralSubquery(node *algebra.SubqueryTerm) (plan.Operator, error) {
+	children := this.children
+	subChildren := this.subChildren
+	defer func() {
+		this.children = children
+		this.subChildren = subChildren
+	}()
+
+	sel, err := node.Subquery().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewSequence(sel.(plan.Operator), plan.NewAlias(node.Alias())), nil
+}
+
 func (this *builder) buildAnsiNest(node *algebra.AnsiNest) (op plan.Operator, err error) {
 	right := node.Right()
 
@@ -0,0 +1,49 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/plan"
+)
+
+func (this *builder) VisitCreatePolicy(stmt *algebra.CreatePolicy) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	ksref.SetDefaultNamespace(this.namespace)
+	_, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewCreatePolicy(stmt), nil
+}
+
+func (this *builder) VisitDropPolicy(stmt *algebra.DropPolicy) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	ksref.SetDefaultNamespace(this.namespace)
+	return plan.NewDropPolicy(stmt), nil
+}
+
+func (this *builder) VisitCreateMask(stmt *algebra.CreateMask) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	ksref.SetDefaultNamespace(this.namespace)
+	_, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewCreateMask(stmt), nil
+}
+
+func (this *builder) VisitDropMask(stmt *algebra.DropMask) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	ksref.SetDefaultNamespace(this.namespace)
+	return plan.NewDropMask(stmt), nil
+}
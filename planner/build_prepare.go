@@ -15,6 +15,7 @@ import (
 	"encoding/base64"
 
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/util"
@@ -60,3 +61,14 @@ func (this *builder) VisitPrepare(stmt *algebra.Prepare) (interface{}, error) {
 
 	return plan.NewPrepare(val, pl), nil
 }
+
+// plan a PREPARE statement outside of a request, as when reloading it
+func BuildPrepare(stmt *algebra.Prepare, datastore, systemstore datastore.Datastore, namespace string,
+	indexApiVersion int, featureControls uint64) (*plan.Prepared, error) {
+	builder := newBuilder(datastore, systemstore, namespace, false, nil, nil, indexApiVersion, featureControls)
+	o, err := builder.VisitPrepare(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return o.(*plan.Prepare).Plan(), nil
+}
@@ -0,0 +1,79 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+)
+
+/*
+Geo predicates are sargable on an index key GEOHASH(point, precision)
+over the same point. The bounds of the predicate are covered by
+geohash cells, and each cell becomes a prefix span. The spans are
+never exact; the predicate is always re-applied.
+*/
+func (this *sarg) visitGeo(pred expression.GeoFunction) (interface{}, error) {
+	if SubsetOf(pred, this.key) {
+		return _SELF_SPANS, nil
+	}
+
+	key, ok := this.key.(*expression.GeohashEncode)
+	if !ok || !pred.Point().EquivalentTo(key.Point()) {
+		if pred.DependsOn(this.key) {
+			return _VALUED_SPANS, nil
+		} else {
+			return nil, nil
+		}
+	}
+
+	precision, ok := key.Precision()
+	if !ok {
+		return _VALUED_SPANS, nil
+	}
+
+	box, ok := pred.Bounds()
+	if !ok {
+		return _VALUED_SPANS, nil
+	}
+
+	cells := expression.GeohashCover(box, precision)
+	if len(cells) == 0 {
+		return _EMPTY_SPANS, nil
+	}
+
+	// Merge adjacent cells into a single span
+	lows := make([]string, 0, len(cells))
+	highs := make([]string, 0, len(cells))
+	for _, cell := range cells {
+		// Geohash characters are alphanumeric, so incrementing
+		// the last byte never overflows
+		bytes := []byte(cell)
+		bytes[len(bytes)-1]++
+
+		n := len(highs)
+		if n > 0 && highs[n-1] == cell {
+			highs[n-1] = string(bytes)
+		} else {
+			lows = append(lows, cell)
+			highs = append(highs, string(bytes))
+		}
+	}
+
+	spans := make(plan.Spans2, 0, len(lows))
+	for i, low := range lows {
+		range2 := plan.NewRange2(expression.NewConstant(low),
+			expression.NewConstant(highs[i]), datastore.LOW)
+		spans = append(spans, plan.NewSpan2(nil, plan.Ranges2{range2}, false))
+	}
+
+	return NewTermSpans(spans...), nil
+}
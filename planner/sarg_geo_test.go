@@ -0,0 +1,227 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+)
+
+var geoPoint = expression.NewIdentifier("loc")
+
+func geoKey(precision int) expression.Expression {
+	return expression.NewGeohashEncode(geoPoint, expression.NewConstant(precision))
+}
+
+func geoBBox(west, south, east, north float64) expression.Expression {
+	return expression.NewGeoWithinBBox(geoPoint,
+		expression.NewConstant([]interface{}{west, south, east, north}))
+}
+
+type geoSpan struct {
+	low, high string
+}
+
+/*
+Return the prefix spans produced for pred on key, checking that each
+is a single [low, high) range over a geohash cell.
+*/
+func geoSpans(t *testing.T, pred, key expression.Expression) []geoSpan {
+	spans, err := sargFor(pred, key, false, "")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	terms, ok := spans.(*TermSpans)
+	if !ok || len(terms.spans) == 0 {
+		t.Fatalf("Expected term spans, got %v", spans)
+	}
+
+	rv := make([]geoSpan, 0, len(terms.spans))
+	for _, span := range terms.spans {
+		if span.Exact || len(span.Ranges) != 1 {
+			t.Fatalf("Expected one inexact range, got %v", span)
+		}
+
+		r := span.Ranges[0]
+		if r.Inclusion != datastore.LOW {
+			t.Errorf("Expected inclusion LOW, got %v", r.Inclusion)
+		}
+
+		low, _ := r.Low.Value().Actual().(string)
+		high, _ := r.High.Value().Actual().(string)
+		if low == "" || len(high) != len(low) || low >= high {
+			t.Fatalf("Invalid range [%v, %v)", r.Low, r.High)
+		}
+
+		if n := len(rv); n > 0 && rv[n-1].high >= low {
+			t.Errorf("Spans %v and [%s, %s) are not ordered and merged", rv[n-1], low, high)
+		}
+
+		rv = append(rv, geoSpan{low, high})
+	}
+
+	return rv
+}
+
+func geoCovered(spans []geoSpan, hash string) bool {
+	for _, span := range spans {
+		if hash >= span.low && hash < span.high {
+			return true
+		}
+	}
+
+	return false
+}
+
+func geoHash(t *testing.T, lon, lat float64, precision int) string {
+	point := expression.NewConstant([]interface{}{lon, lat})
+	hash, err := expression.NewGeohashEncode(point, expression.NewConstant(precision)).Evaluate(nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	return hash.Actual().(string)
+}
+
+/*
+Check that the spans cover exactly the cells of the box, and the
+geohashes of the given [lon, lat] points.
+*/
+func checkGeoCover(t *testing.T, id string, spans []geoSpan, box expression.GeoBox,
+	precision int, points [][2]float64) {
+	cells := expression.GeohashCover(box, precision)
+	if len(cells) == 0 {
+		t.Fatalf("Case %s: no cells for %v", id, box)
+	}
+
+	for _, cell := range cells {
+		if !geoCovered(spans, cell) {
+			t.Errorf("Case %s: cell %s is not covered by %v", id, cell, spans)
+		}
+	}
+
+	for _, span := range spans {
+		found := false
+		for _, cell := range cells {
+			if cell == span.low {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Case %s: span %v does not start at a cell", id, span)
+		}
+	}
+
+	for _, p := range points {
+		hash := geoHash(t, p[0], p[1], precision)
+		if !geoCovered(spans, hash) {
+			t.Errorf("Case %s: point %v (%s) is not covered by %v", id, p, hash, spans)
+		}
+	}
+}
+
+func TestSargGeoBBox(t *testing.T) {
+	cases := []struct {
+		id        string
+		box       expression.GeoBox
+		precision int
+		points    [][2]float64
+	}{
+		{"city", expression.GeoBox{West: -122.5, South: 37.7, East: -122.3, North: 37.8}, 5,
+			[][2]float64{{-122.5, 37.7}, {-122.3, 37.8}, {-122.4, 37.75}}},
+		{"antimeridian", expression.GeoBox{West: 179.5, South: -1.0, East: -179.5, North: 1.0}, 3,
+			[][2]float64{{179.9, 0.0}, {-179.9, 0.0}, {179.5, -1.0}, {-179.5, 1.0}}},
+		{"north-east corner", expression.GeoBox{West: 170.0, South: 80.0, East: 180.0, North: 90.0}, 4,
+			[][2]float64{{180.0, 90.0}, {170.0, 80.0}}},
+		{"south-west corner", expression.GeoBox{West: -180.0, South: -90.0, East: -170.0, North: -80.0}, 4,
+			[][2]float64{{-180.0, -90.0}, {-170.0, -80.0}}},
+	}
+
+	for _, c := range cases {
+		pred := geoBBox(c.box.West, c.box.South, c.box.East, c.box.North)
+		spans := geoSpans(t, pred, geoKey(c.precision))
+		checkGeoCover(t, c.id, spans, c.box, c.precision, c.points)
+	}
+
+	// The antimeridian box does not cover the other side of the globe
+	spans := geoSpans(t, geoBBox(179.5, -1.0, -179.5, 1.0), geoKey(3))
+	if geoCovered(spans, geoHash(t, 0.0, 0.0, 3)) {
+		t.Errorf("Antimeridian spans %v cover [0, 0]", spans)
+	}
+}
+
+func TestSargGeoWorld(t *testing.T) {
+	// Too many cells at full precision; coarser cells are used
+	spans := geoSpans(t, geoBBox(-180.0, -90.0, 180.0, 90.0), geoKey(12))
+	for _, span := range spans {
+		if len(span.low) >= 12 {
+			t.Errorf("Expected coarser cells, got %v", span)
+		}
+	}
+
+	for _, p := range [][2]float64{{-180.0, -90.0}, {180.0, 90.0}, {0.0, 0.0}, {-73.98, 40.75}} {
+		if !geoCovered(spans, geoHash(t, p[0], p[1], 12)) {
+			t.Errorf("Point %v is not covered by %v", p, spans)
+		}
+	}
+}
+
+func TestSargGeoRadius(t *testing.T) {
+	center := map[string]interface{}{"lat": 37.77, "lon": -122.42}
+	pred := expression.NewGeoWithinRadius(geoPoint, expression.NewConstant(center),
+		expression.NewConstant(5000.0))
+
+	box, ok := pred.(expression.GeoFunction).Bounds()
+	if !ok {
+		t.Fatalf("Expected bounds for %v", pred)
+	}
+
+	spans := geoSpans(t, pred, geoKey(5))
+	checkGeoCover(t, "radius", spans, box, 5, [][2]float64{{-122.42, 37.77}})
+}
+
+func TestSargGeoNotSargable(t *testing.T) {
+	box := expression.NewConstant([]interface{}{-122.5, 37.7, -122.3, 37.8})
+	bounds := expression.NewField(expression.NewIdentifier("doc"), expression.NewFieldName("box", false))
+	precision := expression.NewField(expression.NewIdentifier("doc"), expression.NewFieldName("precision", false))
+
+	cases := []struct {
+		id   string
+		pred expression.Expression
+		key  expression.Expression
+	}{
+		{"plain key", expression.NewGeoWithinBBox(geoPoint, box), geoPoint},
+		{"variable precision", expression.NewGeoWithinBBox(geoPoint, box),
+			expression.NewGeohashEncode(geoPoint, precision)},
+		{"variable bounds", expression.NewGeoWithinBBox(geoPoint, bounds), geoKey(5)},
+		{"invalid bounds", geoBBox(-122.5, 37.8, -122.3, 37.7), geoKey(5)},
+	}
+
+	for _, c := range cases {
+		spans, err := sargFor(c.pred, c.key, false, "")
+		if err != nil {
+			t.Fatalf("Case %s: unexpected error %v", c.id, err)
+		}
+		if spans != _VALUED_SPANS {
+			t.Errorf("Case %s: expected valued spans, got %v", c.id, spans)
+		}
+	}
+
+	// A predicate on another point does not constrain the key
+	other := expression.NewGeoWithinBBox(expression.NewIdentifier("other"), box)
+	spans, err := sargFor(other, geoKey(5), false, "")
+	if err != nil || spans != nil {
+		t.Errorf("Expected no spans for another point, got %v, %v", spans, err)
+	}
+}
@@ -226,6 +226,8 @@ func (this *sargable) VisitFunction(pred expression.Function) (interface{}, erro
 	switch pred := pred.(type) {
 	case *expression.RegexpLike:
 		return this.visitLike(pred)
+	case expression.GeoFunction:
+		return this.visitGeo(pred)
 	}
 
 	return this.visitDefault(pred)
@@ -0,0 +1,639 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+)
+
+type rowPolicied interface {
+	RowPolicies() *auth.RowPolicies
+	SetRowPolicies(rowPolicies *auth.RowPolicies)
+}
+
+/*
+Inject the predicates of the policies applicable to the users into
+a parsed and formalized statement, mask the fields the users may not
+see, and record on the statement the keyspaces that were checked and
+the policies and masks that were applied.
+*/
+func Apply(stmt algebra.Statement, namespace string, users auth.AuthenticatedUsers) errors.Error {
+	return _REGISTRY.apply(stmt, namespace, users)
+}
+
+/*
+Check that the policies and masks a plan was built with are those
+applicable to the users running it. Plans predating policies carry no record, and
+are checked against the keyspaces they require privileges on.
+*/
+func Verify(rowPolicies *auth.RowPolicies, privs *auth.Privileges, users auth.AuthenticatedUsers) errors.Error {
+	return _REGISTRY.verify(rowPolicies, privs, users)
+}
+
+func (this *Registry) apply(stmt algebra.Statement, namespace string, users auth.AuthenticatedUsers) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	err := this.load(false)
+	if err != nil {
+		return err
+	}
+
+	rw := &rewriter{
+		registry:  this,
+		namespace: namespace,
+		users:     users,
+		scopes:    make(map[string]bool),
+		applied:   make(map[string]int64),
+		masks:     make(map[string]int64),
+		visited:   make(map[*algebra.Select]bool),
+		skip:      make(map[expression.Expression]bool),
+	}
+	err = rw.visitStatement(stmt)
+	if err != nil {
+		return err
+	}
+
+	rowPolicies := rw.rowPolicies()
+	setRowPolicies(stmt, rowPolicies)
+
+	// the prepared statement is planned on its own
+	if prepare, ok := stmt.(*algebra.Prepare); ok {
+		setRowPolicies(prepare.Statement(), rowPolicies)
+	}
+	return nil
+}
+
+func setRowPolicies(stmt algebra.Statement, rowPolicies *auth.RowPolicies) {
+	if rp, ok := stmt.(rowPolicied); ok {
+		rp.SetRowPolicies(rowPolicies)
+	}
+}
+
+func (this *Registry) verify(rowPolicies *auth.RowPolicies, privs *auth.Privileges,
+	users auth.AuthenticatedUsers) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	err := this.load(false)
+	if err != nil {
+		return err
+	}
+	if len(this.policies) == 0 && len(this.masks) == 0 &&
+		(rowPolicies == nil || (len(rowPolicies.Applied) == 0 && len(rowPolicies.Masks) == 0)) {
+		return nil
+	}
+
+	var scopes []string
+	var expected, expectedMasks map[string]int64
+	if rowPolicies != nil {
+		scopes = rowPolicies.Scopes
+		expected = rowPolicies.Applied
+		expectedMasks = rowPolicies.Masks
+	} else if privs != nil {
+		privs.ForEach(func(pair auth.PrivilegePair) {
+			switch pair.Priv {
+			case auth.PRIV_QUERY_SELECT:
+				scopes = append(scopes, SELECT+":"+pair.Target)
+			case auth.PRIV_QUERY_UPDATE:
+				scopes = append(scopes, UPDATE+":"+pair.Target)
+			case auth.PRIV_QUERY_DELETE:
+				scopes = append(scopes, DELETE+":"+pair.Target)
+			}
+		})
+	}
+
+	found := make(map[string]bool, len(expected))
+	foundMasks := make(map[string]bool, len(expectedMasks))
+	for _, scope := range scopes {
+		parts := strings.SplitN(scope, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		policies, err := this.applicable(parts[1], parts[2], parts[0], users)
+		if err != nil {
+			return err
+		}
+		for _, p := range policies {
+			revision, ok := expected[p.Name]
+			if !ok || revision != p.Revision {
+				return errors.NewPolicyMismatchError()
+			}
+			found[p.Name] = true
+		}
+
+		// masks apply to every operation
+		masks, err := this.applicableMasks(parts[1], parts[2], users)
+		if err != nil {
+			return err
+		}
+		for _, m := range masks {
+			revision, ok := expectedMasks[m.Name]
+			if !ok || revision != m.Revision {
+				return errors.NewPolicyMismatchError()
+			}
+			foundMasks[m.Name] = true
+		}
+	}
+
+	// policies or masks no longer applicable
+	if len(found) != len(expected) || len(foundMasks) != len(expectedMasks) {
+		return errors.NewPolicyMismatchError()
+	}
+	return nil
+}
+
+type rewriter struct {
+	registry  *Registry
+	namespace string
+	users     auth.AuthenticatedUsers
+	scopes    map[string]bool
+	applied   map[string]int64
+	masks     map[string]int64
+	visited   map[*algebra.Select]bool
+	skip      map[expression.Expression]bool
+}
+
+func (this *rewriter) rowPolicies() *auth.RowPolicies {
+	rv := &auth.RowPolicies{Scopes: make([]string, 0, len(this.scopes))}
+	for scope, _ := range this.scopes {
+		rv.Scopes = append(rv.Scopes, scope)
+	}
+	sort.Strings(rv.Scopes)
+	if len(this.applied) > 0 {
+		rv.Applied = this.applied
+	}
+	if len(this.masks) > 0 {
+		rv.Masks = this.masks
+	}
+	return rv
+}
+
+func (this *rewriter) visitStatement(stmt algebra.Statement) errors.Error {
+	switch stmt := stmt.(type) {
+	case *algebra.Select:
+		return this.visitSelect(stmt)
+	case *algebra.Update:
+		return this.visitUpdate(stmt)
+	case *algebra.Delete:
+		err := this.visitSubqueries(stmt.Expressions())
+		if err != nil {
+			return err
+		}
+		ref := stmt.KeyspaceRef()
+		where, err := this.filter(ref.Namespace(), ref.Keyspace(), ref.Alias(), DELETE, stmt.Where())
+		if err != nil {
+			return err
+		}
+		stmt.SetWhere(where)
+
+		aliases := make(map[string][]*Mask, 1)
+		err = this.collectMasks(aliases, ref.Namespace(), ref.Keyspace(), ref.Alias())
+		if err != nil || len(aliases) == 0 {
+			return err
+		}
+		return this.mask(stmt, stmt.Returning(), aliases)
+	case *algebra.Insert:
+		if stmt.Select() != nil {
+			err := this.visitSelect(stmt.Select())
+			if err != nil {
+				return err
+			}
+		}
+		return this.visitSubqueries(stmt.Expressions())
+	case *algebra.Upsert:
+
+		// an UPSERT can overwrite rows an UPDATE policy would not let through
+		ref := stmt.KeyspaceRef()
+		err := this.unsupported(ref.Namespace(), ref.Keyspace(), UPDATE, "UPSERT")
+		if err != nil {
+			return err
+		}
+		if stmt.Select() != nil {
+			err = this.visitSelect(stmt.Select())
+			if err != nil {
+				return err
+			}
+		}
+		return this.visitSubqueries(stmt.Expressions())
+	case *algebra.Merge:
+		return this.visitMerge(stmt)
+	case *algebra.InferKeyspace:
+		ref := stmt.Keyspace()
+		err := this.unsupported(ref.Namespace(), ref.Keyspace(), SELECT, "INFER")
+		if err != nil {
+			return err
+		}
+		masks, err := this.registry.applicableMasks(this.ns(ref.Namespace()), ref.Keyspace(), this.users)
+		if err != nil || len(masks) == 0 {
+			return err
+		}
+		methods := make(map[string]string, len(masks))
+		for _, m := range masks {
+			methods[m.Path] = m.Method
+			this.masks[m.Name] = m.Revision
+		}
+		stmt.SetMasks(methods)
+		return nil
+	case *algebra.Explain:
+		return this.visitStatement(stmt.Statement())
+	case *algebra.Prepare:
+		return this.visitStatement(stmt.Statement())
+	}
+	return nil
+}
+
+/*
+The paths updated by SET and UNSET are not masked, as they are
+written to rather than read.
+*/
+func (this *rewriter) visitUpdate(stmt *algebra.Update) errors.Error {
+	err := this.visitSubqueries(stmt.Expressions())
+	if err != nil {
+		return err
+	}
+	ref := stmt.KeyspaceRef()
+	where, err := this.filter(ref.Namespace(), ref.Keyspace(), ref.Alias(), UPDATE, stmt.Where())
+	if err != nil {
+		return err
+	}
+	stmt.SetWhere(where)
+
+	aliases := make(map[string][]*Mask, 1)
+	err = this.collectMasks(aliases, ref.Namespace(), ref.Keyspace(), ref.Alias())
+	if err != nil || len(aliases) == 0 {
+		return err
+	}
+
+	// the variables of update-for clauses refer into the document
+	if stmt.Set() != nil {
+		for _, term := range stmt.Set().Terms() {
+			if term.UpdateFor() != nil {
+				return errors.NewMaskUnsupportedError(this.ns(ref.Namespace())+":"+ref.Keyspace(), "UPDATE FOR")
+			}
+			this.skip[term.Path()] = true
+		}
+	}
+	if stmt.Unset() != nil {
+		for _, term := range stmt.Unset().Terms() {
+			if term.UpdateFor() != nil {
+				return errors.NewMaskUnsupportedError(this.ns(ref.Namespace())+":"+ref.Keyspace(), "UPDATE FOR")
+			}
+			this.skip[term.Path()] = true
+		}
+	}
+	return this.mask(stmt, stmt.Returning(), aliases)
+}
+
+/*
+MERGE has no filter on its target to restrict, and no WHERE
+clause for a keyspace source.
+*/
+func (this *rewriter) visitMerge(stmt *algebra.Merge) errors.Error {
+	source := stmt.Source()
+	if source.Select() != nil {
+		err := this.visitSelect(source.Select())
+		if err != nil {
+			return err
+		}
+	}
+	term := source.From()
+	if source.ExpressionTerm() != nil && source.ExpressionTerm().IsKeyspace() {
+		term = source.ExpressionTerm().KeyspaceTerm()
+	}
+	if term != nil {
+		err := this.unsupported(term.Namespace(), term.Keyspace(), SELECT, "a MERGE source")
+		if err == nil {
+			err = this.unmaskable(term.Namespace(), term.Keyspace(), "a MERGE source")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	ref := stmt.KeyspaceRef()
+	for _, op := range []string{UPDATE, DELETE} {
+		err := this.unsupported(ref.Namespace(), ref.Keyspace(), op, "MERGE")
+		if err != nil {
+			return err
+		}
+	}
+	err := this.unmaskable(ref.Namespace(), ref.Keyspace(), "MERGE")
+	if err != nil {
+		return err
+	}
+	return this.visitSubqueries(stmt.Expressions())
+}
+
+func (this *rewriter) visitSelect(stmt *algebra.Select) errors.Error {
+	if this.visited[stmt] {
+		return nil
+	}
+	this.visited[stmt] = true
+
+	aliases, err := this.visitSubresult(stmt.Subresult())
+	if err != nil {
+		return err
+	}
+
+	// ORDER BY is evaluated on the items, rather than the projection
+	if len(aliases) > 0 && stmt.Order() != nil {
+		e := stmt.Order().MapExpressions(newMasker(aliases, this.skip))
+		if e != nil {
+			return errors.NewError(e, "")
+		}
+	}
+	return this.visitSubqueries(stmt.Expressions())
+}
+
+func (this *rewriter) visitSubqueries(exprs expression.Expressions) errors.Error {
+	subqueries, e := expression.ListSubqueries(exprs, false)
+	if e != nil {
+		return errors.NewError(e, "")
+	}
+	for _, s := range subqueries {
+		err := this.visitSelect(s.(*algebra.Subquery).Select())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type setOp interface {
+	First() algebra.Subresult
+	Second() algebra.Subresult
+}
+
+/*
+Returns the masks of the keyspace aliases of a subselect, which
+have been applied to its expressions.
+*/
+func (this *rewriter) visitSubresult(subresult algebra.Subresult) (map[string][]*Mask, errors.Error) {
+	switch subresult := subresult.(type) {
+	case *algebra.Subselect:
+		if subresult.From() == nil {
+			return nil, nil
+		}
+		aliases := make(map[string][]*Mask)
+		err := this.visitFrom(subresult.From(), subresult, aliases)
+		if err != nil || len(aliases) == 0 {
+			return nil, err
+		}
+		return aliases, this.mask(subresult, subresult.Projection(), aliases)
+	case *algebra.SelectTerm:
+		return nil, this.visitSelect(subresult.Select())
+	case setOp:
+		_, err := this.visitSubresult(subresult.First())
+		if err != nil {
+			return nil, err
+		}
+		_, err = this.visitSubresult(subresult.Second())
+		return nil, err
+	}
+	return nil, nil
+}
+
+/*
+Keyspaces scanned, and inner lookup joins, are filtered in WHERE.
+The right hand side of ANSI joins and nests is filtered in the ON
+clause, which keeps outer joins correct. The masks of every keyspace
+are collected by alias.
+*/
+func (this *rewriter) visitFrom(term algebra.FromTerm, sub *algebra.Subselect,
+	aliases map[string][]*Mask) errors.Error {
+	switch term := term.(type) {
+	case *algebra.KeyspaceTerm:
+		return this.filterWhere(term, sub, aliases)
+	case *algebra.ExpressionTerm:
+		if term.IsKeyspace() {
+			return this.filterWhere(term.KeyspaceTerm(), sub, aliases)
+		}
+	case *algebra.SubqueryTerm:
+		return this.visitSelect(term.Subquery())
+	case *algebra.Join:
+		err := this.visitFrom(term.Left(), sub, aliases)
+		if err != nil {
+			return err
+		}
+		if term.Outer() {
+			return this.outerLookup(term.Right(), aliases, "outer lookup joins")
+		}
+		return this.filterWhere(term.Right(), sub, aliases)
+	case *algebra.IndexJoin:
+		err := this.visitFrom(term.Left(), sub, aliases)
+		if err != nil {
+			return err
+		}
+		if term.Outer() {
+			return this.outerLookup(term.Right(), aliases, "outer lookup joins")
+		}
+		return this.filterWhere(term.Right(), sub, aliases)
+	case *algebra.Nest:
+		err := this.visitFrom(term.Left(), sub, aliases)
+		if err != nil {
+			return err
+		}
+		return this.outerLookup(term.Right(), aliases, "lookup nests")
+	case *algebra.IndexNest:
+		err := this.visitFrom(term.Left(), sub, aliases)
+		if err != nil {
+			return err
+		}
+		return this.outerLookup(term.Right(), aliases, "lookup nests")
+	case *algebra.AnsiJoin:
+		err := this.visitFrom(term.Left(), sub, aliases)
+		if err != nil {
+			return err
+		}
+		onclause, err := this.filterRight(term.Right(), term.Onclause(), aliases)
+		if err == nil {
+			term.SetOnclause(onclause)
+		}
+		return err
+	case *algebra.AnsiNest:
+		err := this.visitFrom(term.Left(), sub, aliases)
+		if err != nil {
+			return err
+		}
+		onclause, err := this.filterRight(term.Right(), term.Onclause(), aliases)
+		if err == nil {
+			term.SetOnclause(onclause)
+		}
+		return err
+	case *algebra.Unnest:
+		return this.visitFrom(term.Left(), sub, aliases)
+	}
+	return nil
+}
+
+/*
+Policies cannot filter outer lookup joins and nests, but masks
+apply to them.
+*/
+func (this *rewriter) outerLookup(term *algebra.KeyspaceTerm, aliases map[string][]*Mask,
+	what string) errors.Error {
+	err := this.unsupported(this.ns(term.Namespace()), term.Keyspace(), SELECT, what)
+	if err != nil {
+		return err
+	}
+	return this.collectMasks(aliases, term.Namespace(), term.Keyspace(), term.Alias())
+}
+
+func (this *rewriter) filterWhere(term *algebra.KeyspaceTerm, sub *algebra.Subselect,
+	aliases map[string][]*Mask) errors.Error {
+	where, err := this.filter(term.Namespace(), term.Keyspace(), term.Alias(), SELECT, sub.Where())
+	if err != nil {
+		return err
+	}
+	sub.SetWhere(where)
+	return this.collectMasks(aliases, term.Namespace(), term.Keyspace(), term.Alias())
+}
+
+func (this *rewriter) filterRight(term algebra.FromTerm, onclause expression.Expression,
+	aliases map[string][]*Mask) (expression.Expression, errors.Error) {
+	switch term := term.(type) {
+	case *algebra.KeyspaceTerm:
+		onclause, err := this.filter(term.Namespace(), term.Keyspace(), term.Alias(), SELECT, onclause)
+		if err != nil {
+			return nil, err
+		}
+		return onclause, this.collectMasks(aliases, term.Namespace(), term.Keyspace(), term.Alias())
+	case *algebra.ExpressionTerm:
+		if term.IsKeyspace() {
+			ks := term.KeyspaceTerm()
+			onclause, err := this.filter(ks.Namespace(), ks.Keyspace(), ks.Alias(), SELECT, onclause)
+			if err != nil {
+				return nil, err
+			}
+			return onclause, this.collectMasks(aliases, ks.Namespace(), ks.Keyspace(), ks.Alias())
+		}
+	case *algebra.SubqueryTerm:
+		return onclause, this.visitSelect(term.Subquery())
+	}
+	return onclause, nil
+}
+
+/*
+AND the predicates of the applicable policies, qualified by the
+alias of the keyspace, with a filter.
+*/
+func (this *rewriter) filter(namespace, keyspace, alias, op string, cond expression.Expression) (
+	expression.Expression, errors.Error) {
+	namespace = this.ns(namespace)
+	this.scopes[op+":"+namespace+":"+keyspace] = true
+
+	policies, err := this.registry.applicable(namespace, keyspace, op, this.users)
+	if err != nil || len(policies) == 0 {
+		return cond, err
+	}
+
+	terms := make(expression.Expressions, 0, len(policies)+1)
+	if cond != nil {
+		terms = append(terms, cond)
+	}
+	for _, p := range policies {
+
+		// formalizing maps the expression in place
+		expr, e := expression.NewSelfFormalizer(alias, nil).Map(p.using.Copy())
+		if e != nil {
+			return nil, errors.NewInvalidPolicyError(p.Name, e.Error())
+		}
+
+		// policies see the fields masks hide
+		this.skip[expr] = true
+		terms = append(terms, expr)
+		this.applied[p.Name] = p.Revision
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return expression.NewAnd(terms...), nil
+}
+
+func (this *rewriter) unsupported(namespace, keyspace, op, what string) errors.Error {
+	namespace = this.ns(namespace)
+	this.scopes[op+":"+namespace+":"+keyspace] = true
+
+	policies, err := this.registry.applicable(namespace, keyspace, op, this.users)
+	if err != nil {
+		return err
+	}
+	if len(policies) > 0 {
+		return errors.NewPolicyUnsupportedError(namespace+":"+keyspace, what)
+	}
+	return nil
+}
+
+/*
+Record the masks applicable to a keyspace under its alias.
+*/
+func (this *rewriter) collectMasks(aliases map[string][]*Mask, namespace, keyspace, alias string) errors.Error {
+	masks, err := this.registry.applicableMasks(this.ns(namespace), keyspace, this.users)
+	if err != nil || len(masks) == 0 {
+		return err
+	}
+	aliases[alias] = masks
+	for _, m := range masks {
+		this.masks[m.Name] = m.Revision
+	}
+	return nil
+}
+
+func (this *rewriter) unmaskable(namespace, keyspace, what string) errors.Error {
+	namespace = this.ns(namespace)
+	masks, err := this.registry.applicableMasks(namespace, keyspace, this.users)
+	if err != nil {
+		return err
+	}
+	if len(masks) > 0 {
+		return errors.NewMaskUnsupportedError(namespace+":"+keyspace, what)
+	}
+	return nil
+}
+
+type mappable interface {
+	MapExpressions(mapper expression.Mapper) error
+}
+
+/*
+Mask the expressions of a subselect or of a DML statement, and the
+unprefixed stars of its projection or RETURNING clause, which are
+masked as the items are projected.
+*/
+func (this *rewriter) mask(node mappable, projection *algebra.Projection,
+	aliases map[string][]*Mask) errors.Error {
+	masker := newMasker(aliases, this.skip)
+	e := node.MapExpressions(masker)
+	if e != nil {
+		return errors.NewError(e, "")
+	}
+	if projection == nil {
+		return nil
+	}
+	for _, term := range projection.Terms() {
+		if term.Star() && term.Expression() == nil {
+			projection.SetMask(masker.maskSelf())
+			break
+		}
+	}
+	return nil
+}
+
+func (this *rewriter) ns(namespace string) string {
+	if namespace == "" {
+		return this.namespace
+	}
+	return namespace
+}
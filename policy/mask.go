@@ -0,0 +1,349 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/logging"
+)
+
+const _MASKS_KEY = "column_masks"
+
+type Mask struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Keyspace  string   `json:"keyspace"`
+	Path      string   `json:"path"`
+	Method    string   `json:"method"`
+	To        []string `json:"to,omitempty"`
+	Revision  int64    `json:"revision"`
+
+	names []string
+}
+
+/*
+Build a mask from a CREATE MASK statement. The path has been
+formalized by the parser, and is relative to the documents of the
+keyspace.
+*/
+func NewMask(stmt *algebra.CreateMask, namespace string) *Mask {
+	if stmt.Keyspace().Namespace() != "" {
+		namespace = stmt.Keyspace().Namespace()
+	}
+	names, _ := maskPath(stmt.Path())
+	return &Mask{
+		Name:      stmt.Name(),
+		Namespace: namespace,
+		Keyspace:  stmt.Keyspace().Keyspace(),
+		Path:      strings.Join(names, "."),
+		Method:    stmt.Method(),
+		To:        stmt.To(),
+		names:     names,
+	}
+}
+
+/*
+The field names of a mask path, which may only navigate fields of
+the document.
+*/
+func maskPath(expr expression.Expression) ([]string, bool) {
+	switch expr := expr.(type) {
+	case *expression.Self:
+		return nil, true
+	case *expression.Identifier:
+		if expr.CaseInsensitive() {
+			return nil, false
+		}
+		return []string{expr.Identifier()}, true
+	case *expression.Field:
+		names, ok := maskPath(expr.First())
+		name, isName := expr.Second().(*expression.FieldName)
+		if !ok || !isName || expr.CaseInsensitive() {
+			return nil, false
+		}
+		return append(names, name.Alias()), true
+	}
+	return nil, false
+}
+
+func (this *Mask) validate() errors.Error {
+	if len(this.names) == 0 {
+		return errors.NewInvalidMaskError(this.Name, "only fields of the documents can be masked.")
+	}
+	for _, name := range this.names {
+		if strings.Contains(name, ".") {
+			return errors.NewInvalidMaskError(this.Name, "masked field names cannot contain dots.")
+		}
+	}
+	switch this.Method {
+	case expression.REDACT_HASH, expression.REDACT_PARTIAL, expression.REDACT_NULL:
+		return nil
+	}
+	return errors.NewInvalidMaskError(this.Name, "the method must be one of hash, partial or null.")
+}
+
+func CreateMask(mask *Mask) errors.Error {
+	return _REGISTRY.createMask(mask)
+}
+
+func DropMask(namespace, keyspace, name string) errors.Error {
+	return _REGISTRY.dropMask(namespace, keyspace, name)
+}
+
+func (this *Registry) createMask(mask *Mask) errors.Error {
+	err := mask.validate()
+	if err != nil {
+		return err
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	err = this.load(true)
+	if err != nil {
+		return err
+	}
+	for _, m := range this.masks {
+		if m.Name == mask.Name {
+			return errors.NewMaskExistsError(mask.Name)
+		}
+	}
+	mask.Revision = time.Now().UnixNano()
+	return this.saveMasks(append(this.masks[:len(this.masks):len(this.masks)], mask))
+}
+
+func (this *Registry) dropMask(namespace, keyspace, name string) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	err := this.load(true)
+	if err != nil {
+		return err
+	}
+	for i, m := range this.masks {
+		if m.Name == name && m.Namespace == namespace && m.Keyspace == keyspace {
+			masks := make([]*Mask, 0, len(this.masks)-1)
+			masks = append(masks, this.masks[:i]...)
+			return this.saveMasks(append(masks, this.masks[i+1:]...))
+		}
+	}
+	return errors.NewMaskNotFoundError(name, namespace+":"+keyspace)
+}
+
+// caller holds the lock
+func (this *Registry) loadMasks() ([]*Mask, errors.Error) {
+	data, err := this.store.GetMetadata(_MASKS_KEY)
+	if err != nil {
+		logging.Errorf("Unable to load column masks: %v", err)
+		return nil, errors.NewMaskStoreError(err, "loading")
+	}
+
+	var masks []*Mask
+	if len(data) > 0 {
+		e := json.Unmarshal(data, &masks)
+		if e != nil {
+			logging.Errorf("Unable to decode column masks: %v", e)
+			return nil, errors.NewMaskStoreError(e, "decoding")
+		}
+	}
+	for _, m := range masks {
+		m.names = strings.Split(m.Path, ".")
+	}
+	return masks, nil
+}
+
+// caller holds the lock
+func (this *Registry) saveMasks(masks []*Mask) errors.Error {
+	if this.store == nil {
+		return errors.NewMaskStoreError(nil, "storing")
+	}
+	data, e := json.Marshal(masks)
+	if e != nil {
+		return errors.NewMaskStoreError(e, "encoding")
+	}
+	err := this.store.SetMetadata(_MASKS_KEY, data)
+	if err != nil {
+		return errors.NewMaskStoreError(err, "storing")
+	}
+	this.masks = masks
+	return nil
+}
+
+/*
+The masks applicable to a keyspace for a set of users. Caller holds
+the lock.
+*/
+func (this *Registry) applicableMasks(namespace, keyspace string,
+	users auth.AuthenticatedUsers) ([]*Mask, errors.Error) {
+	var candidates []*Mask
+	for _, m := range this.masks {
+		if m.Namespace == namespace && m.Keyspace == keyspace {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	roles, err := this.userRoles()
+	if err != nil || isAdmin(users, roles) {
+		return nil, err
+	}
+
+	rv := candidates[:0]
+	for _, m := range candidates {
+		if granted(m.To, m.Keyspace, users, roles) {
+			rv = append(rv, m)
+		}
+	}
+	return rv, nil
+}
+
+/*
+masker rewrites the references to the keyspace aliases of a
+subselect that reach masked fields with REDACT. For hash masks on
+email and contact.email:
+
+	o.email          REDACT(o.email, {"": "hash"})
+	o.email.domain   REDACT(o.email, {"": "hash"}).domain
+	o.contact        REDACT(o.contact, {"email": "hash"})
+	o                REDACT(o, {"contact.email": "hash", "email": "hash"})
+	SELF             REDACT(SELF, {"o.contact.email": "hash", "o.email": "hash"})
+
+Fields disjoint from the masked ones are left alone, so that they
+remain sargable and coverable. The REDACT expressions created, and
+the predicates of policies, are recorded in skip and left as they
+are by later maskers.
+*/
+type masker struct {
+	expression.MapperBase
+	aliases map[string][]*Mask
+	skip    map[expression.Expression]bool
+}
+
+func newMasker(aliases map[string][]*Mask, skip map[expression.Expression]bool) *masker {
+	rv := &masker{
+		aliases: aliases,
+		skip:    skip,
+	}
+
+	rv.SetMapper(rv)
+	rv.SetMapFunc(rv.mask)
+	return rv
+}
+
+func (this *masker) mask(expr expression.Expression) (expression.Expression, error) {
+	if this.skip[expr] {
+		return expr, nil
+	}
+
+	switch expr := expr.(type) {
+	case *expression.Self:
+		return this.maskSelf(), nil
+
+	// META() takes the alias itself
+	case *expression.Meta:
+		return expr, nil
+	}
+
+	alias, names, ok := fieldPath(expr)
+	if ok {
+		if masks, ok := this.aliases[alias]; ok {
+			return this.redact(expr, names, masks), nil
+		}
+		return expr, nil
+	}
+
+	return expr, expr.MapChildren(this)
+}
+
+/*
+The alias and field names of an identifier, or of a chain of
+constant, case sensitive, field accesses. Any other navigation is
+applied to the masked value of the chain it navigates.
+*/
+func fieldPath(expr expression.Expression) (string, []string, bool) {
+	switch expr := expr.(type) {
+	case *expression.Identifier:
+		return expr.Identifier(), nil, true
+	case *expression.Field:
+		name, ok := expr.Second().(*expression.FieldName)
+		if !ok || expr.CaseInsensitive() {
+			return "", nil, false
+		}
+		alias, names, ok := fieldPath(expr.First())
+		if !ok {
+			return "", nil, false
+		}
+		return alias, append(names[:len(names):len(names)], name.Alias()), true
+	}
+	return "", nil, false
+}
+
+func (this *masker) redact(expr expression.Expression, names []string, masks []*Mask) expression.Expression {
+	exact := ""
+	below := false
+	inner := make(map[string]interface{}, len(masks))
+	for _, m := range masks {
+		switch {
+		case len(m.names) < len(names) && isPrefix(m.names, names):
+			below = true
+		case len(m.names) == len(names) && isPrefix(m.names, names):
+			if exact == "" {
+				exact = m.Method
+			}
+		case len(m.names) > len(names) && isPrefix(names, m.names):
+			inner[strings.Join(m.names[len(names):], ".")] = m.Method
+		}
+	}
+
+	if below {
+		field := expr.(*expression.Field)
+		return expression.NewField(this.redact(field.First(), names[:len(names)-1], masks), field.Second())
+	} else if exact != "" {
+		return this.newRedact(expr, map[string]interface{}{"": exact})
+	} else if len(inner) > 0 {
+		return this.newRedact(expr, inner)
+	}
+	return expr
+}
+
+/*
+SELF masks the fields of every alias.
+*/
+func (this *masker) maskSelf() expression.Expression {
+	masks := make(map[string]interface{})
+	for alias, ms := range this.aliases {
+		for _, m := range ms {
+			masks[alias+"."+m.Path] = m.Method
+		}
+	}
+	return this.newRedact(expression.NewSelf(), masks)
+}
+
+func (this *masker) newRedact(expr expression.Expression, masks map[string]interface{}) expression.Expression {
+	rv := expression.NewRedact(expr, expression.NewConstant(masks))
+	this.skip[rv] = true
+	return rv
+}
+
+func isPrefix(prefix, names []string) bool {
+	for i, name := range prefix {
+		if names[i] != name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,387 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package policy implements row security policies and column masks.
+
+A policy restricts the rows of a keyspace that SELECT, UPDATE and
+DELETE statements see to those satisfying a predicate. Policies are
+kept as a single document in the configuration store, so that every
+query node shares them, and each node reloads the document at most
+every few seconds.
+
+Before planning, Apply ANDs the predicates of the applicable
+policies into the WHERE clause (or the ON clause of ANSI joins) of
+the statement, where they are sargable like any other filter. The
+keyspaces checked and the policies applied are recorded on the
+statement and carried by the Authorize operator of the plan, so that
+Verify can reject a plan whose policies do not match the user
+running it, for instance a prepared statement executed by another
+user, or a plan older than a policy change.
+
+Masks hash, partially mask or null out a field of the documents of
+a keyspace for a set of users. They are kept and checked like
+policies, and Apply rewrites every reference to a masked field, or to
+an object containing it, with the REDACT function, so that the field
+is masked wherever it is used: in projections, in filters, which
+can then neither probe it nor be pushed to an index on it, and over
+covering index keys.
+
+Users holding the admin role bypass all policies and masks.
+*/
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/logging"
+)
+
+const (
+	SELECT = "select"
+	UPDATE = "update"
+	DELETE = "delete"
+)
+
+const _METADATA_KEY = "row_policies"
+const _REFRESH = 5 * time.Second
+const _ADMIN_ROLE = "admin"
+
+// The subset of clustering.ConfigurationStore used to share policies
+type Store interface {
+	GetMetadata(key string) ([]byte, errors.Error)
+	SetMetadata(key string, value []byte) errors.Error
+}
+
+type Policy struct {
+	Name       string   `json:"name"`
+	Namespace  string   `json:"namespace"`
+	Keyspace   string   `json:"keyspace"`
+	Operations []string `json:"operations,omitempty"`
+	Using      string   `json:"using"`
+	To         []string `json:"to,omitempty"`
+	Revision   int64    `json:"revision"`
+
+	using expression.Expression
+}
+
+/*
+Build a policy from a CREATE POLICY statement. The using clause
+has been formalized by the parser, and refers to the keyspace as SELF.
+*/
+func NewPolicy(stmt *algebra.CreatePolicy, namespace string) *Policy {
+	if stmt.Keyspace().Namespace() != "" {
+		namespace = stmt.Keyspace().Namespace()
+	}
+	operations := make([]string, len(stmt.Operations()))
+	for i, op := range stmt.Operations() {
+		operations[i] = strings.ToLower(op)
+	}
+	return &Policy{
+		Name:       stmt.Name(),
+		Namespace:  namespace,
+		Keyspace:   stmt.Keyspace().Keyspace(),
+		Operations: operations,
+		Using:      expression.NewStringer().Visit(stmt.Using()),
+		To:         stmt.To(),
+		using:      stmt.Using(),
+	}
+}
+
+func (this *Policy) appliesTo(namespace, keyspace, op string) bool {
+	if this.Namespace != namespace || this.Keyspace != keyspace {
+		return false
+	}
+	if len(this.Operations) == 0 {
+		return true
+	}
+	for _, o := range this.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Policy) grantedTo(users auth.AuthenticatedUsers, roles map[string][]datastore.Role) bool {
+	return granted(this.To, this.Keyspace, users, roles)
+}
+
+// grantees are user ids, domain:id user keys, or roles
+func granted(to []string, keyspace string, users auth.AuthenticatedUsers,
+	roles map[string][]datastore.Role) bool {
+	if len(to) == 0 {
+		return true
+	}
+	for _, grantee := range to {
+		for _, user := range users {
+			if grantee == user || (!strings.Contains(grantee, ":") && strings.HasSuffix(user, ":"+grantee)) {
+				return true
+			}
+			role := auth.NormalizeRoleNames([]string{grantee})[0]
+			for _, r := range roles[user] {
+				if r.Name == role && (r.Bucket == "" || r.Bucket == "*" || r.Bucket == keyspace) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+/*
+Policies may not depend on anything but the row and the request
+users, so that they can be evaluated as plain filters.
+*/
+func validate(name string, expr expression.Expression) errors.Error {
+	switch expr.(type) {
+	case expression.Subquery:
+		return errors.NewInvalidPolicyError(name, "subqueries are not allowed.")
+	case *algebra.NamedParameter, *algebra.PositionalParameter:
+		return errors.NewInvalidPolicyError(name, "parameters are not allowed.")
+	case algebra.Aggregate:
+		return errors.NewInvalidPolicyError(name, "aggregates are not allowed.")
+	}
+	for _, child := range expr.Children() {
+		err := validate(name, child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Registry struct {
+	sync.Mutex
+	store       Store
+	policies    []*Policy
+	masks       []*Mask
+	loaded      time.Time
+	users       func() ([]datastore.User, errors.Error)
+	roles       map[string][]datastore.Role
+	rolesLoaded time.Time
+}
+
+func newRegistry(store Store) *Registry {
+	return &Registry{
+		store: store,
+		users: func() ([]datastore.User, errors.Error) {
+			ds := datastore.GetDatastore()
+			if ds == nil {
+				return nil, nil
+			}
+			return ds.GetUserInfoAll()
+		},
+	}
+}
+
+var _REGISTRY = newRegistry(nil)
+
+/*
+Set the store policies are kept in.
+*/
+func SetStore(store Store) {
+	_REGISTRY.Lock()
+	defer _REGISTRY.Unlock()
+	_REGISTRY.store = store
+	_REGISTRY.loaded = time.Time{}
+}
+
+/*
+Whether any policy or mask is defined.
+*/
+func Exist() bool {
+	return _REGISTRY.exist()
+}
+
+func Create(policy *Policy) errors.Error {
+	return _REGISTRY.create(policy)
+}
+
+func Drop(namespace, keyspace, name string) errors.Error {
+	return _REGISTRY.drop(namespace, keyspace, name)
+}
+
+func (this *Registry) exist() bool {
+	this.Lock()
+	defer this.Unlock()
+	this.load(false)
+	return len(this.policies) > 0 || len(this.masks) > 0
+}
+
+func (this *Registry) create(policy *Policy) errors.Error {
+	err := validate(policy.Name, policy.using)
+	if err != nil {
+		return err
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	err = this.load(true)
+	if err != nil {
+		return err
+	}
+	for _, p := range this.policies {
+		if p.Name == policy.Name {
+			return errors.NewPolicyExistsError(policy.Name)
+		}
+	}
+	policy.Revision = time.Now().UnixNano()
+	return this.save(append(this.policies[:len(this.policies):len(this.policies)], policy))
+}
+
+func (this *Registry) drop(namespace, keyspace, name string) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	err := this.load(true)
+	if err != nil {
+		return err
+	}
+	for i, p := range this.policies {
+		if p.Name == name && p.Namespace == namespace && p.Keyspace == keyspace {
+			policies := make([]*Policy, 0, len(this.policies)-1)
+			policies = append(policies, this.policies[:i]...)
+			return this.save(append(policies, this.policies[i+1:]...))
+		}
+	}
+	return errors.NewPolicyNotFoundError(name, namespace+":"+keyspace)
+}
+
+// caller holds the lock
+func (this *Registry) load(force bool) errors.Error {
+	if this.store == nil || (!force && time.Since(this.loaded) < _REFRESH) {
+		return nil
+	}
+	data, err := this.store.GetMetadata(_METADATA_KEY)
+	if err != nil {
+		logging.Errorf("Unable to load row security policies: %v", err)
+		return errors.NewPolicyStoreError(err, "loading")
+	}
+
+	var policies []*Policy
+	if len(data) > 0 {
+		e := json.Unmarshal(data, &policies)
+		if e != nil {
+			logging.Errorf("Unable to decode row security policies: %v", e)
+			return errors.NewPolicyStoreError(e, "decoding")
+		}
+	}
+	for _, p := range policies {
+		p.using, err = parsePolicy(p)
+		if err != nil {
+			return err
+		}
+	}
+	masks, err := this.loadMasks()
+	if err != nil {
+		return err
+	}
+	this.policies = policies
+	this.masks = masks
+	this.loaded = time.Now()
+	return nil
+}
+
+func parsePolicy(p *Policy) (expression.Expression, errors.Error) {
+	expr, e := parser.Parse(p.Using)
+	if e != nil {
+		return nil, errors.NewPolicyStoreError(e, "decoding")
+	}
+	return expr, nil
+}
+
+// caller holds the lock
+func (this *Registry) save(policies []*Policy) errors.Error {
+	if this.store == nil {
+		return errors.NewPolicyStoreError(nil, "storing")
+	}
+	data, e := json.Marshal(policies)
+	if e != nil {
+		return errors.NewPolicyStoreError(e, "encoding")
+	}
+	err := this.store.SetMetadata(_METADATA_KEY, data)
+	if err != nil {
+		return errors.NewPolicyStoreError(err, "storing")
+	}
+	this.policies = policies
+	this.loaded = time.Now()
+	return nil
+}
+
+/*
+The policies applicable to an operation on a keyspace for a set of
+users. Caller holds the lock.
+*/
+func (this *Registry) applicable(namespace, keyspace, op string,
+	users auth.AuthenticatedUsers) ([]*Policy, errors.Error) {
+	var candidates []*Policy
+	for _, p := range this.policies {
+		if p.appliesTo(namespace, keyspace, op) {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	roles, err := this.userRoles()
+	if err != nil || isAdmin(users, roles) {
+		return nil, err
+	}
+
+	rv := candidates[:0]
+	for _, p := range candidates {
+		if p.grantedTo(users, roles) {
+			rv = append(rv, p)
+		}
+	}
+	return rv, nil
+}
+
+func isAdmin(users auth.AuthenticatedUsers, roles map[string][]datastore.Role) bool {
+	for _, user := range users {
+		for _, r := range roles[user] {
+			if r.Name == _ADMIN_ROLE {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// roles are only needed once policies exist, and are cached as policies are
+func (this *Registry) userRoles() (map[string][]datastore.Role, errors.Error) {
+	if this.roles != nil && time.Since(this.rolesLoaded) < _REFRESH {
+		return this.roles, nil
+	}
+	users, err := this.users()
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string][]datastore.Role, len(users))
+	for _, u := range users {
+		domain := u.Domain
+		if domain == "" {
+			domain = "local"
+		}
+		roles[domain+":"+u.Id] = u.Roles
+	}
+	this.roles = roles
+	this.rolesLoaded = time.Now()
+	return roles, nil
+}
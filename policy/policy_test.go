@@ -0,0 +1,309 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+type memStore map[string][]byte
+
+func (this memStore) GetMetadata(key string) ([]byte, errors.Error) {
+	return this[key], nil
+}
+
+func (this memStore) SetMetadata(key string, value []byte) errors.Error {
+	this[key] = value
+	return nil
+}
+
+func newTestRegistry() *Registry {
+	rv := newRegistry(memStore{})
+	rv.users = func() ([]datastore.User, errors.Error) {
+		return []datastore.User{
+			{Id: "joe", Domain: "local", Roles: []datastore.Role{{Name: "query_select", Bucket: "orders"}}},
+			{Id: "ann", Domain: "local", Roles: []datastore.Role{{Name: "data_reader", Bucket: "*"}}},
+			{Id: "bob", Domain: "local", Roles: []datastore.Role{{Name: "admin"}}},
+		}, nil
+	}
+	return rv
+}
+
+func newTestPolicy(t *testing.T, name string, operations []string, using string, to []string) *Policy {
+	p := &Policy{Name: name, Namespace: "default", Keyspace: "orders",
+		Operations: operations, Using: using, To: to}
+	expr, err := parsePolicy(p)
+	if err != nil {
+		t.Fatalf("unable to parse policy %v: %v", name, err)
+	}
+	p.using = expr
+	return p
+}
+
+func TestCreateDrop(t *testing.T) {
+	registry := newTestRegistry()
+
+	err := registry.create(newTestPolicy(t, "own", nil, "owner IN CURRENT_USERS()", nil))
+	if err != nil {
+		t.Fatalf("unable to create policy: %v", err)
+	}
+	err = registry.create(newTestPolicy(t, "own", nil, "true", nil))
+	if err == nil || err.Code() != 5290 {
+		t.Errorf("expected policy exists error, found %v", err)
+	}
+
+	for _, using := range []string{"owner IN (SELECT RAW u FROM users u)", "owner = $user", "COUNT(*) > 0"} {
+		err = registry.create(newTestPolicy(t, "invalid", nil, using, nil))
+		if err == nil || err.Code() != 5310 {
+			t.Errorf("expected %v to be rejected, found %v", using, err)
+		}
+	}
+
+	// policies survive a reload from the store
+	registry.loaded = registry.loaded.Add(-_REFRESH)
+	if !registry.exist() || registry.policies[0].Revision == 0 {
+		t.Errorf("expected policy to be reloaded, found %v", registry.policies)
+	}
+
+	err = registry.drop("default", "customers", "own")
+	if err == nil || err.Code() != 5300 {
+		t.Errorf("expected policy not found error, found %v", err)
+	}
+	err = registry.drop("default", "orders", "own")
+	if err != nil || registry.exist() {
+		t.Errorf("expected policy to be dropped, found %v, error %v", registry.policies, err)
+	}
+}
+
+func where(t *testing.T, stmt algebra.Statement) string {
+	var cond interface {
+		String() string
+	}
+	switch stmt := stmt.(type) {
+	case *algebra.Select:
+		cond = stmt.Subresult().(*algebra.Subselect).Where()
+	case *algebra.Update:
+		cond = stmt.Where()
+	case *algebra.Delete:
+		cond = stmt.Where()
+	}
+	if cond == nil {
+		return ""
+	}
+	return cond.String()
+}
+
+func TestApply(t *testing.T) {
+	registry := newTestRegistry()
+	for _, p := range []*Policy{
+		newTestPolicy(t, "own", []string{SELECT, UPDATE}, "owner IN CURRENT_USERS()", []string{"select"}),
+		newTestPolicy(t, "open", []string{DELETE}, "meta().id LIKE \"tmp%\"", []string{"ann"}),
+	} {
+		err := registry.create(p)
+		if err != nil {
+			t.Fatalf("unable to create policy: %v", err)
+		}
+	}
+
+	cases := []struct {
+		stmt    string
+		users   auth.AuthenticatedUsers
+		applied string
+		where   []string
+	}{
+		// joe holds the select role on orders
+		{"SELECT * FROM orders o WHERE o.total > 10", auth.AuthenticatedUsers{"local:joe"}, "own",
+			[]string{"(`o`.`total`)", "(`o`.`owner`) in current_users()"}},
+		{"UPDATE orders SET paid = true", auth.AuthenticatedUsers{"local:joe"}, "own",
+			[]string{"(`orders`.`owner`) in current_users()"}},
+		{"DELETE FROM orders", auth.AuthenticatedUsers{"local:ann"}, "open",
+			[]string{"meta(`orders`)"}},
+		{"SELECT * FROM customers c WHERE c.id IN (SELECT RAW o.customer FROM orders o)",
+			auth.AuthenticatedUsers{"local:joe"}, "own", nil},
+
+		// not granted, and bypassed by admins
+		{"SELECT * FROM orders o", auth.AuthenticatedUsers{"local:ann"}, "", nil},
+		{"SELECT * FROM orders o", auth.AuthenticatedUsers{"local:bob", "local:joe"}, "", nil},
+	}
+
+	for i, c := range cases {
+		stmt, e := n1ql.ParseStatement(c.stmt)
+		if e != nil {
+			t.Fatalf("case %d: unable to parse: %v", i, e)
+		}
+		err := registry.apply(stmt, "default", c.users)
+		if err != nil {
+			t.Errorf("case %d: unexpected error %v", i, err)
+			continue
+		}
+		rp := stmt.(rowPolicied).RowPolicies()
+		if strings.Join(rp.Names(), ",") != c.applied {
+			t.Errorf("case %d: expected %v applied, found %v", i, c.applied, rp.Names())
+		}
+		cond := where(t, stmt)
+		for _, w := range c.where {
+			if !strings.Contains(cond, w) {
+				t.Errorf("case %d: expected %v in %v", i, w, cond)
+			}
+		}
+		if c.applied == "" && strings.Contains(cond, "current_users") {
+			t.Errorf("case %d: unexpected policy in %v", i, cond)
+		}
+
+		// the plan is only good for users the same policies apply to
+		err = registry.verify(rp, nil, c.users)
+		if err != nil {
+			t.Errorf("case %d: unexpected verify error %v", i, err)
+		}
+	}
+
+	stmt, _ := n1ql.ParseStatement("SELECT * FROM orders o")
+	registry.apply(stmt, "default", auth.AuthenticatedUsers{"local:joe"})
+	rp := stmt.(rowPolicied).RowPolicies()
+	for _, users := range []auth.AuthenticatedUsers{{"local:ann"}, {"local:bob"}} {
+		err := registry.verify(rp, nil, users)
+		if err == nil || err.Code() != 5340 {
+			t.Errorf("expected policy mismatch for %v, found %v", users, err)
+		}
+	}
+
+	// plans built without policies are checked against their privileges
+	privs := auth.NewPrivileges()
+	privs.Add("default:orders", auth.PRIV_QUERY_SELECT)
+	if err := registry.verify(nil, privs, auth.AuthenticatedUsers{"local:joe"}); err == nil {
+		t.Errorf("expected policy mismatch for a plan without policies")
+	}
+
+	for _, s := range []string{
+		"SELECT * FROM customers c LEFT JOIN orders o ON KEYS c.orders",
+		"MERGE INTO orders o USING customers c ON KEY c.id WHEN MATCHED THEN UPDATE SET o.x = 1",
+		"UPSERT INTO orders (KEY, VALUE) VALUES (\"o1\", {\"owner\": \"ann\"})",
+	} {
+		stmt, _ := n1ql.ParseStatement(s)
+		err := registry.apply(stmt, "default", auth.AuthenticatedUsers{"local:joe"})
+		if err == nil || err.Code() != 5330 {
+			t.Errorf("expected %v to be rejected, found %v", s, err)
+		}
+	}
+}
+
+func newTestMask(name, path, method string, to []string) *Mask {
+	return &Mask{Name: name, Namespace: "default", Keyspace: "customers",
+		Path: path, Method: method, To: to, names: strings.Split(path, ".")}
+}
+
+func TestMasks(t *testing.T) {
+	registry := newTestRegistry()
+	for _, m := range []*Mask{
+		newTestMask("email", "email", "hash", nil),
+		newTestMask("card", "payment.card", "partial", []string{"local:joe"}),
+	} {
+		err := registry.createMask(m)
+		if err != nil {
+			t.Fatalf("unable to create mask: %v", err)
+		}
+	}
+	if err := registry.createMask(newTestMask("email", "phone", "null", nil)); err == nil || err.Code() != 5350 {
+		t.Errorf("expected mask exists error, found %v", err)
+	}
+	if err := registry.createMask(newTestMask("phone", "phone", "scramble", nil)); err == nil || err.Code() != 5370 {
+		t.Errorf("expected invalid mask error, found %v", err)
+	}
+
+	cases := []struct {
+		stmt     string
+		users    auth.AuthenticatedUsers
+		masked   []string
+		unmasked []string
+	}{
+		// only joe sees the card masked
+		{"SELECT c.email, c.name, c.payment.card FROM customers c WHERE c.email = \"ann@example.com\"",
+			auth.AuthenticatedUsers{"local:ann"},
+			[]string{"redact((`c`.`email`), {\"\":\"hash\"})"},
+			[]string{"(`c`.`name`)", "((`c`.`payment`).`card`)"}},
+		{"SELECT c.payment.card.digits FROM customers c ORDER BY c.payment",
+			auth.AuthenticatedUsers{"local:joe"},
+			[]string{"(redact(((`c`.`payment`).`card`), {\"\":\"partial\"}).`digits`)",
+				"redact((`c`.`payment`), {\"card\":\"partial\"})"}, nil},
+		{"SELECT META(c).id, c FROM customers c", auth.AuthenticatedUsers{"local:ann"},
+			[]string{"redact(`c`, {\"email\":\"hash\"})"}, []string{"(meta(`c`).`id`)"}},
+		{"SELECT RAW o.total FROM orders o WHERE o.customer IN (SELECT RAW c.email FROM customers c)",
+			auth.AuthenticatedUsers{"local:ann"}, []string{"redact((`c`.`email`)"}, nil},
+
+		// admins bypass masks
+		{"SELECT c.email FROM customers c", auth.AuthenticatedUsers{"local:bob"},
+			nil, []string{"(`c`.`email`)"}},
+	}
+
+	for i, c := range cases {
+		stmt, e := n1ql.ParseStatement(c.stmt)
+		if e != nil {
+			t.Fatalf("case %d: unable to parse: %v", i, e)
+		}
+		err := registry.apply(stmt, "default", c.users)
+		if err != nil {
+			t.Errorf("case %d: unexpected error %v", i, err)
+			continue
+		}
+		text := stmt.(*algebra.Select).String()
+		for _, m := range c.masked {
+			if !strings.Contains(text, m) {
+				t.Errorf("case %d: expected %v in %v", i, m, text)
+			}
+		}
+		for _, u := range c.unmasked {
+			if !strings.Contains(text, u) || strings.Contains(text, "redact("+u) {
+				t.Errorf("case %d: expected %v to be unmasked in %v", i, u, text)
+			}
+		}
+		if len(c.masked) == 0 && strings.Contains(text, "redact") {
+			t.Errorf("case %d: unexpected mask in %v", i, text)
+		}
+	}
+
+	// unprefixed stars are masked as they are projected
+	stmt, _ := n1ql.ParseStatement("SELECT * FROM customers c")
+	registry.apply(stmt, "default", auth.AuthenticatedUsers{"local:joe"})
+	mask := stmt.(*algebra.Select).Subresult().(*algebra.Subselect).Projection().Mask()
+	if mask == nil || !strings.Contains(mask.String(), "\"c.payment.card\":\"partial\"") {
+		t.Errorf("expected star projection to be masked, found %v", mask)
+	}
+
+	rp := stmt.(rowPolicied).RowPolicies()
+	if strings.Join(rp.MaskNames(), ",") != "card,email" {
+		t.Errorf("expected masks card,email applied, found %v", rp.MaskNames())
+	}
+	if err := registry.verify(rp, nil, auth.AuthenticatedUsers{"local:ann"}); err == nil || err.Code() != 5340 {
+		t.Errorf("expected mask mismatch, found %v", err)
+	}
+
+	// updated paths are written, not masked
+	stmt, _ = n1ql.ParseStatement("UPDATE customers c SET c.email = LOWER(c.email) RETURNING c.email")
+	if err := registry.apply(stmt, "default", auth.AuthenticatedUsers{"local:ann"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	set := stmt.(*algebra.Update).Set().Terms()[0]
+	if set.Path().String() != "(`c`.`email`)" || !strings.Contains(set.Value().String(), "redact(") {
+		t.Errorf("expected only the value of %v to be masked", set)
+	}
+
+	stmt, _ = n1ql.ParseStatement("MERGE INTO customers c USING orders o ON KEY o.customer " +
+		"WHEN MATCHED THEN UPDATE SET c.last = o.total")
+	if err := registry.apply(stmt, "default", auth.AuthenticatedUsers{"local:ann"}); err == nil || err.Code() != 5390 {
+		t.Errorf("expected MERGE to be rejected, found %v", err)
+	}
+}
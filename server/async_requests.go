@@ -0,0 +1,206 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+ Async_requests tracks requests submitted in asynchronous mode.
+ The request id doubles as the handle by which the client retrieves the outcome.
+ The response of each request is spooled to the temp space directory, so that
+ memory stays bounded, and is retained until deleted or until its TTL expires
+ after the request completes. While the request runs, it is also tracked as an
+ active request, and on completion it is logged like any other completed request.
+*/
+package server
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/util"
+)
+
+const (
+	ASYNC_DEFAULT_TTL   = time.Hour
+	ASYNC_DEFAULT_LIMIT = 1024
+)
+
+const _ASYNC_SWEEP_INTERVAL = 30 * time.Second
+
+// The spooled response of an async request
+type AsyncSpool interface {
+	Remove()
+}
+
+type AsyncEntry struct {
+	Handle    string
+	ClientId  string
+	Statement string
+	Users     string
+	State     string
+	Submitted time.Time
+	Completed time.Time
+	TTL       time.Duration
+	Spool     AsyncSpool
+}
+
+func (this *AsyncEntry) IsCompleted() bool {
+	return !this.Completed.IsZero()
+}
+
+func (this *AsyncEntry) Expired() bool {
+	return this.IsCompleted() && time.Since(this.Completed) > this.TTL
+}
+
+type AsyncLog struct {
+	sync.RWMutex
+	dir   string
+	ttl   time.Duration
+	limit int
+
+	cache *util.GenCache
+}
+
+var asyncLog = &AsyncLog{
+	dir:   os.TempDir(),
+	ttl:   ASYNC_DEFAULT_TTL,
+	limit: ASYNC_DEFAULT_LIMIT,
+	cache: util.NewGenCache(-1),
+}
+
+// init async requests
+
+func AsyncInit(dir string, ttl time.Duration, limit int) {
+	asyncLog.Lock()
+	if dir != "" {
+		asyncLog.dir = dir
+	}
+	asyncLog.ttl = ttl
+	asyncLog.limit = limit
+	asyncLog.Unlock()
+
+	go asyncLog.sweep()
+}
+
+// configure async requests
+
+func AsyncDir() string {
+	asyncLog.RLock()
+	defer asyncLog.RUnlock()
+	return asyncLog.dir
+}
+
+func AsyncSetDir(dir string) {
+	asyncLog.Lock()
+	defer asyncLog.Unlock()
+	asyncLog.dir = dir
+}
+
+func AsyncTTL() time.Duration {
+	asyncLog.RLock()
+	defer asyncLog.RUnlock()
+	return asyncLog.ttl
+}
+
+func AsyncSetTTL(ttl time.Duration) {
+	asyncLog.Lock()
+	defer asyncLog.Unlock()
+	asyncLog.ttl = ttl
+}
+
+func AsyncLimit() int {
+	asyncLog.RLock()
+	defer asyncLog.RUnlock()
+	return asyncLog.limit
+}
+
+func AsyncSetLimit(limit int) {
+	asyncLog.Lock()
+	defer asyncLog.Unlock()
+	asyncLog.limit = limit
+}
+
+// async requests operations
+
+// negative limit means no upper bound
+func AsyncAdd(entry *AsyncEntry) errors.Error {
+	limit := AsyncLimit()
+	if limit >= 0 && asyncLog.cache.Size() >= limit {
+		return errors.NewServiceErrorAsyncLimit(limit)
+	}
+	asyncLog.cache.Add(entry, entry.Handle, nil)
+	return nil
+}
+
+// returns false if the handle is unknown or has expired
+func AsyncDo(handle string, f func(*AsyncEntry)) bool {
+	found := false
+	_ = asyncLog.cache.Get(handle, func(e interface{}) {
+		entry := e.(*AsyncEntry)
+		if !entry.Expired() {
+			found = true
+			f(entry)
+		}
+	})
+	return found
+}
+
+// marks the request as completed and starts its TTL
+// returns false if the handle has been deleted in the interim
+func AsyncComplete(handle string, state string) bool {
+	return asyncLog.cache.Use(handle, func(e interface{}) {
+		entry := e.(*AsyncEntry)
+		entry.State = state
+		entry.Completed = time.Now()
+	}) != nil
+}
+
+func AsyncDelete(handle string) bool {
+	return asyncLog.cache.Delete(handle, func(e interface{}) {
+		entry := e.(*AsyncEntry)
+		if entry.Spool != nil {
+			entry.Spool.Remove()
+		}
+	})
+}
+
+func AsyncHandles() []string {
+	return asyncLog.cache.Names()
+}
+
+func AsyncCount() int {
+	return asyncLog.cache.Size()
+}
+
+func AsyncForeach(nonBlocking func(string, *AsyncEntry) bool, blocking func() bool) {
+	dummyF := func(handle string, e interface{}) bool {
+		return nonBlocking(handle, e.(*AsyncEntry))
+	}
+	asyncLog.cache.ForEach(dummyF, blocking)
+}
+
+// periodically get rid of expired entries and their spools
+func (this *AsyncLog) sweep() {
+	ticker := time.NewTicker(_ASYNC_SWEEP_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired := []string{}
+		AsyncForeach(func(handle string, entry *AsyncEntry) bool {
+			if entry.Expired() {
+				expired = append(expired, handle)
+			}
+			return true
+		}, nil)
+
+		for _, handle := range expired {
+			AsyncDelete(handle)
+		}
+	}
+}
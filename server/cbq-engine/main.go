@@ -12,11 +12,13 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/debug"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 	"time"
 
@@ -27,19 +29,25 @@ import (
 	acct_resolver "github.com/couchbase/query/accounting/resolver"
 	"github.com/couchbase/query/audit"
 	config_resolver "github.com/couchbase/query/clustering/resolver"
+	"github.com/couchbase/query/clustering/static"
 	datastore_package "github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/datastore/resolver"
 	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/logging"
 	log_resolver "github.com/couchbase/query/logging/resolver"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/server"
 	"github.com/couchbase/query/server/http"
+	"github.com/couchbase/query/server/pgwire"
+	tracing_resolver "github.com/couchbase/query/tracing/resolver"
 	"github.com/couchbase/query/util"
 )
 
 var DATASTORE = flag.String("datastore", "", "Datastore address (http://URL or dir:PATH or mock:)")
-var CONFIGSTORE = flag.String("configstore", "stub:", "Configuration store address (http://URL or stub:)")
+var CONFIGSTORE = flag.String("configstore", "stub:", "Configuration store address (http://URL, file:path or stub:)")
+var PEERS = flag.String("peers", "", "file listing the query nodes of a standalone cluster")
 var ACCTSTORE = flag.String("acctstore", "gometrics:", "Accounting store address (http://URL or stub:)")
 var NAMESPACE = flag.String("namespace", "default", "Default namespace")
 var TIMEOUT = flag.Duration("timeout", 0*time.Second, "Server execution timeout, e.g. 500ms or 2s; use zero or negative value to disable")
@@ -60,6 +68,8 @@ var HTTPS_ADDR = flag.String("https", ":18093", "HTTPS service address")
 var CERT_FILE = flag.String("certfile", "", "HTTPS certificate file")
 var KEY_FILE = flag.String("keyfile", "", "HTTPS private key file")
 var IPv6 = flag.Bool("ipv6", false, "Query is IPv6 compliant")
+var PGWIRE_ADDR = flag.String("pgwire", "", "PostgreSQL wire protocol service address; empty to disable")
+var WEBSOCKET_ORIGINS = flag.String("websocket-origins", "", "comma separated origins, besides the server's own, allowed to open websockets")
 
 // The ssl_minimum_protocol flag is currently provided but is unused.
 // It is included here because if a flag is provided and is not picked up,
@@ -82,10 +92,48 @@ var CPU_PROFILE = flag.String("cpuprofile", "", "write cpu profile to file")
 var MEM_PROFILE = flag.String("memprofile", "", "write memory profile to this file")
 
 // Monitoring API
+var PROFILE = flag.String("profile", "off", "Profiling state: off, phases, timings")
+var CONTROLS = flag.Bool("controls", false, "Response to include controls section")
 var COMPLETED_THRESHOLD = flag.Int("completed-threshold", 1000, "cache completed query lasting longer than this many milliseconds")
 var COMPLETED_LIMIT = flag.Int("completed-limit", 4000, "maximum number of completed requests")
+var COMPLETED_HISTORY_DIR = flag.String("completed-history-dir", "", "directory to persist completed requests to; empty to disable")
+var COMPLETED_HISTORY_SIZE = flag.Int64("completed-history-size", server.HISTORY_DEFAULT_SIZE, "maximum size in bytes of each completed requests history file")
+var COMPLETED_HISTORY_FILES = flag.Int("completed-history-files", server.HISTORY_DEFAULT_FILES, "maximum number of completed requests history files")
 
 var PREPARED_LIMIT = flag.Int("prepared-limit", 16384, "maximum number of prepared statements")
+var PREPARED_DIR = flag.String("prepared-dir", "", "directory to persist prepared statements to; empty to disable")
+
+// local audit log
+var AUDIT_DIR = flag.String("audit-dir", "", "directory to write audit records to; empty to disable")
+var AUDIT_CHAIN = flag.Bool("audit-chain", false, "chain each audit record to the hash of the previous one")
+var AUDIT_CHAIN_KEY_FILE = flag.String("audit-chain-key-file", "", "file holding the key the audit chain hashes are HMACs with; kept away from the log")
+
+// masking
+var REDACT_KEY_FILE = flag.String("redact-key-file", "", "file holding the key of the hashes masks replace values with; random if empty")
+
+// bearer token and client certificate authentication
+var JWT_SECRET_FILE = flag.String("jwt-secret-file", "", "file holding the shared secret for HS256 bearer tokens")
+var JWT_PUBLIC_KEY_FILE = flag.String("jwt-public-key-file", "", "PEM file holding the public key for RS256 bearer tokens")
+var JWT_JWKS_FILE = flag.String("jwt-jwks-file", "", "JWKS file holding bearer token keys by key id")
+var JWT_ISSUER = flag.String("jwt-issuer", "", "issuer bearer tokens must carry; empty to accept any")
+var JWT_AUDIENCE = flag.String("jwt-audience", "", "audience bearer tokens must carry; empty to accept any")
+var JWT_USER_CLAIM = flag.String("jwt-user-claim", "sub", "bearer token claim naming the user")
+var JWT_ROLES_CLAIM = flag.String("jwt-roles-claim", "roles", "bearer token claim listing the user's roles")
+var JWT_DOMAIN = flag.String("jwt-domain", "external", "domain of users authenticated by bearer token")
+var CLIENT_CERT_MODE = flag.String("client-cert-mode", "none", "client certificate authentication: none, enable or mandatory")
+var CLIENT_CA_FILE = flag.String("client-ca-file", "", "PEM file of the CAs client certificates must chain to")
+var CLIENT_CERT_USER = flag.String("client-cert-user", "subject.cn", "certificate field naming the user: subject.cn, san.email, san.dns or san.uri")
+var CLIENT_CERT_DOMAIN = flag.String("client-cert-domain", "local", "domain of users authenticated by client certificate")
+
+// async requests
+var TMP_SPACE_DIR = flag.String("tmp-space-dir", os.TempDir(), "directory to spool async request results to")
+var ASYNC_TTL = flag.Duration("async-ttl", server.ASYNC_DEFAULT_TTL, "how long async request results are retained after completion")
+var ASYNC_LIMIT = flag.Int("async-limit", server.ASYNC_DEFAULT_LIMIT, "maximum number of retained async requests")
+
+// Tracing
+var TRACE_EXPORTER = flag.String("trace-exporter", "", "Where to export request trace spans: stdout, stderr or file:<path>")
+
+var CURSOR_IDLE_TIMEOUT = flag.Duration("cursor-idle-timeout", server.CURSOR_IDLE_DEFAULT, "how long an open cursor can go without fetches")
 
 // GOGC
 var _GOGC_PERCENT = 200
@@ -158,6 +206,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// standalone nodes can still gather system keyspaces from their peers
+	if *PEERS != "" {
+		remoteAccess, err := clustering_static.NewSystemRemoteAccess(*PEERS, *HTTP_ADDR)
+		if err != nil {
+			logging.Errorp(err.Error())
+			os.Exit(1)
+		}
+		distributed.SetRemoteAccess(remoteAccess)
+	}
+
 	acctstore, err := acct_resolver.NewAcctstore(*ACCTSTORE)
 	if err != nil {
 		logging.Errorp("Could not connect to acctstore",
@@ -188,6 +246,15 @@ func main() {
 
 	// Start the completed requests log
 	server.RequestsInit(*COMPLETED_THRESHOLD, *COMPLETED_LIMIT)
+	err = server.RequestsHistoryInit(*COMPLETED_HISTORY_DIR, *COMPLETED_HISTORY_SIZE, *COMPLETED_HISTORY_FILES)
+	if err != nil {
+		logging.Errorp(err.Error())
+		logging.Errorf("Shutting down.")
+		os.Exit(1)
+	}
+
+	// Start tracking async requests
+	server.AsyncInit(*TMP_SPACE_DIR, *ASYNC_TTL, *ASYNC_LIMIT)
 
 	// Initialized the prepared statement cache
 	if *PREPARED_LIMIT <= 0 {
@@ -196,6 +263,11 @@ func main() {
 		*PREPARED_LIMIT = 16384
 	}
 	plan.PreparedsInit(*PREPARED_LIMIT)
+	err = plan.PreparedsPersistInit(*PREPARED_DIR)
+	if err != nil {
+		logging.Errorp(err.Error())
+		os.Exit(1)
+	}
 
 	numProcs := runtime.GOMAXPROCS(0)
 	channel := make(server.RequestChannel, *REQUEST_CAP*numProcs)
@@ -227,9 +299,40 @@ func main() {
 	server.SetRequestSizeCap(*REQUEST_SIZE_CAP)
 	server.SetScanCap(*SCAN_CAP)
 	server.SetMaxIndexAPI(*MAX_INDEX_API)
+	server.SetCursorIdleTimeout(*CURSOR_IDLE_TIMEOUT)
 	util.SetN1qlFeatureControl(*N1QL_FEAT_CTRL)
 
 	audit.StartAuditService(*DATASTORE)
+	if *AUDIT_CHAIN_KEY_FILE != "" {
+		key, er := ioutil.ReadFile(*AUDIT_CHAIN_KEY_FILE)
+		if er != nil || len(key) == 0 {
+			logging.Errorp("Unable to read audit chain key", logging.Pair{"file", *AUDIT_CHAIN_KEY_FILE},
+				logging.Pair{"error", er})
+			os.Exit(1)
+		}
+		audit.SetFileAuditChainKey(key)
+	}
+	err = audit.SetFileAuditSettings(&audit.FileSettings{Dir: *AUDIT_DIR, Chain: *AUDIT_CHAIN})
+	if err != nil {
+		logging.Errorp(err.Error())
+		os.Exit(1)
+	}
+
+	if *REDACT_KEY_FILE != "" {
+		key, er := ioutil.ReadFile(*REDACT_KEY_FILE)
+		if er != nil || len(key) == 0 {
+			logging.Errorp("Unable to read redaction key", logging.Pair{"file", *REDACT_KEY_FILE},
+				logging.Pair{"error", er})
+			os.Exit(1)
+		}
+		expression.SetRedactKey(key)
+	}
+
+	_, err = tracing_resolver.NewExporter(*TRACE_EXPORTER)
+	if err != nil {
+		logging.Errorp(err.Error())
+		os.Exit(1)
+	}
 
 	go server.Serve()
 	go server.PlusServe()
@@ -251,10 +354,46 @@ func main() {
 		logging.Pair{"timeout", server.Timeout()},
 	)
 
+	er := http.SetAuthentication(&http.AuthConfig{
+		JWTSecretFile:    *JWT_SECRET_FILE,
+		JWTPublicKeyFile: *JWT_PUBLIC_KEY_FILE,
+		JWKSFile:         *JWT_JWKS_FILE,
+		JWTIssuer:        *JWT_ISSUER,
+		JWTAudience:      *JWT_AUDIENCE,
+		JWTUserClaim:     *JWT_USER_CLAIM,
+		JWTRolesClaim:    *JWT_ROLES_CLAIM,
+		JWTDomain:        *JWT_DOMAIN,
+		ClientCertMode:   *CLIENT_CERT_MODE,
+		ClientCAFile:     *CLIENT_CA_FILE,
+		ClientCertUser:   *CLIENT_CERT_USER,
+		ClientCertDomain: *CLIENT_CERT_DOMAIN,
+	})
+	if er != nil {
+		logging.Errorp("cbq-engine exiting with error",
+			logging.Pair{"error", er},
+		)
+		os.Exit(1)
+	}
+
+	if *WEBSOCKET_ORIGINS != "" {
+		http.SetWebSocketOrigins(strings.Split(*WEBSOCKET_ORIGINS, ","))
+	}
+
 	// Create http endpoint
 	endpoint := http.NewServiceEndpoint(server, *STATIC_PATH, *METRICS,
 		*HTTP_ADDR, *HTTPS_ADDR, *CERT_FILE, *KEY_FILE)
-	er := endpoint.Listen()
+
+	// settings kept by the configuration store override the command line
+	err = server.WatchSettings()
+	if err != nil {
+		logging.Errorp(err.Error())
+		os.Exit(1)
+	}
+
+	// indexes and policies are in place, bring back the prepared statements
+	server.ReloadPrepareds()
+
+	er = endpoint.Listen()
 	if er != nil {
 		logging.Errorp("cbq-engine exiting with error",
 			logging.Pair{"error", er},
@@ -272,6 +411,19 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	if *PGWIRE_ADDR != "" {
+		pgEndpoint, er := pgwire.NewPgEndpoint(server, *PGWIRE_ADDR, *CERT_FILE, *KEY_FILE)
+		if er == nil {
+			er = pgEndpoint.Listen()
+		}
+		if er != nil {
+			logging.Errorp("cbq-engine exiting with error",
+				logging.Pair{"error", er},
+				logging.Pair{"PGWIRE_ADDR", *PGWIRE_ADDR},
+			)
+			os.Exit(1)
+		}
+	}
 	signalCatcher(server, endpoint)
 }
 
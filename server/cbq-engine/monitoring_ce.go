@@ -12,25 +12,14 @@
 package main
 
 import (
-	"flag"
-
 	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/server"
 )
 
-var PROFILE = flag.String("profile", "off", "EE only - Profiling state: off, phases, timings")
-var CONTROLS = flag.Bool("controls", false, "EE only - Response to include controls section")
-
+// profiling and controls only rely on the local execution statistics,
+// remote access to other nodes' monitoring data is EE only
 func monitoringInit(configstore clustering.ConfigurationStore) (server.Profile, bool, errors.Error) {
-	var err errors.Error
-
 	prof, _ := server.ParseProfile(*PROFILE)
-	if prof != server.ProfOff {
-		err = errors.NewNotImplemented("Profiling is an EE only feature")
-	}
-	if *CONTROLS {
-		err = errors.NewNotImplemented("Controls is an EE only feature")
-	}
-	return server.ProfOff, false, err
+	return prof, *CONTROLS, nil
 }
@@ -12,8 +12,6 @@
 package main
 
 import (
-	"flag"
-
 	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/distributed"
 	"github.com/couchbase/query/errors"
@@ -21,10 +19,6 @@ import (
 	"github.com/couchbase/query/server/http"
 )
 
-// Profiling
-var PROFILE = flag.String("profile", "off", "Profiling state: off, phases, timings")
-var CONTROLS = flag.Bool("controls", false, "Response to include controls section")
-
 func monitoringInit(configstore clustering.ConfigurationStore) (server.Profile, bool, errors.Error) {
 	distributed.SetRemoteAccess(http.NewSystemRemoteAccess(configstore))
 	prof, _ := server.ParseProfile(*PROFILE)
@@ -0,0 +1,472 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+ The completed requests history persists the requests that satisfy the completed
+ requests qualifiers to a rotating set of files, as JSON lines, so that they
+ survive restarts and can be analysed after the fact.
+ Each file is capped in size, and once the cap is reached, a new file is started
+ and the oldest are deleted, so that no more than the configured number of files
+ is kept.
+ Entries are identified by the sequence number of their file and their offset in it.
+ Completed requests hand their entries to a single writer through a bounded queue,
+ and entries that find the queue full are dropped and counted.
+*/
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/couchbase/query/distributed"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/logging"
+)
+
+const (
+	HISTORY_DEFAULT_SIZE  = 64 * 1024 * 1024
+	HISTORY_DEFAULT_FILES = 10
+
+	_HISTORY_PREFIX = "completed_requests-"
+	_HISTORY_SUFFIX = ".jsonl"
+	_HISTORY_QUEUE  = 1024
+)
+
+type requestHistory struct {
+	sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	seq      int64
+	file     *os.File
+	size     int64
+	queue    chan *historyEntry
+	dropped  int64
+	once     sync.Once
+}
+
+// an entry waiting to be written, or a marker for flushes
+type historyEntry struct {
+	re      *RequestLogEntry
+	timings execution.Operator
+	done    chan bool
+}
+
+var history = &requestHistory{
+	maxSize:  HISTORY_DEFAULT_SIZE,
+	maxFiles: HISTORY_DEFAULT_FILES,
+	queue:    make(chan *historyEntry, _HISTORY_QUEUE),
+}
+
+// init and configure the history
+// an empty directory disables it
+
+func RequestsHistoryInit(dir string, size int64, files int) errors.Error {
+	history.Lock()
+	defer history.Unlock()
+	if size > 0 {
+		history.maxSize = size
+	}
+	if files > 0 {
+		history.maxFiles = files
+	}
+	history.once.Do(func() {
+		go history.writer()
+	})
+	return history.setDir(dir)
+}
+
+func RequestsHistoryDir() string {
+	history.Lock()
+	defer history.Unlock()
+	return history.dir
+}
+
+func RequestsHistorySetDir(dir string) errors.Error {
+	history.Lock()
+	defer history.Unlock()
+	return history.setDir(dir)
+}
+
+func RequestsHistorySize() int64 {
+	history.Lock()
+	defer history.Unlock()
+	return history.maxSize
+}
+
+func RequestsHistorySetSize(size int64) {
+	if size <= 0 {
+		size = HISTORY_DEFAULT_SIZE
+	}
+	history.Lock()
+	history.maxSize = size
+	history.Unlock()
+}
+
+func RequestsHistoryFiles() int {
+	history.Lock()
+	defer history.Unlock()
+	return history.maxFiles
+}
+
+func RequestsHistorySetFiles(files int) {
+	if files <= 0 {
+		files = HISTORY_DEFAULT_FILES
+	}
+	history.Lock()
+	history.maxFiles = files
+	history.prune()
+	history.Unlock()
+}
+
+func requestsHistoryEnabled() bool {
+	history.Lock()
+	defer history.Unlock()
+	return history.dir != ""
+}
+
+func (this *requestHistory) setDir(dir string) errors.Error {
+	if this.file != nil {
+		this.file.Close()
+		this.file = nil
+	}
+	this.dir = ""
+	if dir == "" {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.NewCompletedHistoryError(err, "cannot create "+dir)
+	}
+	this.dir = dir
+
+	// carry on from the latest file
+	seqs := this.files()
+	this.seq = 0
+	if len(seqs) > 0 {
+		this.seq = seqs[len(seqs)-1]
+	}
+	return this.open()
+}
+
+func (this *requestHistory) fileName(seq int64) string {
+	return historyFileName(this.dir, seq)
+}
+
+func historyFileName(dir string, seq int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%08d%s", _HISTORY_PREFIX, seq, _HISTORY_SUFFIX))
+}
+
+// the sequence numbers of the existing files, oldest first
+func (this *requestHistory) files() []int64 {
+	names, _ := filepath.Glob(filepath.Join(this.dir, _HISTORY_PREFIX+"*"+_HISTORY_SUFFIX))
+	seqs := make([]int64, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), _HISTORY_PREFIX), _HISTORY_SUFFIX)
+		seq, err := strconv.ParseInt(name, 10, 64)
+		if err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+func (this *requestHistory) open() errors.Error {
+	f, err := os.OpenFile(this.fileName(this.seq), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.NewCompletedHistoryError(err, "cannot open "+this.fileName(this.seq))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.NewCompletedHistoryError(err, "cannot open "+this.fileName(this.seq))
+	}
+	this.file = f
+	this.size = info.Size()
+	return nil
+}
+
+// start a new file and get rid of the oldest
+func (this *requestHistory) rotate() errors.Error {
+	this.file.Close()
+	this.file = nil
+	this.seq++
+	err := this.open()
+	this.prune()
+	return err
+}
+
+func (this *requestHistory) prune() {
+	if this.dir == "" {
+		return
+	}
+	for _, seq := range this.files() {
+		if seq > this.seq-int64(this.maxFiles) {
+			break
+		}
+		os.Remove(this.fileName(seq))
+	}
+}
+
+// queue an entry for the writer, never blocking the completed request
+func requestsHistoryWrite(re *RequestLogEntry, timings execution.Operator) {
+	select {
+	case history.queue <- &historyEntry{re: re, timings: timings}:
+	default:
+		dropped := atomic.AddInt64(&history.dropped, 1)
+		if dropped%_HISTORY_QUEUE == 1 {
+			logging.Warnf("Completed requests history: %v entries dropped so far, as the queue is full", dropped)
+		}
+	}
+}
+
+// the number of entries dropped because the queue was full
+func RequestsHistoryDropped() int64 {
+	return atomic.LoadInt64(&history.dropped)
+}
+
+// wait for the queued entries to be written
+func requestsHistoryFlush() {
+	done := make(chan bool)
+	history.queue <- &historyEntry{done: done}
+	<-done
+}
+
+func (this *requestHistory) writer() {
+	for entry := range this.queue {
+		if entry.done != nil {
+			close(entry.done)
+			continue
+		}
+		this.write(entry.re, entry.timings)
+	}
+}
+
+func (this *requestHistory) write(re *RequestLogEntry, timings execution.Operator) {
+	bytes, err := json.Marshal(historyRecord(re, timings))
+	if err != nil {
+		logging.Errorf("Completed requests history: cannot marshal request %v: %v", re.RequestId, err)
+		return
+	}
+	bytes = append(bytes, '\n')
+
+	this.Lock()
+	defer this.Unlock()
+	if this.file == nil {
+		return
+	}
+	if this.size > 0 && this.size+int64(len(bytes)) > this.maxSize {
+		if err := this.rotate(); err != nil {
+			logging.Errorf("%v", err)
+			return
+		}
+	}
+
+	// a single write, so that readers never see partial entries
+	// other than at the end of the file
+	n, err := this.file.Write(bytes)
+	this.size += int64(n)
+	if err != nil {
+		logging.Errorf("Completed requests history: cannot write request %v: %v", re.RequestId, err)
+	}
+}
+
+func historyRecord(re *RequestLogEntry, timings execution.Operator) map[string]interface{} {
+	record := map[string]interface{}{
+		"requestId":       re.RequestId,
+		"state":           re.State,
+		"scanConsistency": re.ScanConsistency,
+		"requestTime":     re.Time.String(),
+		"elapsedTime":     re.ElapsedTime.String(),
+		"serviceTime":     re.ServiceTime.String(),
+		"resultCount":     re.ResultCount,
+		"resultSize":      re.ResultSize,
+		"errorCount":      re.ErrorCount,
+	}
+	node := distributed.RemoteAccess().WhoAmI()
+	if node != "" {
+		record["node"] = node
+	}
+	if re.ClientId != "" {
+		record["clientContextID"] = re.ClientId
+	}
+	if re.Statement != "" {
+		record["statement"] = re.Statement
+	}
+	if re.PreparedName != "" {
+		record["preparedName"] = re.PreparedName
+		record["preparedText"] = re.PreparedText
+	}
+	if len(re.Errors) > 0 {
+		errs := make([]interface{}, len(re.Errors))
+		for i, err := range re.Errors {
+			errs[i] = map[string]interface{}{"code": err.Code(), "msg": err.Error()}
+		}
+		record["errors"] = errs
+	}
+	if re.PhaseTimes != nil {
+		record["phaseTimes"] = re.PhaseTimes
+	}
+	if re.PhaseCounts != nil {
+		record["phaseCounts"] = re.PhaseCounts
+	}
+	if re.PhaseOperators != nil {
+		record["phaseOperators"] = re.PhaseOperators
+	}
+	if re.NamedArgs != nil {
+		record["namedArgs"] = re.NamedArgs
+	}
+	if re.PositionalArgs != nil {
+		record["positionalArgs"] = re.PositionalArgs
+	}
+	if re.Users != "" {
+		record["users"] = re.Users
+	}
+	if re.RemoteAddr != "" {
+		record["remoteAddr"] = re.RemoteAddr
+	}
+	if re.UserAgent != "" {
+		record["userAgent"] = re.UserAgent
+	}
+
+	// the plan, as executed, with its operators' statistics
+	if timings != nil {
+		bytes, err := json.Marshal(timings)
+		if err == nil {
+			record["plan"] = json.RawMessage(bytes)
+		}
+	}
+	return record
+}
+
+// history operations
+
+func historyKey(seq int64, offset int64) string {
+	return strconv.FormatInt(seq, 10) + "-" + strconv.FormatInt(offset, 10)
+}
+
+func splitHistoryKey(key string) (int64, int64, bool) {
+	i := strings.IndexByte(key, '-')
+	if i < 0 {
+		return 0, 0, false
+	}
+	seq, err1 := strconv.ParseInt(key[:i], 10, 64)
+	offset, err2 := strconv.ParseInt(key[i+1:], 10, 64)
+	return seq, offset, err1 == nil && err2 == nil && offset >= 0
+}
+
+// the files to read, oldest first
+func historySnapshot() (string, []int64) {
+	history.Lock()
+	defer history.Unlock()
+	if history.dir == "" {
+		return "", nil
+	}
+	return history.dir, history.files()
+}
+
+// Visit the keys of all entries, oldest first
+func RequestsHistoryForeach(f func(key string) bool) {
+	dir, seqs := historySnapshot()
+	for _, seq := range seqs {
+		if !historyForeachInFile(dir, seq, f) {
+			return
+		}
+	}
+}
+
+func historyForeachInFile(dir string, seq int64, f func(key string) bool) bool {
+	file, err := os.Open(historyFileName(dir, seq))
+
+	// rotated away
+	if err != nil {
+		return true
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	offset := int64(0)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+
+			// long entries are read in chunks
+			n := int64(len(line))
+			for err == bufio.ErrBufferFull {
+				line, err = reader.ReadSlice('\n')
+				n += int64(len(line))
+			}
+			if err != nil {
+				return true
+			}
+			if !f(historyKey(seq, offset)) {
+				return false
+			}
+			offset += n
+			continue
+		}
+
+		// incomplete entries are still being written
+		if err != nil {
+			return true
+		}
+		if !f(historyKey(seq, offset)) {
+			return false
+		}
+		offset += int64(len(line))
+	}
+}
+
+func RequestsHistoryCount() int {
+	count := 0
+	RequestsHistoryForeach(func(key string) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// Get an entry by key
+func RequestsHistoryGet(key string) (map[string]interface{}, errors.Error) {
+	seq, offset, ok := splitHistoryKey(key)
+	dir, _ := historySnapshot()
+	if !ok || dir == "" {
+		return nil, errors.NewSystemStmtNotFoundError(nil, key)
+	}
+	file, err := os.Open(historyFileName(dir, seq))
+	if err != nil {
+		return nil, errors.NewSystemStmtNotFoundError(err, key)
+	}
+	defer file.Close()
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, errors.NewSystemStmtNotFoundError(err, key)
+	}
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return nil, errors.NewSystemStmtNotFoundError(err, key)
+	}
+	var record map[string]interface{}
+	err = json.Unmarshal(line, &record)
+	if err != nil {
+		return nil, errors.NewCompletedHistoryError(err, "invalid entry "+key)
+	}
+	return record, nil
+}
@@ -0,0 +1,163 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompletedQualifiers(t *testing.T) {
+	var settings interface{}
+	json.Unmarshal([]byte(`{"user": ["etl", "report"], "error": 0, "statement": "^SELECT", "resultcount": 1000}`),
+		&settings)
+	if err := RequestsCheckQualifiers(settings); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	invalid := []string{
+		`[]`,
+		`{"threshold": 100}`,
+		`{"resultcount": [1, 2]}`,
+		`{"statement": "(unbalanced"}`,
+		`{"error": -1}`,
+		`{"user": ""}`,
+		`{"color": "blue"}`,
+	}
+	for _, s := range invalid {
+		var val interface{}
+		json.Unmarshal([]byte(s), &val)
+		if err := RequestsCheckQualifiers(val); err == nil {
+			t.Errorf("Expected %s to be rejected", s)
+		}
+	}
+
+	RequestsInit(1000, 10)
+	defer RequestsSetQualifiers(map[string]interface{}{})
+	if err := RequestsSetQualifiers(settings); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	qualifiers := RequestsQualifiers()
+	users, _ := qualifiers["user"].([]interface{})
+	statements, _ := qualifiers["statement"].([]interface{})
+	if len(users) != 2 || len(statements) != 1 || qualifiers["resultcount"] != 1000 {
+		t.Errorf("Unexpected qualifiers %v", qualifiers)
+	}
+	if _, ok := qualifiers["threshold"]; ok {
+		t.Errorf("Threshold is not expected among qualifiers")
+	}
+	if threshold, err := RequestsGetQualifier("threshold"); err != nil || threshold != time.Duration(1000) {
+		t.Errorf("Expected threshold to be kept, received %v", threshold)
+	}
+
+	user, _ := newUserQualifier("etl")
+	if !user.evaluate(nil, &completion{users: "local:admin,local:etl"}) ||
+		user.evaluate(nil, &completion{users: "local:etl2"}) {
+		t.Errorf("Unexpected user qualifier evaluation")
+	}
+	count, _ := newResultCountQualifier(1000)
+	if !count.evaluate(nil, &completion{resultCount: 1000}) || count.evaluate(nil, &completion{resultCount: 999}) {
+		t.Errorf("Unexpected result count qualifier evaluation")
+	}
+}
+
+func TestCompletedHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("Cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := RequestsHistoryInit(dir, 1024, 2); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer RequestsHistoryInit("", HISTORY_DEFAULT_SIZE, HISTORY_DEFAULT_FILES)
+
+	for i := 0; i < 20; i++ {
+		requestsHistoryWrite(&RequestLogEntry{
+			RequestId:   "request-" + strings.Repeat("x", i),
+			State:       "completed",
+			Time:        time.Now(),
+			ElapsedTime: time.Second,
+			Statement:   "SELECT 1",
+			ResultCount: i,
+		}, nil)
+	}
+	requestsHistoryFlush()
+
+	// older files have been rotated away
+	names, _ := ioutil.ReadDir(dir)
+	if len(names) != 2 {
+		t.Errorf("Expected 2 files, found %v", len(names))
+	}
+
+	var keys []string
+	RequestsHistoryForeach(func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) == 0 || len(keys) >= 20 || len(keys) != RequestsHistoryCount() {
+		t.Fatalf("Unexpected keys %v", keys)
+	}
+
+	// the latest entry comes last
+	entry, err := RequestsHistoryGet(keys[len(keys)-1])
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if entry["requestId"] != "request-"+strings.Repeat("x", 19) || entry["statement"] != "SELECT 1" {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+	if _, err := RequestsHistoryGet("0-0"); err == nil {
+		t.Errorf("Expected rotated entry not to be found")
+	}
+
+	// restarts carry on from the latest file
+	last := keys[len(keys)-1]
+	if err := RequestsHistorySetDir(dir); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, err := RequestsHistoryGet(last); err != nil {
+		t.Errorf("Expected %v to survive the restart", last)
+	}
+}
+
+func TestCompletedHistoryDropped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("Cannot create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := RequestsHistoryInit(dir, 0, 0); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer RequestsHistoryInit("", HISTORY_DEFAULT_SIZE, HISTORY_DEFAULT_FILES)
+
+	// hold the writer, so that the queue fills up
+	dropped := RequestsHistoryDropped()
+	history.Lock()
+	for i := 0; i < _HISTORY_QUEUE+10; i++ {
+		requestsHistoryWrite(&RequestLogEntry{RequestId: "request", Time: time.Now()}, nil)
+	}
+	history.Unlock()
+	requestsHistoryFlush()
+
+	if n := RequestsHistoryDropped() - dropped; n < 9 {
+		t.Errorf("Expected at least 9 entries dropped, found %v", n)
+	}
+	if count := RequestsHistoryCount(); count < _HISTORY_QUEUE || count > _HISTORY_QUEUE+1 {
+		t.Errorf("Expected the queued entries to be written, found %v", count)
+	}
+}
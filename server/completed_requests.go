@@ -20,6 +20,8 @@ package server
 
 import (
 	"net/http"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -55,6 +57,14 @@ type RequestLogEntry struct {
 	Users           string
 	RemoteAddr      string
 	UserAgent       string
+	Errors          []errors.Error
+}
+
+// what the qualifiers know of a completed request
+type completion struct {
+	users       string
+	resultCount int
+	errorCount  int
 }
 
 type qualifier interface {
@@ -62,7 +72,32 @@ type qualifier interface {
 	unique() bool
 	condition() interface{}
 	isCondition(c interface{}) bool
-	evaluate(request *BaseRequest) bool
+	evaluate(request *BaseRequest, outcome *completion) bool
+}
+
+// qualifier names
+const (
+	QUALIFIER_THRESHOLD   = "threshold"
+	QUALIFIER_USER        = "user"
+	QUALIFIER_ERROR       = "error"
+	QUALIFIER_STATEMENT   = "statement"
+	QUALIFIER_RESULTCOUNT = "resultcount"
+)
+
+func newQualifier(name string, condition interface{}) (qualifier, errors.Error) {
+	switch name {
+	case QUALIFIER_THRESHOLD:
+		return newTimeThreshold(condition)
+	case QUALIFIER_USER:
+		return newUserQualifier(condition)
+	case QUALIFIER_ERROR:
+		return newErrorQualifier(condition)
+	case QUALIFIER_STATEMENT:
+		return newStatementQualifier(condition)
+	case QUALIFIER_RESULTCOUNT:
+		return newResultCountQualifier(condition)
+	}
+	return nil, errors.NewCompletedQualifierUnknown(name)
 }
 
 type RequestLog struct {
@@ -107,17 +142,12 @@ func RequestsAddQualifier(name string, condition interface{}) errors.Error {
 	requestLog.Lock()
 	defer requestLog.Unlock()
 	for _, q := range requestLog.qualifiers {
-		if q.name() == name && q.unique() {
+		if q.name() == name && (q.unique() || q.isCondition(condition)) {
 			return errors.NewCompletedQualifierExists(name)
 		}
 	}
-	switch name {
-	case "threshold":
-		q, err = newTimeThreshold(condition)
-	default:
-		return errors.NewCompletedQualifierUnknown(name)
-	}
-	if q != nil {
+	q, err = newQualifier(name, condition)
+	if err == nil {
 		requestLog.qualifiers = append(requestLog.qualifiers, q)
 	}
 	return err
@@ -137,13 +167,8 @@ func RequestsUpdateQualifier(name string, condition interface{}) errors.Error {
 			requestLog.qualifiers = append(requestLog.qualifiers[:i], requestLog.qualifiers[i+1:]...)
 		}
 	}
-	switch name {
-	case "threshold":
-		q, err = newTimeThreshold(condition)
-	default:
-		return errors.NewCompletedQualifierUnknown(name)
-	}
-	if q != nil {
+	q, err = newQualifier(name, condition)
+	if err == nil {
 		requestLog.qualifiers = append(requestLog.qualifiers, q)
 	}
 	return err
@@ -191,6 +216,83 @@ func RequestsGetQualifiers() (qualifiers []struct {
 	return
 }
 
+// The qualifiers other than the threshold, which has a setting of its own,
+// as a map of qualifier names to conditions, or to arrays of conditions for
+// qualifiers that can be repeated
+func RequestsQualifiers() map[string]interface{} {
+	requestLog.RLock()
+	defer requestLog.RUnlock()
+	rv := make(map[string]interface{}, len(requestLog.qualifiers))
+	for _, q := range requestLog.qualifiers {
+		switch {
+		case q.name() == QUALIFIER_THRESHOLD:
+		case q.unique():
+			rv[q.name()] = q.condition()
+		default:
+			conditions, _ := rv[q.name()].([]interface{})
+			rv[q.name()] = append(conditions, q.condition())
+		}
+	}
+	return rv
+}
+
+// Replace the qualifiers other than the threshold
+func RequestsSetQualifiers(settings interface{}) errors.Error {
+	qualifiers, err := newQualifiers(settings)
+	if err != nil {
+		return err
+	}
+
+	requestLog.Lock()
+	defer requestLog.Unlock()
+	for _, q := range requestLog.qualifiers {
+		if q.name() == QUALIFIER_THRESHOLD {
+			qualifiers = append(qualifiers, q)
+		}
+	}
+	requestLog.qualifiers = qualifiers
+	return nil
+}
+
+// Check qualifier settings
+func RequestsCheckQualifiers(settings interface{}) errors.Error {
+	_, err := newQualifiers(settings)
+	return err
+}
+
+func newQualifiers(settings interface{}) ([]qualifier, errors.Error) {
+	m, ok := settings.(map[string]interface{})
+	if !ok {
+		return nil, errors.NewCompletedQualifierInvalidArgument("qualifiers", settings)
+	}
+	var qualifiers []qualifier
+	for name, c := range m {
+		if name == QUALIFIER_THRESHOLD {
+			return nil, errors.NewCompletedQualifierInvalidArgument(name, c)
+		}
+		conditions, ok := c.([]interface{})
+		if !ok {
+			conditions = []interface{}{c}
+		}
+		for _, condition := range conditions {
+
+			// JSON numbers come as floats
+			if f, ok := condition.(float64); ok && f == float64(int(f)) {
+				condition = int(f)
+			}
+			q, err := newQualifier(name, condition)
+			if err != nil {
+				return nil, err
+			}
+			if q.unique() && len(conditions) > 1 {
+				return nil, errors.NewCompletedQualifierNotUnique(name)
+			}
+			qualifiers = append(qualifiers, q)
+		}
+	}
+	return qualifiers, nil
+}
+
 // completed requests operations
 
 func RequestEntry(id string) *RequestLogEntry {
@@ -238,16 +340,23 @@ func LogRequest(request_time time.Duration, service_time time.Duration,
 
 	// negative limit means no upper bound (handled in cache)
 	// zero limit means log nothing (handled here to avoid time wasting in cache)
-	if requestLog.cache.Limit() == 0 {
+	// unless there is a history to write to
+	history := requestsHistoryEnabled()
+	if requestLog.cache.Limit() == 0 && !history {
 		return
 	}
 	requestLog.RLock()
 	defer requestLog.RUnlock()
 
 	// apply all the qualifiers until one is satisfied
+	outcome := &completion{
+		users:       datastore.CredsString(request.Credentials(), req),
+		resultCount: result_count,
+		errorCount:  error_count,
+	}
 	doLog := false
 	for _, q := range requestLog.qualifiers {
-		doLog = q.evaluate(request)
+		doLog = q.evaluate(request, outcome)
 		if doLog {
 			break
 		}
@@ -282,6 +391,7 @@ func LogRequest(request_time time.Duration, service_time time.Duration,
 	}
 	re.PhaseCounts = request.FmtPhaseCounts()
 	re.PhaseOperators = request.FmtPhaseOperators()
+	re.Errors = request.LoggedErrors()
 
 	// in order not to bloat service memory, we only
 	// store timings if they are turned on at the service
@@ -299,8 +409,9 @@ func LogRequest(request_time time.Duration, service_time time.Duration,
 	if prof != ProfOff {
 		re.PhaseTimes = request.FmtPhaseTimes()
 	}
+	timings := request.GetTimings()
 	if prof == ProfOn {
-		re.Timings = request.GetTimings()
+		re.Timings = timings
 		request.SetTimings(nil)
 	}
 
@@ -316,14 +427,21 @@ func LogRequest(request_time time.Duration, service_time time.Duration,
 		re.PositionalArgs = request.PositionalArgs()
 	}
 
-	re.Users = datastore.CredsString(request.Credentials(), req)
+	re.Users = outcome.users
 	re.RemoteAddr = request.RemoteAddr()
 	userAgent := request.UserAgent()
 	if userAgent != "" {
 		re.UserAgent = userAgent
 	}
 
-	requestLog.cache.Add(re, id, nil)
+	// the history gets the plan whether profiling or not, as there
+	// is no memory to save
+	if history {
+		requestsHistoryWrite(re, timings)
+	}
+	if requestLog.cache.Limit() != 0 {
+		requestLog.cache.Add(re, id, nil)
+	}
 }
 
 // request qualifiers
@@ -361,7 +479,7 @@ func (this *timeThreshold) isCondition(c interface{}) bool {
 	return false
 }
 
-func (this *timeThreshold) evaluate(request *BaseRequest) bool {
+func (this *timeThreshold) evaluate(request *BaseRequest, outcome *completion) bool {
 
 	// negative threshold means log nothing
 	// zero threshold means log everything (no threshold)
@@ -372,3 +490,172 @@ func (this *timeThreshold) evaluate(request *BaseRequest) bool {
 	}
 	return true
 }
+
+// 2- user
+type userQualifier struct {
+	user string
+}
+
+func newUserQualifier(c interface{}) (*userQualifier, errors.Error) {
+	switch c := c.(type) {
+	case string:
+		if c != "" {
+			return &userQualifier{user: c}, nil
+		}
+	}
+	return nil, errors.NewCompletedQualifierInvalidArgument(QUALIFIER_USER, c)
+}
+
+func (this *userQualifier) name() string {
+	return QUALIFIER_USER
+}
+
+func (this *userQualifier) unique() bool {
+	return false
+}
+
+func (this *userQualifier) condition() interface{} {
+	return this.user
+}
+
+func (this *userQualifier) isCondition(c interface{}) bool {
+	user, ok := c.(string)
+	return ok && user == this.user
+}
+
+// users may or may not come with their domain
+func (this *userQualifier) evaluate(request *BaseRequest, outcome *completion) bool {
+	for _, user := range strings.Split(outcome.users, ",") {
+		if user == this.user || strings.HasSuffix(user, ":"+this.user) {
+			return true
+		}
+	}
+	return false
+}
+
+// 3- error
+type errorQualifier struct {
+	code int
+}
+
+func newErrorQualifier(c interface{}) (*errorQualifier, errors.Error) {
+	switch c := c.(type) {
+	case int:
+		if c >= 0 {
+			return &errorQualifier{code: c}, nil
+		}
+	}
+	return nil, errors.NewCompletedQualifierInvalidArgument(QUALIFIER_ERROR, c)
+}
+
+func (this *errorQualifier) name() string {
+	return QUALIFIER_ERROR
+}
+
+func (this *errorQualifier) unique() bool {
+	return false
+}
+
+func (this *errorQualifier) condition() interface{} {
+	return this.code
+}
+
+func (this *errorQualifier) isCondition(c interface{}) bool {
+	code, ok := c.(int)
+	return ok && code == this.code
+}
+
+// code zero stands for any error
+func (this *errorQualifier) evaluate(request *BaseRequest, outcome *completion) bool {
+	if outcome.errorCount == 0 {
+		return false
+	}
+	if this.code == 0 {
+		return true
+	}
+	for _, err := range request.LoggedErrors() {
+		if int(err.Code()) == this.code {
+			return true
+		}
+	}
+	return false
+}
+
+// 4- statement
+type statementQualifier struct {
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+func newStatementQualifier(c interface{}) (*statementQualifier, errors.Error) {
+	switch c := c.(type) {
+	case string:
+		re, err := regexp.Compile(c)
+		if err == nil && c != "" {
+			return &statementQualifier{pattern: c, regexp: re}, nil
+		}
+	}
+	return nil, errors.NewCompletedQualifierInvalidArgument(QUALIFIER_STATEMENT, c)
+}
+
+func (this *statementQualifier) name() string {
+	return QUALIFIER_STATEMENT
+}
+
+func (this *statementQualifier) unique() bool {
+	return false
+}
+
+func (this *statementQualifier) condition() interface{} {
+	return this.pattern
+}
+
+func (this *statementQualifier) isCondition(c interface{}) bool {
+	pattern, ok := c.(string)
+	return ok && pattern == this.pattern
+}
+
+// prepared statements are matched by their text
+func (this *statementQualifier) evaluate(request *BaseRequest, outcome *completion) bool {
+	stmt := request.Statement()
+	if stmt == "" && request.Prepared() != nil {
+		stmt = request.Prepared().Text()
+	}
+	return stmt != "" && this.regexp.MatchString(stmt)
+}
+
+// 5- result count
+type resultCountQualifier struct {
+	count int
+}
+
+func newResultCountQualifier(c interface{}) (*resultCountQualifier, errors.Error) {
+	switch c := c.(type) {
+	case int:
+		if c >= 0 {
+			return &resultCountQualifier{count: c}, nil
+		}
+	}
+	return nil, errors.NewCompletedQualifierInvalidArgument(QUALIFIER_RESULTCOUNT, c)
+}
+
+func (this *resultCountQualifier) name() string {
+	return QUALIFIER_RESULTCOUNT
+}
+
+func (this *resultCountQualifier) unique() bool {
+	return true
+}
+
+func (this *resultCountQualifier) condition() interface{} {
+	return this.count
+}
+
+func (this *resultCountQualifier) isCondition(c interface{}) bool {
+	count, ok := c.(int)
+	return ok && count == this.count
+}
+
+func (this *resultCountQualifier) evaluate(request *BaseRequest, outcome *completion) bool {
+	return outcome.resultCount >= this.count
+}
@@ -13,6 +13,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/couchbase/query/accounting"
@@ -32,6 +33,7 @@ const (
 	preparedsPrefix  = adminPrefix + "/prepareds"
 	requestsPrefix   = adminPrefix + "/active_requests"
 	completedsPrefix = adminPrefix + "/completed_requests"
+	historyPrefix    = adminPrefix + "/completed_requests_history"
 	indexesPrefix    = adminPrefix + "/indexes"
 	expvarsRoute     = "/debug/vars"
 )
@@ -80,22 +82,30 @@ func (this *HttpEndpoint) registerAccountingHandlers() {
 	completedIndexHandler := func(w http.ResponseWriter, req *http.Request) {
 		this.wrapAPI(w, req, doCompletedIndex)
 	}
+	historyHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doCompletedHistory)
+	}
+	historyIndexHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doCompletedHistoryIndex)
+	}
 	routeMap := map[string]struct {
 		handler handlerFunc
 		methods []string
 	}{
-		accountingPrefix:                      {handler: statsHandler, methods: []string{"GET"}},
-		accountingPrefix + "/{stat}":          {handler: statHandler, methods: []string{"GET", "DELETE"}},
-		vitalsPrefix:                          {handler: vitalsHandler, methods: []string{"GET"}},
-		preparedsPrefix:                       {handler: preparedsHandler, methods: []string{"GET"}},
-		preparedsPrefix + "/{name}":           {handler: preparedHandler, methods: []string{"GET", "POST", "DELETE", "PUT"}},
-		requestsPrefix:                        {handler: requestsHandler, methods: []string{"GET"}},
-		requestsPrefix + "/{request}":         {handler: requestHandler, methods: []string{"GET", "POST", "DELETE"}},
-		completedsPrefix:                      {handler: completedsHandler, methods: []string{"GET"}},
-		completedsPrefix + "/{request}":       {handler: completedHandler, methods: []string{"GET", "POST", "DELETE"}},
-		indexesPrefix + "/prepareds":          {handler: preparedIndexHandler, methods: []string{"GET"}},
-		indexesPrefix + "/active_requests":    {handler: requestIndexHandler, methods: []string{"GET"}},
-		indexesPrefix + "/completed_requests": {handler: completedIndexHandler, methods: []string{"GET"}},
+		accountingPrefix:                              {handler: statsHandler, methods: []string{"GET"}},
+		accountingPrefix + "/{stat}":                  {handler: statHandler, methods: []string{"GET", "DELETE"}},
+		vitalsPrefix:                                  {handler: vitalsHandler, methods: []string{"GET"}},
+		preparedsPrefix:                               {handler: preparedsHandler, methods: []string{"GET"}},
+		preparedsPrefix + "/{name}":                   {handler: preparedHandler, methods: []string{"GET", "POST", "DELETE", "PUT"}},
+		requestsPrefix:                                {handler: requestsHandler, methods: []string{"GET"}},
+		requestsPrefix + "/{request}":                 {handler: requestHandler, methods: []string{"GET", "POST", "DELETE"}},
+		completedsPrefix:                              {handler: completedsHandler, methods: []string{"GET"}},
+		completedsPrefix + "/{request}":               {handler: completedHandler, methods: []string{"GET", "POST", "DELETE"}},
+		historyPrefix + "/{key}":                      {handler: historyHandler, methods: []string{"GET"}},
+		indexesPrefix + "/prepareds":                  {handler: preparedIndexHandler, methods: []string{"GET"}},
+		indexesPrefix + "/active_requests":            {handler: requestIndexHandler, methods: []string{"GET"}},
+		indexesPrefix + "/completed_requests":         {handler: completedIndexHandler, methods: []string{"GET"}},
+		indexesPrefix + "/completed_requests_history": {handler: historyIndexHandler, methods: []string{"GET"}},
 	}
 
 	for route, h := range routeMap {
@@ -129,6 +139,9 @@ func doStats(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (
 		for name, metric := range reg.Histograms() {
 			addMetricData(name, stats, getMetricData(metric))
 		}
+		for name, metric := range reg.BucketHistograms() {
+			addMetricData(name, stats, getMetricData(metric))
+		}
 		return stats, nil
 	default:
 		return nil, errors.NewServiceErrorHttpMethod(req.Method)
@@ -218,6 +231,9 @@ func getCredentialsFromRequest(req *http.Request) (auth.Credentials, errors.Erro
 
 func verifyCredentialsFromRequest(api string, req *http.Request) errors.Error {
 	creds, err := getCredentialsFromRequest(req)
+	if err == nil {
+		req, err = authenticate(req)
+	}
 	if err != nil {
 		return err
 	}
@@ -284,6 +300,12 @@ func doPrepared(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request
 			if req.Method == "POST" {
 				itemMap["plan"] = entry.Prepared.Operator
 			}
+			if entry.State != "" {
+				itemMap["state"] = entry.State
+				if entry.ReloadError != nil {
+					itemMap["error"] = entry.ReloadError.Error()
+				}
+			}
 			if entry.Uses > 0 {
 				itemMap["lastUse"] = entry.LastUse.String()
 				itemMap["avgElapsedTime"] = (time.Duration(entry.RequestTime) /
@@ -325,6 +347,12 @@ func doPrepareds(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Reques
 			data[i]["encoded_plan"] = d.Prepared.EncodedPlan()
 			data[i]["statement"] = d.Prepared.Text()
 			data[i]["uses"] = d.Uses
+			if d.State != "" {
+				data[i]["state"] = d.State
+				if d.ReloadError != nil {
+					data[i]["error"] = d.ReloadError.Error()
+				}
+			}
 			if d.Uses > 0 {
 				data[i]["lastUse"] = d.LastUse.String()
 			}
@@ -388,6 +416,10 @@ func activeRequestWorkHorse(endpoint *HttpEndpoint, requestId string, profiling
 		reqMap["executionTime"] = time.Since(request.ServiceTime()).String()
 		reqMap["state"] = request.State()
 		reqMap["scanConsistency"] = request.ScanConsistency()
+		if class := request.WorkloadClass(); class != "" {
+			reqMap["workloadClass"] = class
+			reqMap["queueTime"] = request.QueueTime().String()
+		}
 
 		p := request.Output().FmtPhaseCounts()
 		if p != nil {
@@ -486,6 +518,10 @@ func doActiveRequests(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.R
 		requests[i]["executionTime"] = time.Since(request.ServiceTime()).String()
 		requests[i]["state"] = request.State()
 		requests[i]["scanConsistency"] = request.ScanConsistency()
+		if class := request.WorkloadClass(); class != "" {
+			requests[i]["workloadClass"] = class
+			requests[i]["queueTime"] = request.QueueTime().String()
+		}
 
 		credsString := datastore.CredsString(request.Credentials(), request.OriginalHttpRequest())
 		if credsString != "" {
@@ -684,6 +720,23 @@ func doCompletedIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.R
 	return completed, nil
 }
 
+func doCompletedHistory(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	err := verifyCredentialsFromRequest("completed_requests", req)
+	if err != nil {
+		return nil, err
+	}
+	return server.RequestsHistoryGet(mux.Vars(req)["key"])
+}
+
+func doCompletedHistoryIndex(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	history := make([]string, 0, 1024)
+	server.RequestsHistoryForeach(func(key string) bool {
+		history = append(history, key)
+		return true
+	})
+	return history, nil
+}
+
 func getMetricData(metric accounting.Metric) map[string]interface{} {
 	values := make(map[string]interface{})
 	switch metric := metric.(type) {
@@ -725,6 +778,14 @@ func getMetricData(metric accounting.Metric) map[string]interface{} {
 		values["95%"] = ps[2]
 		values["99%"] = ps[3]
 		values["99.9%"] = ps[4]
+	case accounting.BucketHistogram:
+		buckets := make(map[string]interface{}, len(metric.Bounds()))
+		for i, n := range metric.Buckets() {
+			buckets[strconv.FormatFloat(metric.Bounds()[i], 'g', -1, 64)] = n
+		}
+		values["count"] = metric.Count()
+		values["sum"] = metric.Sum()
+		values["buckets"] = buckets
 	}
 	return values
 }
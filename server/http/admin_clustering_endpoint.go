@@ -17,6 +17,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/couchbase/query/audit"
 	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
@@ -322,12 +323,23 @@ const (
 	_TIMEOUT         = "timeout"
 	_CMPTHRESHOLD    = "completed-threshold"
 	_CMPLIMIT        = "completed-limit"
+	_CMPQUALIFIERS   = "completed-qualifiers"
+	_CMPHISTDIR      = "completed-history-dir"
+	_CMPHISTSIZE     = "completed-history-size"
+	_CMPHISTFILES    = "completed-history-files"
 	_PRPLIMIT        = "prepared-limit"
 	_PRETTY          = "pretty"
 	_PROFILE         = "profile"
 	_CONTROLS        = "controls"
 	_MAXINDEXAPI     = "max-index-api"
 	_N1QLFEATCTRL    = "n1ql-feat-ctrl"
+	_TMPSPACEDIR     = "tmp-space-dir"
+	_ASYNCTTL        = "async-ttl"
+	_ASYNCLIMIT      = "async-limit"
+	_CURSORIDLE      = "cursor-idle-timeout"
+	_WORKLOAD        = "workload-classes"
+	_RATELIMITS      = "rate-limits"
+	_AUDITLOG        = "audit-log"
 )
 
 type checker func(interface{}) (bool, errors.Error)
@@ -355,6 +367,44 @@ func checkString(val interface{}) (bool, errors.Error) {
 	return ok, nil
 }
 
+func checkWorkloadClasses(val interface{}) (bool, errors.Error) {
+	_, err := server.NewWorkloadClasses(val)
+	return err == nil, err
+}
+
+func getWorkloadClasses(srvr *server.Server) []interface{} {
+	classes := srvr.WorkloadClasses()
+	rv := make([]interface{}, len(classes))
+	for i, class := range classes {
+		rv[i] = class.Settings()
+	}
+	return rv
+}
+
+func checkRateLimits(val interface{}) (bool, errors.Error) {
+	_, err := server.NewRateLimits(val)
+	return err == nil, err
+}
+
+func getRateLimits() []interface{} {
+	limits := server.RateLimits()
+	rv := make([]interface{}, len(limits))
+	for i, limit := range limits {
+		rv[i] = limit.Settings()
+	}
+	return rv
+}
+
+func checkAuditLog(val interface{}) (bool, errors.Error) {
+	_, err := audit.NewFileSettings(val)
+	return err == nil, err
+}
+
+func checkQualifiers(val interface{}) (bool, errors.Error) {
+	err := server.RequestsCheckQualifiers(val)
+	return err == nil, err
+}
+
 func checkLogLevel(val interface{}) (bool, errors.Error) {
 	level, is_string := val.(string)
 	if !is_string {
@@ -379,12 +429,23 @@ var _CHECKERS = map[string]checker{
 	_TIMEOUT:         checkNumber,
 	_CMPTHRESHOLD:    checkNumber,
 	_CMPLIMIT:        checkNumber,
+	_CMPQUALIFIERS:   checkQualifiers,
+	_CMPHISTDIR:      checkString,
+	_CMPHISTSIZE:     checkNumber,
+	_CMPHISTFILES:    checkNumber,
 	_PRPLIMIT:        checkPositiveInteger,
 	_PRETTY:          checkBool,
 	_PROFILE:         checkProfileAdmin,
 	_CONTROLS:        checkControlsAdmin,
 	_MAXINDEXAPI:     checkNumber,
 	_N1QLFEATCTRL:    checkNumber,
+	_TMPSPACEDIR:     checkString,
+	_ASYNCTTL:        checkNumber,
+	_ASYNCLIMIT:      checkNumber,
+	_CURSORIDLE:      checkNumber,
+	_WORKLOAD:        checkWorkloadClasses,
+	_RATELIMITS:      checkRateLimits,
+	_AUDITLOG:        checkAuditLog,
 }
 
 type setter func(*server.Server, interface{})
@@ -446,6 +507,24 @@ var _SETTERS = map[string]setter{
 		value, _ := o.(float64)
 		server.RequestsSetLimit(int(value))
 	},
+	_CMPQUALIFIERS: func(s *server.Server, o interface{}) {
+		_ = server.RequestsSetQualifiers(o)
+	},
+	_CMPHISTDIR: func(s *server.Server, o interface{}) {
+		value, _ := o.(string)
+		err := server.RequestsHistorySetDir(value)
+		if err != nil {
+			logging.Errorf("%v", err)
+		}
+	},
+	_CMPHISTSIZE: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		server.RequestsHistorySetSize(int64(value))
+	},
+	_CMPHISTFILES: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		server.RequestsHistorySetFiles(int(value))
+	},
 	_PRPLIMIT: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		plan.PreparedsSetLimit(int(value))
@@ -464,6 +543,37 @@ var _SETTERS = map[string]setter{
 		value, _ := o.(float64)
 		util.SetN1qlFeatureControl(uint64(value))
 	},
+	_TMPSPACEDIR: func(s *server.Server, o interface{}) {
+		value, _ := o.(string)
+		server.AsyncSetDir(value)
+	},
+	_ASYNCTTL: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		server.AsyncSetTTL(time.Duration(value))
+	},
+	_ASYNCLIMIT: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		server.AsyncSetLimit(int(value))
+	},
+	_CURSORIDLE: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetCursorIdleTimeout(time.Duration(value))
+	},
+	_WORKLOAD: func(s *server.Server, o interface{}) {
+		classes, _ := server.NewWorkloadClasses(o)
+		s.SetWorkloadClasses(classes)
+	},
+	_RATELIMITS: func(s *server.Server, o interface{}) {
+		limits, _ := server.NewRateLimits(o)
+		server.SetRateLimits(limits)
+	},
+	_AUDITLOG: func(s *server.Server, o interface{}) {
+		settings, _ := audit.NewFileSettings(o)
+		err := audit.SetFileAuditSettings(settings)
+		if err != nil {
+			logging.Errorf("%v", err)
+		}
+	},
 }
 
 func doSettings(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
@@ -492,6 +602,13 @@ func doSettings(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request
 			return nil, errP
 		}
 
+		// persist the changes, if the configuration store keeps settings
+		if store, ok := srvr.ConfigurationStore().(clustering.SettingsStore); ok {
+			if errP := store.SetSettings(settings); errP != nil {
+				return nil, errP
+			}
+		}
+
 		return fillSettings(settings, srvr), nil
 	default:
 		return nil, errors.NewServiceErrorHttpMethod(req.Method)
@@ -537,10 +654,21 @@ func fillSettings(settings map[string]interface{}, srvr *server.Server) map[stri
 	threshold, _ := server.RequestsGetQualifier("threshold")
 	settings[_CMPTHRESHOLD] = threshold
 	settings[_CMPLIMIT] = server.RequestsLimit()
+	settings[_CMPQUALIFIERS] = server.RequestsQualifiers()
+	settings[_CMPHISTDIR] = server.RequestsHistoryDir()
+	settings[_CMPHISTSIZE] = server.RequestsHistorySize()
+	settings[_CMPHISTFILES] = server.RequestsHistoryFiles()
 	settings[_PRPLIMIT] = plan.PreparedsLimit()
 	settings[_PRETTY] = srvr.Pretty()
 	settings[_MAXINDEXAPI] = srvr.MaxIndexAPI()
 	settings[_N1QLFEATCTRL] = util.GetN1qlFeatureControl()
+	settings[_TMPSPACEDIR] = server.AsyncDir()
+	settings[_ASYNCTTL] = server.AsyncTTL()
+	settings[_ASYNCLIMIT] = server.AsyncLimit()
+	settings[_CURSORIDLE] = srvr.CursorIdleTimeout()
+	settings[_WORKLOAD] = getWorkloadClasses(srvr)
+	settings[_RATELIMITS] = getRateLimits()
+	settings[_AUDITLOG] = audit.FileAuditSettings()
 	settings = getProfileAdmin(settings, srvr)
 	settings = getControlsAdmin(settings, srvr)
 	return settings
@@ -0,0 +1,235 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/distributed"
+)
+
+// The metrics registry, in the Prometheus text exposition format
+const (
+	metricsPrefix      = "/metrics"
+	_METRICS_NAMESPACE = "n1ql_"
+	_METRICS_CONTENT   = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var _METRICS_QUANTILES = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// counters that go down as well as up
+func isGaugeCounter(name string) bool {
+	return name == accounting.ACTIVE_REQUESTS ||
+		strings.HasSuffix(name, accounting.QUEUED_REQUESTS)
+}
+
+func (this *HttpEndpoint) registerMetricsHandlers() {
+	metricsHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.serveMetrics(w, req)
+	}
+	this.mux.HandleFunc(metricsPrefix, metricsHandler).Methods("GET")
+}
+
+func (this *HttpEndpoint) serveMetrics(w http.ResponseWriter, req *http.Request) {
+	node := distributed.RemoteAccess().WhoAmI()
+	if node == "" {
+		node, _ = os.Hostname()
+	}
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, this.server.AccountingStore().MetricRegistry(), node)
+	w.Header().Set("Content-Type", _METRICS_CONTENT)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// metrics are grouped in families of the same name and type,
+// differing in their labels
+type metricFamily struct {
+	name    string
+	kind    string
+	metrics map[string]accounting.Metric // by labels
+}
+
+func writeMetrics(buf *bytes.Buffer, reg accounting.MetricRegistry, node string) {
+	families := map[string]*metricFamily{}
+	add := func(metric string, kind string, m accounting.Metric) {
+		name, labels := accounting.SplitMetric(metric)
+		name = _METRICS_NAMESPACE + sanitizeMetricName(name)
+		family := families[name]
+		if family == nil {
+			family = &metricFamily{name: name, kind: kind, metrics: map[string]accounting.Metric{}}
+			families[name] = family
+		} else if family.kind != kind {
+
+			// a name can only have one type
+			return
+		}
+		family.metrics[labels] = m
+	}
+
+	for name, m := range reg.Counters() {
+		base, _ := accounting.SplitMetric(name)
+		if isGaugeCounter(base) {
+			add(name, "gauge", m)
+		} else {
+			add(name, "counter", m)
+		}
+	}
+	for name, m := range reg.Gauges() {
+		add(name, "gauge", m)
+	}
+	for name, m := range reg.Meters() {
+		add(name, "counter", m)
+	}
+	for name, m := range reg.Timers() {
+		add(name+"_seconds", "summary", m)
+	}
+	for name, m := range reg.Histograms() {
+		add(name, "summary", m)
+	}
+	for name, m := range reg.BucketHistograms() {
+		add(name, "histogram", m)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodeLabel := "node=" + strconv.Quote(node)
+	for _, name := range names {
+		family := families[name]
+		buf.WriteString("# TYPE " + name + " " + family.kind + "\n")
+
+		labelSets := make([]string, 0, len(family.metrics))
+		for labels := range family.metrics {
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+		for _, labels := range labelSets {
+			writeMetric(buf, name, nodeLabel+prefixLabels(labels), family.metrics[labels])
+		}
+
+		// meters and timers also have rates, which are gauges
+		var rates []string
+		for _, labels := range labelSets {
+			switch family.metrics[labels].(type) {
+			case accounting.Meter, accounting.Timer:
+				rates = append(rates, labels)
+			}
+		}
+		if len(rates) > 0 {
+			rateName := strings.TrimSuffix(name, "_seconds") + "_per_second"
+			buf.WriteString("# TYPE " + rateName + " gauge\n")
+			for _, labels := range rates {
+				writeRates(buf, rateName, nodeLabel+prefixLabels(labels), family.metrics[labels])
+			}
+		}
+	}
+}
+
+func prefixLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "," + labels
+}
+
+func writeMetric(buf *bytes.Buffer, name string, labels string, m accounting.Metric) {
+	switch m := m.(type) {
+	case accounting.Counter:
+		writeSample(buf, name, labels, float64(m.Count()))
+	case accounting.Gauge:
+		writeSample(buf, name, labels, float64(m.Value()))
+	case accounting.Meter:
+		writeSample(buf, name, labels, float64(m.Count()))
+	case accounting.Timer:
+		ps := m.Percentiles(_METRICS_QUANTILES)
+		for i, q := range _METRICS_QUANTILES {
+			writeSample(buf, name, labels+",quantile="+quoteFloat(q), ps[i]/float64(time.Second))
+		}
+		writeSample(buf, name+"_sum", labels, float64(m.Sum())/float64(time.Second))
+		writeSample(buf, name+"_count", labels, float64(m.Count()))
+	case accounting.Histogram:
+		ps := m.Percentiles(_METRICS_QUANTILES)
+		for i, q := range _METRICS_QUANTILES {
+			writeSample(buf, name, labels+",quantile="+quoteFloat(q), ps[i])
+		}
+		writeSample(buf, name+"_sum", labels, float64(m.Sum()))
+		writeSample(buf, name+"_count", labels, float64(m.Count()))
+	case accounting.BucketHistogram:
+		bounds := m.Bounds()
+		for i, n := range m.Buckets() {
+			writeSample(buf, name+"_bucket", labels+",le="+quoteFloat(bounds[i]), float64(n))
+		}
+		count := float64(m.Count())
+		writeSample(buf, name+"_bucket", labels+",le=\"+Inf\"", count)
+		writeSample(buf, name+"_sum", labels, m.Sum())
+		writeSample(buf, name+"_count", labels, count)
+	}
+}
+
+func writeRates(buf *bytes.Buffer, name string, labels string, m accounting.Metric) {
+	var rates [4]float64
+	switch m := m.(type) {
+	case accounting.Meter:
+		rates = [4]float64{m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean()}
+	case accounting.Timer:
+		rates = [4]float64{m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean()}
+	}
+	for i, window := range []string{"1m", "5m", "15m", "mean"} {
+		writeSample(buf, name, labels+",window=\""+window+"\"", rates[i])
+	}
+}
+
+func writeSample(buf *bytes.Buffer, name string, labels string, v float64) {
+	buf.WriteString(name)
+	buf.WriteByte('{')
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	buf.WriteString(formatFloat(v))
+	buf.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func quoteFloat(v float64) string {
+	return "\"" + formatFloat(v) + "\""
+}
+
+// metric names may only have letters, digits, underscores and colons
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
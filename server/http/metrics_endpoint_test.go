@@ -0,0 +1,58 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/accounting/gometrics"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	reg := accounting_gm.NewAccountingStore().MetricRegistry()
+
+	reg.Counter("test_requests").Inc(3)
+	reg.Counter("workload_batch-1_" + accounting.QUEUED_REQUESTS).Inc(2)
+	reg.Counter(accounting.LabelledMetric("test_keyspace_requests", accounting.LABEL_KEYSPACE, "default:travel")).Inc(1)
+	reg.Timer("test_timer").Update(2 * time.Second)
+	h := reg.BucketHistogram(accounting.LabelledMetric("test_duration_seconds",
+		accounting.LABEL_PHASE, "fetch", accounting.LABEL_STATEMENT, "select"), []float64{0.1, 1})
+	h.Observe(0.5)
+	h.Observe(5)
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, reg, "node1")
+	out := buf.String()
+
+	expected := []string{
+		"# TYPE n1ql_test_requests counter\n",
+		"n1ql_test_requests{node=\"node1\"} 3\n",
+		"# TYPE n1ql_workload_batch_1_queued_requests gauge\n",
+		"n1ql_test_keyspace_requests{node=\"node1\",keyspace=\"default:travel\"} 1\n",
+		"# TYPE n1ql_test_timer_seconds summary\n",
+		"n1ql_test_timer_seconds{node=\"node1\",quantile=\"0.5\"} 2\n",
+		"n1ql_test_timer_seconds_count{node=\"node1\"} 1\n",
+		"# TYPE n1ql_test_timer_per_second gauge\n",
+		"# TYPE n1ql_test_duration_seconds histogram\n",
+		"n1ql_test_duration_seconds_bucket{node=\"node1\",phase=\"fetch\",statement=\"select\",le=\"0.1\"} 0\n",
+		"n1ql_test_duration_seconds_bucket{node=\"node1\",phase=\"fetch\",statement=\"select\",le=\"1\"} 1\n",
+		"n1ql_test_duration_seconds_bucket{node=\"node1\",phase=\"fetch\",statement=\"select\",le=\"+Inf\"} 2\n",
+		"n1ql_test_duration_seconds_sum{node=\"node1\",phase=\"fetch\",statement=\"select\"} 5.5\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("Expected %q in:\n%s", e, out)
+		}
+	}
+}
@@ -7,8 +7,6 @@
 //  either express or implied. See the License for the specific language governing permissions
 //  and limitations under the License.
 
-// +build enterprise
-
 package http
 
 import (
@@ -18,7 +16,11 @@ import (
 )
 
 func checkProfileAdmin(val interface{}) (bool, errors.Error) {
-	_, ok := val.(string)
+	profile, ok := val.(string)
+	if !ok {
+		return false, nil
+	}
+	_, ok = server.ParseProfile(profile)
 	return ok, nil
 }
 
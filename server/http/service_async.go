@@ -0,0 +1,401 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	go_errors "errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/server"
+	"github.com/gorilla/mux"
+)
+
+const (
+	resultsPrefix = "/query/results"
+)
+
+const ( // Results argument names
+	CURSOR = "cursor"
+	COUNT  = "count"
+)
+
+// one index entry every so many results
+const _ASYNC_INDEX_STRIDE = 256
+
+// Requests submitted with mode=async are acknowledged immediately with
+// a handle, their response being spooled to a file in the temp space
+// directory. The response can be retrieved, whole or in pages, from
+// /query/results/{handle} until its TTL expires.
+func (this *HttpEndpoint) serveAsync(request *httpRequest) bool {
+	handle := request.Id().String()
+
+	spool, e := newAsyncSpool(server.AsyncDir(), handle)
+	if e != nil {
+		request.Fail(errors.NewServiceErrorAsyncSpool(e))
+		return false
+	}
+
+	entry := &server.AsyncEntry{
+		Handle:    handle,
+		ClientId:  request.ClientID().String(),
+		Statement: request.Statement(),
+		Users:     datastore.CredsString(request.Credentials(), request.req),
+		State:     string(server.RUNNING),
+		Submitted: time.Now(),
+		TTL:       request.ttl,
+		Spool:     spool,
+	}
+	err := server.AsyncAdd(entry)
+	if err != nil {
+		spool.Remove()
+		request.Fail(err)
+		return false
+	}
+
+	// from now on, the response goes to the spool, and
+	// the client going away does not stop the request
+	request.writer = spool
+	request.spool = spool
+	request.httpCloseNotify = nil
+
+	this.actives.Put(request)
+	if !this.enqueue(request) {
+		this.actives.Delete(handle, false)
+		server.AsyncDelete(handle)
+		request.resp.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	}
+
+	go func() {
+		<-request.CloseNotify()
+		this.actives.Delete(handle, false)
+		this.doStats(request, this.server)
+		server.AsyncComplete(handle, request.EventStatus())
+	}()
+
+	rv := map[string]interface{}{
+		"requestID": handle,
+		"handle":    resultsPrefix + "/" + handle,
+		"status":    server.RUNNING,
+	}
+	if request.ClientID().IsValid() {
+		rv["clientContextID"] = request.ClientID().String()
+	}
+	writeJSON(request.resp, http.StatusAccepted, rv)
+	return true
+}
+
+func (this *HttpEndpoint) registerAsyncHandlers() {
+	resultsHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.serveResults(w, req)
+	}
+
+	this.mux.HandleFunc(resultsPrefix+"/{handle}", resultsHandler).
+		Methods("GET", "DELETE")
+}
+
+func (this *HttpEndpoint) serveResults(w http.ResponseWriter, req *http.Request) {
+	handle := mux.Vars(req)["handle"]
+
+	creds, err := getCredentialsFromRequest(req)
+	if err == nil {
+		req, err = authenticate(req)
+	}
+	if err != nil {
+		writeAsyncError(w, err)
+		return
+	}
+
+	// only the submitter can see the handle
+	var entry server.AsyncEntry
+	users := datastore.CredsString(creds, req)
+	found := server.AsyncDo(handle, func(e *server.AsyncEntry) {
+		entry = *e
+	})
+	if !found || entry.Users != users {
+		writeAsyncError(w, errors.NewServiceErrorAsyncHandle(handle))
+		return
+	}
+
+	spool := entry.Spool.(*asyncSpool)
+
+	switch req.Method {
+	case "DELETE":
+		if !entry.IsCompleted() {
+			this.actives.Delete(handle, true)
+		}
+		server.AsyncDelete(handle)
+		writeJSON(w, http.StatusOK, true)
+	case "GET":
+		if !entry.IsCompleted() {
+			rv := activeRequestWorkHorse(this, handle, false)
+			rv["handle"] = resultsPrefix + "/" + handle
+			rv["status"] = server.RUNNING
+			rv["resultCount"] = spool.resultCount()
+			writeJSON(w, http.StatusOK, rv)
+			return
+		}
+
+		start, count, err := getPage(req)
+		if err != nil {
+			writeAsyncError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		e := spool.writePage(w, start, count)
+		if e != nil {
+			logging.Infof("Error writing async results for %s: %v", handle, e)
+		}
+	default:
+		writeAsyncError(w, errors.NewServiceErrorHttpMethod(req.Method))
+	}
+}
+
+func getPage(req *http.Request) (int, int, errors.Error) {
+	var start, count int
+	var e error
+
+	cursor := req.FormValue(CURSOR)
+	if cursor != "" {
+		start, e = strconv.Atoi(cursor)
+		if e != nil || start < 0 {
+			return 0, 0, errors.NewServiceErrorBadValue(go_errors.New("cursor is invalid"), CURSOR)
+		}
+	}
+
+	param := req.FormValue(COUNT)
+	if param != "" {
+		count, e = strconv.Atoi(param)
+		if e != nil || count <= 0 {
+			return 0, 0, errors.NewServiceErrorBadValue(go_errors.New("count is invalid"), COUNT)
+		}
+	}
+
+	return start, count, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, obj interface{}) {
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		writeAsyncError(w, errors.NewServiceErrorInvalidJSON(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf)
+}
+
+func writeAsyncError(w http.ResponseWriter, err errors.Error) {
+	buf, _ := json.Marshal(map[string]interface{}{
+		"errors": []interface{}{map[string]interface{}{
+			"code": err.Code(),
+			"msg":  err.Error(),
+		}},
+		"status": server.FATAL,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(mapErrorToHttpResponse(err, http.StatusInternalServerError))
+	w.Write(buf)
+}
+
+// asyncSpool is a responseDataManager that writes the response of an
+// async request to a file. Async responses are never pretty, so each
+// result sits on a line of its own, and pages of results can be served
+// from a sparse index of result offsets.
+type asyncSpool struct {
+	sync.Mutex
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64   // bytes written so far
+	results int     // results written so far
+	index   []int64 // offset of every _ASYNC_INDEX_STRIDE-th result
+	suffix  int64   // offset of the end of the results array
+	closed  bool
+	removed bool
+}
+
+func newAsyncSpool(dir, handle string) (*asyncSpool, error) {
+	path := filepath.Join(dir, "async-"+handle+".json")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asyncSpool{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+		suffix: -1,
+	}, nil
+}
+
+func (this *asyncSpool) writeString(s string) bool {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return false
+	}
+
+	n, err := this.writer.WriteString(s)
+	this.size += int64(n)
+	if err != nil {
+		logging.Errorf("Error spooling async results to %s: %v", this.path, err)
+		return false
+	}
+	return true
+}
+
+func (this *asyncSpool) noMoreData() {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return
+	}
+
+	err := this.writer.Flush()
+	if err == nil {
+		err = this.file.Close()
+	} else {
+		this.file.Close()
+	}
+	if err != nil {
+		logging.Errorf("Error spooling async results to %s: %v", this.path, err)
+	}
+	this.closed = true
+}
+
+// called with the spool positioned at the start of the next result
+func (this *asyncSpool) markResult() {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.results%_ASYNC_INDEX_STRIDE == 0 {
+		this.index = append(this.index, this.size)
+	}
+	this.results++
+}
+
+// called with the spool positioned at the end of the results
+func (this *asyncSpool) markSuffix() {
+	this.Lock()
+	defer this.Unlock()
+
+	this.suffix = this.size
+}
+
+func (this *asyncSpool) resultCount() int {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.results
+}
+
+// For server.AsyncSpool interface.
+func (this *asyncSpool) Remove() {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.removed {
+		return
+	}
+	if !this.closed {
+		this.file.Close()
+		this.closed = true
+	}
+	os.Remove(this.path)
+	this.removed = true
+}
+
+// Write the whole response, or, if count is positive, the response with only
+// count results starting at start, and a cursor to the next page if any
+func (this *asyncSpool) writePage(w io.Writer, start, count int) error {
+	this.Lock()
+	results := this.results
+	suffix := this.suffix
+	index := this.index
+	this.Unlock()
+
+	file, err := os.Open(this.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if count <= 0 || results == 0 || suffix < 0 {
+		_, err = io.Copy(w, file)
+		return err
+	}
+
+	// everything up to the first result
+	_, err = io.CopyN(w, file, index[0])
+	if err != nil {
+		return err
+	}
+
+	if start < results {
+		_, err = file.Seek(index[start/_ASYNC_INDEX_STRIDE], io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		reader := bufio.NewReader(file)
+		for i := start - start%_ASYNC_INDEX_STRIDE; i < start; i++ {
+			_, err = reader.ReadString('\n')
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := start; i < start+count && i < results; i++ {
+			line, err := reader.ReadString('\n')
+			if err != nil && !(err == io.EOF && line != "") {
+				return err
+			}
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), ",")
+			if i > start {
+				line = ",\n" + line
+			}
+			_, err = io.WriteString(w, line)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// close the results array, add the cursor and then the rest
+	_, err = file.Seek(suffix, io.SeekStart)
+	if err == nil {
+		_, err = io.CopyN(w, file, int64(len("\n]")))
+	}
+	if err == nil && start+count < results {
+		_, err = io.WriteString(w, ",\n\""+CURSOR+"\": \""+strconv.Itoa(start+count)+"\"")
+	}
+	if err == nil {
+		_, err = io.Copy(w, file)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func newTestSpool(t *testing.T, dir string, results int) *asyncSpool {
+	spool, err := newAsyncSpool(dir, "handle")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// mimic httpRequest.Execute()
+	spool.writeString("{\n\"requestID\": \"handle\",\n\"results\": [")
+	for i := 0; i < results; i++ {
+		if i == 0 {
+			spool.writeString("\n")
+		} else {
+			spool.writeString(",\n")
+		}
+		spool.markResult()
+		spool.writeString(fmt.Sprintf("{\"n\":%d,\"s\":\"a\\nb\"}", i))
+	}
+	spool.markSuffix()
+	spool.writeString("\n]")
+	spool.writeString(",\n\"status\": \"success\"")
+	spool.writeString("\n}\n")
+	spool.noMoreData()
+	return spool
+}
+
+type testPage struct {
+	Results []struct {
+		N int `json:"n"`
+	} `json:"results"`
+	Cursor string `json:"cursor"`
+	Status string `json:"status"`
+}
+
+func readTestPage(t *testing.T, spool *asyncSpool, start, count int) *testPage {
+	var buf bytes.Buffer
+	if err := spool.writePage(&buf, start, count); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var page testPage
+	if err := json.Unmarshal(buf.Bytes(), &page); err != nil {
+		t.Fatalf("Invalid page %v: %s", err, buf.String())
+	}
+	return &page
+}
+
+func TestAsyncSpoolPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "async")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	spool := newTestSpool(t, dir, 600)
+
+	page := readTestPage(t, spool, 0, 0)
+	if len(page.Results) != 600 || page.Cursor != "" || page.Status != "success" {
+		t.Errorf("Whole response: %d results, cursor %q, status %q", len(page.Results), page.Cursor, page.Status)
+	}
+
+	tests := []struct {
+		start, count int
+		first, n     int
+		cursor       string
+	}{
+		{0, 100, 0, 100, "100"},
+		{250, 10, 250, 10, "260"},
+		{513, 200, 513, 87, ""},
+		{599, 1, 599, 1, ""},
+		{700, 10, 0, 0, ""},
+	}
+
+	for _, test := range tests {
+		page := readTestPage(t, spool, test.start, test.count)
+		if len(page.Results) != test.n || page.Cursor != test.cursor || page.Status != "success" {
+			t.Errorf("Page %d+%d: %d results, cursor %q, status %q", test.start, test.count,
+				len(page.Results), page.Cursor, page.Status)
+			continue
+		}
+		for i, r := range page.Results {
+			if r.N != test.first+i {
+				t.Errorf("Page %d+%d: expected result %d, actual %d", test.start, test.count, test.first+i, r.N)
+				break
+			}
+		}
+	}
+
+	spool.Remove()
+	if _, err := os.Stat(spool.path); !os.IsNotExist(err) {
+		t.Errorf("Spool %s not removed", spool.path)
+	}
+}
+
+func TestAsyncSpoolNoResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "async")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	spool := newTestSpool(t, dir, 0)
+
+	page := readTestPage(t, spool, 0, 10)
+	if len(page.Results) != 0 || page.Cursor != "" || page.Status != "success" {
+		t.Errorf("Empty response: %d results, cursor %q, status %q", len(page.Results), page.Cursor, page.Status)
+	}
+}
@@ -0,0 +1,438 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	go_errors "errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+)
+
+// Besides passwords, which the datastore checks, users can authenticate
+// with a JWT bearer token, or a client certificate on the TLS listener.
+// The service checks these itself, and the user they name travels with
+// the http request to the datastore, which grants the privileges of the
+// roles the token carries, or of the user's own roles if it carries none.
+type AuthConfig struct {
+	JWTSecretFile    string // HS256 shared secret
+	JWTPublicKeyFile string // RS256 PEM public key or certificate
+	JWKSFile         string // HS256 and RS256 keys, by key id
+	JWTIssuer        string // required iss, if set
+	JWTAudience      string // required aud, if set
+	JWTUserClaim     string // defaults to sub
+	JWTRolesClaim    string // defaults to roles
+	JWTDomain        string // defaults to external
+
+	ClientCertMode   string // none, enable or mandatory
+	ClientCAFile     string
+	ClientCertUser   string // subject.cn, san.email, san.dns or san.uri
+	ClientCertDomain string // defaults to local
+}
+
+const (
+	_CERT_NONE      = "none"
+	_CERT_ENABLE    = "enable"
+	_CERT_MANDATORY = "mandatory"
+
+	// allowance for clock skew in token expiry
+	_JWT_LEEWAY = time.Minute
+)
+
+type authenticator struct {
+	hmacKeys   map[string][]byte // by key id, "" if none
+	rsaKeys    map[string]*rsa.PublicKey
+	issuer     string
+	audience   string
+	userClaim  string
+	rolesClaim string
+	domain     string
+
+	certMode   string
+	clientCAs  *x509.CertPool
+	certUser   string
+	certDomain string
+}
+
+var _AUTHENTICATOR *authenticator
+
+// Set up bearer token and client certificate authentication, before
+// the endpoint starts listening.
+func SetAuthentication(config *AuthConfig) error {
+	a, err := newAuthenticator(config)
+	if err != nil {
+		return err
+	}
+	_AUTHENTICATOR = a
+	return nil
+}
+
+func newAuthenticator(config *AuthConfig) (*authenticator, error) {
+	rv := &authenticator{
+		hmacKeys:   make(map[string][]byte),
+		rsaKeys:    make(map[string]*rsa.PublicKey),
+		issuer:     config.JWTIssuer,
+		audience:   config.JWTAudience,
+		userClaim:  defaultString(config.JWTUserClaim, "sub"),
+		rolesClaim: defaultString(config.JWTRolesClaim, "roles"),
+		domain:     defaultString(config.JWTDomain, "external"),
+		certMode:   defaultString(config.ClientCertMode, _CERT_NONE),
+		certUser:   defaultString(config.ClientCertUser, "subject.cn"),
+		certDomain: defaultString(config.ClientCertDomain, "local"),
+	}
+
+	if config.JWTSecretFile != "" {
+		secret, err := ioutil.ReadFile(config.JWTSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = bytes.TrimRight(secret, "\r\n")
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("empty JWT secret in %s", config.JWTSecretFile)
+		}
+		rv.hmacKeys[""] = secret
+	}
+	if config.JWTPublicKeyFile != "" {
+		key, err := loadPublicKey(config.JWTPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		rv.rsaKeys[""] = key
+	}
+	if config.JWKSFile != "" {
+		err := rv.loadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch rv.certMode {
+	case _CERT_NONE:
+	case _CERT_ENABLE, _CERT_MANDATORY:
+		if config.ClientCAFile == "" {
+			return nil, fmt.Errorf("client certificate mode %s requires a CA file", rv.certMode)
+		}
+		cas, err := ioutil.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		rv.clientCAs = x509.NewCertPool()
+		if !rv.clientCAs.AppendCertsFromPEM(cas) {
+			return nil, fmt.Errorf("no certificates in %s", config.ClientCAFile)
+		}
+	default:
+		return nil, fmt.Errorf("invalid client certificate mode %s", rv.certMode)
+	}
+	switch rv.certUser {
+	case "subject.cn", "san.email", "san.dns", "san.uri":
+	default:
+		return nil, fmt.Errorf("invalid client certificate user %s", rv.certUser)
+	}
+	return rv, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func loadPublicKey(name string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", name)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		err = fmt.Errorf("unexpected %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key in %s: %v", name, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an RSA public key", name)
+	}
+	return rsaKey, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// a JWKS file holds RSA and symmetric keys; others are ignored
+func (this *authenticator) loadJWKS(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = json.Unmarshal(data, &jwks)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS in %s: %v", name, err)
+	}
+
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+			e, err2 := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+			if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return fmt.Errorf("invalid RSA key %s in %s", k.Kid, name)
+			}
+			this.rsaKeys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.K, "="))
+			if err != nil || len(secret) == 0 {
+				return fmt.Errorf("invalid symmetric key %s in %s", k.Kid, name)
+			}
+			this.hmacKeys[k.Kid] = secret
+		}
+	}
+	return nil
+}
+
+// TLS client authentication for the listener
+func clientAuthentication() (tls.ClientAuthType, *x509.CertPool) {
+	a := _AUTHENTICATOR
+	if a == nil {
+		return tls.NoClientCert, nil
+	}
+	switch a.certMode {
+	case _CERT_ENABLE:
+		return tls.VerifyClientCertIfGiven, a.clientCAs
+	case _CERT_MANDATORY:
+		return tls.RequireAndVerifyClientCert, a.clientCAs
+	}
+	return tls.NoClientCert, nil
+}
+
+// Returns the request carrying the user named by its bearer token or,
+// failing that, by its client certificate. A bearer token that does
+// not check out fails the request, rather than leaving it anonymous.
+func authenticate(req *http.Request) (*http.Request, errors.Error) {
+	a := _AUTHENTICATOR
+	if a == nil || auth.RequestIdentity(req) != nil {
+		return req, nil
+	}
+
+	for _, h := range req.Header["Authorization"] {
+		if strings.HasPrefix(h, "Bearer ") {
+			identity, err := a.verifyToken(strings.TrimSpace(h[len("Bearer "):]), time.Now())
+			if err != nil {
+				return req, errors.NewServiceErrorAuthentication("bearer token", err.Error())
+			}
+			return auth.WithIdentity(req, identity), nil
+		}
+	}
+
+	if a.certMode != _CERT_NONE && req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		identity, err := a.certIdentity(req.TLS.VerifiedChains[0][0])
+		if err != nil {
+			return req, errors.NewServiceErrorAuthentication("client certificate", err.Error())
+		}
+		return auth.WithIdentity(req, identity), nil
+	}
+	return req, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (this *authenticator) verifyToken(token string, now time.Time) (*auth.Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, go_errors.New("malformed token")
+	}
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, go_errors.New("malformed token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return nil, go_errors.New("malformed token signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	switch header.Alg {
+	case "HS256":
+		for kid, key := range this.hmacKeys {
+			if header.Kid != "" && kid != header.Kid {
+				continue
+			}
+			mac := hmac.New(sha256.New, key)
+			mac.Write(signed)
+			if hmac.Equal(mac.Sum(nil), signature) {
+				verified = true
+				break
+			}
+		}
+	case "RS256":
+		digest := sha256.Sum256(signed)
+		for kid, key := range this.rsaKeys {
+			if header.Kid != "" && kid != header.Kid {
+				continue
+			}
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				verified = true
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", header.Alg)
+	}
+	if !verified {
+		return nil, go_errors.New("invalid signature")
+	}
+
+	var claims map[string]interface{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, go_errors.New("malformed token claims")
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, go_errors.New("no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(_JWT_LEEWAY)) {
+		return nil, go_errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(_JWT_LEEWAY).Before(time.Unix(int64(nbf), 0)) {
+		return nil, go_errors.New("token not yet valid")
+	}
+	if this.issuer != "" && claims["iss"] != this.issuer {
+		return nil, go_errors.New("unexpected issuer")
+	}
+	if this.audience != "" && !hasAudience(claims["aud"], this.audience) {
+		return nil, go_errors.New("unexpected audience")
+	}
+
+	user, _ := claims[this.userClaim].(string)
+	if user == "" {
+		return nil, fmt.Errorf("no %s claim", this.userClaim)
+	}
+	identity := &auth.Identity{User: this.domain + ":" + user, Method: "jwt"}
+
+	// roles are a list, or a space separated string as for scopes
+	switch roles := claims[this.rolesClaim].(type) {
+	case nil:
+	case string:
+		identity.Roles = strings.Fields(roles)
+	case []interface{}:
+		identity.Roles = make([]string, 0, len(roles))
+		for _, r := range roles {
+			role, ok := r.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s claim", this.rolesClaim)
+			}
+			identity.Roles = append(identity.Roles, role)
+		}
+	default:
+		return nil, fmt.Errorf("invalid %s claim", this.rolesClaim)
+	}
+	return identity, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (this *authenticator) certIdentity(cert *x509.Certificate) (*auth.Identity, error) {
+	var user string
+	switch this.certUser {
+	case "subject.cn":
+		user = cert.Subject.CommonName
+	case "san.email":
+		if len(cert.EmailAddresses) > 0 {
+			user = cert.EmailAddresses[0]
+		}
+	case "san.dns":
+		if len(cert.DNSNames) > 0 {
+			user = cert.DNSNames[0]
+		}
+	case "san.uri":
+		if len(cert.URIs) > 0 {
+			user = cert.URIs[0].String()
+		}
+	}
+	if user == "" {
+		return nil, fmt.Errorf("no %s in certificate", this.certUser)
+	}
+	return &auth.Identity{User: this.certDomain + ":" + user, Method: "cert"}, nil
+}
@@ -0,0 +1,214 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/auth"
+)
+
+func signToken(t *testing.T, header, claims map[string]interface{}, key interface{}) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("Unable to sign %v", err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyToken(t *testing.T) {
+	dir, e := ioutil.TempDir("", "auth")
+	if e != nil {
+		t.Fatalf("Unable to create directory %v", e)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := []byte("not so secret")
+	rsaKey, e := rsa.GenerateKey(rand.Reader, 2048)
+	if e != nil {
+		t.Fatalf("Unable to generate key %v", e)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]interface{}{
+		{"kty": "oct", "kid": "hs", "k": base64.RawURLEncoding.EncodeToString(secret)},
+		{"kty": "RSA", "kid": "rs", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+	}})
+	jwksFile := filepath.Join(dir, "jwks.json")
+	ioutil.WriteFile(jwksFile, jwks, 0600)
+
+	a, err := newAuthenticator(&AuthConfig{JWKSFile: jwksFile, JWTAudience: "query"})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	now := time.Now()
+	exp := now.Add(time.Hour).Unix()
+	good := []struct {
+		header map[string]interface{}
+		claims map[string]interface{}
+		key    interface{}
+		roles  []string
+	}{
+		{map[string]interface{}{"alg": "HS256", "kid": "hs"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "query"}, secret, nil},
+		{map[string]interface{}{"alg": "RS256", "kid": "rs"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": []string{"other", "query"},
+				"roles": []string{"select[orders]", "insert"}}, rsaKey, []string{"select[orders]", "insert"}},
+		{map[string]interface{}{"alg": "RS256"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "query", "roles": "select query_select"},
+			rsaKey, []string{"select", "query_select"}},
+	}
+	for i, g := range good {
+		identity, err := a.verifyToken(signToken(t, g.header, g.claims, g.key), now)
+		if err != nil {
+			t.Errorf("Unexpected error in token %d: %v", i, err)
+			continue
+		}
+		if identity.User != "external:ann" || identity.Method != "jwt" ||
+			len(identity.Roles) != len(g.roles) || (g.roles == nil) != (identity.Roles == nil) {
+			t.Errorf("Unexpected identity in token %d: %v", i, identity)
+		}
+	}
+
+	otherKey := []byte("another secret")
+	bad := []struct {
+		header map[string]interface{}
+		claims map[string]interface{}
+		key    interface{}
+	}{
+		// wrong key
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "query"}, otherKey},
+		// wrong key id
+		{map[string]interface{}{"alg": "HS256", "kid": "rs"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "query"}, secret},
+		// expired
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"sub": "ann", "exp": now.Add(-time.Hour).Unix(), "aud": "query"}, secret},
+		// no expiry
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"sub": "ann", "aud": "query"}, secret},
+		// not yet valid
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "nbf": now.Add(time.Hour).Unix(), "aud": "query"}, secret},
+		// wrong audience
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "other"}, secret},
+		// no user
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"exp": exp, "aud": "query"}, secret},
+		// bad roles
+		{map[string]interface{}{"alg": "HS256"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "query", "roles": 1}, secret},
+		// unsigned
+		{map[string]interface{}{"alg": "none"},
+			map[string]interface{}{"sub": "ann", "exp": exp, "aud": "query"}, nil},
+	}
+	for i, b := range bad {
+		if _, err := a.verifyToken(signToken(t, b.header, b.claims, b.key), now); err == nil {
+			t.Errorf("Expected token %d to be rejected", i)
+		}
+	}
+	if _, err := a.verifyToken("not.a.token", now); err == nil {
+		t.Errorf("Expected malformed token to be rejected")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	defer func() { _AUTHENTICATOR = nil }()
+
+	secret := []byte("not so secret")
+	_AUTHENTICATOR = &authenticator{hmacKeys: map[string][]byte{"": secret}, userClaim: "sub",
+		rolesClaim: "roles", domain: "external", certMode: _CERT_NONE}
+
+	req, _ := http.NewRequest("GET", "/query/service", nil)
+	req, err := authenticate(req)
+	if err != nil || auth.RequestIdentity(req) != nil {
+		t.Errorf("Expected request without token to be left alone")
+	}
+
+	token := signToken(t, map[string]interface{}{"alg": "HS256"},
+		map[string]interface{}{"sub": "ann", "exp": time.Now().Add(time.Hour).Unix()}, secret)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req, err = authenticate(req)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if identity := auth.RequestIdentity(req); identity == nil || identity.User != "external:ann" {
+		t.Errorf("Unexpected identity %v", identity)
+	}
+
+	req, _ = http.NewRequest("GET", "/query/service", nil)
+	req.Header.Set("Authorization", "Bearer "+token+"x")
+	if _, err = authenticate(req); err == nil || err.Code() != 1190 {
+		t.Errorf("Expected authentication error, found %v", err)
+	}
+}
+
+func TestCertIdentity(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "joe"},
+		EmailAddresses: []string{"joe@example.com"},
+	}
+
+	a := &authenticator{certUser: "subject.cn", certDomain: "local"}
+	identity, err := a.certIdentity(cert)
+	if err != nil || identity.User != "local:joe" || identity.Method != "cert" {
+		t.Errorf("Unexpected identity %v, error %v", identity, err)
+	}
+
+	a.certUser = "san.email"
+	identity, err = a.certIdentity(cert)
+	if err != nil || identity.User != "local:joe@example.com" {
+		t.Errorf("Unexpected identity %v, error %v", identity, err)
+	}
+
+	a.certUser = "san.dns"
+	if _, err = a.certIdentity(cert); err == nil {
+		t.Errorf("Expected certificate without DNS names to be rejected")
+	}
+
+	if _, err := newAuthenticator(&AuthConfig{ClientCertMode: _CERT_MANDATORY}); err == nil {
+		t.Errorf("Expected mandatory client certificates without CA file to be rejected")
+	}
+	if _, err := newAuthenticator(&AuthConfig{ClientCertUser: "subject.o"}); err == nil {
+		t.Errorf("Expected invalid certificate user to be rejected")
+	}
+}
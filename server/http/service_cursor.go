@@ -0,0 +1,336 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	go_errors "errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/value"
+	"github.com/gorilla/mux"
+)
+
+const (
+	cursorPrefix = "/query/cursor"
+)
+
+// page size, if none is specified
+const _CURSOR_DEFAULT_COUNT = 100
+
+// Requests submitted with cursor=true return the first page of results
+// together with a cursor, from which further pages are fetched with
+// /query/cursor/{id}?count=N.
+// Between fetches the request is not consuming results, so the pipeline
+// is held by the backpressure of its value exchanges, rather than having
+// the results buffered. The cursor goes away when the results are exhausted,
+// when it is deleted, or when it is left idle for too long.
+// Since an open cursor holds on to its servicer, only a quarter of the
+// servicers can be used by cursors at any one time.
+type httpCursor struct {
+	request   *httpRequest
+	users     string
+	count     int
+	first     *cursorPage
+	fetches   chan *cursorPage
+	closed    chan bool
+	lookahead value.Value // first result of the next page
+	timer     *time.Timer
+}
+
+func newHttpCursor(request *httpRequest, count int) *httpCursor {
+	return &httpCursor{
+		request: request,
+		count:   count,
+		first:   newCursorPage(count),
+		fetches: make(chan *cursorPage),
+		closed:  make(chan bool),
+	}
+}
+
+func getCursorCount(param string) (int, errors.Error) {
+	if param == "" {
+		return _CURSOR_DEFAULT_COUNT, nil
+	}
+
+	count, e := strconv.Atoi(param)
+	if e != nil || count <= 0 {
+		return 0, errors.NewServiceErrorBadValue(go_errors.New("count is invalid"), COUNT)
+	}
+	return count, nil
+}
+
+// the number of cursors that can be open at the same time
+func (this *HttpEndpoint) cursorLimit() int32 {
+	limit := this.server.Servicers() / 4
+	if limit < 1 {
+		limit = 1
+	}
+	return int32(limit)
+}
+
+func (this *HttpEndpoint) serveCursor(request *httpRequest) {
+	id := request.Id().String()
+	cursor := request.cursor
+	cursor.users = datastore.CredsString(request.Credentials(), request.req)
+
+	limit := this.cursorLimit()
+	if atomic.AddInt32(&this.openCursors, 1) > limit {
+		atomic.AddInt32(&this.openCursors, -1)
+		writeAsyncError(request.resp, errors.NewServiceErrorCursorLimit(int(limit)))
+		return
+	}
+
+	// the first page is the response to the request itself,
+	// and no client is connected between fetches
+	request.writer = cursor.first
+	request.httpCloseNotify = nil
+
+	this.actives.Put(request)
+	if !this.enqueue(request) {
+		atomic.AddInt32(&this.openCursors, -1)
+		this.actives.Delete(id, false)
+		request.resp.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	this.cursors.Add(cursor, id, nil)
+
+	go func() {
+		<-request.CloseNotify()
+		close(cursor.closed)
+		this.cursors.Delete(id, nil)
+		atomic.AddInt32(&this.openCursors, -1)
+		this.actives.Delete(id, false)
+		this.doStats(request, this.server)
+	}()
+
+	cursor.servePage(request.resp, cursor.first)
+}
+
+func (this *HttpEndpoint) registerCursorHandlers() {
+	cursorHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.serveCursorFetch(w, req)
+	}
+
+	this.mux.HandleFunc(cursorPrefix+"/{id}", cursorHandler).
+		Methods("GET", "POST", "DELETE")
+}
+
+func (this *HttpEndpoint) serveCursorFetch(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+
+	creds, err := getCredentialsFromRequest(req)
+	if err == nil {
+		req, err = authenticate(req)
+	}
+	if err != nil {
+		writeAsyncError(w, err)
+		return
+	}
+
+	// only the owner can fetch from the cursor
+	var cursor *httpCursor
+	_ = this.cursors.Get(id, func(c interface{}) {
+		cursor = c.(*httpCursor)
+	})
+	if cursor == nil || cursor.users != datastore.CredsString(creds, req) {
+		writeAsyncError(w, errors.NewServiceErrorCursor(id))
+		return
+	}
+
+	switch req.Method {
+	case "DELETE":
+		this.actives.Delete(id, true)
+		writeJSON(w, http.StatusOK, true)
+	default:
+		count := cursor.count
+		if param := req.FormValue(COUNT); param != "" {
+			count, err = getCursorCount(param)
+			if err != nil {
+				writeAsyncError(w, err)
+				return
+			}
+		}
+
+		page := newCursorPage(count)
+		select {
+		case cursor.fetches <- page:
+			cursor.servePage(w, page)
+		case <-cursor.closed:
+			writeAsyncError(w, errors.NewServiceErrorCursor(id))
+		}
+	}
+}
+
+func (this *httpCursor) servePage(w http.ResponseWriter, page *cursorPage) {
+	<-page.done
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(page.buffer.Len()))
+	w.WriteHeader(this.request.httpCode())
+	w.Write(page.buffer.Bytes())
+}
+
+// wait for the next fetch
+// returns nil if the request is stopped or the cursor is left idle
+func (this *httpCursor) nextPage(idle time.Duration) *cursorPage {
+	if idle > 0 {
+		this.timer = time.AfterFunc(idle, func() {
+			this.request.Expire(server.TIMEOUT, idle)
+		})
+	}
+
+	var page *cursorPage
+	select {
+	case page = <-this.fetches:
+	case <-this.request.StopExecute():
+		this.request.SetState(server.STOPPED)
+	}
+
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+	return page
+}
+
+func (this *httpRequest) executeCursor(srvr *server.Server, signature value.Value) {
+	prefix, indent := this.prettyStrings(srvr.Pretty(), false)
+
+	page := this.cursor.first
+	more, stopped := false, false
+	for {
+		this.writer = page
+		this.pageStart = this.resultCount
+		this.setHttpCode(http.StatusOK)
+		this.writePrefix(srvr, signature, prefix, indent)
+		more, stopped = this.writeCursorResults(srvr.Pretty(), page.count)
+
+		this.markTimeOfCompletion()
+		if !more {
+			break
+		}
+
+		this.writeCursorSuffix(srvr, prefix, indent)
+		this.writer.noMoreData()
+
+		page = this.cursor.nextPage(srvr.CursorIdleTimeout())
+		if page == nil {
+			stopped = true
+			break
+		}
+	}
+
+	if page != nil {
+		state := this.State()
+		this.writeSuffix(srvr, state, prefix, indent)
+		this.writer.noMoreData()
+	}
+	if stopped {
+		this.Close()
+	} else {
+		this.stopAndClose(server.COMPLETED)
+	}
+}
+
+// returns true if there are more results to come, and
+// true if the request has already been stopped
+func (this *httpRequest) writeCursorResults(pretty bool, count int) (bool, bool) {
+	var buf bytes.Buffer
+
+	prefix, indent := this.prettyStrings(pretty, true)
+	for n := 0; ; n++ {
+		item := this.cursor.lookahead
+		this.cursor.lookahead = nil
+
+		if item == nil {
+			ok := true
+			select {
+			case item, ok = <-this.Results():
+				if this.Halted() {
+					return false, true
+				}
+			case <-this.StopExecute():
+				this.SetState(server.STOPPED)
+				return false, true
+			}
+
+			if !ok {
+				this.SetState(server.COMPLETED)
+				return false, false
+			}
+		}
+
+		// hold on to one result, so that the last page is known as such
+		if n == count {
+			this.cursor.lookahead = item
+			return true, false
+		}
+
+		if !this.writeResult(item, &buf, prefix, indent) {
+			return false, false
+		}
+	}
+}
+
+func (this *httpRequest) writeCursorSuffix(srvr *server.Server, prefix, indent string) bool {
+	return this.writeString("\n") && this.writeString(prefix) && this.writeString("]") &&
+		this.writeString(",\n") && this.writeString(prefix) &&
+		this.writeString("\"cursor\": \"") && this.writeString(cursorPrefix+"/"+this.Id().String()) &&
+		this.writeString("\"") &&
+		this.writeState(server.RUNNING, prefix) &&
+		this.writeMetrics(srvr.Metrics(), prefix, indent) &&
+		this.writeString("\n}\n")
+}
+
+// cursorPage is a responseDataManager that collects a page of results
+// for the fetch waiting on it
+type cursorPage struct {
+	sync.Mutex
+	count  int
+	buffer bytes.Buffer
+	closed bool
+	done   chan bool
+}
+
+func newCursorPage(count int) *cursorPage {
+	return &cursorPage{
+		count: count,
+		done:  make(chan bool),
+	}
+}
+
+func (this *cursorPage) writeString(s string) bool {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return false
+	}
+	_, err := this.buffer.WriteString(s)
+	return err == nil
+}
+
+func (this *cursorPage) noMoreData() {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return
+	}
+	this.closed = true
+	close(this.done)
+}
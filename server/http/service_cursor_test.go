@@ -0,0 +1,217 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/server"
+)
+
+type testRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (this testRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func newTestCursorRequest(params url.Values) *httpRequest {
+	req, _ := http.NewRequest("POST", "/query/service", strings.NewReader(params.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	return newHttpRequest(testRecorder{httptest.NewRecorder()}, req, NewSyncPool(1024), 1024)
+}
+
+func TestCursorRequest(t *testing.T) {
+	tests := []struct {
+		params url.Values
+		fatal  bool
+		count  int
+	}{
+		{url.Values{"statement": {"select 1"}}, false, 0},
+		{url.Values{"statement": {"select 1"}, "cursor": {"true"}}, false, _CURSOR_DEFAULT_COUNT},
+		{url.Values{"statement": {"select 1"}, "cursor": {"true"}, "count": {"5"}}, false, 5},
+		{url.Values{"statement": {"select 1"}, "cursor": {"true"}, "count": {"0"}}, true, 0},
+		{url.Values{"statement": {"select 1"}, "cursor": {"true"}, "mode": {"async"}}, true, 0},
+	}
+
+	for _, test := range tests {
+		request := newTestCursorRequest(test.params)
+		if fatal := request.State() == server.FATAL; fatal != test.fatal {
+			t.Errorf("%v: expected fatal %v, actual %v", test.params, test.fatal, fatal)
+			continue
+		}
+		if test.fatal {
+			continue
+		}
+
+		count := 0
+		if request.cursor != nil {
+			count = request.cursor.count
+		}
+		if count != test.count {
+			t.Errorf("%v: expected count %d, actual %d", test.params, test.count, count)
+		}
+	}
+}
+
+func openCursor(t *testing.T, ts *httptest.Server, count string) (int, map[string]interface{}) {
+	resp, err := http.PostForm(ts.URL+servicePrefix, url.Values{
+		"statement": {"SELECT RAW r FROM ARRAY_RANGE(0, 5) AS r"},
+		"cursor":    {"true"},
+		"count":     {count},
+	})
+	if err != nil {
+		t.Fatalf("Unable to open cursor: %v", err)
+	}
+	return resp.StatusCode, decodeCursorPage(t, resp)
+}
+
+func fetchCursor(t *testing.T, ts *httptest.Server, method, cursor string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest(method, ts.URL+cursor, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Unable to fetch cursor: %v", err)
+	}
+	return resp.StatusCode, decodeCursorPage(t, resp)
+}
+
+// errors and deletes may not have a page to decode
+func decodeCursorPage(t *testing.T, resp *http.Response) map[string]interface{} {
+	defer resp.Body.Close()
+	var page map[string]interface{}
+	err := json.NewDecoder(resp.Body).Decode(&page)
+	if err != nil && resp.StatusCode == http.StatusOK && resp.Request.Method != "DELETE" {
+		t.Fatalf("Unable to decode page: %v", err)
+	}
+	return page
+}
+
+func TestCursorFetch(t *testing.T) {
+	ts := httptest.NewServer(newTestEndpoint(t).mux)
+	defer ts.Close()
+
+	code, page := openCursor(t, ts, "2")
+	if code != http.StatusOK {
+		t.Fatalf("Expected %d, actual %d: %v", http.StatusOK, code, page)
+	}
+
+	var results []interface{}
+	var pages int
+	cursor, _ := page["cursor"].(string)
+	for {
+		pages++
+		results = append(results, page["results"].([]interface{})...)
+		next, _ := page["cursor"].(string)
+		if next == "" {
+			break
+		}
+		code, page = fetchCursor(t, ts, "GET", next)
+		if code != http.StatusOK {
+			t.Fatalf("Expected %d, actual %d: %v", http.StatusOK, code, page)
+		}
+	}
+
+	if pages != 3 || len(results) != 5 {
+		t.Errorf("Expected 5 results in 3 pages, actual %v in %d pages", results, pages)
+	}
+	if page["status"] != string(server.SUCCESS) {
+		t.Errorf("Expected status %v, actual %v", server.SUCCESS, page["status"])
+	}
+
+	// the cursor goes away once exhausted
+	time.Sleep(100 * time.Millisecond)
+	code, _ = fetchCursor(t, ts, "GET", cursor)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected %d for an exhausted cursor, actual %d", http.StatusNotFound, code)
+	}
+}
+
+func TestCursorExpiry(t *testing.T) {
+	endpoint := newTestEndpoint(t)
+	endpoint.server.SetCursorIdleTimeout(100 * time.Millisecond)
+	ts := httptest.NewServer(endpoint.mux)
+	defer ts.Close()
+
+	_, page := openCursor(t, ts, "1")
+	cursor, _ := page["cursor"].(string)
+	if cursor == "" {
+		t.Fatalf("Expected a cursor, actual %v", page)
+	}
+
+	// fetching would keep the cursor alive, so wait for it to go away
+	for i := 0; endpoint.cursors.Size() > 0; i++ {
+		if i == 50 {
+			t.Fatalf("Cursor did not expire")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	code, _ := fetchCursor(t, ts, "GET", cursor)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected %d for an expired cursor, actual %d", http.StatusNotFound, code)
+	}
+}
+
+func TestCursorClose(t *testing.T) {
+	ts := httptest.NewServer(newTestEndpoint(t).mux)
+	defer ts.Close()
+
+	_, page := openCursor(t, ts, "1")
+	cursor, _ := page["cursor"].(string)
+	if cursor == "" {
+		t.Fatalf("Expected a cursor, actual %v", page)
+	}
+
+	code, _ := fetchCursor(t, ts, "DELETE", cursor)
+	if code != http.StatusOK {
+		t.Fatalf("Expected %d, actual %d", http.StatusOK, code)
+	}
+	time.Sleep(100 * time.Millisecond)
+	code, _ = fetchCursor(t, ts, "GET", cursor)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected %d for a closed cursor, actual %d", http.StatusNotFound, code)
+	}
+}
+
+func TestCursorLimit(t *testing.T) {
+	ts := httptest.NewServer(newTestEndpoint(t).mux)
+	defer ts.Close()
+
+	// four servicers allow for a single cursor
+	_, page := openCursor(t, ts, "1")
+	cursor, _ := page["cursor"].(string)
+	if cursor == "" {
+		t.Fatalf("Expected a cursor, actual %v", page)
+	}
+
+	code, page := openCursor(t, ts, "1")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %d, actual %d: %v", http.StatusServiceUnavailable, code, page)
+	}
+
+	fetchCursor(t, ts, "DELETE", cursor)
+	for i := 0; ; i++ {
+		code, page = openCursor(t, ts, "10")
+		if code == http.StatusOK {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("Cursor limit not released: %d %v", code, page)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
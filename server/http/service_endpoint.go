@@ -17,6 +17,7 @@ import (
 	"github.com/couchbase/cbauth"
 	"github.com/couchbase/query/accounting"
 	"github.com/couchbase/query/audit"
+	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
@@ -38,6 +39,8 @@ type HttpEndpoint struct {
 	listenerTLS net.Listener
 	mux         *mux.Router
 	actives     server.ActiveRequests
+	cursors     *util.GenCache
+	openCursors int32
 	options     server.ServerOptions
 }
 
@@ -56,12 +59,19 @@ func NewServiceEndpoint(srv *server.Server, staticPath string, metrics bool,
 		keyFile:   keyFile,
 		bufpool:   NewSyncPool(srv.KeepAlive()),
 		actives:   NewActiveRequests(),
+		cursors:   util.NewGenCache(-1),
 		options:   NewHttpOptions(srv),
 	}
 
 	server.SetActives(rv.actives)
 	server.SetOptions(rv.options)
 
+	// Only settings kept by the configuration store itself are applied to
+	// the service; those from metakv are left to /admin/settings
+	if _, ok := srv.ConfigurationStore().(clustering.SettingsStore); ok {
+		server.SetSettingsProcessor(ProcessSettings)
+	}
+
 	rv.registerHandlers(staticPath)
 	return rv
 }
@@ -85,9 +95,11 @@ func (this *HttpEndpoint) ListenTLS() error {
 
 	ln, err := net.Listen("tcp", this.httpsAddr)
 	if err == nil {
+		clientAuth, clientCAs := clientAuthentication()
 		cfg := &tls.Config{
 			Certificates: []tls.Certificate{tlsCert},
-			ClientAuth:   tls.NoClientCert,
+			ClientAuth:   clientAuth,
+			ClientCAs:    clientCAs,
 			MinVersion:   cbauth.MinTLSVersion(),
 			CipherSuites: cbauth.CipherSuites(),
 			NextProtos:   []string{"h2", "http/1.1"},
@@ -105,6 +117,20 @@ func (this *HttpEndpoint) ListenTLS() error {
 func (this *HttpEndpoint) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	request := newHttpRequest(resp, req, this.bufpool, this.server.RequestSizeCap())
 
+	if request.async && request.State() != server.FATAL && this.serveAsync(request) {
+		return
+	}
+
+	if request.cursor != nil && request.State() != server.FATAL {
+		this.serveCursor(request)
+		return
+	}
+
+	this.serveRequest(request)
+}
+
+// Track and run the request, and wait until it exits.
+func (this *HttpEndpoint) serveRequest(request *httpRequest) {
 	this.actives.Put(request)
 	defer this.actives.Delete(request.Id().String(), false)
 
@@ -116,27 +142,26 @@ func (this *HttpEndpoint) ServeHTTP(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	if request.ScanConsistency() == datastore.UNBOUNDED {
-		select {
-		case this.server.Channel() <- request:
-			// Wait until the request exits.
-			<-request.CloseNotify()
-		default:
-			// Buffer is full.
-			resp.WriteHeader(http.StatusServiceUnavailable)
-		}
+	if this.enqueue(request) {
+		// Wait until the request exits.
+		<-request.CloseNotify()
 	} else {
-		select {
-		case this.server.PlusChannel() <- request:
-			// Wait until the request exits.
-			<-request.CloseNotify()
-		default:
-			// Buffer is full.
-			resp.WriteHeader(http.StatusServiceUnavailable)
-		}
+		// Buffer is full.
+		request.resp.WriteHeader(http.StatusServiceUnavailable)
 	}
 }
 
+// Queue the request for the servicers, subject to its workload class,
+// unless the buffer is full.
+func (this *HttpEndpoint) enqueue(request *httpRequest) bool {
+	channel := this.server.Channel()
+	if request.ScanConsistency() != datastore.UNBOUNDED {
+		channel = this.server.PlusChannel()
+	}
+
+	return this.server.Submit(request, channel)
+}
+
 func (this *HttpEndpoint) Close() error {
 	return this.closeListener(this.listener)
 }
@@ -164,8 +189,12 @@ func (this *HttpEndpoint) registerHandlers(staticPath string) {
 	this.mux.Handle("/query", this).
 		Methods("GET", "POST")
 
+	this.registerAsyncHandlers()
+	this.registerCursorHandlers()
 	this.registerClusterHandlers()
 	this.registerAccountingHandlers()
+	this.registerMetricsHandlers()
+	this.registerWebSocketHandlers()
 	this.registerStaticHandlers(staticPath)
 }
 
@@ -190,6 +219,8 @@ func (this *HttpEndpoint) doStats(request *httpRequest, srvr *server.Server) {
 		request.resultSize, request.errorCount, request.warningCount, request.Type(),
 		prepared, (request.State() != server.COMPLETED),
 		string(request.ScanConsistency()))
+	accounting.RecordRequestMetrics(acctstore, request.Type(), request.Keyspaces(),
+		request_time, request.PhaseTimes())
 
 	request.CompleteRequest(request_time, service_time, request.resultCount,
 		request.resultSize, request.errorCount, request.req, srvr)
@@ -212,12 +243,19 @@ func NewActiveRequests() server.ActiveRequests {
 	}
 }
 
+// requests served by other protocols, such as the PostgreSQL
+// wire protocol, are tracked alongside http requests
+type stoppableRequest interface {
+	server.Request
+	Stop(server.State)
+}
+
 func (this *activeHttpRequests) Put(req server.Request) errors.Error {
-	http_req, is_http := req.(*httpRequest)
-	if !is_http {
+	active_req, is_stoppable := req.(stoppableRequest)
+	if !is_stoppable {
 		return errors.NewServiceErrorHttpReq(req.Id().String())
 	}
-	this.cache.Add(http_req, http_req.Id().String(), nil)
+	this.cache.Add(active_req, active_req.Id().String(), nil)
 	return nil
 }
 
@@ -226,7 +264,7 @@ func (this *activeHttpRequests) Get(id string, f func(server.Request)) errors.Er
 
 	if f != nil {
 		dummyF = func(e interface{}) {
-			r := e.(*httpRequest)
+			r := e.(stoppableRequest)
 			f(r)
 		}
 	}
@@ -237,7 +275,7 @@ func (this *activeHttpRequests) Get(id string, f func(server.Request)) errors.Er
 func (this *activeHttpRequests) Delete(id string, stop bool) bool {
 	this.cache.Delete(id, func(e interface{}) {
 		if stop {
-			req := e.(*httpRequest)
+			req := e.(stoppableRequest)
 			req.Stop(server.STOPPED)
 		}
 	})
@@ -251,7 +289,7 @@ func (this *activeHttpRequests) Count() (int, errors.Error) {
 
 func (this *activeHttpRequests) ForEach(nonBlocking func(string, server.Request) bool, blocking func() bool) {
 	dummyF := func(id string, r interface{}) bool {
-		return nonBlocking(id, r.(*httpRequest))
+		return nonBlocking(id, r.(stoppableRequest))
 	}
 	this.cache.ForEach(dummyF, blocking)
 }
@@ -0,0 +1,76 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/value"
+)
+
+// ndjson responses have one result per line, followed by a status
+// line holding what follows the results in a json response
+
+func (this *httpRequest) executeNdjson(srvr *server.Server, signature value.Value) {
+	this.setHttpCode(http.StatusOK)
+	stopped := this.writeResults(false)
+
+	this.markTimeOfCompletion()
+
+	this.writeStatusLine(srvr, this.State(), signature)
+	this.writer.noMoreData()
+	if stopped {
+		this.Close()
+	} else {
+		this.stopAndClose(server.COMPLETED)
+	}
+}
+
+func (this *httpRequest) failedNdjson(srvr *server.Server) {
+	this.markTimeOfCompletion()
+	this.writeStatusLine(srvr, "", nil)
+	this.writer.noMoreData()
+}
+
+func (this *httpRequest) writeStatusLine(srvr *server.Server, state server.State, signature value.Value) bool {
+	writer := this.writer
+	this.writer = &lineWriter{writer: writer}
+
+	rv := this.writeString("{") &&
+		this.writeRequestID("") &&
+		this.writeClientContextID("") &&
+		(signature == nil || this.writeSignature(srvr.Signature(), signature, "", "")) &&
+		this.writeErrors("", "") &&
+		this.writeWarnings("", "") &&
+		this.writeState(state, "") &&
+		this.writeMetrics(srvr.Metrics(), "", "") &&
+		this.writeProfile(srvr.Profile(), "", "") &&
+		this.writeControls(srvr.Controls(), "", "") &&
+		this.writeString("}")
+
+	this.writer = writer
+	return rv && this.writeString("\n")
+}
+
+// lineWriter keeps the status line on a single line: with no pretty
+// printing, the only line breaks in a response separate its fields
+type lineWriter struct {
+	writer responseDataManager
+}
+
+func (this *lineWriter) writeString(s string) bool {
+	return this.writer.writeString(strings.Replace(s, "\n", "", -1))
+}
+
+func (this *lineWriter) noMoreData() {
+	this.writer.noMoreData()
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/server"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
@@ -39,7 +40,13 @@ type httpRequest struct {
 	req             *http.Request
 	httpCloseNotify <-chan bool
 	writer          responseDataManager
+	spool           *asyncSpool
+	async           bool
+	ttl             time.Duration
+	cursor          *httpCursor
+	pageStart       int // results written before the current cursor page
 	httpRespCode    int
+	format          Format
 	resultCount     int
 	resultSize      int
 	errorCount      int
@@ -206,7 +213,7 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		format, err = getFormat(httpArgs)
 	}
 
-	if err == nil && format != JSON {
+	if err == nil && format != JSON && format != NDJSON {
 		err = errors.NewServiceErrorNotImplemented("format", format.String())
 	}
 
@@ -238,6 +245,49 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		pretty, err = httpArgs.getTristate(PRETTY)
 	}
 
+	var async bool
+	if err == nil {
+		async, err = getAsyncMode(httpArgs)
+	}
+
+	var ttl time.Duration
+	if err == nil && async {
+		ttl, err = httpArgs.getDuration(TTL)
+		if err == nil && ttl <= 0 {
+			ttl = server.AsyncTTL()
+		}
+
+		// async responses are spooled one result per line
+		pretty = value.FALSE
+	}
+
+	var cursor value.Tristate
+	if err == nil {
+		cursor, err = httpArgs.getTristate(CURSOR)
+		if err == nil && cursor == value.TRUE && async {
+			err = errors.NewServiceErrorBadValue(go_errors.New("cursors cannot be opened in async mode"), CURSOR)
+		}
+	}
+
+	if err == nil && format == NDJSON {
+		if async || cursor == value.TRUE {
+			err = errors.NewServiceErrorBadValue(go_errors.New("ndjson responses cannot be async or use cursors"), FORMAT)
+		} else {
+			resp.Header().Set("Content-Type", ndjsonType)
+
+			// one result per line
+			pretty = value.FALSE
+		}
+	}
+
+	var count int
+	if err == nil && cursor == value.TRUE {
+		param, err = httpArgs.getString(COUNT, "")
+		if err == nil {
+			count, err = getCursorCount(param)
+		}
+	}
+
 	var consistency *scanConfigImpl
 
 	if err == nil {
@@ -249,6 +299,10 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		creds, err = getCredentials(httpArgs, req.Header["Authorization"])
 	}
 
+	if err == nil {
+		req, err = authenticate(req)
+	}
+
 	client_id := ""
 	if err == nil {
 		client_id, err = getClientID(httpArgs)
@@ -259,14 +313,25 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		controls, err = getControlsRequest(httpArgs)
 	}
 
+	var workloadClass string
+	if err == nil {
+		workloadClass, err = httpArgs.getString(WORKLOAD_CLASS, "")
+	}
+
 	userAgent := req.UserAgent()
 	cbUserAgent := req.Header.Get("CB-User-Agent")
 	if cbUserAgent != "" {
 		userAgent = userAgent + " (" + cbUserAgent + ")"
 	}
 	rv := &httpRequest{
-		resp: resp,
-		req:  req,
+		resp:   resp,
+		req:    req,
+		async:  async,
+		ttl:    ttl,
+		format: format,
+	}
+	if cursor == value.TRUE {
+		rv.cursor = newHttpCursor(rv, count)
 	}
 
 	server.NewBaseRequest(&rv.BaseRequest, statement, prepared, namedArgs, positionalArgs,
@@ -274,6 +339,13 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		readonly, metrics, signature, pretty, consistency, client_id, creds,
 		req.RemoteAddr, userAgent)
 
+	if workloadClass != "" {
+		rv.SetWorkloadClass(workloadClass)
+	}
+
+	// continue the caller's trace, if any
+	rv.SetTraceParent(req.Header.Get(tracing.TRACEPARENT))
+
 	var prof server.Profile
 	if err == nil {
 		rv.SetControls(controls)
@@ -321,6 +393,16 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 	return rv
 }
 
+// For audit.Auditable interface.
+// Users authenticated by token or certificate carry no password.
+func (this *httpRequest) EventUsers() []string {
+	users := this.BaseRequest.EventUsers()
+	if identity := auth.RequestIdentity(this.req); identity != nil {
+		users = append(users, identity.User)
+	}
+	return users
+}
+
 // For audit.Auditable interface.
 func (this *httpRequest) ElapsedTime() time.Duration {
 	return this.elapsedTime
@@ -398,6 +480,9 @@ const ( // Request argument names
 	CONTROLS          = "controls"
 	N1QL_FEAT_CTRL    = "n1ql_feat_ctrl"
 	MAX_INDEX_API     = "max_index_api"
+	MODE              = "mode"
+	TTL               = "ttl"
+	WORKLOAD_CLASS    = "workload_class"
 )
 
 var _PARAMETERS = []string{
@@ -428,6 +513,11 @@ var _PARAMETERS = []string{
 	CONTROLS,
 	N1QL_FEAT_CTRL,
 	MAX_INDEX_API,
+	MODE,
+	TTL,
+	CURSOR,
+	COUNT,
+	WORKLOAD_CLASS,
 }
 
 func isValidParameter(a string) bool {
@@ -566,6 +656,22 @@ func getFormat(a httpRequestArgs) (Format, errors.Error) {
 	return format, err
 }
 
+func getAsyncMode(a httpRequestArgs) (bool, errors.Error) {
+	mode, err := a.getString(MODE, "")
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(mode) {
+	case "", "sync":
+		return false, nil
+	case "async":
+		return true, nil
+	default:
+		return false, errors.NewServiceErrorUnrecognizedValue(MODE, mode)
+	}
+}
+
 func getReadonly(a httpRequestArgs, isGet bool) (value.Tristate, errors.Error) {
 	readonly, err := a.getTristate(READONLY)
 	if err == nil && isGet {
@@ -682,6 +788,7 @@ func getProfile(a httpRequestArgs) (server.Profile, errors.Error) {
 const acceptType = "application/json"
 const versionTag = "version="
 const version = acceptType + "; " + versionTag + util.VERSION
+const ndjsonType = "application/x-ndjson"
 
 func contentNegotiation(resp http.ResponseWriter, req *http.Request) errors.Error {
 	// set content type to current version
@@ -692,6 +799,10 @@ func contentNegotiation(resp http.ResponseWriter, req *http.Request) errors.Erro
 		return nil
 	}
 	desiredContent := accept[0]
+	// newline delimited results are requested through the format
+	if strings.HasPrefix(desiredContent, ndjsonType) {
+		return nil
+	}
 	// media type must be application/json at least
 	if !strings.HasPrefix(desiredContent, acceptType) {
 		return errors.NewServiceErrorMediaType(desiredContent)
@@ -1255,6 +1366,7 @@ const (
 	XML
 	CSV
 	TSV
+	NDJSON
 	UNDEFINED_FORMAT
 )
 
@@ -1268,6 +1380,8 @@ func newFormat(s string) Format {
 		return CSV
 	case "TSV":
 		return TSV
+	case "NDJSON":
+		return NDJSON
 	default:
 		return UNDEFINED_FORMAT
 	}
@@ -1284,6 +1398,8 @@ func (f Format) String() string {
 		s = "CSV"
 	case TSV:
 		s = "TSV"
+	case NDJSON:
+		s = "NDJSON"
 	default:
 		s = "UNDEFINED_FORMAT"
 	}
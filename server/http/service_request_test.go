@@ -21,12 +21,14 @@ import (
 	"testing"
 	"time"
 
+	acct_resolver "github.com/couchbase/query/accounting/resolver"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/datastore/resolver"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 
 	log_resolver "github.com/couchbase/query/logging/resolver"
@@ -237,6 +239,29 @@ func TestRequestDefaults(t *testing.T) {
 	}
 }
 
+func TestRequestNdjson(t *testing.T) {
+	tests := []struct {
+		params url.Values
+		fatal  bool
+	}{
+		{url.Values{"statement": {"select 1"}, "format": {"ndjson"}}, false},
+		{url.Values{"statement": {"select 1"}, "format": {"ndjson"}, "async": {"true"}}, true},
+		{url.Values{"statement": {"select 1"}, "format": {"ndjson"}, "cursor": {"true"}}, true},
+		{url.Values{"statement": {"select 1"}, "format": {"xml"}}, true},
+	}
+
+	for _, test := range tests {
+		request := newTestCursorRequest(test.params)
+		fatal := request.State() == server.FATAL
+		if fatal != test.fatal {
+			t.Errorf("%v: expected fatal %v, found %v", test.params, test.fatal, fatal)
+		}
+		if !fatal && request.format != NDJSON {
+			t.Errorf("%v: expected ndjson format, found %v", test.params, request.format)
+		}
+	}
+}
+
 func TestRequestWithTimeout(t *testing.T) {
 	request_timeout := "100ms"
 	expected_timeout := time.Millisecond * 100
@@ -256,6 +281,26 @@ func TestRequestWithTimeout(t *testing.T) {
 	}
 }
 
+func TestRequestWithProfile(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select 1",
+		"profile":   "timings",
+		"controls":  true,
+	}
+
+	_, err := doJsonEncodedPost(payload)
+	if err != nil {
+		t.Errorf("Unexpected error in HTTP request: %v", err)
+	}
+
+	if test_server.request().Profile() != server.ProfOn {
+		t.Errorf("Expected profile: %v, actual: %v\n", server.ProfOn, test_server.request().Profile())
+	}
+	if test_server.request().Controls() != value.TRUE {
+		t.Errorf("Expected controls: %v, actual: %v\n", value.TRUE, test_server.request().Controls())
+	}
+}
+
 func TestPrepareStatements(t *testing.T) {
 	preparedSequence(t, "doSelect", "SELECT b FROM p0:b0 LIMIT 5")
 	preparedSequence(t, "doInsert", "INSERT INTO p0:b0 VALUES ($1, $2)")
@@ -391,6 +436,36 @@ func makeMockServer() *server.Server {
 	return server
 }
 
+// an endpoint serving requests end to end, on a server of its own
+func newTestEndpoint(t *testing.T) *HttpEndpoint {
+	acctstore, err := acct_resolver.NewAcctstore("stub:")
+	if err != nil {
+		t.Fatalf("Unable to create accounting store %v", err)
+	}
+	channel := make(server.RequestChannel, 10)
+	plusChannel := make(server.RequestChannel, 10)
+	srv, err := server.NewServer(test_server.query_server.Datastore(), nil, nil, acctstore, "default",
+		false, channel, plusChannel, 4, 4, 0, 0, false, false, false, false, server.ProfOff, false)
+	if err != nil {
+		t.Fatalf("Unable to create server %v", err)
+	}
+	srv.SetKeepAlive(1 << 10)
+	srv.SetRequestSizeCap(server.MAX_REQUEST_SIZE)
+	server.RequestsInit(0, 0)
+	go srv.Serve()
+	go srv.PlusServe()
+
+	rv := &HttpEndpoint{
+		server:  srv,
+		bufpool: NewSyncPool(srv.KeepAlive()),
+		actives: NewActiveRequests(),
+		cursors: util.NewGenCache(-1),
+		options: NewHttpOptions(srv),
+	}
+	rv.registerHandlers("")
+	return rv
+}
+
 func (this *testServer) testHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		this.query_request = newHttpRequest(w, r, NewSyncPool(1024), 1024)
@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"sync"
@@ -44,7 +45,8 @@ func (this *httpRequest) Fail(err errors.Error) {
 	// Determine the appropriate http response code based on the error
 	httpRespCode := mapErrorToHttpResponse(err, http.StatusInternalServerError)
 	this.setHttpCode(httpRespCode)
-	// Put the error on the errors channel
+	// Put the error on the errors channel, and keep it for completed requests
+	this.KeepError(err)
 	this.Errors() <- err
 }
 
@@ -70,6 +72,22 @@ func mapErrorToHttpResponse(err errors.Error, def int) int {
 		return http.StatusBadRequest
 	case 1120:
 		return http.StatusNotAcceptable
+	case 1180: // too many async requests
+		return http.StatusServiceUnavailable
+	case 1182: // no such async handle
+		return http.StatusNotFound
+	case 1183: // no such cursor
+		return http.StatusNotFound
+	case 1184: // no such workload class
+		return http.StatusBadRequest
+	case 1185: // rate limited
+		return http.StatusTooManyRequests
+	case 1190: // bad token or certificate
+		return http.StatusUnauthorized
+	case 1191: // websocket from another site
+		return http.StatusForbidden
+	case 1192: // too many open cursors
+		return http.StatusServiceUnavailable
 	case 3000: // parse error range
 		return http.StatusBadRequest
 	case 4000, errors.NO_SUCH_PREPARED: // plan error range
@@ -85,6 +103,20 @@ func mapErrorToHttpResponse(err errors.Error, def int) int {
 	}
 }
 
+// tell clients that are turned away when to come back
+// async and cursor responses are no longer written to the original
+// ResponseWriter, by the time errors come in
+func (this *httpRequest) setRetryAfter(err errors.Error) {
+	if this.resp == nil || this.spool != nil || this.cursor != nil {
+		return
+	}
+	retry, ok := err.Cause().(errors.RetryAfter)
+	if ok {
+		secs := int(math.Ceil(time.Duration(retry).Seconds()))
+		this.resp.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+}
+
 func (this *httpRequest) httpCode() int {
 	this.RLock()
 	defer this.RUnlock()
@@ -100,6 +132,11 @@ func (this *httpRequest) setHttpCode(httpRespCode int) {
 func (this *httpRequest) Failed(srvr *server.Server) {
 	defer this.stopAndClose(server.FATAL)
 
+	if this.format == NDJSON {
+		this.failedNdjson(srvr)
+		return
+	}
+
 	prefix, indent := this.prettyStrings(srvr.Pretty(), false)
 	this.writeString("{\n")
 	this.writeRequestID(prefix)
@@ -125,6 +162,15 @@ func (this *httpRequest) markTimeOfCompletion() {
 func (this *httpRequest) Execute(srvr *server.Server, signature value.Value, stopNotify execution.Operator) {
 	this.NotifyStop(stopNotify)
 
+	if this.cursor != nil {
+		this.executeCursor(srvr, signature)
+		return
+	}
+	if this.format == NDJSON {
+		this.executeNdjson(srvr, signature)
+		return
+	}
+
 	prefix, indent := this.prettyStrings(srvr.Pretty(), false)
 
 	this.setHttpCode(http.StatusOK)
@@ -250,16 +296,27 @@ func (this *httpRequest) writeResult(item value.Value, buf *bytes.Buffer, prefix
 		return false
 	}
 
-	if this.resultCount == 0 {
+	switch {
+	case this.format == NDJSON:
+		// results are terminated, rather than separated
+		success = true
+	case this.resultCount == this.pageStart:
 		success = this.writeString("\n")
-	} else {
+	default:
 		success = this.writeString(",\n")
 	}
 
 	if success {
+		if this.spool != nil {
+			this.spool.markResult()
+		}
 		success = this.writeString(prefix) && this.writeString(buf.String())
 	}
 
+	if success && this.format == NDJSON {
+		success = this.writeString("\n")
+	}
+
 	if success {
 		this.resultSize += len(buf.Bytes())
 		this.resultCount++
@@ -286,6 +343,9 @@ func (this *httpRequest) writeValue(item value.Value, prefix, indent string) boo
 }
 
 func (this *httpRequest) writeSuffix(srvr *server.Server, state server.State, prefix, indent string) bool {
+	if this.spool != nil {
+		this.spool.markSuffix()
+	}
 	return this.writeString("\n") && this.writeString(prefix) && this.writeString("]") &&
 		this.writeErrors(prefix, indent) &&
 		this.writeWarnings(prefix, indent) &&
@@ -338,6 +398,7 @@ loop:
 					if this.State() != server.FATAL {
 						this.setHttpCode(mapErrorToHttpResponse(err, http.StatusOK))
 					}
+					this.setRetryAfter(err)
 				}
 				ok = this.writeError(err, this.errorCount, prefix, indent)
 				this.errorCount++
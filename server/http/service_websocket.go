@@ -0,0 +1,511 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	go_errors "errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/server"
+)
+
+// A websocket connection takes statements as JSON messages, with
+// the same parameters as service requests, and runs them in order.
+// The response to each statement is sent in ndjson format, one
+// message per line: one per result, then the status line.
+// A {"cancel": true} message stops the statement being executed,
+// and so does {"cancel": "<id>"} if the request id or client
+// context id of the statement matches.
+// Since statements carry the credentials of the handshake, browsers
+// may only open websockets from the server's own origin, or from
+// those allowed with SetWebSocketOrigins.
+
+const (
+	websocketPrefix = "/query/websocket"
+)
+
+const (
+	_WS_GUID  = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	_WS_QUEUE = 64 // statements waiting to run on a connection
+)
+
+// how long an idle connection is kept open, and how long a frame may
+// take to arrive
+var websocketIdleTimeout = 5 * time.Minute
+
+// origins, besides the server's own, that may open websockets
+var websocketOrigins []string
+
+func SetWebSocketOrigins(origins []string) {
+	websocketOrigins = origins
+}
+
+var errWsIdle = go_errors.New("websocket idle")
+
+// frame opcodes
+const (
+	_WS_CONTINUATION = 0x0
+	_WS_TEXT         = 0x1
+	_WS_BINARY       = 0x2
+	_WS_CLOSE        = 0x8
+	_WS_PING         = 0x9
+	_WS_PONG         = 0xA
+)
+
+func (this *HttpEndpoint) registerWebSocketHandlers() {
+	websocketHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.serveWebSocket(w, req)
+	}
+	this.mux.HandleFunc(websocketPrefix, websocketHandler).Methods("GET")
+}
+
+func (this *HttpEndpoint) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	err := checkOrigin(req)
+	if err != nil {
+		writeAsyncError(w, err)
+		return
+	}
+	conn, err := upgradeWebSocket(w, req)
+	if err != nil {
+		if conn == nil {
+			writeAsyncError(w, err)
+		}
+		return
+	}
+
+	session := &wsSession{
+		endpoint:   this,
+		conn:       conn,
+		upgrade:    req,
+		statements: make(chan []byte, _WS_QUEUE),
+	}
+	go session.run()
+	session.read()
+}
+
+type wsSession struct {
+	sync.Mutex
+	endpoint   *HttpEndpoint
+	conn       *wsConn
+	upgrade    *http.Request
+	statements chan []byte
+	active     *httpRequest
+	pending    int // statements queued or running
+}
+
+// reads statements and cancellations until the connection closes
+func (this *wsSession) read() {
+	defer this.conn.close()
+	defer close(this.statements)
+
+	for {
+		msg, err := this.conn.readMessage(this.endpoint.server.RequestSizeCap())
+
+		// statements may take longer than the idle timeout
+		if err == errWsIdle && this.busy() {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				logging.Debugp("Websocket: read failed", logging.Pair{"remoteAddr", this.upgrade.RemoteAddr},
+					logging.Pair{"error", err})
+			}
+			return
+		}
+
+		// malformed messages go through, and fail as requests
+		var args map[string]interface{}
+		if json.Unmarshal(msg, &args) == nil {
+			if id, ok := args["cancel"]; ok {
+				this.cancel(id)
+				continue
+			}
+			args[FORMAT] = NDJSON.String()
+			msg, _ = json.Marshal(args)
+		}
+
+		this.addPending(1)
+		select {
+		case this.statements <- msg:
+		default:
+			this.addPending(-1)
+			this.conn.writeStatus(errors.NewServiceErrorUnavailable())
+		}
+	}
+}
+
+// runs the statements in the order they were received
+func (this *wsSession) run() {
+	for msg := range this.statements {
+		select {
+		case <-this.conn.closeNotify:
+			return
+		default:
+		}
+
+		resp := &wsResponse{conn: this.conn, header: make(http.Header)}
+		request := newHttpRequest(resp, this.newRequest(msg), this.endpoint.bufpool,
+			this.endpoint.server.RequestSizeCap())
+		request.format = NDJSON
+
+		this.setActive(request)
+		this.endpoint.serveRequest(request)
+		this.setActive(nil)
+		this.addPending(-1)
+
+		if resp.status == http.StatusServiceUnavailable && !resp.written {
+			this.conn.writeStatus(errors.NewServiceErrorUnavailable())
+		}
+	}
+}
+
+func (this *wsSession) addPending(n int) {
+	this.Lock()
+	this.pending += n
+	this.Unlock()
+}
+
+func (this *wsSession) busy() bool {
+	this.Lock()
+	defer this.Unlock()
+	return this.pending > 0
+}
+
+// each statement is handled as a service request carrying the
+// headers of the websocket handshake, credentials included
+func (this *wsSession) newRequest(msg []byte) *http.Request {
+	req, _ := http.NewRequest("POST", websocketPrefix, bytes.NewReader(msg))
+	for k, v := range this.upgrade.Header {
+		req.Header[k] = v
+	}
+	req.Header.Del("Accept")
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = this.upgrade.RemoteAddr
+	req.TLS = this.upgrade.TLS
+	return req
+}
+
+func (this *wsSession) setActive(request *httpRequest) {
+	this.Lock()
+	this.active = request
+	this.Unlock()
+}
+
+func (this *wsSession) cancel(id interface{}) {
+	this.Lock()
+	request := this.active
+	this.Unlock()
+	if request == nil {
+		return
+	}
+
+	switch id := id.(type) {
+	case bool:
+		if !id {
+			return
+		}
+	case string:
+		if id != request.Id().String() && id != request.ClientID().String() {
+			return
+		}
+	default:
+		return
+	}
+	this.endpoint.actives.Delete(request.Id().String(), true)
+}
+
+// wsResponse is the http.ResponseWriter of the statements of a
+// websocket connection: every line written is sent as a text message
+type wsResponse struct {
+	conn    *wsConn
+	header  http.Header
+	status  int
+	line    bytes.Buffer
+	written bool
+}
+
+func (this *wsResponse) Header() http.Header {
+	return this.header
+}
+
+func (this *wsResponse) WriteHeader(status int) {
+	this.status = status
+}
+
+func (this *wsResponse) Write(b []byte) (int, error) {
+	n := len(b)
+	this.written = this.written || n > 0
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			this.line.Write(b)
+			break
+		}
+		this.line.Write(b[:i])
+		err := this.conn.writeFrame(_WS_TEXT, this.line.Bytes())
+		this.line.Reset()
+		if err != nil {
+			return 0, err
+		}
+		b = b[i+1:]
+	}
+	return n, nil
+}
+
+func (this *wsResponse) Flush() {
+}
+
+func (this *wsResponse) CloseNotify() <-chan bool {
+	return this.conn.closeNotify
+}
+
+// wsConn implements the framing of RFC 6455 over a hijacked connection
+type wsConn struct {
+	sync.Mutex  // serializes writes
+	conn        net.Conn
+	rw          *bufio.ReadWriter
+	closeNotify chan bool
+	closeOnce   sync.Once
+}
+
+func newWsConn(conn net.Conn, rw *bufio.ReadWriter) *wsConn {
+	return &wsConn{
+		conn:        conn,
+		rw:          rw,
+		closeNotify: make(chan bool),
+	}
+}
+
+// Browsers always send the origin of the page opening a websocket,
+// which must be the server itself or an allowed origin: otherwise any
+// page could run statements with the user's cookies.
+// Other clients need not send one.
+func checkOrigin(req *http.Request) errors.Error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err == nil && u.Host != "" && strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	for _, allowed := range websocketOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return errors.NewServiceErrorOrigin(origin)
+}
+
+// A nil connection and an error mean the handshake was refused;
+// the error is to be reported to the client.
+func upgradeWebSocket(w http.ResponseWriter, req *http.Request) (*wsConn, errors.Error) {
+	if !headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") {
+		return nil, errors.NewServiceErrorBadValue(go_errors.New("not a websocket handshake"), "websocket upgrade")
+	}
+	if req.Header.Get("Sec-Websocket-Version") != "13" {
+		return nil, errors.NewServiceErrorBadValue(go_errors.New("unsupported websocket version"), "websocket upgrade")
+	}
+	key := req.Header.Get("Sec-Websocket-Key")
+	if key == "" {
+		return nil, errors.NewServiceErrorMissingValue("Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.NewServiceErrorBadValue(go_errors.New("connection cannot be upgraded"), "websocket upgrade")
+	}
+
+	conn, rw, e := hj.Hijack()
+	if e != nil {
+		return nil, errors.NewServiceErrorBadValue(e, "websocket upgrade")
+	}
+	rv := newWsConn(conn, rw)
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	e = rw.Flush()
+	if e != nil {
+		rv.close()
+		return rv, errors.NewServiceErrorBadValue(e, "websocket upgrade")
+	}
+	return rv, nil
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + _WS_GUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (this *wsConn) close() {
+	this.closeOnce.Do(func() {
+		close(this.closeNotify)
+		this.conn.Close()
+	})
+}
+
+// returns the next data message, answering control frames on the way
+func (this *wsConn) readMessage(sizeCap int) ([]byte, error) {
+	var message []byte
+
+	for {
+		opcode, fin, payload, err := this.readFrame(sizeCap)
+
+		// only a connection idle between messages can be kept open
+		if err == errWsIdle && len(message) > 0 {
+			err = go_errors.New("websocket message timed out")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case _WS_PING:
+			err = this.writeFrame(_WS_PONG, payload)
+		case _WS_PONG:
+		case _WS_CLOSE:
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			this.writeFrame(_WS_CLOSE, payload)
+			return nil, io.EOF
+		case _WS_TEXT, _WS_BINARY, _WS_CONTINUATION:
+			message = append(message, payload...)
+			if sizeCap > 0 && len(message) > sizeCap {
+				return nil, go_errors.New("websocket message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, go_errors.New("unknown websocket opcode")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (this *wsConn) readFrame(sizeCap int) (byte, bool, []byte, error) {
+	var head [2]byte
+	var mask [4]byte
+
+	// the whole frame must arrive before the deadline
+	this.conn.SetReadDeadline(time.Now().Add(websocketIdleTimeout))
+	n, err := io.ReadFull(this.rw, head[:])
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() && n == 0 {
+			return 0, false, nil, errWsIdle
+		}
+		return 0, false, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0f
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(this.rw, ext[:])
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(this.rw, ext[:])
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	// clients must mask their frames
+	if head[1]&0x80 == 0 {
+		return 0, false, nil, go_errors.New("unmasked websocket frame")
+	}
+	if sizeCap > 0 && size > uint64(sizeCap) {
+		return 0, false, nil, go_errors.New("websocket frame too large")
+	}
+	_, err = io.ReadFull(this.rw, mask[:])
+	if err != nil {
+		return 0, false, nil, err
+	}
+	payload := make([]byte, size)
+	_, err = io.ReadFull(this.rw, payload)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, fin, payload, nil
+}
+
+func (this *wsConn) writeFrame(opcode byte, payload []byte) error {
+	var head [10]byte
+
+	this.Lock()
+	defer this.Unlock()
+
+	head[0] = 0x80 | opcode
+	n := 2
+	l := len(payload)
+	switch {
+	case l < 126:
+		head[1] = byte(l)
+	case l <= 0xffff:
+		head[1] = 126
+		binary.BigEndian.PutUint16(head[2:], uint16(l))
+		n = 4
+	default:
+		head[1] = 127
+		binary.BigEndian.PutUint64(head[2:], uint64(l))
+		n = 10
+	}
+	this.rw.Write(head[:n])
+	this.rw.Write(payload)
+	return this.rw.Flush()
+}
+
+// status line for statements that could not be turned into requests
+func (this *wsConn) writeStatus(err errors.Error) error {
+	status := map[string]interface{}{
+		"errors": []interface{}{map[string]interface{}{
+			"code": err.Code(),
+			"msg":  err.Error(),
+		}},
+		"status": server.FATAL,
+	}
+	b, _ := json.Marshal(status)
+	return this.writeFrame(_WS_TEXT, b)
+}
@@ -0,0 +1,328 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/server"
+)
+
+func TestWebSocketAccept(t *testing.T) {
+	// the sample handshake of RFC 6455
+	accept := websocketAccept("dGhlIHNhbXBsZSBub25jZQ==")
+	if accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %v", accept)
+	}
+}
+
+// client frames are masked
+func writeClientFrame(w io.Writer, opcode byte, fin bool, payload []byte) {
+	var head bytes.Buffer
+
+	b := opcode
+	if fin {
+		b |= 0x80
+	}
+	head.WriteByte(b)
+	switch l := len(payload); {
+	case l < 126:
+		head.WriteByte(0x80 | byte(l))
+	default:
+		head.WriteByte(0x80 | 126)
+		binary.Write(&head, binary.BigEndian, uint16(l))
+	}
+	mask := []byte{1, 2, 3, 4}
+	head.Write(mask)
+	for i, c := range payload {
+		head.WriteByte(c ^ mask[i%4])
+	}
+	w.Write(head.Bytes())
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := newWsConn(server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)))
+	defer conn.close()
+	reader := bufio.NewReader(client)
+
+	large := strings.Repeat("x", 300)
+	go func() {
+		writeClientFrame(client, _WS_TEXT, false, []byte(`{"statement":`))
+		writeClientFrame(client, _WS_PING, true, []byte("ping"))
+		writeClientFrame(client, _WS_CONTINUATION, true, []byte(`"select 1"}`))
+		writeClientFrame(client, _WS_TEXT, true, []byte(large))
+		writeClientFrame(client, _WS_CLOSE, true, []byte{0x03, 0xe8})
+	}()
+
+	// the ping is answered while the message is assembled
+	readFrame := func() (byte, []byte) {
+		var head [2]byte
+		io.ReadFull(reader, head[:])
+		size := int(head[1] & 0x7f)
+		if size == 126 {
+			var ext [2]byte
+			io.ReadFull(reader, ext[:])
+			size = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, size)
+		io.ReadFull(reader, payload)
+		return head[0] & 0x0f, payload
+	}
+	pong := make(chan []byte)
+	go func() {
+		opcode, payload := readFrame()
+		if opcode != _WS_PONG {
+			t.Errorf("expected pong, found opcode %v", opcode)
+		}
+		pong <- payload
+	}()
+
+	msg, err := conn.readMessage(1024)
+	if err != nil || string(msg) != `{"statement":"select 1"}` {
+		t.Fatalf("unexpected message %s, error %v", msg, err)
+	}
+	if p := <-pong; string(p) != "ping" {
+		t.Errorf("expected pong payload ping, found %s", p)
+	}
+	msg, err = conn.readMessage(1024)
+	if err != nil || string(msg) != large {
+		t.Fatalf("unexpected large message, error %v", err)
+	}
+
+	go func() {
+		opcode, _ := readFrame()
+		if opcode != _WS_CLOSE {
+			t.Errorf("expected close, found opcode %v", opcode)
+		}
+		pong <- nil
+	}()
+	_, err = conn.readMessage(1024)
+	if err != io.EOF {
+		t.Errorf("expected EOF on close, found %v", err)
+	}
+	<-pong
+}
+
+func TestWebSocketResponse(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := newWsConn(server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)))
+	defer conn.close()
+	resp := &wsResponse{conn: conn}
+
+	// lines split across writes are sent whole
+	go func() {
+		resp.Write([]byte("{\"$1\":1}\n{\"$1\""))
+		resp.Write([]byte(":2}\n{\"status\":\"success\"}\n"))
+	}()
+	reader := bufio.NewReader(client)
+	for _, e := range []string{`{"$1":1}`, `{"$1":2}`, `{"status":"success"}`} {
+		var head [2]byte
+		io.ReadFull(reader, head[:])
+		if head[0] != 0x80|_WS_TEXT || head[1]&0x80 != 0 {
+			t.Fatalf("unexpected frame header %v", head)
+		}
+		payload := make([]byte, head[1])
+		io.ReadFull(reader, payload)
+		if string(payload) != e {
+			t.Errorf("expected %v, found %s", e, payload)
+		}
+	}
+}
+
+// server frames are not masked
+func readServerFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+
+	_, err := io.ReadFull(r, head[:])
+	if err != nil {
+		return 0, nil, err
+	}
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(r, ext[:])
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(r, ext[:])
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, size)
+	_, err = io.ReadFull(r, payload)
+	return head[0] & 0x0f, payload, err
+}
+
+// returns the connection and its reader, or the refused handshake
+func dialWebSocket(t *testing.T, ts *httptest.Server, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unable to connect %v", err)
+	}
+	req, _ := http.NewRequest("GET", ts.URL+websocketPrefix, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	req.Write(conn)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatalf("Unable to read handshake %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, nil, resp
+	}
+	return conn, reader, resp
+}
+
+// the messages of a statement, up to its status line
+func readStatement(t *testing.T, reader *bufio.Reader) ([]map[string]interface{}, map[string]interface{}) {
+	var results []map[string]interface{}
+
+	for {
+		opcode, payload, err := readServerFrame(reader)
+		if err != nil {
+			t.Fatalf("Unable to read response %v", err)
+		}
+		if opcode != _WS_TEXT {
+			t.Fatalf("Unexpected opcode %v", opcode)
+		}
+		var line map[string]interface{}
+		if err = json.Unmarshal(payload, &line); err != nil {
+			t.Fatalf("Unexpected message %s", payload)
+		}
+		if _, ok := line["status"]; ok {
+			return results, line
+		}
+		results = append(results, line)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	ts := httptest.NewServer(newTestEndpoint(t).mux)
+	defer ts.Close()
+	defer SetWebSocketOrigins(nil)
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	for _, origin := range []string{"", "http://" + host} {
+		conn, _, resp := dialWebSocket(t, ts, origin)
+		if conn == nil {
+			t.Errorf("Expected origin %q to be accepted, found %v", origin, resp.Status)
+			continue
+		}
+		conn.Close()
+	}
+
+	conn, _, resp := dialWebSocket(t, ts, "http://evil.example.com")
+	if conn != nil {
+		conn.Close()
+		t.Fatalf("Expected another origin to be refused")
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected forbidden, found %v", resp.Status)
+	}
+
+	SetWebSocketOrigins([]string{"http://app.example.com/"})
+	conn, _, resp = dialWebSocket(t, ts, "http://app.example.com")
+	if conn == nil {
+		t.Errorf("Expected allowed origin to be accepted, found %v", resp.Status)
+	} else {
+		conn.Close()
+	}
+}
+
+func TestWebSocketSession(t *testing.T) {
+	endpoint := newTestEndpoint(t)
+	ts := httptest.NewServer(endpoint.mux)
+	defer ts.Close()
+
+	conn, reader, resp := dialWebSocket(t, ts, "")
+	if conn == nil {
+		t.Fatalf("Unexpected handshake response %v", resp.Status)
+	}
+	defer conn.Close()
+
+	writeClientFrame(conn, _WS_TEXT, true, []byte(`{"statement": "select 1"}`))
+	results, status := readStatement(t, reader)
+	if len(results) != 1 || results[0]["$1"] != float64(1) || status["status"] != string(server.SUCCESS) {
+		t.Errorf("Unexpected response %v %v", results, status)
+	}
+
+	// a statement that takes long enough to be cancelled
+	writeClientFrame(conn, _WS_TEXT, true, []byte(`{"statement": `+
+		`"select raw count(*) from p0:b0 unnest array_range(0, 100000) r", "client_context_id": "slow"}`))
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		started := false
+		endpoint.actives.ForEach(func(id string, request server.Request) bool {
+			started = started || request.ClientID().String() == "slow"
+			return true
+		}, nil)
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Statement did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	writeClientFrame(conn, _WS_TEXT, true, []byte(`{"cancel": "slow"}`))
+	_, status = readStatement(t, reader)
+	if status["status"] != string(server.STOPPED) || status["clientContextID"] != "slow" {
+		t.Errorf("Expected statement to be stopped, found %v", status)
+	}
+
+	// the session carries on
+	writeClientFrame(conn, _WS_TEXT, true, []byte(`{"statement": "select 2"}`))
+	results, status = readStatement(t, reader)
+	if len(results) != 1 || results[0]["$1"] != float64(2) {
+		t.Errorf("Unexpected response %v %v", results, status)
+	}
+}
+
+func TestWebSocketIdle(t *testing.T) {
+	defer func(timeout time.Duration) { websocketIdleTimeout = timeout }(websocketIdleTimeout)
+	websocketIdleTimeout = 100 * time.Millisecond
+
+	ts := httptest.NewServer(newTestEndpoint(t).mux)
+	defer ts.Close()
+
+	conn, reader, resp := dialWebSocket(t, ts, "")
+	if conn == nil {
+		t.Fatalf("Unexpected handshake response %v", resp.Status)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := readServerFrame(reader); err != io.EOF {
+		t.Errorf("Expected idle connection to be closed, found %v", err)
+	}
+}
@@ -0,0 +1,573 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pgwire
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/value"
+)
+
+// reported to clients, which often refuse to talk to servers that
+// look too old
+var _PARAMETER_STATUS = [][2]string{
+	{"server_version", "9.6.0"},
+	{"server_encoding", "UTF8"},
+	{"client_encoding", "UTF8"},
+	{"DateStyle", "ISO, MDY"},
+	{"integer_datetimes", "on"},
+	{"standard_conforming_strings", "on"},
+}
+
+// a statement prepared by a Parse message, held in the N1QL
+// prepared cache under a generated name
+type pgStatement struct {
+	text       string
+	name       string
+	signature  value.Value
+	paramTypes []int32
+}
+
+// Startup packets and passwords are read before the user is known,
+// and are kept small.
+const _STARTUP_SIZE_CAP = 10000
+
+// a statement bound to its arguments
+// A portal executed with a row limit keeps the rows past the limit, and
+// the tag to complete with, for the next Execute.
+type pgPortal struct {
+	statement *pgStatement
+	args      value.Values
+	rows      [][]byte
+	tag       []byte
+}
+
+type pgConn struct {
+	sync.Mutex
+	endpoint   *PgEndpoint
+	conn       net.Conn
+	reader     *bufio.Reader
+	writer     *bufio.Writer
+	writeErr   error
+	key        pgKey
+	remoteAddr string
+	secure     bool
+	user       string
+	appName    string
+	creds      auth.Credentials
+	statements map[string]*pgStatement
+	portals    map[string]*pgPortal
+	active     *pgRequest
+	skipToSync bool // an extended query failed: ignore messages until Sync
+}
+
+func newPgConn(endpoint *PgEndpoint, conn net.Conn) *pgConn {
+	return &pgConn{
+		endpoint:   endpoint,
+		conn:       conn,
+		reader:     bufio.NewReader(conn),
+		writer:     bufio.NewWriter(conn),
+		remoteAddr: conn.RemoteAddr().String(),
+		statements: make(map[string]*pgStatement),
+		portals:    make(map[string]*pgPortal),
+	}
+}
+
+func (this *pgConn) serve() {
+	defer this.close()
+
+	if !this.startup() {
+		return
+	}
+	for {
+		typ, msg, err := readMessage(this.reader, this.endpoint.server.RequestSizeCap())
+		if err != nil {
+			if err != io.EOF {
+				logging.Debugp("PgEndpoint: read failed", logging.Pair{"remoteAddr", this.remoteAddr},
+					logging.Pair{"error", err})
+			}
+			return
+		}
+		if typ == _MSG_TERMINATE {
+			return
+		}
+		if this.skipToSync && typ != _MSG_SYNC {
+			continue
+		}
+
+		switch typ {
+		case _MSG_QUERY:
+			this.simpleQuery(msg)
+		case _MSG_PARSE:
+			this.parse(msg)
+		case _MSG_BIND:
+			this.bind(msg)
+		case _MSG_DESCRIBE:
+			this.describe(msg)
+		case _MSG_EXECUTE:
+			this.execute(msg)
+		case _MSG_CLOSE:
+			this.closeStatement(msg)
+		case _MSG_FLUSH:
+			this.flush()
+		case _MSG_SYNC:
+			this.skipToSync = false
+			this.readyForQuery()
+		default:
+			this.protocolError(fmt.Sprintf("unsupported message type %q", typ))
+			return
+		}
+		if this.writeErr != nil {
+			return
+		}
+	}
+}
+
+func (this *pgConn) close() {
+	this.endpoint.unregister(this)
+	for _, stmt := range this.statements {
+		dropStatement(stmt)
+	}
+	this.flush()
+	this.conn.Close()
+}
+
+// Handles the startup packet, negotiating TLS if requested, and
+// authenticates the user with a cleartext password.
+// When the endpoint has a certificate, TLS is required, so that passwords
+// never travel in the clear.
+func (this *pgConn) startup() bool {
+	for {
+		body, err := readBody(this.reader, _STARTUP_SIZE_CAP)
+		if err != nil {
+			return false
+		}
+		msg := &pgMessage{data: body}
+		code := msg.int32()
+
+		switch code {
+		case _SSL_REQUEST:
+			if this.endpoint.tlsConfig == nil {
+				this.conn.Write([]byte{'N'})
+				continue
+			}
+			this.conn.Write([]byte{'S'})
+			this.conn = tls.Server(this.conn, this.endpoint.tlsConfig)
+			this.reader = bufio.NewReader(this.conn)
+			this.writer = bufio.NewWriter(this.conn)
+			this.secure = true
+			continue
+		case _GSSENC_REQUEST:
+			this.conn.Write([]byte{'N'})
+			continue
+		case _CANCEL_REQUEST:
+			key := pgKey{pid: uint32(msg.int32()), secret: uint32(msg.int32())}
+			if msg.err == nil {
+				this.endpoint.cancel(key)
+			}
+			return false
+		case _PROTOCOL_VERSION:
+			if this.endpoint.tlsConfig != nil && !this.secure {
+				this.protocolError("SSL connection is required")
+				return false
+			}
+			params := make(map[string]string)
+			for {
+				name := msg.string()
+				if name == "" || msg.err != nil {
+					break
+				}
+				params[name] = msg.string()
+			}
+			this.user = params["user"]
+			this.appName = params["application_name"]
+			return this.authenticate()
+		default:
+			this.protocolError(fmt.Sprintf("unsupported protocol version %d", code))
+			return false
+		}
+	}
+}
+
+func (this *pgConn) authenticate() bool {
+	var buf pgBuffer
+
+	buf.int32(_AUTH_CLEARTEXT_PASSWORD)
+	this.send(_MSG_AUTHENTICATION, buf.Bytes())
+	this.flush()
+
+	typ, msg, err := readMessage(this.reader, _STARTUP_SIZE_CAP)
+	if err != nil {
+		return false
+	}
+	if typ != _MSG_PASSWORD {
+		this.protocolError(fmt.Sprintf("expected password message, found %q", typ))
+		return false
+	}
+	password := msg.string()
+
+	// Authorizing no privileges authenticates the credentials. Datastores
+	// that do not authenticate users return no user list at all.
+	creds := auth.Credentials{this.user: password}
+	users, er := datastore.GetDatastore().Authorize(auth.NewPrivileges(), creds, nil)
+	if er != nil || (users != nil && len(users) == 0) {
+		this.sendError(errors.NewServiceErrorPgAuthentication(this.user))
+		return false
+	}
+	this.creds = creds
+
+	buf.Reset()
+	buf.int32(_AUTH_OK)
+	this.send(_MSG_AUTHENTICATION, buf.Bytes())
+	for _, p := range _PARAMETER_STATUS {
+		buf.Reset()
+		buf.string(p[0])
+		buf.string(p[1])
+		this.send(_MSG_PARAMETER_STATUS, buf.Bytes())
+	}
+	this.key = this.endpoint.register(this)
+	buf.Reset()
+	buf.int32(int32(this.key.pid))
+	buf.int32(int32(this.key.secret))
+	this.send(_MSG_BACKEND_KEY_DATA, buf.Bytes())
+	this.readyForQuery()
+	return true
+}
+
+func (this *pgConn) simpleQuery(msg *pgMessage) {
+	defer this.readyForQuery()
+
+	text := msg.string()
+	if msg.err != nil {
+		this.protocolError("malformed query message")
+		return
+	}
+	if strings.TrimSpace(strings.Trim(text, "; \t\r\n")) == "" {
+		this.send(_MSG_EMPTY_QUERY, nil)
+		return
+	}
+	this.run(newPgRequest(this, text, nil, nil, _MODE_SIMPLE))
+}
+
+// Parse is mapped onto PREPARE: the statement is prepared under a
+// generated name, which later Execute messages refer to.
+func (this *pgConn) parse(msg *pgMessage) {
+	name := msg.string()
+	text := msg.string()
+	n := msg.count()
+	paramTypes := make([]int32, 0, n)
+	for i := 0; i < n; i++ {
+		paramTypes = append(paramTypes, msg.int32())
+	}
+	if msg.err != nil {
+		this.protocolError("malformed parse message")
+		return
+	}
+
+	request := newPgRequest(this, "PREPARE "+text, nil, nil, _MODE_COLLECT)
+	this.run(request)
+	if request.failure != nil {
+		this.extendedError(request.failure)
+		return
+	}
+	if len(request.collected) != 1 {
+		this.extendedError(errors.NewServiceErrorPgProtocol("unable to prepare " + text))
+		return
+	}
+	prepName, _ := request.collected[0].Field("name")
+	prepared, err := plan.GetPrepared(prepName, 0)
+	if err != nil {
+		this.extendedError(err)
+		return
+	}
+
+	// declare the parameters the client left out
+	for _, m := range _PARAM_REGEXP.FindAllStringSubmatch(text, -1) {
+		p, _ := strconv.Atoi(m[1])
+		for len(paramTypes) < p {
+			paramTypes = append(paramTypes, _OID_UNSPECIFIED)
+		}
+	}
+
+	if old, ok := this.statements[name]; ok {
+		dropStatement(old)
+	}
+	this.statements[name] = &pgStatement{
+		text:       text,
+		name:       prepared.Name(),
+		signature:  prepared.Signature(),
+		paramTypes: paramTypes,
+	}
+	this.send(_MSG_PARSE_COMPLETE, nil)
+}
+
+var _PARAM_REGEXP = regexp.MustCompile(`\$([0-9]+)`)
+
+func (this *pgConn) bind(msg *pgMessage) {
+	portalName := msg.string()
+	stmtName := msg.string()
+	n := msg.count()
+	for i := 0; i < n; i++ {
+		if msg.int16() != 0 {
+			this.extendedError(errors.NewServiceErrorPgProtocol("binary parameters are not supported"))
+			return
+		}
+	}
+	n = msg.count()
+	params := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		l := int(msg.int32())
+		if l >= 0 {
+			params[i] = msg.next(l)
+		}
+	}
+	n = msg.count()
+	for i := 0; i < n; i++ {
+		if msg.int16() != 0 {
+			this.extendedError(errors.NewServiceErrorPgProtocol("binary results are not supported"))
+			return
+		}
+	}
+	if msg.err != nil {
+		this.protocolError("malformed bind message")
+		return
+	}
+
+	stmt, ok := this.statements[stmtName]
+	if !ok {
+		this.extendedError(errors.NewNoSuchPreparedError(stmtName))
+		return
+	}
+	args := make(value.Values, len(params))
+	for i, p := range params {
+		var oid int32
+		if i < len(stmt.paramTypes) {
+			oid = stmt.paramTypes[i]
+		}
+		args[i] = paramValue(oid, p)
+	}
+	this.portals[portalName] = &pgPortal{statement: stmt, args: args}
+	this.send(_MSG_BIND_COMPLETE, nil)
+}
+
+func (this *pgConn) describe(msg *pgMessage) {
+	kind := msg.next(1)
+	name := msg.string()
+	if msg.err != nil {
+		this.protocolError("malformed describe message")
+		return
+	}
+
+	var stmt *pgStatement
+	switch kind[0] {
+	case 'S':
+		stmt = this.statements[name]
+		if stmt == nil {
+			this.extendedError(errors.NewNoSuchPreparedError(name))
+			return
+		}
+		var buf pgBuffer
+		buf.int16(len(stmt.paramTypes))
+		for _, oid := range stmt.paramTypes {
+			if oid == _OID_UNSPECIFIED {
+				oid = _OID_TEXT
+			}
+			buf.int32(oid)
+		}
+		this.send(_MSG_PARAMETER_DESC, buf.Bytes())
+	case 'P':
+		portal := this.portals[name]
+		if portal == nil {
+			this.extendedError(errors.NewServiceErrorPgProtocol("no such portal " + name))
+			return
+		}
+		stmt = portal.statement
+	default:
+		this.protocolError("malformed describe message")
+		return
+	}
+
+	columns := newColumns(stmt.signature)
+	if columns == nil {
+		this.send(_MSG_NO_DATA, nil)
+	} else {
+		this.send(_MSG_ROW_DESCRIPTION, columns.rowDescription())
+	}
+}
+
+// Execute is mapped onto EXECUTE of the prepared statement, with the
+// bound values as positional arguments.
+// The statement runs to completion whatever the row limit: the rows past
+// the limit are kept in the portal, so that no servicer is held while the
+// client does not fetch them.
+func (this *pgConn) execute(msg *pgMessage) {
+	name := msg.string()
+	limit := int(msg.int32())
+	if msg.err != nil {
+		this.protocolError("malformed execute message")
+		return
+	}
+
+	portal := this.portals[name]
+	if portal == nil {
+		this.extendedError(errors.NewServiceErrorPgProtocol("no such portal " + name))
+		return
+	}
+	if portal.tag != nil {
+		this.resume(portal, limit)
+		return
+	}
+	prepared, err := plan.GetPrepared(value.NewValue(portal.statement.name), plan.OPT_TRACK)
+	if err != nil {
+		this.extendedError(err)
+		return
+	}
+	request := newPgRequest(this, portal.statement.text, prepared, portal.args, _MODE_EXTENDED)
+	request.limit = limit
+	this.run(request)
+	if request.failure != nil {
+		this.skipToSync = true
+	} else if request.suspended != nil {
+		portal.rows = request.suspended
+		portal.tag = request.commandTag()
+	}
+}
+
+// sends the next rows of a suspended portal
+func (this *pgConn) resume(portal *pgPortal, limit int) {
+	rows := portal.rows
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	for _, row := range rows {
+		this.send(_MSG_DATA_ROW, row)
+	}
+	portal.rows = portal.rows[len(rows):]
+	if len(portal.rows) > 0 {
+		this.send(_MSG_PORTAL_SUSPENDED, nil)
+		return
+	}
+	this.send(_MSG_COMMAND_COMPLETE, portal.tag)
+	portal.rows = nil
+	portal.tag = nil
+}
+
+func (this *pgConn) closeStatement(msg *pgMessage) {
+	kind := msg.next(1)
+	name := msg.string()
+	if msg.err != nil {
+		this.protocolError("malformed close message")
+		return
+	}
+	switch kind[0] {
+	case 'S':
+		if stmt, ok := this.statements[name]; ok {
+			dropStatement(stmt)
+			delete(this.statements, name)
+		}
+	case 'P':
+		delete(this.portals, name)
+	}
+	this.send(_MSG_CLOSE_COMPLETE, nil)
+}
+
+func dropStatement(stmt *pgStatement) {
+	plan.DeletePrepared(stmt.name)
+}
+
+// Runs a request through the servicers, like the http endpoint does,
+// and waits for it to complete.
+func (this *pgConn) run(request *pgRequest) {
+	srvr := this.endpoint.server
+
+	server.ActiveRequestsPut(request)
+	defer server.ActiveRequestsRemove(request.Id().String())
+	defer request.doStats(srvr)
+
+	this.setActive(request)
+	defer this.setActive(nil)
+
+	if srvr.Submit(request, srvr.Channel()) {
+		// Wait until the request exits.
+		<-request.CloseNotify()
+	} else {
+		// Buffer is full.
+		request.Fail(errors.NewServiceErrorUnavailable())
+		request.Failed(srvr)
+	}
+}
+
+func (this *pgConn) setActive(request *pgRequest) {
+	this.Lock()
+	this.active = request
+	this.Unlock()
+}
+
+// stops the statement being executed, if any
+func (this *pgConn) cancel() {
+	this.Lock()
+	request := this.active
+	this.Unlock()
+	if request != nil {
+		server.ActiveRequestsDelete(request.Id().String())
+	}
+}
+
+func (this *pgConn) extendedError(err errors.Error) {
+	this.sendError(err)
+	this.skipToSync = true
+}
+
+func (this *pgConn) protocolError(msg string) {
+	this.sendError(errors.NewServiceErrorPgProtocol(msg))
+	this.flush()
+}
+
+func (this *pgConn) sendError(err errors.Error) {
+	this.send(_MSG_ERROR_RESPONSE, errorFields(err, "ERROR"))
+}
+
+func (this *pgConn) readyForQuery() {
+	this.send(_MSG_READY_FOR_QUERY, []byte{'I'})
+	this.flush()
+}
+
+// returns false once the client has gone away
+func (this *pgConn) send(typ byte, payload []byte) bool {
+	if this.writeErr == nil {
+		this.writeErr = writeMessage(this.writer, typ, payload)
+	}
+	return this.writeErr == nil
+}
+
+func (this *pgConn) flush() {
+	if this.writeErr == nil {
+		this.writeErr = this.writer.Flush()
+	}
+}
+
+type pgKey struct {
+	pid    uint32
+	secret uint32
+}
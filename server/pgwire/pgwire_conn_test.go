@@ -0,0 +1,98 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pgwire
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"testing"
+)
+
+func newTestConn(in []byte, out *bytes.Buffer, tlsConfig *tls.Config) *pgConn {
+	return &pgConn{
+		endpoint: &PgEndpoint{tlsConfig: tlsConfig},
+		reader:   bufio.NewReader(bytes.NewReader(in)),
+		writer:   bufio.NewWriter(out),
+		portals:  make(map[string]*pgPortal),
+	}
+}
+
+// a startup packet has no message type
+func startupPacket(payload []byte) []byte {
+	var l [4]byte
+
+	binary.BigEndian.PutUint32(l[:], uint32(len(payload)+4))
+	return append(l[:], payload...)
+}
+
+func TestStartup(t *testing.T) {
+	var payload pgBuffer
+	var out bytes.Buffer
+
+	payload.int32(_PROTOCOL_VERSION)
+	payload.string("user")
+	payload.string("joe")
+	payload.string("")
+
+	// passwords are not asked for in the clear when TLS is available
+	conn := newTestConn(startupPacket(payload.Bytes()), &out, &tls.Config{})
+	if conn.startup() {
+		t.Fatalf("expected startup without TLS to fail")
+	}
+	typ, _, err := readMessage(bufio.NewReader(&out), 0)
+	if err != nil || typ != _MSG_ERROR_RESPONSE {
+		t.Errorf("expected error response, found %q, error %v", typ, err)
+	}
+
+	// nor are large startup packets read
+	out.Reset()
+	big := make([]byte, _STARTUP_SIZE_CAP+1)
+	binary.BigEndian.PutUint32(big, _PROTOCOL_VERSION)
+	conn = newTestConn(startupPacket(big), &out, nil)
+	if conn.startup() {
+		t.Fatalf("expected startup with a large packet to fail")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no response, found %v", out.Bytes())
+	}
+}
+
+func TestPortalSuspended(t *testing.T) {
+	var tag pgBuffer
+	var out bytes.Buffer
+
+	tag.string("SELECT 5")
+	conn := newTestConn(nil, &out, nil)
+	portal := &pgPortal{
+		rows: [][]byte{[]byte("3"), []byte("4"), []byte("5")},
+		tag:  tag.Bytes(),
+	}
+
+	conn.resume(portal, 2)
+	conn.resume(portal, 0)
+	conn.flush()
+
+	expected := []byte{_MSG_DATA_ROW, _MSG_DATA_ROW, _MSG_PORTAL_SUSPENDED, _MSG_DATA_ROW, _MSG_COMMAND_COMPLETE}
+	r := bufio.NewReader(&out)
+	for i, e := range expected {
+		typ, msg, err := readMessage(r, 0)
+		if err != nil || typ != e {
+			t.Fatalf("message %v: expected %q, found %q, error %v", i, e, typ, err)
+		}
+		if typ == _MSG_COMMAND_COMPLETE && msg.string() != "SELECT 5" {
+			t.Errorf("unexpected command tag %q", msg.data)
+		}
+	}
+	if portal.rows != nil || portal.tag != nil {
+		t.Errorf("expected the portal to be done, found %v rows", len(portal.rows))
+	}
+}
@@ -0,0 +1,130 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package pgwire serves N1QL statements over the PostgreSQL
+frontend/backend protocol, for the benefit of tools that only
+speak that protocol.
+
+Simple queries run the statement text as is. Extended queries map
+Parse onto PREPARE, and Bind and Execute onto the execution of the
+prepared statement with positional arguments.
+Results are described after the projection of the statement, and
+sent in text format.
+*/
+package pgwire
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/couchbase/cbauth"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/server"
+)
+
+type PgEndpoint struct {
+	sync.Mutex
+	server    *server.Server
+	addr      string
+	tlsConfig *tls.Config
+	listener  net.Listener
+	conns     map[pgKey]*pgConn
+	nextPid   uint32
+}
+
+// certFile and keyFile are optional, and allow clients to
+// negotiate TLS
+func NewPgEndpoint(srv *server.Server, addr, certFile, keyFile string) (*PgEndpoint, error) {
+	rv := &PgEndpoint{
+		server: srv,
+		addr:   addr,
+		conns:  make(map[pgKey]*pgConn),
+	}
+	if certFile != "" && keyFile != "" {
+		tlsCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		rv.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{tlsCert},
+			ClientAuth:   tls.NoClientCert,
+			MinVersion:   cbauth.MinTLSVersion(),
+			CipherSuites: cbauth.CipherSuites(),
+		}
+	}
+	return rv, nil
+}
+
+func (this *PgEndpoint) Listen() error {
+	ln, err := net.Listen("tcp", this.addr)
+	if err == nil {
+		this.listener = ln
+		go this.serve(ln)
+		logging.Infop("PgEndpoint: Listen", logging.Pair{"Address", ln.Addr()})
+	}
+	return err
+}
+
+func (this *PgEndpoint) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go newPgConn(this, conn).serve()
+	}
+}
+
+func (this *PgEndpoint) Close() error {
+	var err error
+	if this.listener != nil {
+		err = this.listener.Close()
+		logging.Infop("PgEndpoint: close listener ", logging.Pair{"Address", this.listener.Addr()},
+			logging.Pair{"err", err})
+	}
+	return err
+}
+
+// Connections are known by a process id and a secret, which clients
+// quote to cancel the statement being executed.
+func (this *PgEndpoint) register(conn *pgConn) pgKey {
+	var secret [4]byte
+
+	rand.Read(secret[:])
+	this.Lock()
+	defer this.Unlock()
+	this.nextPid++
+	key := pgKey{pid: this.nextPid, secret: binary.BigEndian.Uint32(secret[:])}
+	this.conns[key] = conn
+	return key
+}
+
+func (this *PgEndpoint) unregister(conn *pgConn) {
+	this.Lock()
+	defer this.Unlock()
+	if this.conns[conn.key] == conn {
+		delete(this.conns, conn.key)
+	}
+}
+
+func (this *PgEndpoint) cancel(key pgKey) {
+	this.Lock()
+	conn := this.conns[key]
+	this.Unlock()
+	if conn != nil {
+		conn.cancel()
+	}
+}
@@ -0,0 +1,421 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pgwire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+// startup packet codes
+const (
+	_PROTOCOL_VERSION = 196608 // 3.0
+	_CANCEL_REQUEST   = 80877102
+	_SSL_REQUEST      = 80877103
+	_GSSENC_REQUEST   = 80877104
+)
+
+// frontend messages
+const (
+	_MSG_BIND      = 'B'
+	_MSG_CLOSE     = 'C'
+	_MSG_DESCRIBE  = 'D'
+	_MSG_EXECUTE   = 'E'
+	_MSG_FLUSH     = 'H'
+	_MSG_PARSE     = 'P'
+	_MSG_PASSWORD  = 'p'
+	_MSG_QUERY     = 'Q'
+	_MSG_SYNC      = 'S'
+	_MSG_TERMINATE = 'X'
+)
+
+// backend messages
+const (
+	_MSG_AUTHENTICATION   = 'R'
+	_MSG_BACKEND_KEY_DATA = 'K'
+	_MSG_BIND_COMPLETE    = '2'
+	_MSG_CLOSE_COMPLETE   = '3'
+	_MSG_COMMAND_COMPLETE = 'C'
+	_MSG_DATA_ROW         = 'D'
+	_MSG_EMPTY_QUERY      = 'I'
+	_MSG_ERROR_RESPONSE   = 'E'
+	_MSG_NO_DATA          = 'n'
+	_MSG_NOTICE_RESPONSE  = 'N'
+	_MSG_PARAMETER_DESC   = 't'
+	_MSG_PARAMETER_STATUS = 'S'
+	_MSG_PARSE_COMPLETE   = '1'
+	_MSG_PORTAL_SUSPENDED = 's'
+	_MSG_READY_FOR_QUERY  = 'Z'
+	_MSG_ROW_DESCRIPTION  = 'T'
+)
+
+const (
+	_AUTH_OK                 = 0
+	_AUTH_CLEARTEXT_PASSWORD = 3
+)
+
+// type OIDs of the columns and parameters we describe
+const (
+	_OID_UNSPECIFIED = 0
+	_OID_BOOL        = 16
+	_OID_TEXT        = 25
+	_OID_JSON        = 114
+	_OID_VARCHAR     = 1043
+	_OID_NUMERIC     = 1700
+)
+
+const _JSON_COLUMN = "json"
+
+// pgBuffer accumulates the payload of a backend message
+type pgBuffer struct {
+	bytes.Buffer
+}
+
+func (this *pgBuffer) int16(i int) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(i))
+	this.Write(b[:])
+}
+
+func (this *pgBuffer) int32(i int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(i))
+	this.Write(b[:])
+}
+
+func (this *pgBuffer) string(s string) {
+	this.WriteString(s)
+	this.WriteByte(0)
+}
+
+// pgMessage decodes the payload of a frontend message
+type pgMessage struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (this *pgMessage) next(n int) []byte {
+	if this.err != nil {
+		return nil
+	}
+	if n < 0 || this.pos+n > len(this.data) {
+		this.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	rv := this.data[this.pos : this.pos+n]
+	this.pos += n
+	return rv
+}
+
+func (this *pgMessage) int16() int {
+	b := this.next(2)
+	if b == nil {
+		return 0
+	}
+	return int(int16(binary.BigEndian.Uint16(b)))
+}
+
+// the number of items that follow, which cannot be negative
+func (this *pgMessage) count() int {
+	n := this.int16()
+	if n < 0 && this.err == nil {
+		this.err = fmt.Errorf("invalid count %d", n)
+	}
+	if this.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (this *pgMessage) int32() int32 {
+	b := this.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (this *pgMessage) string() string {
+	if this.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(this.data[this.pos:], 0)
+	if i < 0 {
+		this.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	rv := string(this.data[this.pos : this.pos+i])
+	this.pos += i + 1
+	return rv
+}
+
+// reads the length prefixed body that follows the message type, if any
+func readBody(r *bufio.Reader, sizeCap int) ([]byte, error) {
+	var l [4]byte
+
+	_, err := io.ReadFull(r, l[:])
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(l[:])) - 4
+	if size < 0 || (sizeCap > 0 && size > sizeCap) {
+		return nil, fmt.Errorf("invalid message length %d", size+4)
+	}
+	body := make([]byte, size)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
+
+func readMessage(r *bufio.Reader, sizeCap int) (byte, *pgMessage, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := readBody(r, sizeCap)
+	if err != nil {
+		return 0, nil, err
+	}
+	return typ, &pgMessage{data: body}, nil
+}
+
+func writeMessage(w *bufio.Writer, typ byte, payload []byte) error {
+	var l [4]byte
+
+	binary.BigEndian.PutUint32(l[:], uint32(len(payload)+4))
+	w.WriteByte(typ)
+	w.Write(l[:])
+	_, err := w.Write(payload)
+	return err
+}
+
+// pgColumn describes a result column, and which part of each
+// result it is rendered from
+type pgColumn struct {
+	name  string
+	oid   int32
+	whole bool // the column is the whole result, not one of its fields
+}
+
+type pgColumns []*pgColumn
+
+// Columns are derived from the projection signature: each named
+// projection term becomes a column, typed after the term.
+// Raw projections produce a single column; so do star projections
+// and statements of unknown shape, as JSON.
+// A nil signature means the statement returns no rows.
+func newColumns(signature value.Value) pgColumns {
+	if signature == nil {
+		return nil
+	}
+	switch signature.Type() {
+	case value.OBJECT:
+		fields := signature.Fields()
+		if len(fields) == 0 {
+			break
+		}
+		if _, ok := fields["*"]; ok {
+			break
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		rv := make(pgColumns, len(names))
+		for i, name := range names {
+			typ, _ := value.NewValue(fields[name]).Actual().(string)
+			rv[i] = &pgColumn{name: name, oid: typeOID(typ)}
+		}
+		return rv
+	case value.STRING:
+		typ, _ := signature.Actual().(string)
+		return pgColumns{&pgColumn{name: _JSON_COLUMN, oid: typeOID(typ), whole: true}}
+	case value.NULL, value.MISSING:
+		return nil
+	}
+	return jsonColumns()
+}
+
+func jsonColumns() pgColumns {
+	return pgColumns{&pgColumn{name: _JSON_COLUMN, oid: _OID_JSON, whole: true}}
+}
+
+func typeOID(typ string) int32 {
+	switch typ {
+	case value.BOOLEAN.String():
+		return _OID_BOOL
+	case value.NUMBER.String():
+		return _OID_NUMERIC
+	case value.STRING.String():
+		return _OID_TEXT
+	default:
+		return _OID_JSON
+	}
+}
+
+func (this pgColumns) rowDescription() []byte {
+	var buf pgBuffer
+
+	buf.int16(len(this))
+	for _, c := range this {
+		buf.string(c.name)
+		buf.int32(0) // table OID
+		buf.int16(0) // column number
+		buf.int32(c.oid)
+		buf.int16(-1) // variable length
+		buf.int32(-1) // type modifier
+		buf.int16(0)  // text format
+	}
+	return buf.Bytes()
+}
+
+// renders a result as a data row, in text format
+func (this pgColumns) dataRow(item value.Value) ([]byte, error) {
+	var buf pgBuffer
+
+	buf.int16(len(this))
+	for _, c := range this {
+		v := item
+		if !c.whole {
+			v, _ = item.Field(c.name)
+		}
+		text, null, err := c.text(v)
+		if err != nil {
+			return nil, err
+		}
+		if null {
+			buf.int32(-1)
+			continue
+		}
+		buf.int32(int32(len(text)))
+		buf.Write(text)
+	}
+	return buf.Bytes(), nil
+}
+
+func (this *pgColumn) text(v value.Value) ([]byte, bool, error) {
+	if v == nil || v.Type() <= value.NULL {
+		return nil, true, nil
+	}
+	switch v.Type() {
+	case value.BOOLEAN:
+		if this.oid == _OID_BOOL {
+			if v.Truth() {
+				return []byte("t"), false, nil
+			}
+			return []byte("f"), false, nil
+		}
+	case value.STRING:
+		if this.oid == _OID_TEXT {
+			return []byte(v.Actual().(string)), false, nil
+		}
+	}
+	b, err := json.Marshal(v)
+	return b, false, err
+}
+
+// Parameters declared as text are passed as strings, the rest are
+// taken as JSON, falling back to strings for unquoted text.
+func paramValue(oid int32, data []byte) value.Value {
+	if data == nil {
+		return value.NULL_VALUE
+	}
+	switch oid {
+	case _OID_TEXT, _OID_VARCHAR:
+		return value.NewValue(string(data))
+	}
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return value.NewValue(string(data))
+	}
+	return value.NewValue(v)
+}
+
+// SQLSTATE codes reported for N1QL errors
+const (
+	_SQLSTATE_READ_ONLY             = "25006"
+	_SQLSTATE_QUERY_CANCELED        = "57014"
+	_SQLSTATE_LIMIT_EXCEEDED        = "53400"
+	_SQLSTATE_PROTOCOL_VIOLATION    = "08P01"
+	_SQLSTATE_INVALID_PASSWORD      = "28P01"
+	_SQLSTATE_INSUFFICIENT_RESOURCE = "53000"
+	_SQLSTATE_INVALID_PARAMETER     = "22023"
+	_SQLSTATE_INVALID_STATEMENT     = "26000"
+	_SQLSTATE_INSUFFICIENT_PRIV     = "42501"
+	_SQLSTATE_SYNTAX_ERROR          = "42601"
+	_SQLSTATE_ACCESS_RULE_VIOLATION = "42000"
+	_SQLSTATE_SYSTEM_ERROR          = "58000"
+	_SQLSTATE_INTERNAL_ERROR        = "XX000"
+)
+
+func sqlState(err errors.Error) string {
+	code := int(err.Code())
+	switch code {
+	case 1000:
+		return _SQLSTATE_READ_ONLY
+	case 1020, 1030, 1040, 1050, 1060, 1065, 1070:
+		return _SQLSTATE_INVALID_PARAMETER
+	case 1080:
+		return _SQLSTATE_QUERY_CANCELED
+	case 1185:
+		return _SQLSTATE_LIMIT_EXCEEDED
+	case 1186:
+		return _SQLSTATE_PROTOCOL_VIOLATION
+	case 1187:
+		return _SQLSTATE_INVALID_PASSWORD
+	case 1188:
+		return _SQLSTATE_INSUFFICIENT_RESOURCE
+	case 1189:
+		return _SQLSTATE_QUERY_CANCELED
+	case errors.NO_SUCH_PREPARED:
+		return _SQLSTATE_INVALID_STATEMENT
+	case errors.DS_AUTH_ERROR:
+		return _SQLSTATE_INSUFFICIENT_PRIV
+	}
+	switch {
+	case code >= 3000 && code < 4000: // parse errors
+		return _SQLSTATE_SYNTAX_ERROR
+	case code >= 4000 && code < 5000: // plan errors
+		return _SQLSTATE_ACCESS_RULE_VIOLATION
+	case code >= 10000 && code < 20000: // datastore errors
+		return _SQLSTATE_SYSTEM_ERROR
+	default:
+		return _SQLSTATE_INTERNAL_ERROR
+	}
+}
+
+// the body of an ErrorResponse or NoticeResponse
+func errorFields(err errors.Error, severity string) []byte {
+	var buf pgBuffer
+
+	buf.WriteByte('S')
+	buf.string(severity)
+	buf.WriteByte('V')
+	buf.string(severity)
+	buf.WriteByte('C')
+	buf.string(sqlState(err))
+	buf.WriteByte('M')
+	buf.string(err.Error())
+	buf.WriteByte('D')
+	buf.string(fmt.Sprintf("N1QL error code %d", err.Code()))
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
@@ -0,0 +1,151 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pgwire
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	var payload pgBuffer
+	var out bytes.Buffer
+
+	payload.string("select 1")
+	payload.int16(2)
+	payload.int32(_OID_TEXT)
+	w := bufio.NewWriter(&out)
+	writeMessage(w, _MSG_PARSE, payload.Bytes())
+	w.Flush()
+
+	typ, msg, err := readMessage(bufio.NewReader(&out), 0)
+	if err != nil || typ != _MSG_PARSE {
+		t.Fatalf("unexpected message %q, error %v", typ, err)
+	}
+	if s := msg.string(); s != "select 1" {
+		t.Errorf("expected statement, found %q", s)
+	}
+	if n := msg.count(); n != 2 {
+		t.Errorf("expected count 2, found %v", n)
+	}
+	if oid := msg.int32(); oid != _OID_TEXT {
+		t.Errorf("expected text OID, found %v", oid)
+	}
+	msg.int32()
+	if msg.err == nil {
+		t.Errorf("expected error reading past the end of the message")
+	}
+}
+
+func TestColumns(t *testing.T) {
+	signature := value.NewValue(map[string]interface{}{
+		"name":   "string",
+		"age":    "number",
+		"active": "boolean",
+		"tags":   "json",
+	})
+	columns := newColumns(signature)
+	names := []string{"active", "age", "name", "tags"}
+	oids := []int32{_OID_BOOL, _OID_NUMERIC, _OID_TEXT, _OID_JSON}
+	if len(columns) != len(names) {
+		t.Fatalf("expected %v columns, found %v", len(names), len(columns))
+	}
+	for i, c := range columns {
+		if c.name != names[i] || c.oid != oids[i] || c.whole {
+			t.Errorf("unexpected column %v: %v", i, c)
+		}
+	}
+
+	row, err := columns.dataRow(value.NewValue(map[string]interface{}{
+		"name":   "joe",
+		"age":    42,
+		"active": true,
+		"tags":   []interface{}{"a"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	msg := &pgMessage{data: row}
+	expected := []string{"t", "42", "joe", `["a"]`}
+	if n := msg.count(); n != len(expected) {
+		t.Fatalf("expected %v values, found %v", len(expected), n)
+	}
+	for _, e := range expected {
+		l := msg.int32()
+		if v := string(msg.next(int(l))); v != e {
+			t.Errorf("expected %v, found %v", e, v)
+		}
+	}
+
+	// missing fields are NULL
+	row, _ = columns.dataRow(value.NewValue(map[string]interface{}{}))
+	msg = &pgMessage{data: row}
+	msg.count()
+	if l := msg.int32(); l != -1 {
+		t.Errorf("expected NULL, found length %v", l)
+	}
+
+	// star projections and unknown shapes are returned as JSON
+	for _, s := range []value.Value{value.NewValue(map[string]interface{}{"*": "*"}), value.NewValue(value.JSON.String())} {
+		columns = newColumns(s)
+		if len(columns) != 1 || columns[0].name != _JSON_COLUMN || columns[0].oid != _OID_JSON || !columns[0].whole {
+			t.Errorf("expected a JSON column for %v, found %v", s, columns)
+		}
+	}
+	if columns = newColumns(nil); columns != nil {
+		t.Errorf("expected no columns, found %v", columns)
+	}
+}
+
+func TestParamValue(t *testing.T) {
+	cases := []struct {
+		oid      int32
+		data     []byte
+		expected interface{}
+	}{
+		{_OID_UNSPECIFIED, []byte("12"), 12},
+		{_OID_UNSPECIFIED, []byte("true"), true},
+		{_OID_UNSPECIFIED, []byte(`{"a":1}`), map[string]interface{}{"a": 1}},
+		{_OID_UNSPECIFIED, []byte("joe"), "joe"},
+		{_OID_TEXT, []byte("12"), "12"},
+		{_OID_TEXT, nil, nil},
+	}
+	for _, c := range cases {
+		v := paramValue(c.oid, c.data)
+		if !v.EquivalentTo(value.NewValue(c.expected)) {
+			t.Errorf("expected %v for %s, found %v", c.expected, c.data, v)
+		}
+	}
+}
+
+func TestSqlState(t *testing.T) {
+	cases := []struct {
+		err      errors.Error
+		expected string
+	}{
+		{errors.NewServiceErrorReadonly("readonly"), _SQLSTATE_READ_ONLY},
+		{errors.NewTimeoutError(time.Second), _SQLSTATE_QUERY_CANCELED},
+		{errors.NewNoSuchPreparedError("p1"), _SQLSTATE_INVALID_STATEMENT},
+		{errors.NewDatastoreAuthorizationError(nil), _SQLSTATE_INSUFFICIENT_PRIV},
+		{errors.NewServiceErrorPgAuthentication("joe"), _SQLSTATE_INVALID_PASSWORD},
+		{errors.NewParseSyntaxError(nil, "syntax"), _SQLSTATE_SYNTAX_ERROR},
+		{errors.NewError(nil, "internal"), _SQLSTATE_INTERNAL_ERROR},
+	}
+	for _, c := range cases {
+		if s := sqlState(c.err); s != c.expected {
+			t.Errorf("expected %v for %v, found %v", c.expected, c.err, s)
+		}
+	}
+}
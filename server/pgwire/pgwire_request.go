@@ -0,0 +1,310 @@
+//  Copyright (c) 2026 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pgwire
+
+import (
+	"fmt"
+	http_base "net/http"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/server/http"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+// how a request hands its results back
+type pgMode int
+
+const (
+	_MODE_SIMPLE   pgMode = iota // row description, data rows and completion
+	_MODE_EXTENDED               // data rows and completion, the portal was described earlier
+	_MODE_COLLECT                // results kept for the connection, nothing is sent
+)
+
+// pgRequest implements server.Request for statements received
+// over the PostgreSQL wire protocol
+type pgRequest struct {
+	server.BaseRequest
+	conn          *pgConn
+	mode          pgMode
+	collected     []value.Value
+	limit         int          // rows to send, the rest are suspended
+	suspended     [][]byte     // rows past the limit
+	failure       errors.Error // the error reported to the client
+	resultCount   int
+	resultSize    int
+	errorCount    int
+	warningCount  int
+	elapsedTime   time.Duration
+	executionTime time.Duration
+}
+
+func newPgRequest(conn *pgConn, statement string, prepared *plan.Prepared,
+	positionalArgs value.Values, mode pgMode) *pgRequest {
+	rv := &pgRequest{
+		conn: conn,
+		mode: mode,
+	}
+	server.NewBaseRequest(&rv.BaseRequest, statement, prepared, nil, positionalArgs,
+		"", 0, 0, 0, 0, value.NONE, value.NONE, value.NONE, value.NONE, _SCAN_CONFIG,
+		"", conn.creds, conn.remoteAddr, conn.appName)
+	return rv
+}
+
+func (this *pgRequest) OriginalHttpRequest() *http_base.Request {
+	return nil
+}
+
+func (this *pgRequest) Output() execution.Output {
+	return this
+}
+
+func (this *pgRequest) Fail(err errors.Error) {
+	this.SetState(server.FATAL)
+	this.KeepError(err)
+	this.Errors() <- err
+}
+
+func (this *pgRequest) Failed(srvr *server.Server) {
+	defer this.stopAndClose(server.FATAL)
+
+	this.markTimeOfCompletion()
+	this.writeErrors()
+	this.writeWarnings()
+	this.conn.flush()
+}
+
+func (this *pgRequest) Expire(state server.State, timeout time.Duration) {
+	this.Errors() <- errors.NewTimeoutError(timeout)
+	this.Stop(state)
+}
+
+func (this *pgRequest) Execute(srvr *server.Server, signature value.Value, stopNotify execution.Operator) {
+	this.NotifyStop(stopNotify)
+
+	stopped := this.writeResults(newColumns(signature))
+
+	this.markTimeOfCompletion()
+
+	if stopped && this.State() == server.STOPPED {
+		this.Error(errors.NewServiceErrorStopped())
+	}
+	this.writeErrors()
+	this.writeWarnings()
+	if this.failure == nil && this.mode != _MODE_COLLECT {
+		if this.suspended != nil {
+			this.conn.send(_MSG_PORTAL_SUSPENDED, nil)
+		} else {
+			this.conn.send(_MSG_COMMAND_COMPLETE, this.commandTag())
+		}
+	}
+	this.conn.flush()
+	if stopped {
+		this.Close()
+	} else {
+		this.stopAndClose(server.COMPLETED)
+	}
+}
+
+func (this *pgRequest) stopAndClose(state server.State) {
+	this.Stop(state)
+	this.Close()
+}
+
+func (this *pgRequest) markTimeOfCompletion() {
+	this.executionTime = time.Since(this.ServiceTime())
+	this.elapsedTime = time.Since(this.RequestTime())
+}
+
+// returns true if the request has already been stopped
+// (eg through timeout or delete)
+func (this *pgRequest) writeResults(columns pgColumns) bool {
+	var item value.Value
+
+	// simple queries describe the rows before sending them
+	described := this.mode != _MODE_SIMPLE
+	if !described && columns != nil {
+		this.conn.send(_MSG_ROW_DESCRIPTION, columns.rowDescription())
+		described = true
+	}
+
+	ok := true
+	for ok {
+		select {
+		case <-this.StopExecute():
+			this.SetState(server.STOPPED)
+			return true
+		default:
+		}
+
+		select {
+		case item, ok = <-this.Results():
+			if this.Halted() {
+				return true
+			}
+			if ok {
+				if columns == nil {
+					columns = jsonColumns()
+				}
+				if !described {
+					this.conn.send(_MSG_ROW_DESCRIPTION, columns.rowDescription())
+					described = true
+				}
+				if !this.writeResult(item, columns) {
+					return false
+				}
+			}
+		case <-this.StopExecute():
+			this.SetState(server.STOPPED)
+			return true
+		}
+	}
+
+	this.SetState(server.COMPLETED)
+	return false
+}
+
+func (this *pgRequest) writeResult(item value.Value, columns pgColumns) bool {
+	if this.mode == _MODE_COLLECT {
+		this.collected = append(this.collected, item)
+		this.resultCount++
+		return true
+	}
+
+	row, err := columns.dataRow(item)
+
+	// item won't be used past this point
+	item.Recycle()
+
+	if err != nil {
+		this.Errors() <- errors.NewServiceErrorInvalidJSON(err)
+		this.SetState(server.FATAL)
+		return false
+	}
+	if this.limit > 0 && this.resultCount >= this.limit {
+		this.suspended = append(this.suspended, row)
+	} else if !this.conn.send(_MSG_DATA_ROW, row) {
+		this.SetState(server.CLOSED)
+		return false
+	}
+	this.resultSize += len(row)
+	this.resultCount++
+	return true
+}
+
+// The protocol only allows one error per statement: the first is
+// reported, and the rest are counted.
+func (this *pgRequest) writeErrors() {
+	for {
+		select {
+		case err, ok := <-this.Errors():
+			if !ok {
+				return
+			}
+			if this.failure == nil {
+				this.failure = err
+				if this.mode != _MODE_COLLECT {
+					this.conn.send(_MSG_ERROR_RESPONSE, errorFields(err, "ERROR"))
+				}
+			}
+			this.errorCount++
+		default:
+			return
+		}
+	}
+}
+
+func (this *pgRequest) writeWarnings() {
+	alreadySeen := make(map[string]bool)
+	for {
+		select {
+		case wrn, ok := <-this.Warnings():
+			if !ok {
+				return
+			}
+			if wrn.OnceOnly() && alreadySeen[wrn.Error()] {
+				continue
+			}
+			if this.mode != _MODE_COLLECT {
+				this.conn.send(_MSG_NOTICE_RESPONSE, errorFields(wrn, "WARNING"))
+			}
+			this.warningCount++
+			alreadySeen[wrn.Error()] = true
+		default:
+			return
+		}
+	}
+}
+
+// the tag of a CommandComplete message, eg "SELECT 5" or "INSERT 0 1"
+func (this *pgRequest) commandTag() []byte {
+	var buf pgBuffer
+	var tag string
+
+	typ := this.Type()
+	switch {
+	case this.IsPrepare():
+		tag = "PREPARE"
+	case typ == "SELECT", typ == "EXPLAIN", typ == "INFER":
+		tag = fmt.Sprintf("SELECT %d", this.resultCount)
+	case typ == "INSERT", typ == "UPSERT":
+		tag = fmt.Sprintf("INSERT 0 %d", this.MutationCount())
+	case typ == "UPDATE", typ == "DELETE", typ == "MERGE":
+		tag = fmt.Sprintf("%s %d", typ, this.MutationCount())
+	default:
+		tag = strings.Replace(typ, "_", " ", -1)
+	}
+	buf.string(tag)
+	return buf.Bytes()
+}
+
+func (this *pgRequest) doStats(srvr *server.Server) {
+	service_time := this.executionTime
+	request_time := this.elapsedTime
+	acctstore := srvr.AccountingStore()
+	prepared := this.Prepared() != nil
+
+	plan.RecordPreparedMetrics(this.Prepared(), request_time, service_time)
+	accounting.RecordMetrics(acctstore, request_time, service_time, this.resultCount,
+		this.resultSize, this.errorCount, this.warningCount, this.Type(),
+		prepared, (this.State() != server.COMPLETED),
+		string(this.ScanConsistency()))
+	accounting.RecordRequestMetrics(acctstore, this.Type(), this.Keyspaces(),
+		request_time, this.PhaseTimes())
+
+	this.CompleteRequest(request_time, service_time, this.resultCount,
+		this.resultSize, this.errorCount, nil, srvr)
+}
+
+// requests over the PostgreSQL protocol are not bounded
+type scanConfigImpl struct {
+	scan_vector_source timestamp.ScanVectorSource
+}
+
+var _SCAN_CONFIG = &scanConfigImpl{scan_vector_source: &http.ZeroScanVectorSource{}}
+
+func (this *scanConfigImpl) ScanConsistency() datastore.ScanConsistency {
+	return datastore.UNBOUNDED
+}
+
+func (this *scanConfigImpl) ScanWait() time.Duration {
+	return 0
+}
+
+func (this *scanConfigImpl) ScanVectorSource() timestamp.ScanVectorSource {
+	return this.scan_vector_source
+}
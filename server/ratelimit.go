@@ -0,0 +1,462 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+ Rate limits protect the service from runaway clients.
+ Limits apply to authenticated users and to the keyspaces requests access,
+ and cap the rate at which requests are admitted (a token bucket), the number
+ of requests executing at any one time, and the cumulative result bytes and
+ documents fetched over a quota period.
+ A limit for user or keyspace "*" applies to each user or keyspace that does
+ not have one of its own.
+ Requests are vetted once authenticated, before anything is executed; their
+ usage counts against the quotas once they complete, so a request may take a
+ quota beyond its maximum, and the next ones are then turned away until the
+ period is over.
+*/
+package server
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/value"
+)
+
+// Rate limit settings
+const (
+	_RATE_LIMIT_USER        = "user"
+	_RATE_LIMIT_KEYSPACE    = "keyspace"
+	_RATE_LIMIT_RATE        = "requests-per-second"
+	_RATE_LIMIT_BURST       = "burst"
+	_RATE_LIMIT_CONCURRENCY = "max-concurrency"
+	_RATE_LIMIT_RESULT      = "max-result-bytes"
+	_RATE_LIMIT_DOCUMENTS   = "max-documents-fetched"
+	_RATE_LIMIT_PERIOD      = "quota-period"
+)
+
+const _RATE_LIMIT_SETTING = "rate-limits"
+
+// applies to anyone without a limit of their own
+const RATE_LIMIT_ANY = "*"
+
+// how long clients are asked to wait for a concurrency limit
+const _RATE_LIMIT_CONCURRENCY_RETRY = time.Second
+
+type RateLimit struct {
+	User              string
+	Keyspace          string  // namespace:keyspace
+	RequestsPerSecond float64 // no limit if not positive
+	Burst             int
+	MaxConcurrency    int           // no limit if not positive
+	MaxResultBytes    int64         // no limit if not positive
+	MaxDocuments      int64         // no limit if not positive
+	QuotaPeriod       time.Duration // quotas never reset if not positive
+}
+
+// Parse rate limits from their settings, a list of objects.
+func NewRateLimits(val interface{}) ([]*RateLimit, errors.Error) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, errors.NewAdminSettingTypeError(_RATE_LIMIT_SETTING, val)
+	}
+
+	keys := make(map[string]bool, len(list))
+	limits := make([]*RateLimit, 0, len(list))
+	for _, v := range list {
+		settings, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.NewAdminSettingTypeError(_RATE_LIMIT_SETTING, v)
+		}
+
+		limit, err := newRateLimit(settings)
+		if err != nil {
+			return nil, err
+		}
+		key := limit.key()
+		if keys[key] {
+			return nil, errors.NewAdminSettingTypeError(_RATE_LIMIT_SETTING, key)
+		}
+		keys[key] = true
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+func newRateLimit(settings map[string]interface{}) (*RateLimit, errors.Error) {
+	rv := &RateLimit{}
+
+	for setting, val := range settings {
+		var ok bool
+
+		switch setting {
+		case _RATE_LIMIT_USER:
+			rv.User, ok = val.(string)
+			ok = ok && rv.User != ""
+		case _RATE_LIMIT_KEYSPACE:
+			rv.Keyspace, ok = val.(string)
+			ok = ok && rv.Keyspace != ""
+			if ok && rv.Keyspace != RATE_LIMIT_ANY && !strings.Contains(rv.Keyspace, ":") {
+				rv.Keyspace = "default:" + rv.Keyspace
+			}
+		case _RATE_LIMIT_RATE:
+			rv.RequestsPerSecond, ok = val.(float64)
+			ok = ok && rv.RequestsPerSecond >= 0
+		case _RATE_LIMIT_BURST:
+			rv.Burst, ok = workloadInt(val)
+			ok = ok && rv.Burst >= 0
+		case _RATE_LIMIT_CONCURRENCY:
+			rv.MaxConcurrency, ok = workloadInt(val)
+		case _RATE_LIMIT_RESULT:
+			var n int
+			n, ok = workloadInt(val)
+			rv.MaxResultBytes = int64(n)
+		case _RATE_LIMIT_DOCUMENTS:
+			var n int
+			n, ok = workloadInt(val)
+			rv.MaxDocuments = int64(n)
+		case _RATE_LIMIT_PERIOD:
+			switch val := val.(type) {
+			case float64:
+				rv.QuotaPeriod, ok = time.Duration(val), true
+			case string:
+				var e error
+				rv.QuotaPeriod, e = time.ParseDuration(val)
+				ok = e == nil
+			}
+		}
+		if !ok {
+			return nil, errors.NewAdminSettingTypeError(_RATE_LIMIT_SETTING+"."+setting, val)
+		}
+	}
+
+	// exactly one of user and keyspace
+	if (rv.User == "") == (rv.Keyspace == "") {
+		return nil, errors.NewAdminSettingTypeError(_RATE_LIMIT_SETTING+"."+_RATE_LIMIT_USER, rv.User)
+	}
+	if rv.Burst == 0 {
+		rv.Burst = int(math.Ceil(rv.RequestsPerSecond))
+		if rv.Burst == 0 {
+			rv.Burst = 1
+		}
+	}
+	return rv, nil
+}
+
+// The settings for the limit, as accepted by NewRateLimits()
+func (this *RateLimit) Settings() map[string]interface{} {
+	rv := map[string]interface{}{
+		_RATE_LIMIT_RATE:        this.RequestsPerSecond,
+		_RATE_LIMIT_BURST:       this.Burst,
+		_RATE_LIMIT_CONCURRENCY: this.MaxConcurrency,
+		_RATE_LIMIT_RESULT:      this.MaxResultBytes,
+		_RATE_LIMIT_DOCUMENTS:   this.MaxDocuments,
+		_RATE_LIMIT_PERIOD:      this.QuotaPeriod.String(),
+	}
+	if this.User != "" {
+		rv[_RATE_LIMIT_USER] = this.User
+	} else {
+		rv[_RATE_LIMIT_KEYSPACE] = this.Keyspace
+	}
+	return rv
+}
+
+func (this *RateLimit) key() string {
+	if this.User != "" {
+		return rateLimitKey(_RATE_LIMIT_USER, this.User)
+	}
+	return rateLimitKey(_RATE_LIMIT_KEYSPACE, this.Keyspace)
+}
+
+func rateLimitKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// where a user or keyspace stands against its limit
+type rateLimitState struct {
+	limit       *RateLimit
+	kind        string
+	name        string
+	tokens      float64
+	refilled    time.Time
+	running     int
+	resultBytes int64
+	documents   int64
+	periodStart time.Time
+	admitted    int64
+	rejected    int64
+}
+
+func newRateLimitState(limit *RateLimit, kind, name string, now time.Time) *rateLimitState {
+	return &rateLimitState{
+		limit:       limit,
+		kind:        kind,
+		name:        name,
+		tokens:      float64(limit.Burst),
+		refilled:    now,
+		periodStart: now,
+	}
+}
+
+// top up the bucket and start a new quota period, as due
+func (this *rateLimitState) refresh(now time.Time) {
+	limit := this.limit
+	if limit.RequestsPerSecond > 0 {
+		this.tokens += now.Sub(this.refilled).Seconds() * limit.RequestsPerSecond
+		if this.tokens > float64(limit.Burst) {
+			this.tokens = float64(limit.Burst)
+		}
+	}
+	this.refilled = now
+	if limit.QuotaPeriod > 0 && now.Sub(this.periodStart) >= limit.QuotaPeriod {
+		this.resultBytes = 0
+		this.documents = 0
+		this.periodStart = now
+	}
+}
+
+// returns the setting exceeded, if any, and how long before it might not be
+func (this *rateLimitState) check(now time.Time) (string, time.Duration) {
+	limit := this.limit
+	periodLeft := time.Duration(0)
+	if limit.QuotaPeriod > 0 {
+		periodLeft = limit.QuotaPeriod - now.Sub(this.periodStart)
+	}
+
+	switch {
+	case limit.MaxResultBytes > 0 && this.resultBytes >= limit.MaxResultBytes:
+		return _RATE_LIMIT_RESULT, periodLeft
+	case limit.MaxDocuments > 0 && this.documents >= limit.MaxDocuments:
+		return _RATE_LIMIT_DOCUMENTS, periodLeft
+	case limit.MaxConcurrency > 0 && this.running >= limit.MaxConcurrency:
+		return _RATE_LIMIT_CONCURRENCY, _RATE_LIMIT_CONCURRENCY_RETRY
+	case limit.RequestsPerSecond > 0 && this.tokens < 1:
+		wait := (1 - this.tokens) / limit.RequestsPerSecond
+		return _RATE_LIMIT_RATE, time.Duration(wait * float64(time.Second))
+	}
+	return "", 0
+}
+
+func (this *rateLimitState) value(now time.Time) value.Value {
+	this.refresh(now)
+	rv := map[string]interface{}{
+		"limit":       this.limit.Settings(),
+		"tokens":      math.Floor(this.tokens*100) / 100,
+		"running":     this.running,
+		"resultBytes": this.resultBytes,
+		"documents":   this.documents,
+		"periodStart": this.periodStart.Round(0).String(),
+		"admitted":    this.admitted,
+		"rejected":    this.rejected,
+		this.kind:     this.name,
+	}
+	return value.NewValue(rv)
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	limits map[string]*RateLimit
+	states map[string]*rateLimitState
+	holds  map[string][]*rateLimitState // by request id
+}
+
+var rateLimits = &rateLimiter{
+	limits: map[string]*RateLimit{},
+	states: map[string]*rateLimitState{},
+	holds:  map[string][]*rateLimitState{},
+}
+
+func RateLimits() []*RateLimit {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	rv := make([]*RateLimit, 0, len(rateLimits.limits))
+	for _, limit := range rateLimits.limits {
+		rv = append(rv, limit)
+	}
+	return rv
+}
+
+// Replace the rate limits.
+// Users and keyspaces that are still limited keep their usage and requests
+// in flight; the rest start afresh, should they be limited again.
+func SetRateLimits(limits []*RateLimit) {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	rateLimits.limits = make(map[string]*RateLimit, len(limits))
+	for _, limit := range limits {
+		rateLimits.limits[limit.key()] = limit
+	}
+
+	now := time.Now()
+	states := make(map[string]*rateLimitState, len(limits))
+	for key, state := range rateLimits.states {
+		limit := rateLimits.find(state.kind, state.name)
+		if limit != nil {
+			state.limit = limit
+			if state.tokens > float64(limit.Burst) {
+				state.tokens = float64(limit.Burst)
+			}
+			states[key] = state
+		}
+	}
+	for key, limit := range rateLimits.limits {
+		if states[key] == nil && limit.User != RATE_LIMIT_ANY && limit.Keyspace != RATE_LIMIT_ANY {
+			if limit.User != "" {
+				states[key] = newRateLimitState(limit, _RATE_LIMIT_USER, limit.User, now)
+			} else {
+				states[key] = newRateLimitState(limit, _RATE_LIMIT_KEYSPACE, limit.Keyspace, now)
+			}
+		}
+	}
+	rateLimits.states = states
+}
+
+// The users and keyspaces being limited, and where they stand, by key
+func RateLimitsCount() int {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+	return len(rateLimits.states)
+}
+
+func RateLimitsGet(key string) value.Value {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	state := rateLimits.states[key]
+	if state == nil {
+		return nil
+	}
+	return state.value(time.Now())
+}
+
+func RateLimitsForEach(f func(string) bool) {
+	rateLimits.Lock()
+	keys := make([]string, 0, len(rateLimits.states))
+	for key := range rateLimits.states {
+		keys = append(keys, key)
+	}
+	rateLimits.Unlock()
+
+	for _, key := range keys {
+		if !f(key) {
+			break
+		}
+	}
+}
+
+// lock held
+func (this *rateLimiter) find(kind, name string) *RateLimit {
+	limit := this.limits[rateLimitKey(kind, name)]
+	if limit == nil {
+		limit = this.limits[rateLimitKey(kind, RATE_LIMIT_ANY)]
+	}
+	return limit
+}
+
+// lock held
+func (this *rateLimiter) state(kind, name string, now time.Time) *rateLimitState {
+	key := rateLimitKey(kind, name)
+	state := this.states[key]
+	if state == nil {
+		limit := this.find(kind, name)
+		if limit == nil {
+			return nil
+		}
+		state = newRateLimitState(limit, kind, name, now)
+		this.states[key] = state
+	}
+	return state
+}
+
+// Vet a request against the limits of its users and of the keyspaces it
+// accesses, taking its share of each if it can go ahead.
+func rateLimitAdmit(id string, users auth.AuthenticatedUsers, privileges *auth.Privileges) errors.Error {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	if len(rateLimits.limits) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var states []*rateLimitState
+	add := func(kind, name string) {
+		state := rateLimits.state(kind, name, now)
+		if state == nil {
+			return
+		}
+		for _, s := range states {
+			if s == state {
+				return
+			}
+		}
+		states = append(states, state)
+	}
+	for _, user := range users {
+		add(_RATE_LIMIT_USER, user)
+	}
+	if privileges != nil {
+		for _, pair := range privileges.List {
+			if pair.Target != "" {
+				add(_RATE_LIMIT_KEYSPACE, pair.Target)
+			}
+		}
+	}
+
+	for _, state := range states {
+		state.refresh(now)
+		reason, retry := state.check(now)
+		if reason != "" {
+			state.rejected++
+			return errors.NewServiceErrorRateLimit(state.kind, state.name, reason, retry)
+		}
+	}
+	for _, state := range states {
+		if state.limit.RequestsPerSecond > 0 {
+			state.tokens--
+		}
+		state.running++
+		state.admitted++
+	}
+	if len(states) > 0 {
+		rateLimits.holds[id] = states
+	}
+	return nil
+}
+
+// Give back the share of the limits held by a request, and charge
+// its usage against the quotas.
+func rateLimitRelease(id string, resultBytes, documents int64) {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	states, ok := rateLimits.holds[id]
+	if !ok {
+		return
+	}
+	delete(rateLimits.holds, id)
+	for _, state := range states {
+		state.running--
+		state.resultBytes += resultBytes
+		state.documents += documents
+	}
+}
+
+func (this *BaseRequest) releaseRateLimits(resultSize int) {
+	documents := atomic.LoadUint64(&this.phaseStats[execution.FETCH].count)
+	rateLimitRelease(this.Id().String(), int64(resultSize), int64(documents))
+}
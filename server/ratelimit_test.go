@@ -0,0 +1,143 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/auth"
+	"github.com/couchbase/query/errors"
+)
+
+func parseRateLimits(t *testing.T, s string) ([]*RateLimit, bool) {
+	var val interface{}
+	if err := json.Unmarshal([]byte(s), &val); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", s, err)
+	}
+	limits, err := NewRateLimits(val)
+	return limits, err == nil
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	limits, ok := parseRateLimits(t, `[
+		{"user": "etl", "requests-per-second": 2.5, "max-concurrency": 1},
+		{"keyspace": "travel", "max-documents-fetched": 100, "quota-period": "1h"},
+		{"user": "*", "requests-per-second": 10, "burst": 20}
+	]`)
+	if !ok || len(limits) != 3 {
+		t.Fatalf("Expected 3 limits, received %v", limits)
+	}
+	if limits[0].Burst != 3 || limits[0].MaxConcurrency != 1 {
+		t.Errorf("Unexpected etl limit %+v", limits[0])
+	}
+	if limits[1].Keyspace != "default:travel" || limits[1].QuotaPeriod != time.Hour || limits[1].Burst != 1 {
+		t.Errorf("Unexpected travel limit %+v", limits[1])
+	}
+	if limits[2].Burst != 20 {
+		t.Errorf("Unexpected default user limit %+v", limits[2])
+	}
+
+	// the settings go round
+	settings := make([]interface{}, len(limits))
+	for i, limit := range limits {
+		settings[i] = limit.Settings()
+	}
+	bytes, _ := json.Marshal(settings)
+	again, ok := parseRateLimits(t, string(bytes))
+	if !ok || len(again) != 3 || *again[1] != *limits[1] {
+		t.Errorf("Settings do not round trip: %s", bytes)
+	}
+
+	invalid := []string{
+		`{"user": "etl"}`,
+		`[{"requests-per-second": 1}]`,
+		`[{"user": "etl", "keyspace": "travel"}]`,
+		`[{"user": "etl", "requests-per-second": -1}]`,
+		`[{"user": "etl", "max-concurrency": 1.5}]`,
+		`[{"user": "etl"}, {"user": "etl"}]`,
+	}
+	for _, s := range invalid {
+		if _, ok := parseRateLimits(t, s); ok {
+			t.Errorf("Expected %s to be rejected", s)
+		}
+	}
+}
+
+func TestRateLimitAdmission(t *testing.T) {
+	limits, _ := parseRateLimits(t, `[
+		{"user": "etl", "max-concurrency": 1},
+		{"user": "*", "requests-per-second": 1},
+		{"keyspace": "travel", "max-result-bytes": 100, "quota-period": "1h"}
+	]`)
+	SetRateLimits(limits)
+	defer SetRateLimits(nil)
+
+	travel := auth.NewPrivileges()
+	travel.Add("default:travel", auth.PRIV_QUERY_SELECT)
+
+	retryAfter := func(err errors.Error) time.Duration {
+		retry, _ := err.Cause().(errors.RetryAfter)
+		return time.Duration(retry)
+	}
+
+	// concurrency
+	if err := rateLimitAdmit("1", auth.AuthenticatedUsers{"etl"}, nil); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	err := rateLimitAdmit("2", auth.AuthenticatedUsers{"etl"}, nil)
+	if err == nil || err.Code() != 1185 || retryAfter(err) != time.Second {
+		t.Errorf("Expected concurrency limit, received %v", err)
+	}
+	rateLimitRelease("1", 10, 1)
+	if err := rateLimitAdmit("3", auth.AuthenticatedUsers{"etl"}, nil); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	rateLimitRelease("3", 10, 1)
+
+	// rate, for users without a limit of their own
+	if err := rateLimitAdmit("4", auth.AuthenticatedUsers{"alice"}, nil); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	rateLimitRelease("4", 0, 0)
+	err = rateLimitAdmit("5", auth.AuthenticatedUsers{"alice"}, nil)
+	if err == nil || retryAfter(err) <= 0 || retryAfter(err) > time.Second {
+		t.Errorf("Expected rate limit, received %v", err)
+	}
+	if err := rateLimitAdmit("6", auth.AuthenticatedUsers{"bob"}, nil); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	rateLimitRelease("6", 0, 0)
+
+	// quota
+	if err := rateLimitAdmit("7", nil, travel); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	rateLimitRelease("7", 150, 0)
+	err = rateLimitAdmit("8", nil, travel)
+	if err == nil || retryAfter(err) <= 59*time.Minute {
+		t.Errorf("Expected quota limit, received %v", err)
+	}
+
+	if n := RateLimitsCount(); n != 4 {
+		t.Errorf("Expected 4 limited users and keyspaces, received %v", n)
+	}
+	state := RateLimitsGet("keyspace:default:travel")
+	if state == nil {
+		t.Fatalf("No state for travel")
+	}
+	if v, _ := state.Field("resultBytes"); v.Actual() != float64(150) {
+		t.Errorf("Expected 150 result bytes, received %v", v)
+	}
+	if v, _ := state.Field("rejected"); v.Actual() != float64(1) {
+		t.Errorf("Expected 1 rejection, received %v", v)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/couchbase/query/execution"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/tracing"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
@@ -34,6 +35,7 @@ const _ERROR_CAP = 4
 type State string
 
 const (
+	QUEUED    State = "queued"
 	RUNNING   State = "running"
 	SUCCESS   State = "success"
 	ERRORS    State = "errors"
@@ -82,6 +84,7 @@ type Request interface {
 	Expire(state State, timeout time.Duration)
 	SortCount() uint64
 	State() State
+	SetState(state State)
 	Halted() bool
 	Credentials() auth.Credentials
 	RemoteAddr() string
@@ -92,6 +95,15 @@ type Request interface {
 	IsAdHoc() bool
 	IndexApiVersion() int
 	FeatureControls() uint64
+	WorkloadClass() string
+	SetWorkloadClass(string)
+	QueueTime() time.Duration
+	SetQueueTime(time.Duration)
+	Keyspaces() []string
+	SetKeyspaces([]string)
+	SetTraceParent(traceParent string)
+	TraceSpan() *tracing.Span
+	StartExecutionSpan(start time.Time) *tracing.Span
 }
 
 type RequestID interface {
@@ -151,6 +163,15 @@ func ActiveRequestsDelete(id string) bool {
 	return actives.Delete(id, true)
 }
 
+func ActiveRequestsPut(request Request) errors.Error {
+	return actives.Put(request)
+}
+
+// removes a request that has run its course, without stopping it
+func ActiveRequestsRemove(id string) bool {
+	return actives.Delete(id, false)
+}
+
 func ActiveRequestsGet(id string, f func(Request)) errors.Error {
 	return actives.Get(id, f)
 }
@@ -209,6 +230,12 @@ type BaseRequest struct {
 	profile         Profile
 	indexApiVersion int    // Index API version
 	featureControls uint64 // feature bit controls
+	workloadClass   string
+	queueTime       time.Duration
+	keyspaces       []string // accessed, as namespace:keyspace
+	traceSpan       *tracing.Span
+	executionSpan   *tracing.Span
+	loggedErrors    []errors.Error
 }
 
 type requestIDImpl struct {
@@ -484,12 +511,28 @@ func (this *BaseRequest) Fatal(err errors.Error) {
 }
 
 func (this *BaseRequest) Error(err errors.Error) {
+	this.KeepError(err)
 	select {
 	case this.errors <- err:
 	default:
 	}
 }
 
+// keep the first few errors for completed requests
+func (this *BaseRequest) KeepError(err errors.Error) {
+	this.Lock()
+	if len(this.loggedErrors) < _ERROR_CAP {
+		this.loggedErrors = append(this.loggedErrors, err)
+	}
+	this.Unlock()
+}
+
+func (this *BaseRequest) LoggedErrors() []errors.Error {
+	this.RLock()
+	defer this.RUnlock()
+	return this.loggedErrors
+}
+
 func (this *BaseRequest) Warning(wrn errors.Error) {
 	select {
 	case this.warnings <- wrn:
@@ -580,6 +623,24 @@ func (this *BaseRequest) FmtPhaseTimes() map[string]interface{} {
 	return p
 }
 
+// The time spent in each phase, by phase name
+func (this *BaseRequest) PhaseTimes() map[string]time.Duration {
+	var p map[string]time.Duration = nil
+
+	nr := len(this.phaseStats)
+	for i := 0; i < nr; i++ {
+		duration := atomic.LoadUint64(&this.phaseStats[i].duration)
+		if duration > 0 {
+			if p == nil {
+				p = make(map[string]time.Duration,
+					execution.PHASES)
+			}
+			p[execution.Phases(i).String()] = time.Duration(duration)
+		}
+	}
+	return p
+}
+
 func (this *BaseRequest) SetTimings(o execution.Operator) {
 	this.timings = o
 }
@@ -624,6 +685,88 @@ func (this *BaseRequest) FeatureControls() uint64 {
 	return this.featureControls
 }
 
+func (this *BaseRequest) WorkloadClass() string {
+	this.RLock()
+	defer this.RUnlock()
+	return this.workloadClass
+}
+
+func (this *BaseRequest) SetWorkloadClass(class string) {
+	this.Lock()
+	defer this.Unlock()
+	this.workloadClass = class
+}
+
+func (this *BaseRequest) QueueTime() time.Duration {
+	this.RLock()
+	defer this.RUnlock()
+	return this.queueTime
+}
+
+func (this *BaseRequest) SetQueueTime(queueTime time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+	this.queueTime = queueTime
+}
+
+func (this *BaseRequest) Keyspaces() []string {
+	this.RLock()
+	defer this.RUnlock()
+	return this.keyspaces
+}
+
+func (this *BaseRequest) SetKeyspaces(keyspaces []string) {
+	this.Lock()
+	defer this.Unlock()
+	this.keyspaces = keyspaces
+}
+
+// Start tracing the request, as part of the trace described by
+// traceParent, if any
+func (this *BaseRequest) SetTraceParent(traceParent string) {
+	this.traceSpan = tracing.StartSpan("query", traceParent, this.requestTime)
+}
+
+func (this *BaseRequest) TraceSpan() *tracing.Span {
+	return this.traceSpan
+}
+
+func (this *BaseRequest) StartExecutionSpan(start time.Time) *tracing.Span {
+	this.executionSpan = this.traceSpan.StartChild("execute", start)
+	return this.executionSpan
+}
+
+// operator spans are derived from the profile, once execution is over
+func (this *BaseRequest) finishTrace(resultCount int, resultSize int, errorCount int) {
+	if this.traceSpan == nil {
+		return
+	}
+	if this.executionSpan != nil {
+		execution.TraceOperators(this.timings, this.executionSpan)
+		this.executionSpan.Finish()
+	}
+
+	span := this.traceSpan
+	span.SetAttribute("requestID", this.Id().String())
+	if this.ClientID().IsValid() {
+		span.SetAttribute("clientContextID", this.ClientID().String())
+	}
+	if this.statement != "" {
+		span.SetAttribute("statement", this.statement)
+	}
+	if this.prepared != nil && this.prepared.Name() != "" {
+		span.SetAttribute("preparedName", this.prepared.Name())
+	}
+	if this.Type() != "" {
+		span.SetAttribute("statementType", this.Type())
+	}
+	span.SetAttribute("state", string(this.State()))
+	span.SetAttribute("resultCount", resultCount)
+	span.SetAttribute("resultSize", resultSize)
+	span.SetAttribute("errorCount", errorCount)
+	span.Finish()
+}
+
 func (this *BaseRequest) Results() value.ValueChannel {
 	return this.results
 }
@@ -677,9 +820,16 @@ func (this *BaseRequest) CompleteRequest(requestTime time.Duration, serviceTime
 		this.timer.Stop()
 		this.timer = nil
 	}
+	this.finishTrace(resultCount, resultSize, errorCount)
 	LogRequest(requestTime, serviceTime, resultCount,
 		resultSize, errorCount, req, this, server)
 
+	// let the next request of the workload class in
+	if server != nil {
+		server.Release(this.Id().String())
+	}
+	this.releaseRateLimits(resultSize)
+
 	// Request Profiling - signal that request has completed and
 	// resources can be pooled / released as necessary
 	if this.timings != nil {
@@ -721,6 +871,11 @@ func (this *BaseRequest) EventUsers() []string {
 	return ret
 }
 
+// For audit.Auditable interface.
+func (this *BaseRequest) EventKeyspaces() []string {
+	return this.Keyspaces()
+}
+
 // For audit.Auditable interface.
 func (this *BaseRequest) EventNodeName() string {
 	ret := distributed.RemoteAccess().WhoAmI()
@@ -11,6 +11,7 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
 	"os"
 	"runtime"
@@ -22,6 +23,7 @@ import (
 	atomic "github.com/couchbase/go-couchbase/platform"
 	"github.com/couchbase/query/accounting"
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/auth"
 	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
@@ -30,6 +32,7 @@ import (
 	"github.com/couchbase/query/parser/n1ql"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/policy"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
@@ -95,12 +98,18 @@ type Server struct {
 	pretty      bool
 	srvprofile  Profile
 	srvcontrols bool
+	cursorIdle  time.Duration
+	workload    *workloadManager
 }
 
 // Default Keep Alive Length
 
 const KEEP_ALIVE_DEFAULT = 1024 * 16
 
+// Default time an open cursor can go without fetches
+
+const CURSOR_IDLE_DEFAULT = 5 * time.Minute
+
 func NewServer(store datastore.Datastore, sys datastore.Datastore, config clustering.ConfigurationStore,
 	acctng accounting.AccountingStore, namespace string, readonly bool,
 	channel, plusChannel RequestChannel, servicers, plusServicers, maxParallelism int,
@@ -124,6 +133,8 @@ func NewServer(store datastore.Datastore, sys datastore.Datastore, config cluste
 		pretty:      pretty,
 		srvcontrols: srvcontrols,
 		srvprofile:  srvprofile,
+		cursorIdle:  CURSOR_IDLE_DEFAULT,
+		workload:    newWorkloadManager(),
 	}
 
 	// special case handling for the atomic specfic stuff
@@ -137,6 +148,11 @@ func NewServer(store datastore.Datastore, sys datastore.Datastore, config cluste
 	rv.SetMaxIndexAPI(datastore.INDEX_API_MAX)
 	util.SetN1qlFeatureControl(util.DEF_N1QL_FEAT_CTRL)
 
+	// row security policies are shared through the configuration store
+	if config != nil {
+		policy.SetStore(config)
+	}
+
 	//	sys, err := system.NewDatastore(store)
 	//	if err != nil {
 	//		return nil, err
@@ -145,17 +161,65 @@ func NewServer(store datastore.Datastore, sys datastore.Datastore, config cluste
 	//	rv.systemstore = sys
 
 	// Setup callback function for metakv settings changes
-	callb := func(cfg Config) {
-		logging.Infof("Settings notifier from metakv\n")
+	// Stores that keep the settings themselves are set up by WatchSettings
+	if _, ok := config.(clustering.SettingsStore); !ok {
+		SetupSettingsNotifier(rv.settingsChanged, make(chan struct{}))
+	}
 
-		// SetParamValuesForAll accepts a full-set or subset of global configuration
-		// and updates those fields.
-		SetParamValuesForAll(cfg, rv)
+	return rv, nil
+}
+
+func (this *Server) settingsChanged(cfg Config) {
+	logging.Infof("Settings notifier\n")
+
+	// SetParamValuesForAll accepts a full-set or subset of global configuration
+	// and updates those fields.
+	SetParamValuesForAll(cfg, this)
+}
+
+// Applies the settings kept by the configuration store, if it keeps them,
+// once the server has been configured from the command line, so that they
+// take precedence.
+func (this *Server) WatchSettings() errors.Error {
+	store, ok := this.configstore.(clustering.SettingsStore)
+	if !ok {
+		return nil
 	}
+	return SetupSettingsStore(store, this.settingsChanged, make(chan struct{}))
+}
 
-	SetupSettingsNotifier(callb, make(chan struct{}))
+// reload the persisted prepared statements, preparing again from their
+// text those whose plans are no longer valid
+func (this *Server) ReloadPrepareds() {
+	plan.PreparedsReload(this.reprepare)
+}
 
-	return rv, nil
+func (this *Server) reprepare(record *plan.PreparedRecord) (*plan.Prepared, errors.Error) {
+	stmt, err := n1ql.ParseStatement(record.Text)
+	if err != nil {
+		return nil, errors.NewParseSyntaxError(err, "")
+	}
+	prepare, ok := stmt.(*algebra.Prepare)
+	if !ok {
+		return nil, errors.NewUnrecognizedPreparedError(fmt.Errorf("not a PREPARE statement: %v", record.Text))
+	}
+	namespace := record.Namespace
+	if namespace == "" {
+		namespace = this.namespace
+	}
+
+	// anonymous statements keep the name they were first given
+	prepare = algebra.NewPrepare(record.Name, prepare.Statement(), prepare.Text())
+	er := policy.Apply(prepare, namespace, auth.AuthenticatedUsers(record.Users))
+	if er != nil {
+		return nil, er
+	}
+	prepared, err := planner.BuildPrepare(prepare, this.datastore, this.systemstore, namespace,
+		this.MaxIndexAPI(), util.GetN1qlFeatureControl())
+	if err != nil {
+		return nil, errors.NewPlanError(err, "")
+	}
+	return prepared, nil
 }
 
 func (this *Server) Datastore() datastore.Datastore {
@@ -394,6 +458,14 @@ func (this *Server) SetTimeout(timeout time.Duration) {
 	this.timeout = timeout
 }
 
+func (this *Server) CursorIdleTimeout() time.Duration {
+	return this.cursorIdle
+}
+
+func (this *Server) SetCursorIdleTimeout(timeout time.Duration) {
+	this.cursorIdle = timeout
+}
+
 func (this *Server) Profile() Profile {
 	return this.srvprofile
 }
@@ -509,6 +581,7 @@ func (this *Server) serviceRequest(request Request) {
 		request.Failed(this)
 		return
 	}
+	request.SetKeyspaces(preparedKeyspaces(prepared))
 
 	maxParallelism := request.MaxParallelism()
 	if maxParallelism <= 0 {
@@ -534,6 +607,12 @@ func (this *Server) serviceRequest(request Request) {
 
 	operator.SetRoot()
 	request.SetTimings(operator)
+
+	// rate limits apply once the users are known
+	id := request.Id().String()
+	context.SetAdmission(func(users auth.AuthenticatedUsers, privileges *auth.Privileges) errors.Error {
+		return rateLimitAdmit(id, users, privileges)
+	})
 	request.Output().AddPhaseTime(execution.INSTANTIATE, time.Since(build))
 
 	if request.State() == FATAL {
@@ -548,6 +627,12 @@ func (this *Server) serviceRequest(request Request) {
 	if this.timeout > 0 && (this.timeout < timeout || timeout <= 0) {
 		timeout = this.timeout
 	}
+
+	// nor higher than the workload class timeout
+	classTimeout := this.WorkloadTimeout(request.WorkloadClass())
+	if classTimeout > 0 && (classTimeout < timeout || timeout <= 0) {
+		timeout = classTimeout
+	}
 	if timeout > 0 {
 		request.SetTimer(time.AfterFunc(timeout, func() { request.Expire(TIMEOUT, timeout) }))
 		context.SetReqDeadline(time.Now().Add(timeout))
@@ -558,17 +643,22 @@ func (this *Server) serviceRequest(request Request) {
 	go request.Execute(this, prepared.Signature(), operator)
 
 	run := time.Now()
+	context.SetTraceSpan(request.StartExecutionSpan(run))
 	operator.RunOnce(context, nil)
 
 	request.Output().AddPhaseTime(execution.RUN, time.Since(run))
 }
 
 func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepared, errors.Error) {
+	span := request.TraceSpan()
 	prepared := request.Prepared()
 	if prepared == nil {
 		parse := time.Now()
+		parseSpan := span.StartChild("parse", parse)
 		stmt, err := n1ql.ParseStatement(request.Statement())
 		if err != nil {
+			parseSpan.SetAttribute("error", err.Error())
+			parseSpan.Finish()
 			return nil, errors.NewParseSyntaxError(err, "")
 		}
 
@@ -578,17 +668,29 @@ func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepar
 		}
 
 		prep := time.Now()
+		parseSpan.FinishAt(prep)
+		planSpan := span.StartChild("plan", prep)
+		defer planSpan.Finish()
 		namedArgs := request.NamedArgs()
 		positionalArgs := request.PositionalArgs()
 		if isprepare {
 			namedArgs = nil
 			positionalArgs = nil
 		}
+
+		// inject row security policies for the users running the request
+		er := this.applyPolicies(stmt, request, namespace)
+		if er != nil {
+			planSpan.SetAttribute("error", er.Error())
+			return nil, er
+		}
 		prepared, err = planner.BuildPrepared(stmt, this.datastore, this.systemstore, namespace, false,
 			namedArgs, positionalArgs, request.IndexApiVersion(), request.FeatureControls())
 		if err != nil {
+			planSpan.SetAttribute("error", err.Error())
 			return nil, errors.NewPlanError(err, "")
 		}
+		planSpan.SetAttribute("prepared.cache", "miss")
 
 		// In order to allow monitoring to track prepared statement executed through
 		// N1QL "EXECUTE", set request.prepared - because, as of yet, it isn't!
@@ -598,12 +700,17 @@ func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepar
 			if exec.Prepared() != nil {
 
 				// remote checking is done during plannig
+				// the statement may have been deleted or evicted since
 				prep, _ := plan.GetPrepared(exec.Prepared(), plan.OPT_TRACK)
-				request.SetPrepared(prep)
-
-				// when executing prepared statements, we set the type to that
-				// of the prepared statement
-				request.SetType(prep.Type())
+				if prep != nil {
+					request.SetPrepared(prep)
+					planSpan.SetAttribute("prepared.cache", "hit")
+					planSpan.SetAttribute("prepared.name", prep.Name())
+
+					// when executing prepared statements, we set the type to that
+					// of the prepared statement
+					request.SetType(prep.Type())
+				}
 			}
 		default:
 
@@ -628,6 +735,11 @@ func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepar
 
 		// ditto
 		request.SetType(prepared.Type())
+
+		planSpan := span.StartChild("plan", time.Now())
+		planSpan.SetAttribute("prepared.cache", "hit")
+		planSpan.SetAttribute("prepared.name", prepared.Name())
+		planSpan.Finish()
 	}
 
 	if logging.LogLevel() >= logging.DEBUG {
@@ -638,6 +750,42 @@ func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepar
 	return prepared, nil
 }
 
+// policies only depend on the users once some exist, so only then authenticate
+// ahead of the Authorize operator
+func (this *Server) applyPolicies(stmt algebra.Statement, request Request, namespace string) errors.Error {
+	var users auth.AuthenticatedUsers
+	if policy.Exist() {
+		var err errors.Error
+		users, err = this.datastore.Authorize(nil, request.Credentials(), request.OriginalHttpRequest())
+		if err != nil {
+			return err
+		}
+	}
+	return policy.Apply(stmt, namespace, users)
+}
+
+// the keyspaces a statement accesses, as per the privileges it requires
+func preparedKeyspaces(prepared *plan.Prepared) []string {
+	op := prepared.Operator
+	if seq, ok := op.(*plan.Sequence); ok && len(seq.Children()) > 0 {
+		op = seq.Children()[0]
+	}
+	authorize, ok := op.(*plan.Authorize)
+	if !ok || authorize.Privileges() == nil {
+		return nil
+	}
+
+	var keyspaces []string
+	seen := make(map[string]bool, authorize.Privileges().Num())
+	authorize.Privileges().ForEach(func(pair auth.PrivilegePair) {
+		if pair.Target != "" && !seen[pair.Target] {
+			seen[pair.Target] = true
+			keyspaces = append(keyspaces, pair.Target)
+		}
+	})
+	return keyspaces
+}
+
 func logExplain(prepared *plan.Prepared) {
 	var pl plan.Operator = prepared
 	explain, err := json.MarshalIndent(pl, "", "    ")
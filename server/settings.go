@@ -11,12 +11,15 @@ package server
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/couchbase/cbauth/metakv"
 	"github.com/couchbase/indexing/secondary/common"
 	gsi "github.com/couchbase/indexing/secondary/queryport/n1ql"
+	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/value"
 )
@@ -29,9 +32,15 @@ const (
 	QuerySettingsMetaPath = QuerySettingsMetaDir + "config"
 )
 
+// settings from metakv that are not yet mapped to service settings
+const _QUERYSETTINGS = "query.settings."
+
+const _TMPSPACEDIR = "query.settings.tmp_space_dir"
+const _RATELIMITS = "query.settings.rate_limits"
+
 // List of parameters to be sent to the indexer
 var _INDEXERPARAM = map[string]string{
-	"query.settings.tmp_space_dir":  "query_tmpspace_dir",
+	_TMPSPACEDIR:                    "query_tmpspace_dir",
 	"query.settings.tmp_space_size": "query_tmpspace_limit",
 }
 
@@ -93,6 +102,33 @@ func SetupSettingsNotifier(callb func(Config), cancelCh chan struct{}) {
 	return
 }
 
+// Configuration stores that keep the settings themselves, rather than
+// metakv, apply them at startup, and again whenever they are edited.
+func SetupSettingsStore(store clustering.SettingsStore, callb func(Config), cancelCh chan struct{}) errors.Error {
+	settings, err := store.GetSettings()
+	if err != nil {
+		return err
+	}
+	if len(settings) > 0 {
+		logging.Infof("Stored settings loaded: %v", settings)
+		callb(value.NewValue(settings))
+	}
+
+	store.WatchSettings(func(settings map[string]interface{}) {
+		logging.Infof("New settings received: %v", settings)
+		callb(value.NewValue(settings))
+	}, cancelCh)
+	return nil
+}
+
+// Settings are applied to the service by the same code as /admin/settings,
+// which registers itself here.
+var _SETTINGS_PROCESSOR func(map[string]interface{}, *Server) errors.Error
+
+func SetSettingsProcessor(processor func(map[string]interface{}, *Server) errors.Error) {
+	_SETTINGS_PROCESSOR = processor
+}
+
 func valConvert(val []byte) (Config, error) {
 	nval := value.NewValue(val)
 
@@ -117,7 +153,20 @@ func SetParamValuesForAll(cfg Config, srvr *Server) {
 		paramName, ok := _INDEXERPARAM[key]
 		if ok {
 			idxrSettings[paramName] = val
-		} else {
+
+			// async requests spool to the same temp space
+			if dir, isString := val.(string); isString && key == _TMPSPACEDIR {
+				AsyncSetDir(dir)
+			}
+		} else if key == _RATELIMITS {
+			limits, err := NewRateLimits(value.NewValue(val).Actual())
+			if err != nil {
+				logging.Errorf(" ERROR: Could not set rate limits :: %v", err)
+			} else {
+				SetRateLimits(limits)
+				logging.Infof(" Rate limits have been updated %v", val)
+			}
+		} else if !strings.HasPrefix(key, _QUERYSETTINGS) {
 			// QUERY PARAM
 			querySettings[key] = val
 		}
@@ -140,8 +189,11 @@ func SetParamValuesForAll(cfg Config, srvr *Server) {
 		}
 	}
 
-	if len(querySettings) > 0 {
+	if len(querySettings) > 0 && _SETTINGS_PROCESSOR != nil {
 		// Set the query values
-		//http.ProcessSettings(querySettings, srvr)
+		err := _SETTINGS_PROCESSOR(querySettings, srvr)
+		if err != nil {
+			logging.Errorf(" ERROR: Could not set query settings :: %v", err)
+		}
 	}
 }
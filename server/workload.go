@@ -0,0 +1,557 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+ Workload classes govern the admission of requests to the servicers.
+ Each request is mapped to a class, explicitly or by its users, client context id
+ or statement type. Classes cap the number of requests they have executing, and
+ queue those in excess up to a given length, for no longer than their timeout,
+ which also caps the timeout of the requests they execute.
+ When servicers become available, they go to the queued request of the highest
+ priority class that can take more requests, and, within a class, to the oldest.
+ With no classes configured, requests go straight to the servicers.
+*/
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+)
+
+// name of the class for requests not matching any other
+const WORKLOAD_DEFAULT_CLASS = "default"
+
+const WORKLOAD_DEFAULT_QUEUE = 1024
+
+// Workload class settings
+const (
+	_WORKLOAD_NAME        = "name"
+	_WORKLOAD_PRIORITY    = "priority"
+	_WORKLOAD_CONCURRENCY = "max-concurrency"
+	_WORKLOAD_QUEUE       = "queue-length"
+	_WORKLOAD_TIMEOUT     = "timeout"
+	_WORKLOAD_USERS       = "users"
+	_WORKLOAD_CLIENTS     = "client-context-id-prefixes"
+	_WORKLOAD_STATEMENTS  = "statement-types"
+)
+
+const _WORKLOAD_SETTING = "workload-classes"
+
+type WorkloadClass struct {
+	Name           string
+	Priority       int
+	MaxConcurrency int // no limit if not positive
+	QueueLength    int
+	Timeout        time.Duration // no limit if not positive
+	Users          []string
+	ClientPrefixes []string
+	StatementTypes []string
+}
+
+// Parse workload classes from their settings, a list of objects.
+func NewWorkloadClasses(val interface{}) ([]*WorkloadClass, errors.Error) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, errors.NewAdminSettingTypeError(_WORKLOAD_SETTING, val)
+	}
+
+	names := make(map[string]bool, len(list))
+	classes := make([]*WorkloadClass, 0, len(list))
+	for _, v := range list {
+		settings, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.NewAdminSettingTypeError(_WORKLOAD_SETTING, v)
+		}
+
+		class, err := newWorkloadClass(settings)
+		if err != nil {
+			return nil, err
+		}
+		if names[class.Name] {
+			return nil, errors.NewAdminSettingTypeError(_WORKLOAD_SETTING+"."+_WORKLOAD_NAME, class.Name)
+		}
+		names[class.Name] = true
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func newWorkloadClass(settings map[string]interface{}) (*WorkloadClass, errors.Error) {
+	rv := &WorkloadClass{
+		QueueLength: WORKLOAD_DEFAULT_QUEUE,
+	}
+
+	for setting, val := range settings {
+		var ok bool
+
+		switch setting {
+		case _WORKLOAD_NAME:
+			rv.Name, ok = val.(string)
+			ok = ok && validClassName(rv.Name)
+		case _WORKLOAD_PRIORITY:
+			rv.Priority, ok = workloadInt(val)
+		case _WORKLOAD_CONCURRENCY:
+			rv.MaxConcurrency, ok = workloadInt(val)
+		case _WORKLOAD_QUEUE:
+			rv.QueueLength, ok = workloadInt(val)
+			ok = ok && rv.QueueLength >= 0
+		case _WORKLOAD_TIMEOUT:
+			switch val := val.(type) {
+			case float64:
+				rv.Timeout, ok = time.Duration(val), true
+			case string:
+				var e error
+				rv.Timeout, e = time.ParseDuration(val)
+				ok = e == nil
+			}
+		case _WORKLOAD_USERS:
+			rv.Users, ok = workloadStrings(val)
+		case _WORKLOAD_CLIENTS:
+			rv.ClientPrefixes, ok = workloadStrings(val)
+		case _WORKLOAD_STATEMENTS:
+			rv.StatementTypes, ok = workloadStrings(val)
+			for i, t := range rv.StatementTypes {
+				rv.StatementTypes[i] = strings.ToUpper(t)
+			}
+		}
+		if !ok {
+			return nil, errors.NewAdminSettingTypeError(_WORKLOAD_SETTING+"."+setting, val)
+		}
+	}
+
+	if rv.Name == "" {
+		return nil, errors.NewAdminSettingTypeError(_WORKLOAD_SETTING+"."+_WORKLOAD_NAME, rv.Name)
+	}
+	return rv, nil
+}
+
+// class names end up in metric names
+func validClassName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func workloadInt(val interface{}) (int, bool) {
+	v, ok := val.(float64)
+	return int(v), ok && v == float64(int(v))
+}
+
+func workloadStrings(val interface{}) ([]string, bool) {
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	rv := make([]string, len(list))
+	for i, v := range list {
+		rv[i], ok = v.(string)
+		if !ok {
+			return nil, false
+		}
+	}
+	return rv, true
+}
+
+// The settings for the class, as accepted by NewWorkloadClasses()
+func (this *WorkloadClass) Settings() map[string]interface{} {
+	rv := map[string]interface{}{
+		_WORKLOAD_NAME:        this.Name,
+		_WORKLOAD_PRIORITY:    this.Priority,
+		_WORKLOAD_CONCURRENCY: this.MaxConcurrency,
+		_WORKLOAD_QUEUE:       this.QueueLength,
+		_WORKLOAD_TIMEOUT:     this.Timeout.String(),
+	}
+	if len(this.Users) > 0 {
+		rv[_WORKLOAD_USERS] = this.Users
+	}
+	if len(this.ClientPrefixes) > 0 {
+		rv[_WORKLOAD_CLIENTS] = this.ClientPrefixes
+	}
+	if len(this.StatementTypes) > 0 {
+		rv[_WORKLOAD_STATEMENTS] = this.StatementTypes
+	}
+	return rv
+}
+
+func (this *WorkloadClass) matches(users []string, clientId string, stmtType string) bool {
+	for _, u := range this.Users {
+		for _, user := range users {
+			if u == user {
+				return true
+			}
+		}
+	}
+	if clientId != "" {
+		for _, p := range this.ClientPrefixes {
+			if strings.HasPrefix(clientId, p) {
+				return true
+			}
+		}
+	}
+	for _, t := range this.StatementTypes {
+		if t == stmtType {
+			return true
+		}
+	}
+	return false
+}
+
+type workloadEntry struct {
+	request Request
+	channel RequestChannel
+	queued  time.Time
+	timer   *time.Timer
+}
+
+type workloadQueue struct {
+	class   *WorkloadClass
+	running int
+	queue   []*workloadEntry
+}
+
+func (this *workloadQueue) hasRoom() bool {
+	return this.class.MaxConcurrency <= 0 || this.running < this.class.MaxConcurrency
+}
+
+func (this *workloadQueue) remove(entry *workloadEntry) bool {
+	for i, e := range this.queue {
+		if e == entry {
+			copy(this.queue[i:], this.queue[i+1:])
+			this.queue[len(this.queue)-1] = nil
+			this.queue = this.queue[:len(this.queue)-1]
+			return true
+		}
+	}
+	return false
+}
+
+type workloadManager struct {
+	sync.Mutex
+	classes []*workloadQueue // in matching order, default class last
+	byName  map[string]*workloadQueue
+	running map[string]*workloadQueue // by request id
+	total   int                       // requests running across classes
+}
+
+func newWorkloadManager() *workloadManager {
+	return &workloadManager{
+		byName:  map[string]*workloadQueue{},
+		running: map[string]*workloadQueue{},
+	}
+}
+
+func (this *Server) WorkloadClasses() []*WorkloadClass {
+	this.workload.Lock()
+	defer this.workload.Unlock()
+
+	rv := make([]*WorkloadClass, 0, len(this.workload.classes))
+	for _, q := range this.workload.classes {
+		rv = append(rv, q.class)
+	}
+	return rv
+}
+
+// Replace the workload classes.
+// Requests running or queued keep their counts against classes of the
+// same name, and go to the default class if theirs has gone away.
+func (this *Server) SetWorkloadClasses(classes []*WorkloadClass) {
+	m := this.workload
+	m.Lock()
+
+	old := m.byName
+	m.classes = nil
+	m.byName = map[string]*workloadQueue{}
+	hasDefault := false
+	for _, class := range classes {
+		q := &workloadQueue{class: class}
+		m.classes = append(m.classes, q)
+		m.byName[class.Name] = q
+		hasDefault = hasDefault || class.Name == WORKLOAD_DEFAULT_CLASS
+	}
+	if len(m.classes) > 0 && !hasDefault {
+		q := &workloadQueue{class: &WorkloadClass{
+			Name:        WORKLOAD_DEFAULT_CLASS,
+			QueueLength: WORKLOAD_DEFAULT_QUEUE,
+		}}
+		m.classes = append(m.classes, q)
+		m.byName[WORKLOAD_DEFAULT_CLASS] = q
+	}
+
+	// carry over what is in flight
+	for id, q := range m.running {
+		nq := m.byName[q.class.Name]
+		if nq == nil {
+			nq = m.byName[WORKLOAD_DEFAULT_CLASS]
+		}
+		if nq != nil {
+			nq.running++
+		}
+		m.running[id] = nq
+	}
+	var admitted []*workloadEntry
+	for _, q := range old {
+		for _, entry := range q.queue {
+			nq := m.byName[q.class.Name]
+			if nq == nil {
+				nq = m.byName[WORKLOAD_DEFAULT_CLASS]
+			}
+			if nq == nil || nq.class.Name != q.class.Name {
+				accounting.RecordWorkloadQueued(this.acctstore, q.class.Name, -1)
+			}
+			if nq == nil {
+
+				// no more classes: everything goes
+				this.admit(m, nil, entry)
+				admitted = append(admitted, entry)
+				continue
+			}
+			if nq.class.Name != q.class.Name {
+				accounting.RecordWorkloadQueued(this.acctstore, nq.class.Name, 1)
+			}
+			entry.request.SetWorkloadClass(nq.class.Name)
+			nq.queue = append(nq.queue, entry)
+		}
+	}
+	for _, q := range m.classes {
+		sort.SliceStable(q.queue, func(i, j int) bool {
+			return q.queue[i].queued.Before(q.queue[j].queued)
+		})
+	}
+	admitted = append(admitted, this.dispatch(m)...)
+	m.Unlock()
+
+	send(admitted)
+}
+
+// The timeout of the class, if the class is known
+func (this *Server) WorkloadTimeout(name string) time.Duration {
+	this.workload.Lock()
+	defer this.workload.Unlock()
+
+	q := this.workload.byName[name]
+	if q == nil {
+		return 0
+	}
+	return q.class.Timeout
+}
+
+// Submit the request for execution on the given channel, once its workload
+// class admits it.
+// Returns false if the request cannot be accepted, because the channel or
+// the class queue is full.
+func (this *Server) Submit(request Request, channel RequestChannel) bool {
+	m := this.workload
+	m.Lock()
+
+	if len(m.classes) == 0 {
+		m.Unlock()
+		return trySend(request, channel)
+	}
+
+	q, err := m.classify(request)
+	if err != nil {
+		m.Unlock()
+		request.Fail(err)
+		request.Failed(this)
+		return true
+	}
+	request.SetWorkloadClass(q.class.Name)
+	entry := &workloadEntry{
+		request: request,
+		channel: channel,
+		queued:  time.Now(),
+	}
+
+	// run right away
+	if q.hasRoom() && m.total < this.workloadPool() {
+		this.admit(m, q, entry)
+		m.Unlock()
+		if trySend(request, channel) {
+			return true
+		}
+		this.Release(request.Id().String())
+		return false
+	}
+
+	if len(q.queue) >= q.class.QueueLength {
+		m.Unlock()
+		accounting.RecordWorkloadRejected(this.acctstore, q.class.Name)
+		return false
+	}
+
+	request.SetState(QUEUED)
+	q.queue = append(q.queue, entry)
+	accounting.RecordWorkloadQueued(this.acctstore, q.class.Name, 1)
+	if timeout := q.class.Timeout; timeout > 0 {
+		entry.timer = time.AfterFunc(timeout, func() {
+			this.expire(entry, timeout)
+		})
+	}
+	m.Unlock()
+	return true
+}
+
+// Release the slot held by a request, and let the next ones in.
+func (this *Server) Release(id string) {
+	m := this.workload
+	m.Lock()
+
+	q, ok := m.running[id]
+	if !ok {
+		m.Unlock()
+		return
+	}
+	delete(m.running, id)
+	m.total--
+	if q != nil {
+		q.running--
+	}
+	admitted := this.dispatch(m)
+	m.Unlock()
+
+	send(admitted)
+}
+
+// Total number of requests that can run across classes
+func (this *Server) workloadPool() int {
+	return this.Servicers() + this.PlusServicers()
+}
+
+// explicit class first, then the first class matching the request
+func (this *workloadManager) classify(request Request) (*workloadQueue, errors.Error) {
+	name := request.WorkloadClass()
+	if name != "" {
+		q := this.byName[name]
+		if q == nil {
+			return nil, errors.NewServiceErrorWorkloadClass(name)
+		}
+		return q, nil
+	}
+
+	users := strings.Split(datastore.CredsString(request.Credentials(), request.OriginalHttpRequest()), ",")
+	clientId := request.ClientID().String()
+	stmtType := statementType(request)
+	for _, q := range this.classes {
+		if q.class.matches(users, clientId, stmtType) {
+			return q, nil
+		}
+	}
+	return this.byName[WORKLOAD_DEFAULT_CLASS], nil
+}
+
+// requests are only parsed by the servicers, so make do with the first keyword
+func statementType(request Request) string {
+	if request.Prepared() != nil {
+		return request.Prepared().Type()
+	}
+
+	stmt := strings.TrimLeft(request.Statement(), " \t\r\n(")
+	end := strings.IndexAny(stmt, " \t\r\n(")
+	if end >= 0 {
+		stmt = stmt[:end]
+	}
+	return strings.ToUpper(stmt)
+}
+
+// lock held
+func (this *Server) admit(m *workloadManager, q *workloadQueue, entry *workloadEntry) {
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+
+	name := entry.request.WorkloadClass()
+	wait := time.Since(entry.queued)
+	m.running[entry.request.Id().String()] = q
+	m.total++
+	if q != nil {
+		q.running++
+	}
+	entry.request.SetQueueTime(wait)
+	accounting.RecordWorkloadAdmitted(this.acctstore, name, wait)
+}
+
+// lock held
+// pick queued requests by priority and age, for as long as there is room
+func (this *Server) dispatch(m *workloadManager) []*workloadEntry {
+	var admitted []*workloadEntry
+
+	pool := this.workloadPool()
+	for m.total < pool {
+		var next *workloadQueue
+		for _, q := range m.classes {
+			if len(q.queue) == 0 || !q.hasRoom() {
+				continue
+			}
+			if next == nil || q.class.Priority > next.class.Priority ||
+				(q.class.Priority == next.class.Priority && q.queue[0].queued.Before(next.queue[0].queued)) {
+				next = q
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		entry := next.queue[0]
+		next.remove(entry)
+		accounting.RecordWorkloadQueued(this.acctstore, next.class.Name, -1)
+		this.admit(m, next, entry)
+		admitted = append(admitted, entry)
+	}
+	return admitted
+}
+
+// requests stopped while queued go all the same, and stop as soon as they execute
+func send(admitted []*workloadEntry) {
+	for _, entry := range admitted {
+		request := entry.request
+		if request.State() == QUEUED {
+			request.SetState(RUNNING)
+		}
+		go func(channel RequestChannel) {
+			channel <- request
+		}(entry.channel)
+	}
+}
+
+func trySend(request Request, channel RequestChannel) bool {
+	select {
+	case channel <- request:
+		return true
+	default:
+		return false
+	}
+}
+
+// give up on a request that has been queued for too long
+func (this *Server) expire(entry *workloadEntry, timeout time.Duration) {
+	m := this.workload
+	m.Lock()
+	q := m.byName[entry.request.WorkloadClass()]
+	found := q != nil && q.remove(entry)
+	m.Unlock()
+
+	if !found {
+		return
+	}
+	accounting.RecordWorkloadQueued(this.acctstore, q.class.Name, -1)
+	logging.Infof("Request %v timed out in workload class %v queue", entry.request.Id(), q.class.Name)
+	entry.request.SetQueueTime(time.Since(entry.queued))
+	entry.request.Fail(errors.NewTimeoutError(timeout))
+	entry.request.Failed(this)
+}
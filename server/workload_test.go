@@ -0,0 +1,89 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func parseWorkloadClasses(t *testing.T, s string) ([]*WorkloadClass, bool) {
+	var val interface{}
+	if err := json.Unmarshal([]byte(s), &val); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", s, err)
+	}
+	classes, err := NewWorkloadClasses(val)
+	return classes, err == nil
+}
+
+func TestWorkloadClassSettings(t *testing.T) {
+	classes, ok := parseWorkloadClasses(t, `[
+		{"name": "batch", "priority": 1, "max-concurrency": 2, "queue-length": 10, "timeout": "30s",
+		 "users": ["etl"], "client-context-id-prefixes": ["batch-"]},
+		{"name": "dashboards", "priority": 10, "timeout": 5e9, "statement-types": ["select"]}
+	]`)
+	if !ok || len(classes) != 2 {
+		t.Fatalf("Expected 2 classes, received %v", classes)
+	}
+
+	batch, dash := classes[0], classes[1]
+	if batch.Priority != 1 || batch.MaxConcurrency != 2 || batch.QueueLength != 10 || batch.Timeout != 30*time.Second {
+		t.Errorf("Unexpected batch class %+v", batch)
+	}
+	if dash.QueueLength != WORKLOAD_DEFAULT_QUEUE || dash.Timeout != 5*time.Second || dash.StatementTypes[0] != "SELECT" {
+		t.Errorf("Unexpected dashboards class %+v", dash)
+	}
+
+	tests := []struct {
+		class    *WorkloadClass
+		users    []string
+		clientId string
+		stmtType string
+		match    bool
+	}{
+		{batch, []string{"admin", "etl"}, "", "SELECT", true},
+		{batch, []string{""}, "batch-42", "SELECT", true},
+		{batch, []string{""}, "dash-42", "SELECT", false},
+		{dash, []string{""}, "", "SELECT", true},
+		{dash, []string{""}, "", "UPDATE", false},
+	}
+	for _, test := range tests {
+		if match := test.class.matches(test.users, test.clientId, test.stmtType); match != test.match {
+			t.Errorf("%s %v %q %s: expected %v", test.class.Name, test.users, test.clientId, test.stmtType, test.match)
+		}
+	}
+
+	// settings round trip
+	val := []interface{}{}
+	for _, class := range classes {
+		val = append(val, class.Settings())
+	}
+	buf, _ := json.Marshal(val)
+	again, ok := parseWorkloadClasses(t, string(buf))
+	if !ok || len(again) != 2 || again[0].Timeout != batch.Timeout || again[1].Priority != dash.Priority {
+		t.Errorf("Settings do not round trip: %s", buf)
+	}
+
+	invalid := []string{
+		`{"name": "batch"}`,
+		`[{"priority": 1}]`,
+		`[{"name": "batch"}, {"name": "batch"}]`,
+		`[{"name": "bad name"}]`,
+		`[{"name": "batch", "queue-length": -1}]`,
+		`[{"name": "batch", "timeout": "soon"}]`,
+		`[{"name": "batch", "users": "etl"}]`,
+	}
+	for _, s := range invalid {
+		if _, ok := parseWorkloadClasses(t, s); ok {
+			t.Errorf("Expected %s to be invalid", s)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/couchbase/query/datastore"
@@ -206,6 +207,96 @@ func TestSimpleSelect(t *testing.T) {
 	}
 }
 
+func TestExplainAnalyze(t *testing.T) {
+	qc := start()
+
+	r, _, err := Run(qc, true, "EXPLAIN ANALYZE SELECT * FROM default:orders")
+	if err != nil {
+		t.Errorf("did not expect err %s", err.Error())
+	}
+	if len(r) != 1 {
+		t.Fatalf("expected a single plan, found %v", len(r))
+	}
+	explain, _ := r[0].(map[string]interface{})
+	plan, _ := explain["plan"].(map[string]interface{})
+	if explain["analyze"] != true || plan["#operator"] != "Sequence" || plan["#stats"] == nil {
+		t.Errorf("unexpected plan %v", explain)
+	}
+
+	// mutations only go as far as the datastore
+	r, _, err = Run(qc, true, "EXPLAIN ANALYZE UPDATE default:orders SET analyzed = true")
+	if err != nil {
+		t.Errorf("did not expect err %s", err.Error())
+	}
+	if len(r) != 1 {
+		t.Errorf("expected a single plan, found %v", len(r))
+	}
+	r, _, err = Run(qc, true, "SELECT 1 FROM default:orders WHERE analyzed IS NOT MISSING")
+	if err != nil {
+		t.Errorf("did not expect err %s", err.Error())
+	}
+	if len(r) != 0 {
+		t.Errorf("expected no document to be updated, found %v", len(r))
+	}
+}
+
+func TestExplainFormat(t *testing.T) {
+	qc := start()
+
+	r, _, err := Run(qc, true, "EXPLAIN FORMAT TEXT SELECT * FROM default:orders WHERE id = 1")
+	if err != nil {
+		t.Errorf("did not expect err %s", err.Error())
+	}
+	if len(r) != 1 {
+		t.Fatalf("expected a single plan, found %v", len(r))
+	}
+	explain, _ := r[0].(map[string]interface{})
+	lines, _ := explain["plan"].([]interface{})
+	if len(lines) == 0 || lines[0] != "Sequence" {
+		t.Fatalf("unexpected plan %v", explain)
+	}
+	found := false
+	for _, line := range lines {
+		l, _ := line.(string)
+		if strings.HasPrefix(strings.TrimSpace(l), "PrimaryScan") &&
+			strings.Contains(l, "keyspace=default:orders") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a primary scan on orders in %v", lines)
+	}
+
+	r, _, err = Run(qc, true, "EXPLAIN FORMAT DOT SELECT * FROM default:orders WHERE id = 1")
+	if err != nil {
+		t.Errorf("did not expect err %s", err.Error())
+	}
+	if len(r) != 1 {
+		t.Fatalf("expected a single plan, found %v", len(r))
+	}
+	explain, _ = r[0].(map[string]interface{})
+	graph, _ := explain["plan"].(string)
+	if !strings.HasPrefix(graph, "digraph plan {") || !strings.Contains(graph, "n1 -> n0;") {
+		t.Errorf("unexpected graph %v", graph)
+	}
+
+	_, _, err = Run(qc, true, "EXPLAIN FORMAT XML SELECT * FROM default:orders")
+	if err == nil {
+		t.Errorf("expected invalid format to be rejected")
+	}
+
+	// the analyzed plan is only rendered as JSON
+	for _, stmt := range []string{
+		"EXPLAIN FORMAT TEXT ANALYZE SELECT * FROM default:orders",
+		"EXPLAIN ANALYZE FORMAT DOT SELECT * FROM default:orders",
+	} {
+		_, _, err = Run(qc, true, stmt)
+		if err == nil || !strings.Contains(err.Error(), "cannot be combined with ANALYZE") {
+			t.Errorf("expected %s to be rejected, got %v", stmt, err)
+		}
+	}
+}
+
 func TestAllCaseFiles(t *testing.T) {
 	qc := start()
 	matches, err := filepath.Glob("json/default/cases/case_*.json")
@@ -0,0 +1,62 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+
+ Package exporter_file writes finished spans as JSON lines, for local use.
+*/
+package exporter_file
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/couchbase/query/tracing"
+)
+
+type fileExporter struct {
+	sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	encoder *json.Encoder
+}
+
+// Write spans to w, one per line
+func NewExporter(w io.Writer) tracing.Exporter {
+	return &fileExporter{w: w, encoder: json.NewEncoder(w)}
+}
+
+// Append spans to the named file
+func NewFileExporter(path string) (tracing.Exporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{w: f, closer: f, encoder: json.NewEncoder(f)}, nil
+}
+
+func (this *fileExporter) Export(span *tracing.Span) {
+	this.Lock()
+	defer this.Unlock()
+	if this.encoder != nil {
+		this.encoder.Encode(span)
+	}
+}
+
+func (this *fileExporter) Close() {
+	this.Lock()
+	defer this.Unlock()
+	this.encoder = nil
+	if this.closer != nil {
+		this.closer.Close()
+		this.closer = nil
+	}
+}
@@ -0,0 +1,42 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package resolver
+
+import (
+	"os"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/tracing"
+	"github.com/couchbase/query/tracing/exporter_file"
+)
+
+// Set the span exporter from its uri:
+// "stdout", "stderr" or "file:<path>"; "" or "none" disable tracing
+func NewExporter(uri string) (tracing.Exporter, errors.Error) {
+	var exporter tracing.Exporter
+	switch {
+	case uri == "" || uri == "none":
+	case uri == "stdout":
+		exporter = exporter_file.NewExporter(os.Stdout)
+	case uri == "stderr":
+		exporter = exporter_file.NewExporter(os.Stderr)
+	case strings.HasPrefix(uri, "file:") && len(uri) > len("file:"):
+		var err error
+		exporter, err = exporter_file.NewFileExporter(uri[len("file:"):])
+		if err != nil {
+			return nil, errors.NewAdminInvalidURL("Trace exporter", uri)
+		}
+	default:
+		return nil, errors.NewAdminInvalidURL("Trace exporter", uri)
+	}
+	tracing.SetExporter(exporter)
+	return exporter, nil
+}
@@ -0,0 +1,328 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+
+ Package tracing provides spans describing the execution of a request,
+ with W3C trace context propagation, and a pluggable exporter.
+
+ No spans are produced unless an exporter has been set.
+*/
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C trace context: https://www.w3.org/TR/trace-context/
+const (
+	TRACEPARENT = "traceparent"
+	TRACESTATE  = "tracestate"
+
+	_VERSION = "00"
+	_SAMPLED = byte(0x01)
+)
+
+type TraceId [16]byte
+type SpanId [8]byte
+
+func (this TraceId) String() string {
+	return hex.EncodeToString(this[:])
+}
+
+func (this TraceId) IsValid() bool {
+	return this != TraceId{}
+}
+
+func (this SpanId) String() string {
+	return hex.EncodeToString(this[:])
+}
+
+func (this SpanId) IsValid() bool {
+	return this != SpanId{}
+}
+
+// The identity of a span, as propagated across process boundaries
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Flags   byte
+}
+
+func (this SpanContext) IsValid() bool {
+	return this.TraceId.IsValid() && this.SpanId.IsValid()
+}
+
+func (this SpanContext) IsSampled() bool {
+	return this.Flags&_SAMPLED != 0
+}
+
+// The traceparent header value for this context
+func (this SpanContext) TraceParent() string {
+	if !this.IsValid() {
+		return ""
+	}
+	return _VERSION + "-" + this.TraceId.String() + "-" + this.SpanId.String() + "-" +
+		hex.EncodeToString([]byte{this.Flags})
+}
+
+// Parse a traceparent header value.
+// Future versions may append fields, which we ignore.
+func ParseTraceParent(s string) (SpanContext, bool) {
+	var rv SpanContext
+
+	fields := strings.Split(strings.TrimSpace(s), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || fields[0] == "ff" ||
+		(fields[0] == _VERSION && len(fields) != 4) {
+		return rv, false
+	}
+	if !decodeHex(rv.TraceId[:], fields[1]) || !decodeHex(rv.SpanId[:], fields[2]) {
+		return rv, false
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], fields[3]) {
+		return rv, false
+	}
+	rv.Flags = flags[0]
+	return rv, rv.IsValid()
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Implemented by contexts that carry a trace, so that the trace can
+// be propagated to services called on behalf of a request
+type Carrier interface {
+	TraceParent() string
+}
+
+// The traceparent carried by a context, if any
+func TraceParent(context interface{}) string {
+	carrier, ok := context.(Carrier)
+	if !ok {
+		return ""
+	}
+	return carrier.TraceParent()
+}
+
+// Exporters receive spans as they finish
+type Exporter interface {
+	Export(span *Span)
+	Close()
+}
+
+var exporterLock sync.RWMutex
+var exporter Exporter
+
+// Set the exporter, closing the previous one.
+// A nil exporter disables tracing.
+func SetExporter(e Exporter) {
+	exporterLock.Lock()
+	old := exporter
+	exporter = e
+	exporterLock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+func getExporter() Exporter {
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+	return exporter
+}
+
+func Enabled() bool {
+	return getExporter() != nil
+}
+
+// A timed operation within a trace.
+// All methods can safely be called on a nil span, which is what is
+// returned when tracing is disabled or the trace is not sampled.
+type Span struct {
+	lock       sync.Mutex
+	name       string
+	context    SpanContext
+	parentId   SpanId
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	finished   bool
+}
+
+// Start a span, continuing the trace described by the traceparent
+// header value, if valid, or starting a new trace otherwise
+func StartSpan(name string, traceParent string, start time.Time) *Span {
+	if !Enabled() {
+		return nil
+	}
+	parent, ok := ParseTraceParent(traceParent)
+	if !ok {
+		parent = SpanContext{Flags: _SAMPLED}
+		newId(parent.TraceId[:])
+	} else if !parent.IsSampled() {
+		return nil
+	}
+	return newSpan(name, parent, start)
+}
+
+func newSpan(name string, parent SpanContext, start time.Time) *Span {
+	rv := &Span{
+		name:     name,
+		parentId: parent.SpanId,
+		start:    start,
+	}
+	rv.context.TraceId = parent.TraceId
+	rv.context.Flags = parent.Flags
+	newId(rv.context.SpanId[:])
+	return rv
+}
+
+func newId(id []byte) {
+	for {
+		rand.Read(id)
+
+		// all zeroes are invalid
+		for _, b := range id {
+			if b != 0 {
+				return
+			}
+		}
+	}
+}
+
+// Start a child span
+func (this *Span) StartChild(name string, start time.Time) *Span {
+	if this == nil {
+		return nil
+	}
+	return newSpan(name, this.context, start)
+}
+
+func (this *Span) Name() string {
+	if this == nil {
+		return ""
+	}
+	return this.name
+}
+
+func (this *Span) Context() SpanContext {
+	if this == nil {
+		return SpanContext{}
+	}
+	return this.context
+}
+
+func (this *Span) ParentId() SpanId {
+	if this == nil {
+		return SpanId{}
+	}
+	return this.parentId
+}
+
+func (this *Span) TraceParent() string {
+	if this == nil {
+		return ""
+	}
+	return this.context.TraceParent()
+}
+
+func (this *Span) Start() time.Time {
+	if this == nil {
+		return time.Time{}
+	}
+	return this.start
+}
+
+func (this *Span) End() time.Time {
+	if this == nil {
+		return time.Time{}
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.end
+}
+
+func (this *Span) SetAttribute(key string, val interface{}) {
+	if this == nil {
+		return
+	}
+	this.lock.Lock()
+	if this.attributes == nil {
+		this.attributes = make(map[string]interface{}, 8)
+	}
+	this.attributes[key] = val
+	this.lock.Unlock()
+}
+
+func (this *Span) Attribute(key string) interface{} {
+	if this == nil {
+		return nil
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.attributes[key]
+}
+
+// End the span now and export it
+func (this *Span) Finish() {
+	this.FinishAt(time.Now())
+}
+
+// End the span at a given time and export it.
+// Spans are only exported once.
+func (this *Span) FinishAt(end time.Time) {
+	if this == nil {
+		return
+	}
+	this.lock.Lock()
+	if this.finished {
+		this.lock.Unlock()
+		return
+	}
+	this.finished = true
+	this.end = end
+	this.lock.Unlock()
+
+	e := getExporter()
+	if e != nil {
+		e.Export(this)
+	}
+}
+
+func (this *Span) MarshalJSON() ([]byte, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	r := map[string]interface{}{
+		"traceId":   this.context.TraceId.String(),
+		"spanId":    this.context.SpanId.String(),
+		"name":      this.name,
+		"startTime": this.start.Format(time.RFC3339Nano),
+		"endTime":   this.end.Format(time.RFC3339Nano),
+		"duration":  this.end.Sub(this.start).String(),
+	}
+	if this.parentId.IsValid() {
+		r["parentSpanId"] = this.parentId.String()
+	}
+	if len(this.attributes) > 0 {
+		r["attributes"] = this.attributes
+	}
+	return json.Marshal(r)
+}
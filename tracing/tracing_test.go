@@ -0,0 +1,125 @@
+//  Copyright (c) 2017 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testExporter struct {
+	sync.Mutex
+	spans []*Span
+}
+
+func (this *testExporter) Export(span *Span) {
+	this.Lock()
+	this.spans = append(this.spans, span)
+	this.Unlock()
+}
+
+func (this *testExporter) Close() {
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceParent(tp)
+	if !ok || !sc.IsSampled() {
+		t.Fatalf("Expected %s to be valid and sampled", tp)
+	}
+	if sc.TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanId.String() != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected context %v", sc)
+	}
+	if sc.TraceParent() != tp {
+		t.Errorf("Expected %s, received %s", tp, sc.TraceParent())
+	}
+
+	// later versions may have more fields
+	if _, ok := ParseTraceParent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what"); !ok {
+		t.Errorf("Expected future version to be accepted")
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	}
+	for _, s := range invalid {
+		if _, ok := ParseTraceParent(s); ok {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+
+	// no exporter, no spans
+	SetExporter(nil)
+	if span := StartSpan("query", "", time.Now()); span != nil {
+		t.Fatalf("Unexpected span with tracing disabled")
+	}
+
+	// nil spans are harmless
+	var none *Span
+	none.SetAttribute("a", 1)
+	none.StartChild("child", time.Now()).Finish()
+	if none.TraceParent() != "" {
+		t.Errorf("Unexpected traceparent for nil span")
+	}
+
+	exporter := &testExporter{}
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	root := StartSpan("query", tp, time.Now())
+	if root == nil || root.Context().TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		root.ParentId().String() != "00f067aa0ba902b7" {
+		t.Fatalf("Expected span continuing the trace, received %v", root)
+	}
+	child := root.StartChild("parse", time.Now())
+	if child.Context().TraceId != root.Context().TraceId || child.ParentId() != root.Context().SpanId {
+		t.Errorf("Child is not part of the trace")
+	}
+	child.SetAttribute("error", "syntax")
+	child.Finish()
+	child.Finish()
+	root.Finish()
+
+	if len(exporter.spans) != 2 || exporter.spans[0] != child || exporter.spans[1] != root {
+		t.Fatalf("Expected child and root exported once, received %v", exporter.spans)
+	}
+
+	var m map[string]interface{}
+	bytes, _ := json.Marshal(child)
+	if err := json.Unmarshal(bytes, &m); err != nil {
+		t.Fatalf("Invalid span JSON %s", bytes)
+	}
+	if m["parentSpanId"] != root.Context().SpanId.String() || m["name"] != "parse" {
+		t.Errorf("Unexpected span JSON %s", bytes)
+	}
+
+	// unsampled traces are not recorded, new traces are
+	if span := StartSpan("query", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", time.Now()); span != nil {
+		t.Errorf("Unexpected span for unsampled trace")
+	}
+	span := StartSpan("query", "garbage", time.Now())
+	if span == nil || !span.Context().IsValid() || span.ParentId().IsValid() {
+		t.Errorf("Expected new root span, received %v", span)
+	}
+}
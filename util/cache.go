@@ -83,6 +83,9 @@ type GenCache struct {
 	// max size, for LRU lists
 	limit   int
 	curSize int32
+
+	// called with the entries ditched to keep the cache within its limit
+	evicted func(string, interface{})
 }
 
 func NewGenCache(l int) *GenCache {
@@ -96,8 +99,21 @@ func NewGenCache(l int) *GenCache {
 	return rv
 }
 
+// Set the function called, outside of any lock, for entries ejected by LRU purging
+func (this *GenCache) SetEvicted(evicted func(string, interface{})) {
+	this.evicted = evicted
+}
+
 // Add (or update, if ID found) entry, eject old entry if we are controlling sie
 func (this *GenCache) Add(entry interface{}, id string, process func(interface{}) Operation) {
+	var victim *genElem
+
+	defer func() {
+		if victim != nil && this.evicted != nil {
+			this.evicted(victim.ID, victim.contents)
+		}
+	}()
+
 	cacheNum := HashString(id, _CACHES)
 	this.lock(cacheNum)
 
@@ -136,6 +152,7 @@ func (this *GenCache) Add(entry interface{}, id string, process func(interface{}
 		if this.limit > 0 && int(this.curSize) >= this.limit {
 			if elem != nil {
 				this.remove(elem, cacheNum)
+				victim = elem
 			} else {
 
 				// if we had nothing locally, we'll drop
@@ -175,6 +192,7 @@ func (this *GenCache) Add(entry interface{}, id string, process func(interface{}
 				elem = this.lists[newCacheNum][_LRU].prev
 				if elem != nil {
 					this.remove(elem, newCacheNum)
+					victim = elem
 					ditchOther = false
 				}
 				this.locks[newCacheNum].Unlock()
@@ -281,6 +299,9 @@ func (this *GenCache) SetLimit(limit int) {
 			atomic.AddInt32(&this.curSize, -1)
 		}
 		this.locks[c].Unlock()
+		if elem != nil && this.evicted != nil {
+			this.evicted(elem.ID, elem.contents)
+		}
 		c = (c + 1) % _CACHES
 	}
 }
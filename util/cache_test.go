@@ -113,3 +113,31 @@ func TestCache(t *testing.T) {
 
 	c.SetLimit(sz)
 }
+
+func TestCacheEvicted(t *testing.T) {
+	evicted := make(map[string]bool)
+
+	c := NewGenCache(10)
+	c.SetEvicted(func(id string, entry interface{}) {
+		evicted[id] = true
+	})
+	for i := 0; i < 20; i++ {
+		c.Add(testCache{value: i}, strconv.Itoa(i), nil)
+	}
+	if s := c.Size(); s != 10 {
+		t.Errorf("expected 10 elements, got %v", s)
+	}
+	if len(evicted) != 10 {
+		t.Errorf("expected 10 evicted elements, got %v", len(evicted))
+	}
+	for id := range evicted {
+		if c.Get(id, nil) != nil {
+			t.Errorf("evicted element %v still in cache", id)
+		}
+	}
+
+	c.SetLimit(5)
+	if len(evicted) != 15 {
+		t.Errorf("expected 15 evicted elements, got %v", len(evicted))
+	}
+}